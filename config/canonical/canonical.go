@@ -0,0 +1,80 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package canonical converts configs of every spec version accepted by
+// Ignition into the equivalent config of spec version 2.4.0. This can be used
+// to normalise old configs or to inspect what Ignition will actually execute.
+package canonical
+
+import (
+	"encoding/json"
+
+	"github.com/flatcar/ignition/config/v2_4/types"
+	"github.com/flatcar/ignition/config/validate/report"
+	"github.com/flatcar/ignition/internal/config"
+)
+
+// Parse parses a config of any accepted spec version and returns the
+// equivalent 2.4.0 config, along with the report generated while parsing.
+func Parse(rawConfig []byte) (types.Config, report.Report, error) {
+	cfg, rpt, err := config.Parse(rawConfig)
+	if err != nil {
+		return types.Config{}, rpt, err
+	}
+	return config.TranslateToV2_4(cfg), rpt, nil
+}
+
+// Emit parses a config of any accepted spec version and returns the
+// equivalent 2.4.0 config as indented JSON. Object keys are sorted and empty
+// objects are omitted, so equivalent configs produce identical output.
+func Emit(rawConfig []byte) ([]byte, report.Report, error) {
+	cfg, rpt, err := Parse(rawConfig)
+	if err != nil {
+		return nil, rpt, err
+	}
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, rpt, err
+	}
+	var tree interface{}
+	if err := json.Unmarshal(b, &tree); err != nil {
+		return nil, rpt, err
+	}
+	b, err = json.MarshalIndent(pruneEmpty(tree), "", "  ")
+	if err != nil {
+		return nil, rpt, err
+	}
+	return append(b, '\n'), rpt, nil
+}
+
+// pruneEmpty recursively removes objects without any members. The schema
+// types can't omit empty structs on their own.
+func pruneEmpty(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, child := range x {
+			child = pruneEmpty(child)
+			if m, ok := child.(map[string]interface{}); ok && len(m) == 0 {
+				delete(x, k)
+			} else {
+				x[k] = child
+			}
+		}
+	case []interface{}:
+		for i, child := range x {
+			x[i] = pruneEmpty(child)
+		}
+	}
+	return v
+}
@@ -0,0 +1,102 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canonical
+
+import (
+	"testing"
+
+	"github.com/flatcar/ignition/config/shared/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmit(t *testing.T) {
+	type in struct {
+		config string
+	}
+	type out struct {
+		config string
+		err    error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in: in{config: `{"ignitionVersion": 1}`},
+			out: out{config: `{
+  "ignition": {
+    "version": "2.4.0"
+  }
+}
+`},
+		},
+		{
+			in: in{config: `{"ignition": {"version": "2.0.0"}, "systemd": {"units": [{"name": "a.service", "enable": true}]}}`},
+			out: out{config: `{
+  "ignition": {
+    "version": "2.4.0"
+  },
+  "systemd": {
+    "units": [
+      {
+        "enable": true,
+        "name": "a.service"
+      }
+    ]
+  }
+}
+`},
+		},
+		{
+			in: in{config: `{"ignition": {"version": "3.0.0"}, "storage": {"files": [{"path": "/a", "contents": {"source": "data:,x"}}]}}`},
+			out: out{config: `{
+  "ignition": {
+    "version": "2.4.0"
+  },
+  "storage": {
+    "files": [
+      {
+        "contents": {
+          "source": "data:,x"
+        },
+        "filesystem": "root",
+        "overwrite": false,
+        "path": "/a"
+      }
+    ]
+  }
+}
+`},
+		},
+		{
+			in:  in{config: `{"ignition": {"version": "2.5.0"}}`},
+			out: out{err: errors.ErrUnknownVersion},
+		},
+		{
+			in:  in{config: ``},
+			out: out{err: errors.ErrEmpty},
+		},
+	}
+
+	for i, test := range tests {
+		config, _, err := Emit([]byte(test.in.config))
+		assert.Equal(t, test.out.err, err, "#%d: bad error", i)
+		if test.out.err == nil {
+			assert.Equal(t, test.out.config, string(config), "#%d: bad config", i)
+		}
+	}
+}
@@ -38,6 +38,12 @@ var (
 	// Storage section errors
 	ErrPermissionsUnset            = errors.New("permissions unset, defaulting to 0000")
 	ErrDiskDeviceRequired          = errors.New("disk device is required")
+	ErrDiskDeviceOrSelector        = errors.New("disk device or selector is required")
+	ErrDiskDeviceAndSelector       = errors.New("disk cannot specify both a device and a selector")
+	ErrDiskSelectorEmpty           = errors.New("disk selector requires at least one attribute")
+	ErrDiskSelectorSizeInvalid     = errors.New("disk selector sizes must be positive")
+	ErrDiskSelectorSizeRange       = errors.New("disk selector minSizeMiB cannot be larger than maxSizeMiB")
+	ErrDiskSelectorTransport       = errors.New("disk selector transport must be one of nvme, sata, sas, scsi, usb, virtio or mmc")
 	ErrPartitionNumbersCollide     = errors.New("partition numbers collide")
 	ErrPartitionsOverlap           = errors.New("partitions overlap")
 	ErrPartitionsMisaligned        = errors.New("partitions misaligned")
@@ -67,6 +73,68 @@ var (
 	ErrPartitionsUnitsMismatch     = errors.New("cannot mix MBs and sectors within a disk")
 	ErrSizeDeprecated              = errors.New("size is deprecated; use sizeMB instead")
 	ErrStartDeprecated             = errors.New("start is deprecated; use startMB instead")
+	ErrFilesystemNotMountable      = errors.New("path cannot be set for filesystems of format swap or none")
+	ErrOverwriteAndNilSource       = errors.New("overwrite must be false if source is unspecified")
+	ErrLinkTargetRequired          = errors.New("link target is required")
+	ErrLuksNameRequired            = errors.New("luks device name is required")
+	ErrLuksNameContainsSlash       = errors.New("luks device name cannot contain a slash")
+	ErrLuksKeyFileRequired         = errors.New("luks device requires a key file source")
+	ErrLuksLabelTooLong            = errors.New("luks device labels cannot be longer than 47 characters")
+	ErrImageSourceRequired         = errors.New("image source is required")
+	ErrImageWithoutPartitionNumber = errors.New("partition images require a partition number")
+	ErrVolumeGroupNameRequired     = errors.New("volume group name is required")
+	ErrVolumeGroupNameInvalid      = errors.New("volume group name cannot contain a slash or start with a dash")
+	ErrVolumeGroupPVsRequired      = errors.New("volume group requires at least one physical volume")
+	ErrLogicalVolumeNameRequired   = errors.New("logical volume name is required")
+	ErrLogicalVolumeNameInvalid    = errors.New("logical volume name cannot contain a slash or start with a dash")
+	ErrLogicalVolumeNamesCollide   = errors.New("logical volume names collide")
+	ErrLogicalVolumeTypeInvalid    = errors.New("logical volume type must be linear, thin-pool or thin")
+	ErrLogicalVolumeSizeRequired   = errors.New("logical volume requires either sizeMiB or percentFree")
+	ErrLogicalVolumeSizeAndPercent = errors.New("logical volume cannot specify both sizeMiB and percentFree")
+	ErrLogicalVolumeSizeInvalid    = errors.New("logical volume sizeMiB must be positive")
+	ErrLogicalVolumePercentInvalid = errors.New("logical volume percentFree must be between 1 and 100")
+	ErrThinVolumeNeedsPool         = errors.New("thin logical volumes require a pool")
+	ErrThinVolumeNeedsSize         = errors.New("thin logical volumes require sizeMiB")
+	ErrPoolWithoutThinVolume       = errors.New("pool can only be set on thin logical volumes")
+	ErrThinPoolUndefined           = errors.New("pool is not a thin-pool logical volume of the volume group")
+	ErrResizeWithoutNumber         = errors.New("resizing a partition requires a partition number")
+	ErrGrowFilesystemFormat        = errors.New("growFilesystem is only supported for ext4, btrfs and xfs")
+	ErrSubvolumesRequireBtrfs      = errors.New("subvolumes are only supported for btrfs")
+	ErrSubvolumePathsCollide       = errors.New("subvolume paths collide")
+	ErrSubvolumePathRoot           = errors.New("subvolume path cannot be the root of the filesystem")
+	ErrSubvolumeQgroupLimit        = errors.New("subvolume qgroupLimitMiB must be positive")
+	ErrInvalidTableType            = errors.New("tableType must be gpt or dos")
+	ErrGPTFieldsOnDOSTable         = errors.New("labels, guids, typeGuids, attributes and resizing are not supported on dos partition tables")
+	ErrDOSFieldsOnGPTTable         = errors.New("mbrType and bootable are only supported on dos partition tables")
+	ErrInvalidMBRType              = errors.New("mbrType must be a partition type code between 01 and ff, in hex")
+	ErrDOSPartitionNumber          = errors.New("partitions on dos partition tables require a number")
+	ErrDOSMultipleExtended         = errors.New("dos partition tables can only have one extended partition")
+	ErrDOSExtendedNotPrimary       = errors.New("extended partitions must use a primary partition number (1-4)")
+	ErrDOSBootableNotPrimary       = errors.New("only primary partitions can be bootable")
+	ErrInvalidPartitionAttribute   = errors.New("partition attributes must be bit numbers from 0 to 63, bit names, priority=N or tries=N with N from 0 to 15")
+	ErrAttributeFieldRepeated      = errors.New("partition attributes can only set priority and tries once")
+	ErrArchiveFormatInvalid        = errors.New("archive format must be tar or zip")
+	ErrArchiveSourceRequired       = errors.New("archive source is required")
+	ErrArchiveZipCompression       = errors.New("zip archives cannot use compression")
+	ErrRemoveFilesystemRoot        = errors.New("cannot remove the root of a filesystem")
+	ErrXattrNameInvalid            = errors.New("xattr names must start with security., system., trusted. or user.")
+	ErrXattrSELinux                = errors.New("the SELinux context must be set with seLinuxContext instead of xattrs")
+	ErrXattrValueInvalid           = errors.New("xattr values starting with 0x must be hex and values starting with 0s must be base64")
+	ErrXattrNameDuplicate          = errors.New("xattr names must be unique")
+	ErrSELinuxContextInvalid       = errors.New("SELinux contexts must have the form user:role:type[:level]")
+	ErrXattrsOnHardLink            = errors.New("xattrs and seLinuxContext cannot be set on hard links")
+	ErrXattrUserOnSymlink          = errors.New("xattrs in the user namespace cannot be set on symbolic links")
+	ErrXattrsOnArchive             = errors.New("xattrs and seLinuxContext are not supported on archives")
+	ErrTemplateUnsupported         = errors.New("templates are only supported for the contents of files and units")
+	ErrFileEditsConflict           = errors.New("edits cannot be combined with contents, append or overwrite")
+	ErrFileEditActionInvalid       = errors.New("edit action must be replace, ensureLine, deleteLines, setKey or unsetKey")
+	ErrFileEditRegexRequired       = errors.New("replace and deleteLines edits require a regex")
+	ErrFileEditLineRequired        = errors.New("ensureLine edits require a line")
+	ErrFileEditKeyRequired         = errors.New("setKey and unsetKey edits require a key")
+	ErrFileEditKeyInvalid          = errors.New("edit keys cannot contain '=', '[' or whitespace")
+	ErrFileEditSectionInvalid      = errors.New("edit sections cannot contain ']' or newlines")
+	ErrFileEditNewline             = errors.New("edit lines and values cannot contain newlines")
+	ErrFileEditFieldUnused         = errors.New("edit sets a field its action doesn't use")
 
 	// Passwd section errors
 	ErrPasswdCreateDeprecated      = errors.New("the create object has been deprecated in favor of user-level options")
@@ -96,6 +164,7 @@ var (
 	ErrHashMalformed                   = errors.New("malformed hash specifier")
 	ErrHashWrongSize                   = errors.New("incorrect size for hash sum")
 	ErrHashUnrecognized                = errors.New("unrecognized hash function")
+	ErrSourceRequired                  = errors.New("source is required")
 	ErrEngineConfiguration             = errors.New("engine incorrectly configured")
 
 	// AWS S3 specific errors
@@ -0,0 +1,116 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package partitions contains the GPT partition type aliases and attribute
+// names shared between config versions and the partitioning backends.
+package partitions
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/flatcar/ignition/config/shared/errors"
+)
+
+// typeAliases maps the names which can be used in place of a partition type
+// GUID to the GUID.
+var typeAliases = map[string]string{
+	"linux-filesystem":  "0FC63DAF-8483-4772-8E79-3D69D8477DE4",
+	"linux-swap":        "0657FD6D-A4AB-43C4-84E5-0933C84B4F4F",
+	"linux-raid":        "A19D880F-05FC-4D3B-A006-743F0F84911E",
+	"linux-lvm":         "E6D6D379-F507-44C2-A23C-238F2A3DF928",
+	"linux-home":        "933AC7E1-2EB4-4F13-B844-0E14E2AEF915",
+	"esp":               "C12A7328-F81F-11D2-BA4B-00A0C93EC93B",
+	"bios-boot":         "21686148-6449-6E6F-744E-656564454649",
+	"flatcar-usr":       "5DFBF5F4-2848-4BAC-AA5E-0D9A20B745A6",
+	"flatcar-resize":    "3884DD41-8582-4404-B9A8-E9B84F2DF50E",
+	"flatcar-reserved":  "C95DC21A-DF0E-4340-8D7B-26CBFA9A03E0",
+	"flatcar-root-raid": "BE9067B9-EA49-4F15-B4F6-F36F8C9E1818",
+}
+
+// TypeGUID returns the partition type GUID named by alias, or alias itself
+// if it is not a known name.
+func TypeGUID(alias string) string {
+	if guid, ok := typeAliases[alias]; ok {
+		return guid
+	}
+	return alias
+}
+
+// attributeNames maps the names of single attribute bits to their number.
+// Bits 0-2 are defined by the UEFI specification, bit 56 is used by the
+// Flatcar A/B update scheme.
+var attributeNames = map[string]uint{
+	"required-partition":   0,
+	"no-block-io-protocol": 1,
+	"legacy-bios-bootable": 2,
+	"successful":           56,
+}
+
+// attributeFields are the multi-bit fields of the Flatcar A/B update
+// scheme, set with "<name>=<value>", and their first bit. Both are 4 bits
+// wide.
+var attributeFields = map[string]uint{
+	"priority": 48,
+	"tries":    52,
+}
+
+// ParseAttributes returns the attribute bits set by attrs. Each attribute
+// is a bit number from 0 to 63, a bit name, or a field assignment like
+// "priority=1".
+func ParseAttributes(attrs []string) (uint64, error) {
+	var bits uint64
+	fields := map[string]bool{}
+	for _, attr := range attrs {
+		if parts := strings.SplitN(attr, "=", 2); len(parts) == 2 {
+			name, value := parts[0], parts[1]
+			shift, known := attributeFields[name]
+			if !known {
+				return 0, errors.ErrInvalidPartitionAttribute
+			}
+			v, err := strconv.ParseUint(value, 10, 4)
+			if err != nil {
+				return 0, errors.ErrInvalidPartitionAttribute
+			}
+			if fields[name] {
+				return 0, errors.ErrAttributeFieldRepeated
+			}
+			fields[name] = true
+			bits |= v << shift
+			continue
+		}
+		bit, known := attributeNames[attr]
+		if !known {
+			n, err := strconv.ParseUint(attr, 10, 6)
+			if err != nil {
+				return 0, errors.ErrInvalidPartitionAttribute
+			}
+			bit = uint(n)
+		}
+		bits |= 1 << bit
+	}
+	return bits, nil
+}
+
+// FormatAttributes returns the numbers of the bits set in bits, in ascending
+// order, in the form ParseAttributes accepts.
+func FormatAttributes(bits uint64) []string {
+	var attrs []string
+	for bit := uint(0); bit < 64; bit++ {
+		if bits&(1<<bit) != 0 {
+			attrs = append(attrs, strconv.FormatUint(uint64(bit), 10))
+		}
+	}
+	return attrs
+}
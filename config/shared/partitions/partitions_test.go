@@ -0,0 +1,100 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package partitions
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flatcar/ignition/config/shared/errors"
+)
+
+func TestTypeGUID(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{"esp", "C12A7328-F81F-11D2-BA4B-00A0C93EC93B"},
+		{"flatcar-usr", "5DFBF5F4-2848-4BAC-AA5E-0D9A20B745A6"},
+		{"0fc63daf-8483-4772-8e79-3d69d8477de4", "0fc63daf-8483-4772-8e79-3d69d8477de4"},
+		{"", ""},
+	}
+	for i, test := range tests {
+		if guid := TypeGUID(test.in); guid != test.out {
+			t.Errorf("#%d: bad GUID: want %q, got %q", i, test.out, guid)
+		}
+	}
+}
+
+func TestParseAttributes(t *testing.T) {
+	type out struct {
+		bits uint64
+		err  error
+	}
+	tests := []struct {
+		in  []string
+		out out
+	}{
+		{
+			in:  nil,
+			out: out{},
+		},
+		{
+			in:  []string{"legacy-bios-bootable", "63"},
+			out: out{bits: 1<<2 | 1<<63},
+		},
+		{
+			// the initial state of a Flatcar USR-A partition
+			in:  []string{"priority=1", "tries=0", "successful"},
+			out: out{bits: 0x0101000000000000},
+		},
+		{
+			in:  []string{"priority=15", "tries=15"},
+			out: out{bits: 0x00ff000000000000},
+		},
+		{
+			in:  []string{"-1"},
+			out: out{err: errors.ErrInvalidPartitionAttribute},
+		},
+		{
+			in:  []string{"hidden=1"},
+			out: out{err: errors.ErrInvalidPartitionAttribute},
+		},
+		{
+			in:  []string{"priority=1", "priority=2"},
+			out: out{err: errors.ErrAttributeFieldRepeated},
+		},
+	}
+	for i, test := range tests {
+		bits, err := ParseAttributes(test.in)
+		if err != test.out.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+		if bits != test.out.bits {
+			t.Errorf("#%d: bad bits: want %#x, got %#x", i, test.out.bits, bits)
+		}
+	}
+}
+
+func TestAttributeBits(t *testing.T) {
+	attrs := FormatAttributes(0x0101000000000004)
+	if want := []string{"2", "48", "56"}; !reflect.DeepEqual(attrs, want) {
+		t.Errorf("bad attributes: want %v, got %v", want, attrs)
+	}
+	bits, err := ParseAttributes(attrs)
+	if err != nil || bits != 0x0101000000000004 {
+		t.Errorf("attributes don't round trip: got %#x, %v", bits, err)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package xattrs contains the handling of extended attribute names and values
+// shared between config versions and the files stage.
+package xattrs
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+
+	"github.com/flatcar/ignition/config/shared/errors"
+)
+
+// SELinux is the name of the extended attribute holding the SELinux context.
+const SELinux = "security.selinux"
+
+// namespaces are the prefixes of the extended attribute names which can be
+// set.
+var namespaces = []string{"security.", "system.", "trusted.", "user."}
+
+// ValidateName returns an error if name isn't in one of the namespaces of
+// extended attributes or is the SELinux context, which has its own field.
+func ValidateName(name string) error {
+	if name == SELinux {
+		return errors.ErrXattrSELinux
+	}
+	for _, ns := range namespaces {
+		if strings.HasPrefix(name, ns) && len(name) > len(ns) {
+			return nil
+		}
+	}
+	return errors.ErrXattrNameInvalid
+}
+
+// DecodeValue decodes the value of an extended attribute like setfattr: values
+// prefixed with 0x are hex encoded, values prefixed with 0s are base64
+// encoded and any other value is used as is.
+func DecodeValue(value string) ([]byte, error) {
+	if len(value) < 2 || value[0] != '0' {
+		return []byte(value), nil
+	}
+	switch value[1] {
+	case 'x', 'X':
+		b, err := hex.DecodeString(value[2:])
+		if err != nil {
+			return nil, errors.ErrXattrValueInvalid
+		}
+		return b, nil
+	case 's', 'S':
+		b, err := base64.StdEncoding.DecodeString(value[2:])
+		if err != nil {
+			return nil, errors.ErrXattrValueInvalid
+		}
+		return b, nil
+	default:
+		return []byte(value), nil
+	}
+}
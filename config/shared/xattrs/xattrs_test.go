@@ -0,0 +1,71 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xattrs
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flatcar/ignition/config/shared/errors"
+)
+
+func TestValidateName(t *testing.T) {
+	tests := []struct {
+		in  string
+		out error
+	}{
+		{"user.agent.role", nil},
+		{"security.capability", nil},
+		{"trusted.overlay.opaque", nil},
+		{"security.selinux", errors.ErrXattrSELinux},
+		{"user.", errors.ErrXattrNameInvalid},
+		{"agent.role", errors.ErrXattrNameInvalid},
+		{"", errors.ErrXattrNameInvalid},
+	}
+
+	for i, test := range tests {
+		if err := ValidateName(test.in); err != test.out {
+			t.Errorf("#%d: wanted %v, got %v", i, test.out, err)
+		}
+	}
+}
+
+func TestDecodeValue(t *testing.T) {
+	tests := []struct {
+		in  string
+		out []byte
+		err error
+	}{
+		{"", []byte{}, nil},
+		{"worker", []byte("worker"), nil},
+		{"0", []byte("0"), nil},
+		{"0700", []byte("0700"), nil},
+		{"0x0100000200040000", []byte{1, 0, 0, 2, 0, 4, 0, 0}, nil},
+		{"0X0a", []byte{10}, nil},
+		{"0sd29ya2Vy", []byte("worker"), nil},
+		{"0xzz", nil, errors.ErrXattrValueInvalid},
+		{"0s!", nil, errors.ErrXattrValueInvalid},
+	}
+
+	for i, test := range tests {
+		value, err := DecodeValue(test.in)
+		if err != test.err {
+			t.Errorf("#%d: bad error: wanted %v, got %v", i, test.err, err)
+		}
+		if !reflect.DeepEqual(value, test.out) {
+			t.Errorf("#%d: bad value: wanted %v, got %v", i, test.out, value)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"github.com/flatcar/ignition/config/shared/errors"
+	"github.com/flatcar/ignition/config/validate/report"
+)
+
+func (a Archive) Validate() report.Report {
+	if a.hasXattrs() {
+		return report.ReportFromError(errors.ErrXattrsOnArchive, report.EntryError)
+	}
+	return report.Report{}
+}
+
+func (a Archive) ValidateFormat() report.Report {
+	switch a.Format {
+	case "tar", "zip":
+		return report.Report{}
+	default:
+		return report.ReportFromError(errors.ErrArchiveFormatInvalid, report.EntryError)
+	}
+}
+
+// ValidateContents requires a source. Zip archives compress each member on
+// their own, so a zip archive as a whole can't be compressed. Archives are
+// binary, so they can't be rendered as templates.
+func (a Archive) ValidateContents() report.Report {
+	if a.Contents.Source == "" {
+		return report.ReportFromError(errors.ErrArchiveSourceRequired, report.EntryError)
+	}
+	if a.Format == "zip" && a.Contents.Compression != "" {
+		return report.ReportFromError(errors.ErrArchiveZipCompression, report.EntryError)
+	}
+	if a.Contents.Template {
+		return report.ReportFromError(errors.ErrTemplateUnsupported, report.EntryError)
+	}
+	return report.Report{}
+}
+
+func (a Archive) ValidateFileMode() report.Report {
+	if err := validateMode(a.FileMode); err != nil {
+		return report.ReportFromError(err, report.EntryError)
+	}
+	return report.Report{}
+}
+
+func (a Archive) ValidateDirectoryMode() report.Report {
+	if err := validateMode(a.DirectoryMode); err != nil {
+		return report.ReportFromError(err, report.EntryError)
+	}
+	return report.Report{}
+}
@@ -0,0 +1,178 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flatcar/ignition/config/shared/errors"
+	"github.com/flatcar/ignition/config/validate/report"
+)
+
+func TestArchiveValidateFormat(t *testing.T) {
+	type in struct {
+		format string
+	}
+	type out struct {
+		report report.Report
+	}
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in{"tar"},
+			out{report.Report{}},
+		},
+		{
+			in{"zip"},
+			out{report.Report{}},
+		},
+		{
+			in{""},
+			out{report.ReportFromError(errors.ErrArchiveFormatInvalid, report.EntryError)},
+		},
+		{
+			in{"cpio"},
+			out{report.ReportFromError(errors.ErrArchiveFormatInvalid, report.EntryError)},
+		},
+	}
+	for i, test := range tests {
+		r := Archive{ArchiveEmbedded1: ArchiveEmbedded1{Format: test.in.format}}.ValidateFormat()
+		if !reflect.DeepEqual(r, test.out.report) {
+			t.Errorf("#%d: wanted %v, got %v", i, test.out.report, r)
+		}
+	}
+}
+
+func TestArchiveValidateContents(t *testing.T) {
+	type in struct {
+		format      string
+		source      string
+		compression string
+		template    bool
+	}
+	type out struct {
+		report report.Report
+	}
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in{"tar", "https://example.com/app.tar", "", false},
+			out{report.Report{}},
+		},
+		{
+			in{"tar", "", "", false},
+			out{report.ReportFromError(errors.ErrArchiveSourceRequired, report.EntryError)},
+		},
+		{
+			in{"tar", "https://example.com/app.tar.gz", "gzip", false},
+			out{report.Report{}},
+		},
+		{
+			in{"zip", "https://example.com/app.zip", "", false},
+			out{report.Report{}},
+		},
+		{
+			in{"zip", "https://example.com/app.zip.gz", "gzip", false},
+			out{report.ReportFromError(errors.ErrArchiveZipCompression, report.EntryError)},
+		},
+		{
+			in{"tar", "https://example.com/app.tar", "", true},
+			out{report.ReportFromError(errors.ErrTemplateUnsupported, report.EntryError)},
+		},
+	}
+	for i, test := range tests {
+		r := Archive{ArchiveEmbedded1: ArchiveEmbedded1{
+			Format: test.in.format,
+			Contents: FileContents{
+				Source:      test.in.source,
+				Compression: test.in.compression,
+				Template:    test.in.template,
+			},
+		}}.ValidateContents()
+		if !reflect.DeepEqual(r, test.out.report) {
+			t.Errorf("#%d: wanted %v, got %v", i, test.out.report, r)
+		}
+	}
+}
+
+func TestArchiveValidateModes(t *testing.T) {
+	type in struct {
+		fileMode      *int
+		directoryMode *int
+	}
+	type out struct {
+		fileReport      report.Report
+		directoryReport report.Report
+	}
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in{nil, nil},
+			out{report.Report{}, report.Report{}},
+		},
+		{
+			in{intToPtr(0644), intToPtr(0755)},
+			out{report.Report{}, report.Report{}},
+		},
+		{
+			in{intToPtr(010000), intToPtr(-1)},
+			out{
+				report.ReportFromError(errors.ErrFileIllegalMode, report.EntryError),
+				report.ReportFromError(errors.ErrFileIllegalMode, report.EntryError),
+			},
+		},
+	}
+	for i, test := range tests {
+		a := Archive{ArchiveEmbedded1: ArchiveEmbedded1{FileMode: test.in.fileMode, DirectoryMode: test.in.directoryMode}}
+		if r := a.ValidateFileMode(); !reflect.DeepEqual(r, test.out.fileReport) {
+			t.Errorf("#%d: fileMode: wanted %v, got %v", i, test.out.fileReport, r)
+		}
+		if r := a.ValidateDirectoryMode(); !reflect.DeepEqual(r, test.out.directoryReport) {
+			t.Errorf("#%d: directoryMode: wanted %v, got %v", i, test.out.directoryReport, r)
+		}
+	}
+}
+
+func TestArchiveValidate(t *testing.T) {
+	tests := []struct {
+		in  Archive
+		out report.Report
+	}{
+		{
+			in:  Archive{Node: Node{Path: "/opt/app"}},
+			out: report.Report{},
+		},
+		{
+			in:  Archive{Node: Node{Path: "/opt/app", Xattrs: []NodeXattr{{Name: "user.a"}}}},
+			out: report.ReportFromError(errors.ErrXattrsOnArchive, report.EntryError),
+		},
+		{
+			in:  Archive{Node: Node{Path: "/opt/app", SELinuxContext: "system_u:object_r:usr_t:s0"}},
+			out: report.ReportFromError(errors.ErrXattrsOnArchive, report.EntryError),
+		},
+	}
+	for i, test := range tests {
+		if r := test.in.Validate(); !reflect.DeepEqual(test.out, r) {
+			t.Errorf("#%d: wanted %v, got %v", i, test.out, r)
+		}
+	}
+}
@@ -34,6 +34,8 @@ func (c Config) Validate() report.Report {
 	rules := []rule{
 		checkFilesFilesystems,
 		checkDuplicateFilesystems,
+		checkDuplicateLuks,
+		checkDuplicateVolumeGroups,
 	}
 
 	for _, rule := range rules {
@@ -65,6 +67,9 @@ func checkFilesFilesystems(cfg Config, r *report.Report) {
 	filesystems := map[string]struct{}{"root": {}}
 	for _, filesystem := range cfg.Storage.Filesystems {
 		filesystems[filesystem.Name] = struct{}{}
+		for _, name := range filesystem.subvolumeNames() {
+			filesystems[name] = struct{}{}
+		}
 	}
 	for _, file := range cfg.Storage.Files {
 		r.Merge(checkNodeFilesystems(file.Node, filesystems, "File"))
@@ -75,6 +80,12 @@ func checkFilesFilesystems(cfg Config, r *report.Report) {
 	for _, dir := range cfg.Storage.Directories {
 		r.Merge(checkNodeFilesystems(dir.Node, filesystems, "Directory"))
 	}
+	for _, archive := range cfg.Storage.Archives {
+		r.Merge(checkNodeFilesystems(archive.Node, filesystems, "Archive"))
+	}
+	for _, removal := range cfg.Storage.Remove {
+		r.Merge(checkNodeFilesystems(Node{Filesystem: removal.Filesystem, Path: removal.Path}, filesystems, "Removal"))
+	}
 }
 
 func checkDuplicateFilesystems(cfg Config, r *report.Report) {
@@ -87,5 +98,40 @@ func checkDuplicateFilesystems(cfg Config, r *report.Report) {
 			})
 		}
 		filesystems[filesystem.Name] = struct{}{}
+		for _, name := range filesystem.subvolumeNames() {
+			if _, ok := filesystems[name]; ok {
+				r.Add(report.Entry{
+					Kind:    report.EntryWarning,
+					Message: fmt.Sprintf("Subvolume %q shadows exising filesystem definition", name),
+				})
+			}
+			filesystems[name] = struct{}{}
+		}
+	}
+}
+
+func checkDuplicateLuks(cfg Config, r *report.Report) {
+	names := map[string]struct{}{}
+	for _, luks := range cfg.Storage.Luks {
+		if _, ok := names[luks.Name]; ok {
+			r.Add(report.Entry{
+				Kind:    report.EntryError,
+				Message: fmt.Sprintf("LUKS device %q is defined more than once", luks.Name),
+			})
+		}
+		names[luks.Name] = struct{}{}
+	}
+}
+
+func checkDuplicateVolumeGroups(cfg Config, r *report.Report) {
+	names := map[string]struct{}{}
+	for _, vg := range cfg.Storage.Lvm.VolumeGroups {
+		if _, ok := names[vg.Name]; ok {
+			r.Add(report.Entry{
+				Kind:    report.EntryError,
+				Message: fmt.Sprintf("volume group %q is defined more than once", vg.Name),
+			})
+		}
+		names[vg.Name] = struct{}{}
 	}
 }
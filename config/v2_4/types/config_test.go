@@ -0,0 +1,77 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"testing"
+
+	"github.com/flatcar/ignition/config/validate/report"
+)
+
+func TestCheckFilesFilesystems(t *testing.T) {
+	type in struct {
+		storage Storage
+	}
+	type out struct {
+		warning bool
+	}
+
+	filesystems := []Filesystem{
+		{
+			Name: "btrfs",
+			Mount: &Mount{
+				Device: "/dev/sdb",
+				Format: "btrfs",
+				Subvolumes: []Subvolume{
+					{Name: "var", Path: "/@var"},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{storage: Storage{Archives: []Archive{{Node: Node{Filesystem: "root", Path: "/opt"}}}}},
+			out: out{},
+		},
+		{
+			in:  in{storage: Storage{Filesystems: filesystems, Archives: []Archive{{Node: Node{Filesystem: "var", Path: "/opt"}}}}},
+			out: out{},
+		},
+		{
+			in:  in{storage: Storage{Archives: []Archive{{Node: Node{Filesystem: "var", Path: "/opt"}}}}},
+			out: out{warning: true},
+		},
+		{
+			in:  in{storage: Storage{Filesystems: filesystems, Remove: []Removal{{Filesystem: "var", Path: "/tmp"}}}},
+			out: out{},
+		},
+		{
+			in:  in{storage: Storage{Remove: []Removal{{Filesystem: "var", Path: "/tmp"}}}},
+			out: out{warning: true},
+		},
+	}
+
+	for i, test := range tests {
+		r := report.Report{}
+		checkFilesFilesystems(Config{Storage: test.in.storage}, &r)
+		if warning := len(r.Entries) != 0; warning != test.out.warning {
+			t.Errorf("#%d: expected warning %t, got %v", i, test.out.warning, r)
+		}
+	}
+}
@@ -25,7 +25,13 @@ func (n Disk) Validate() report.Report {
 
 func (n Disk) ValidateDevice() report.Report {
 	if len(n.Device) == 0 {
-		return report.ReportFromError(errors.ErrDiskDeviceRequired, report.EntryError)
+		if n.Selector == nil {
+			return report.ReportFromError(errors.ErrDiskDeviceOrSelector, report.EntryError)
+		}
+		return report.Report{}
+	}
+	if n.Selector != nil {
+		return report.ReportFromError(errors.ErrDiskDeviceAndSelector, report.EntryError)
 	}
 	if err := validatePath(string(n.Device)); err != nil {
 		return report.ReportFromError(err, report.EntryError)
@@ -33,6 +39,72 @@ func (n Disk) ValidateDevice() report.Report {
 	return report.Report{}
 }
 
+func (n Disk) ValidateTableType() report.Report {
+	r := report.Report{}
+	switch n.TableType {
+	case "", "gpt":
+		for _, p := range n.Partitions {
+			if p.MbrType != "" || p.Bootable {
+				r.Add(report.Entry{
+					Message: errors.ErrDOSFieldsOnGPTTable.Error(),
+					Kind:    report.EntryError,
+				})
+				break
+			}
+		}
+	case "dos":
+		r.Merge(n.validateDOSPartitions())
+	default:
+		r.Add(report.Entry{
+			Message: errors.ErrInvalidTableType.Error(),
+			Kind:    report.EntryError,
+		})
+	}
+	return r
+}
+
+// validateDOSPartitions checks the partitions of a disk with a dos partition
+// table. Partition numbers 1-4 are primary or extended partitions, 5 and up
+// are logical partitions inside the extended partition.
+func (n Disk) validateDOSPartitions() report.Report {
+	r := report.Report{}
+	gptFields := false
+	noNumber := false
+	extendedNotPrimary := false
+	bootableNotPrimary := false
+	extended := 0
+	for _, p := range n.Partitions {
+		gptFields = gptFields || p.Label != nil || p.GUID != "" || p.TypeGUID != "" || len(p.Attributes) > 0 || p.Resize
+		noNumber = noNumber || p.Number == 0
+		if p.ShouldExist != nil && !*p.ShouldExist {
+			continue
+		}
+		if p.isExtended() {
+			extended++
+			extendedNotPrimary = extendedNotPrimary || p.Number > 4
+		}
+		bootableNotPrimary = bootableNotPrimary || (p.Bootable && (p.Number > 4 || p.isExtended()))
+	}
+	for _, e := range []struct {
+		failed bool
+		err    error
+	}{
+		{gptFields, errors.ErrGPTFieldsOnDOSTable},
+		{noNumber, errors.ErrDOSPartitionNumber},
+		{extended > 1, errors.ErrDOSMultipleExtended},
+		{extendedNotPrimary, errors.ErrDOSExtendedNotPrimary},
+		{bootableNotPrimary, errors.ErrDOSBootableNotPrimary},
+	} {
+		if e.failed {
+			r.Add(report.Entry{
+				Message: e.err.Error(),
+				Kind:    report.EntryError,
+			})
+		}
+	}
+	return r
+}
+
 func (n Disk) ValidatePartitions() report.Report {
 	r := report.Report{}
 	if n.partitionNumbersCollide() {
@@ -98,15 +170,15 @@ func (p Partition) end() int {
 
 // partitionsOverlap returns true if any explicitly dimensioned partitions overlap
 func (n Disk) partitionsOverlap() bool {
-	for _, p := range n.Partitions {
+	for i, p := range n.Partitions {
 		// Starts of 0 are placed by sgdisk into the "largest available block" at that time.
 		// We aren't going to check those for overlap since we don't have the disk geometry.
 		if p.Start == nil || p.Size == nil || *p.Start == 0 {
 			continue
 		}
 
-		for _, o := range n.Partitions {
-			if o.Start == nil || o.Size == nil || p == o || *o.Start == 0 {
+		for j, o := range n.Partitions {
+			if o.Start == nil || o.Size == nil || i == j || *o.Start == 0 {
 				continue
 			}
 
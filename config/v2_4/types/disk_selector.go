@@ -0,0 +1,84 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/flatcar/ignition/config/shared/errors"
+	"github.com/flatcar/ignition/config/validate/report"
+)
+
+func (s DiskSelector) Validate() report.Report {
+	r := report.Report{}
+	if !s.LargestUnused && s.MinSizeMiB == nil && s.MaxSizeMiB == nil && s.Rotational == nil &&
+		s.Transport == nil && s.Model == nil && s.Serial == nil && s.WWN == nil {
+		r.Add(report.Entry{
+			Message: errors.ErrDiskSelectorEmpty.Error(),
+			Kind:    report.EntryError,
+		})
+	}
+	if (s.MinSizeMiB != nil && *s.MinSizeMiB <= 0) || (s.MaxSizeMiB != nil && *s.MaxSizeMiB <= 0) {
+		r.Add(report.Entry{
+			Message: errors.ErrDiskSelectorSizeInvalid.Error(),
+			Kind:    report.EntryError,
+		})
+	} else if s.MinSizeMiB != nil && s.MaxSizeMiB != nil && *s.MinSizeMiB > *s.MaxSizeMiB {
+		r.Add(report.Entry{
+			Message: errors.ErrDiskSelectorSizeRange.Error(),
+			Kind:    report.EntryError,
+		})
+	}
+	return r
+}
+
+func (s DiskSelector) ValidateTransport() report.Report {
+	if s.Transport == nil {
+		return report.Report{}
+	}
+	switch *s.Transport {
+	case "nvme", "sata", "sas", "scsi", "usb", "virtio", "mmc":
+		return report.Report{}
+	default:
+		return report.ReportFromError(errors.ErrDiskSelectorTransport, report.EntryError)
+	}
+}
+
+func (s DiskSelector) ValidateModel() report.Report {
+	return validateSelectorRegexp("model", s.Model)
+}
+
+func (s DiskSelector) ValidateSerial() report.Report {
+	return validateSelectorRegexp("serial", s.Serial)
+}
+
+func (s DiskSelector) ValidateWWN() report.Report {
+	return validateSelectorRegexp("wwn", s.WWN)
+}
+
+func validateSelectorRegexp(field string, expr *string) report.Report {
+	r := report.Report{}
+	if expr == nil {
+		return r
+	}
+	if _, err := regexp.Compile(*expr); err != nil {
+		r.Add(report.Entry{
+			Message: fmt.Sprintf("disk selector %s is not a valid regular expression: %v", field, err),
+			Kind:    report.EntryError,
+		})
+	}
+	return r
+}
@@ -0,0 +1,150 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flatcar/ignition/config/shared/errors"
+	"github.com/flatcar/ignition/config/validate/report"
+)
+
+func boolToPtr(b bool) *bool {
+	return &b
+}
+
+func TestDiskValidateDevice(t *testing.T) {
+	type in struct {
+		disk Disk
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{disk: Disk{Device: "/dev/sda"}},
+			out: out{},
+		},
+		{
+			in:  in{disk: Disk{Selector: &DiskSelector{LargestUnused: true}}},
+			out: out{},
+		},
+		{
+			in:  in{disk: Disk{}},
+			out: out{err: errors.ErrDiskDeviceOrSelector},
+		},
+		{
+			in:  in{disk: Disk{Device: "/dev/sda", Selector: &DiskSelector{LargestUnused: true}}},
+			out: out{err: errors.ErrDiskDeviceAndSelector},
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.disk.ValidateDevice()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), r) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, r)
+		}
+	}
+}
+
+func TestDiskSelectorValidate(t *testing.T) {
+	type in struct {
+		selector DiskSelector
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{selector: DiskSelector{MinSizeMiB: intToPtr(1024), MaxSizeMiB: intToPtr(2048)}},
+			out: out{},
+		},
+		{
+			in:  in{selector: DiskSelector{Rotational: boolToPtr(false)}},
+			out: out{},
+		},
+		{
+			in:  in{selector: DiskSelector{}},
+			out: out{err: errors.ErrDiskSelectorEmpty},
+		},
+		{
+			in:  in{selector: DiskSelector{MinSizeMiB: intToPtr(0)}},
+			out: out{err: errors.ErrDiskSelectorSizeInvalid},
+		},
+		{
+			in:  in{selector: DiskSelector{MinSizeMiB: intToPtr(2048), MaxSizeMiB: intToPtr(1024)}},
+			out: out{err: errors.ErrDiskSelectorSizeRange},
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.selector.Validate()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), r) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, r)
+		}
+	}
+}
+
+func TestDiskSelectorValidateTransport(t *testing.T) {
+	type in struct {
+		transport *string
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{},
+			out: out{},
+		},
+		{
+			in:  in{transport: strToPtrStrict("nvme")},
+			out: out{},
+		},
+		{
+			in:  in{transport: strToPtrStrict("firewire")},
+			out: out{err: errors.ErrDiskSelectorTransport},
+		},
+	}
+
+	for i, test := range tests {
+		r := DiskSelector{Transport: test.in.transport}.ValidateTransport()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), r) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, r)
+		}
+	}
+}
+
+func TestDiskSelectorValidateModel(t *testing.T) {
+	if r := (DiskSelector{Model: strToPtrStrict("^Samsung SSD 9[78]0")}).ValidateModel(); len(r.Entries) != 0 {
+		t.Errorf("valid regular expression reported: %v", r)
+	}
+	if r := (DiskSelector{Model: strToPtrStrict("Samsung(")}).ValidateModel(); !r.IsFatal() {
+		t.Errorf("invalid regular expression not reported")
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flatcar/ignition/config/shared/errors"
+	"github.com/flatcar/ignition/config/validate/report"
+)
+
+func TestValidateTableType(t *testing.T) {
+	no := false
+	type in struct {
+		disk Disk
+	}
+	type out struct {
+		report report.Report
+	}
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in{Disk{Partitions: []Partition{{Number: 1, Label: strToPtrStrict("root")}}}},
+			out{report.Report{}},
+		},
+		{
+			in{Disk{TableType: "gpt", Partitions: []Partition{{Number: 1, TypeGUID: "0FC63DAF-8483-4772-8E79-3D69D8477DE4"}}}},
+			out{report.Report{}},
+		},
+		{
+			in{Disk{TableType: "mbr"}},
+			out{report.ReportFromError(errors.ErrInvalidTableType, report.EntryError)},
+		},
+		{
+			in{Disk{Partitions: []Partition{{Number: 1, Bootable: true}}}},
+			out{report.ReportFromError(errors.ErrDOSFieldsOnGPTTable, report.EntryError)},
+		},
+		{
+			in{Disk{TableType: "gpt", Partitions: []Partition{{Number: 1, MbrType: "83"}}}},
+			out{report.ReportFromError(errors.ErrDOSFieldsOnGPTTable, report.EntryError)},
+		},
+		{
+			in{Disk{TableType: "dos", Partitions: []Partition{
+				{Number: 1, MbrType: "83", Bootable: true},
+				{Number: 2, MbrType: "05"},
+				{Number: 5, MbrType: "82"},
+				{Number: 6},
+			}}},
+			out{report.Report{}},
+		},
+		{
+			in{Disk{TableType: "dos", Partitions: []Partition{{Number: 1, Label: strToPtrStrict("root")}}}},
+			out{report.ReportFromError(errors.ErrGPTFieldsOnDOSTable, report.EntryError)},
+		},
+		{
+			in{Disk{TableType: "dos", Partitions: []Partition{{Number: 1, Resize: true}}}},
+			out{report.ReportFromError(errors.ErrGPTFieldsOnDOSTable, report.EntryError)},
+		},
+		{
+			in{Disk{TableType: "dos", Partitions: []Partition{{Number: 1, Attributes: []PartitionAttribute{"2"}}}}},
+			out{report.ReportFromError(errors.ErrGPTFieldsOnDOSTable, report.EntryError)},
+		},
+		{
+			in{Disk{TableType: "dos", Partitions: []Partition{{MbrType: "83"}}}},
+			out{report.ReportFromError(errors.ErrDOSPartitionNumber, report.EntryError)},
+		},
+		{
+			in{Disk{TableType: "dos", Partitions: []Partition{{Number: 1, MbrType: "05"}, {Number: 2, MbrType: "0f"}}}},
+			out{report.ReportFromError(errors.ErrDOSMultipleExtended, report.EntryError)},
+		},
+		{
+			in{Disk{TableType: "dos", Partitions: []Partition{{Number: 1, MbrType: "05", ShouldExist: &no}, {Number: 2, MbrType: "0f"}}}},
+			out{report.Report{}},
+		},
+		{
+			in{Disk{TableType: "dos", Partitions: []Partition{{Number: 5, MbrType: "05"}}}},
+			out{report.ReportFromError(errors.ErrDOSExtendedNotPrimary, report.EntryError)},
+		},
+		{
+			in{Disk{TableType: "dos", Partitions: []Partition{{Number: 5, Bootable: true}}}},
+			out{report.ReportFromError(errors.ErrDOSBootableNotPrimary, report.EntryError)},
+		},
+		{
+			in{Disk{TableType: "dos", Partitions: []Partition{{Number: 1, MbrType: "85", Bootable: true}}}},
+			out{report.ReportFromError(errors.ErrDOSBootableNotPrimary, report.EntryError)},
+		},
+	}
+	for i, test := range tests {
+		r := test.in.disk.ValidateTableType()
+		if !reflect.DeepEqual(r, test.out.report) {
+			t.Errorf("#%d: wanted %v, got %v", i, test.out.report, r)
+		}
+	}
+}
@@ -17,6 +17,8 @@ package types
 import (
 	"fmt"
 	"net/url"
+	"regexp"
+	"strings"
 
 	"github.com/flatcar/ignition/config/shared/errors"
 	"github.com/flatcar/ignition/config/validate/report"
@@ -26,6 +28,10 @@ func (f File) Validate() report.Report {
 	if f.Overwrite != nil && *f.Overwrite && f.Append {
 		return report.ReportFromError(errors.ErrAppendAndOverwrite, report.EntryError)
 	}
+	// edits apply to the existing file, so nothing may replace it first
+	if len(f.Edits) > 0 && (f.Append || (f.Overwrite != nil && *f.Overwrite) || !f.Contents.isEmpty()) {
+		return report.ReportFromError(errors.ErrFileEditsConflict, report.EntryError)
+	}
 	return report.Report{}
 }
 
@@ -37,7 +43,8 @@ func (f File) ValidateMode() report.Report {
 			Kind:    report.EntryError,
 		})
 	}
-	if f.Mode == nil {
+	// edited files keep their mode unless one is given
+	if f.Mode == nil && len(f.Edits) == 0 {
 		r.Add(report.Entry{
 			Message: errors.ErrPermissionsUnset.Error(),
 			Kind:    report.EntryWarning,
@@ -46,10 +53,15 @@ func (f File) ValidateMode() report.Report {
 	return r
 }
 
+func (fc FileContents) isEmpty() bool {
+	return fc.Compression == "" && len(fc.HTTPHeaders) == 0 && fc.Source == "" &&
+		!fc.Template && fc.Verification.Hash == nil
+}
+
 func (fc FileContents) ValidateCompression() report.Report {
 	r := report.Report{}
 	switch fc.Compression {
-	case "", "gzip":
+	case "", "gzip", "bzip2":
 	default:
 		r.Add(report.Entry{
 			Message: errors.ErrCompressionInvalid.Error(),
@@ -98,3 +110,59 @@ func (fc FileContents) ValidateHTTPHeaders() report.Report {
 
 	return r
 }
+
+func (e FileEdit) Validate() report.Report {
+	if err := e.validate(); err != nil {
+		return report.ReportFromError(err, report.EntryError)
+	}
+	return report.Report{}
+}
+
+// validate checks that e sets the fields its action requires and none of the
+// fields it ignores.
+func (e FileEdit) validate() error {
+	var unused bool
+	switch e.Action {
+	case "replace":
+		if e.Regex == "" {
+			return errors.ErrFileEditRegexRequired
+		}
+		unused = e.Line != "" || e.Section != "" || e.Key != "" || e.Value != ""
+	case "deleteLines":
+		if e.Regex == "" {
+			return errors.ErrFileEditRegexRequired
+		}
+		unused = e.Replacement != "" || e.Line != "" || e.Section != "" || e.Key != "" || e.Value != ""
+	case "ensureLine":
+		if e.Line == "" {
+			return errors.ErrFileEditLineRequired
+		}
+		unused = e.Replacement != "" || e.Section != "" || e.Key != "" || e.Value != ""
+	case "setKey", "unsetKey":
+		if e.Key == "" {
+			return errors.ErrFileEditKeyRequired
+		}
+		unused = e.Regex != "" || e.Replacement != "" || e.Line != "" || (e.Action == "unsetKey" && e.Value != "")
+	default:
+		return errors.ErrFileEditActionInvalid
+	}
+	if unused {
+		return errors.ErrFileEditFieldUnused
+	}
+
+	if e.Regex != "" {
+		if _, err := regexp.Compile(e.Regex); err != nil {
+			return fmt.Errorf("invalid regex %q: %v", e.Regex, err)
+		}
+	}
+	if strings.ContainsAny(e.Key, "=[ \t\r\n") {
+		return errors.ErrFileEditKeyInvalid
+	}
+	if strings.ContainsAny(e.Section, "]\r\n") {
+		return errors.ErrFileEditSectionInvalid
+	}
+	if strings.ContainsAny(e.Line+e.Value, "\r\n") {
+		return errors.ErrFileEditNewline
+	}
+	return nil
+}
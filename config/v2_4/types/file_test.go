@@ -0,0 +1,189 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/flatcar/ignition/config/shared/errors"
+	"github.com/flatcar/ignition/config/validate/report"
+)
+
+func TestFileValidateEdits(t *testing.T) {
+	type in struct {
+		file File
+	}
+	type out struct {
+		err error
+	}
+
+	edits := []FileEdit{{Action: "setKey", Section: "Journal", Key: "Storage", Value: "persistent"}}
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{file: File{FileEmbedded1: FileEmbedded1{Edits: edits}}},
+			out: out{},
+		},
+		{
+			in:  in{file: File{Node: Node{Overwrite: boolToPtr(false)}, FileEmbedded1: FileEmbedded1{Edits: edits}}},
+			out: out{},
+		},
+		{
+			in:  in{file: File{Node: Node{Overwrite: boolToPtr(true)}, FileEmbedded1: FileEmbedded1{Edits: edits}}},
+			out: out{err: errors.ErrFileEditsConflict},
+		},
+		{
+			in:  in{file: File{FileEmbedded1: FileEmbedded1{Append: true, Edits: edits}}},
+			out: out{err: errors.ErrFileEditsConflict},
+		},
+		{
+			in:  in{file: File{FileEmbedded1: FileEmbedded1{Contents: FileContents{Source: "data:,"}, Edits: edits}}},
+			out: out{err: errors.ErrFileEditsConflict},
+		},
+		{
+			in:  in{file: File{FileEmbedded1: FileEmbedded1{Contents: FileContents{Template: true}, Edits: edits}}},
+			out: out{err: errors.ErrFileEditsConflict},
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.file.Validate()
+		expected := report.Report{}
+		if test.out.err != nil {
+			expected = report.ReportFromError(test.out.err, report.EntryError)
+		}
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
+
+func TestFileEditValidate(t *testing.T) {
+	type in struct {
+		edit FileEdit
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{edit: FileEdit{Action: "replace", Regex: `^#?(Storage)=.*$`, Replacement: "${1}=persistent"}},
+			out: out{},
+		},
+		{
+			in:  in{edit: FileEdit{Action: "replace", Regex: `\s+$`}},
+			out: out{},
+		},
+		{
+			in:  in{edit: FileEdit{Action: "deleteLines", Regex: `^#`}},
+			out: out{},
+		},
+		{
+			in:  in{edit: FileEdit{Action: "ensureLine", Line: "nameserver 10.0.0.2"}},
+			out: out{},
+		},
+		{
+			in:  in{edit: FileEdit{Action: "ensureLine", Line: "PermitRootLogin no", Regex: `^#?PermitRootLogin\b`}},
+			out: out{},
+		},
+		{
+			in:  in{edit: FileEdit{Action: "setKey", Section: "Journal", Key: "Storage", Value: "persistent"}},
+			out: out{},
+		},
+		{
+			in:  in{edit: FileEdit{Action: "setKey", Key: "FOO"}},
+			out: out{},
+		},
+		{
+			in:  in{edit: FileEdit{Action: "unsetKey", Section: "Journal", Key: "Storage"}},
+			out: out{},
+		},
+		{
+			in:  in{edit: FileEdit{Action: "append", Line: "foo"}},
+			out: out{err: errors.ErrFileEditActionInvalid},
+		},
+		{
+			in:  in{edit: FileEdit{Action: "replace", Replacement: "foo"}},
+			out: out{err: errors.ErrFileEditRegexRequired},
+		},
+		{
+			in:  in{edit: FileEdit{Action: "deleteLines"}},
+			out: out{err: errors.ErrFileEditRegexRequired},
+		},
+		{
+			in:  in{edit: FileEdit{Action: "ensureLine", Regex: "^foo"}},
+			out: out{err: errors.ErrFileEditLineRequired},
+		},
+		{
+			in:  in{edit: FileEdit{Action: "setKey", Section: "Journal", Value: "persistent"}},
+			out: out{err: errors.ErrFileEditKeyRequired},
+		},
+		{
+			in:  in{edit: FileEdit{Action: "deleteLines", Regex: "^foo", Replacement: "bar"}},
+			out: out{err: errors.ErrFileEditFieldUnused},
+		},
+		{
+			in:  in{edit: FileEdit{Action: "unsetKey", Key: "Storage", Value: "persistent"}},
+			out: out{err: errors.ErrFileEditFieldUnused},
+		},
+		{
+			in:  in{edit: FileEdit{Action: "setKey", Key: "Storage", Regex: "^Storage"}},
+			out: out{err: errors.ErrFileEditFieldUnused},
+		},
+		{
+			in:  in{edit: FileEdit{Action: "replace", Regex: "(foo"}},
+			out: out{err: fmt.Errorf("invalid regex \"(foo\": error parsing regexp: missing closing ): `(foo`")},
+		},
+		{
+			in:  in{edit: FileEdit{Action: "setKey", Key: "Storage=volatile"}},
+			out: out{err: errors.ErrFileEditKeyInvalid},
+		},
+		{
+			in:  in{edit: FileEdit{Action: "unsetKey", Key: "Max Use"}},
+			out: out{err: errors.ErrFileEditKeyInvalid},
+		},
+		{
+			in:  in{edit: FileEdit{Action: "setKey", Section: "Journal]", Key: "Storage"}},
+			out: out{err: errors.ErrFileEditSectionInvalid},
+		},
+		{
+			in:  in{edit: FileEdit{Action: "ensureLine", Line: "a\nb"}},
+			out: out{err: errors.ErrFileEditNewline},
+		},
+		{
+			in:  in{edit: FileEdit{Action: "setKey", Key: "Storage", Value: "a\nb"}},
+			out: out{err: errors.ErrFileEditNewline},
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.edit.Validate()
+		expected := report.Report{}
+		if test.out.err != nil {
+			expected = report.ReportFromError(test.out.err, report.EntryError)
+		}
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
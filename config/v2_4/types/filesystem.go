@@ -16,6 +16,7 @@ package types
 
 import (
 	"fmt"
+	"path/filepath"
 
 	"github.com/flatcar/ignition/config/shared/errors"
 	"github.com/flatcar/ignition/config/validate/report"
@@ -58,6 +59,21 @@ func (f Filesystem) Validate() report.Report {
 	return r
 }
 
+// subvolumeNames returns the names of the subvolumes of f which can be
+// referenced like a filesystem.
+func (f Filesystem) subvolumeNames() []string {
+	names := []string{}
+	if f.Mount == nil {
+		return names
+	}
+	for _, sv := range f.Mount.Subvolumes {
+		if sv.Name != "" {
+			names = append(names, sv.Name)
+		}
+	}
+	return names
+}
+
 func (f Filesystem) ValidatePath() report.Report {
 	r := report.Report{}
 	if f.Path != nil && validatePath(*f.Path) != nil {
@@ -82,6 +98,48 @@ func (m Mount) Validate() report.Report {
 	return r
 }
 
+func (m Mount) ValidateGrowFilesystem() report.Report {
+	r := report.Report{}
+	if !m.GrowFilesystem {
+		return r
+	}
+	switch m.Format {
+	case "ext4", "btrfs", "xfs":
+	default:
+		r.Add(report.Entry{
+			Message: errors.ErrGrowFilesystemFormat.Error(),
+			Kind:    report.EntryError,
+		})
+	}
+	return r
+}
+
+func (m Mount) ValidateSubvolumes() report.Report {
+	r := report.Report{}
+	if len(m.Subvolumes) == 0 {
+		return r
+	}
+	if m.Format != "btrfs" {
+		r.Add(report.Entry{
+			Message: errors.ErrSubvolumesRequireBtrfs.Error(),
+			Kind:    report.EntryError,
+		})
+	}
+	paths := map[string]struct{}{}
+	for _, sv := range m.Subvolumes {
+		path := filepath.Clean(sv.Path)
+		if _, ok := paths[path]; ok {
+			r.Add(report.Entry{
+				Message: errors.ErrSubvolumePathsCollide.Error(),
+				Kind:    report.EntryError,
+			})
+			break
+		}
+		paths[path] = struct{}{}
+	}
+	return r
+}
+
 func (m Mount) ValidateDevice() report.Report {
 	r := report.Report{}
 	if err := validatePath(m.Device); err != nil {
@@ -56,6 +56,44 @@ func TestMountValidate(t *testing.T) {
 	}
 }
 
+func TestMountValidateGrowFilesystem(t *testing.T) {
+	type in struct {
+		mount Mount
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{mount: Mount{Format: "ext4", GrowFilesystem: true}},
+			out: out{},
+		},
+		{
+			in:  in{mount: Mount{Format: "xfs", GrowFilesystem: true}},
+			out: out{},
+		},
+		{
+			in:  in{mount: Mount{Format: "swap", GrowFilesystem: true}},
+			out: out{err: errors.ErrGrowFilesystemFormat},
+		},
+		{
+			in:  in{mount: Mount{Format: "vfat"}},
+			out: out{},
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.mount.ValidateGrowFilesystem()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+	}
+}
+
 func TestFilesystemValidate(t *testing.T) {
 	type in struct {
 		filesystem Filesystem
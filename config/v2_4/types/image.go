@@ -0,0 +1,46 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"github.com/flatcar/ignition/config/shared/errors"
+	"github.com/flatcar/ignition/config/validate/report"
+)
+
+// Images are fetched like file contents, so they share their validation.
+
+func (i Image) contents() FileContents {
+	return FileContents{
+		Compression:  i.Compression,
+		HTTPHeaders:  i.HTTPHeaders,
+		Source:       i.Source,
+		Verification: i.Verification,
+	}
+}
+
+func (i Image) ValidateSource() report.Report {
+	if i.Source == "" {
+		return report.ReportFromError(errors.ErrImageSourceRequired, report.EntryError)
+	}
+	return i.contents().ValidateSource()
+}
+
+func (i Image) ValidateCompression() report.Report {
+	return i.contents().ValidateCompression()
+}
+
+func (i Image) ValidateHTTPHeaders() report.Report {
+	return i.contents().ValidateHTTPHeaders()
+}
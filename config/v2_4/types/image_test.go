@@ -0,0 +1,87 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flatcar/ignition/config/shared/errors"
+	"github.com/flatcar/ignition/config/validate/report"
+)
+
+func TestImageValidateSource(t *testing.T) {
+	type in struct {
+		source string
+	}
+	type out struct {
+		report report.Report
+	}
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in{"https://example.com/disk.img"},
+			out{report.Report{}},
+		},
+		{
+			in{""},
+			out{report.ReportFromError(errors.ErrImageSourceRequired, report.EntryError)},
+		},
+	}
+	for i, test := range tests {
+		r := Image{Source: test.in.source}.ValidateSource()
+		if !reflect.DeepEqual(r, test.out.report) {
+			t.Errorf("#%d: wanted %v, got %v", i, test.out.report, r)
+		}
+	}
+}
+
+func TestImageValidateCompression(t *testing.T) {
+	type in struct {
+		compression string
+	}
+	type out struct {
+		report report.Report
+	}
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in{""},
+			out{report.Report{}},
+		},
+		{
+			in{"gzip"},
+			out{report.Report{}},
+		},
+		{
+			in{"bzip2"},
+			out{report.Report{}},
+		},
+		{
+			in{"xz"},
+			out{report.ReportFromError(errors.ErrCompressionInvalid, report.EntryError)},
+		},
+	}
+	for i, test := range tests {
+		r := Image{Compression: test.in.compression}.ValidateCompression()
+		if !reflect.DeepEqual(r, test.out.report) {
+			t.Errorf("#%d: wanted %v, got %v", i, test.out.report, r)
+		}
+	}
+}
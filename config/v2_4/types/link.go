@@ -0,0 +1,39 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"strings"
+
+	"github.com/flatcar/ignition/config/shared/errors"
+	"github.com/flatcar/ignition/config/validate/report"
+)
+
+// Hard links share the inode of their target, so setting extended attributes
+// on them would modify the target. The kernel doesn't allow attributes in the
+// user namespace on symbolic links.
+func (l Link) Validate() report.Report {
+	if l.Hard && l.hasXattrs() {
+		return report.ReportFromError(errors.ErrXattrsOnHardLink, report.EntryError)
+	}
+	if !l.Hard {
+		for _, x := range l.Xattrs {
+			if strings.HasPrefix(x.Name, "user.") {
+				return report.ReportFromError(errors.ErrXattrUserOnSymlink, report.EntryError)
+			}
+		}
+	}
+	return report.Report{}
+}
@@ -0,0 +1,56 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flatcar/ignition/config/shared/errors"
+	"github.com/flatcar/ignition/config/validate/report"
+)
+
+func TestLinkValidate(t *testing.T) {
+	tests := []struct {
+		in  Link
+		out report.Report
+	}{
+		{
+			in:  Link{Node: Node{SELinuxContext: "system_u:object_r:bin_t:s0"}},
+			out: report.Report{},
+		},
+		{
+			in:  Link{Node: Node{Xattrs: []NodeXattr{{Name: "trusted.a"}}}},
+			out: report.Report{},
+		},
+		{
+			in:  Link{Node: Node{Xattrs: []NodeXattr{{Name: "trusted.a"}, {Name: "user.a"}}}},
+			out: report.ReportFromError(errors.ErrXattrUserOnSymlink, report.EntryError),
+		},
+		{
+			in:  Link{Node: Node{Xattrs: []NodeXattr{{Name: "user.a"}}}, LinkEmbedded1: LinkEmbedded1{Hard: true}},
+			out: report.ReportFromError(errors.ErrXattrsOnHardLink, report.EntryError),
+		},
+		{
+			in:  Link{Node: Node{SELinuxContext: "system_u:object_r:bin_t:s0"}, LinkEmbedded1: LinkEmbedded1{Hard: true}},
+			out: report.ReportFromError(errors.ErrXattrsOnHardLink, report.EntryError),
+		},
+	}
+	for i, test := range tests {
+		if r := test.in.Validate(); !reflect.DeepEqual(test.out, r) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out, r)
+		}
+	}
+}
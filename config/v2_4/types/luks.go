@@ -0,0 +1,68 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"strings"
+
+	"github.com/flatcar/ignition/config/shared/errors"
+	"github.com/flatcar/ignition/config/validate/report"
+)
+
+func (l Luks) ValidateName() report.Report {
+	if l.Name == "" {
+		return report.ReportFromError(errors.ErrLuksNameRequired, report.EntryError)
+	}
+	// the name becomes /dev/mapper/<name>
+	if strings.Contains(l.Name, "/") {
+		return report.ReportFromError(errors.ErrLuksNameContainsSlash, report.EntryError)
+	}
+	return report.Report{}
+}
+
+func (l Luks) ValidateDevice() report.Report {
+	if l.Device == "" {
+		return report.ReportFromError(errors.ErrDiskDeviceRequired, report.EntryError)
+	}
+	if err := validatePath(l.Device); err != nil {
+		return report.ReportFromError(err, report.EntryError)
+	}
+	return report.Report{}
+}
+
+func (l Luks) ValidateKeyFile() report.Report {
+	if l.KeyFile.Source == "" {
+		return report.ReportFromError(errors.ErrLuksKeyFileRequired, report.EntryError)
+	}
+	if l.KeyFile.Template {
+		return report.ReportFromError(errors.ErrTemplateUnsupported, report.EntryError)
+	}
+	return report.Report{}
+}
+
+func (l Luks) ValidateLabel() report.Report {
+	// source: man cryptsetup, LUKS2 labels are limited to 47 characters
+	if l.Label != nil && len(*l.Label) > 47 {
+		return report.ReportFromError(errors.ErrLuksLabelTooLong, report.EntryError)
+	}
+	return report.Report{}
+}
+
+func (l Luks) ValidateUUID() report.Report {
+	if l.UUID == nil {
+		return report.Report{}
+	}
+	return validateGUID(*l.UUID)
+}
@@ -0,0 +1,98 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/flatcar/ignition/config/shared/errors"
+	"github.com/flatcar/ignition/config/validate/report"
+)
+
+func TestLuksValidate(t *testing.T) {
+	type in struct {
+		luks Luks
+	}
+	type out struct {
+		err error
+	}
+
+	strToPtr := func(s string) *string { return &s }
+	valid := func(f func(*Luks)) Luks {
+		l := Luks{
+			Name:    "data",
+			Device:  "/dev/sdb1",
+			KeyFile: FileContents{Source: "data:,secret"},
+		}
+		f(&l)
+		return l
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{luks: valid(func(l *Luks) {})},
+			out: out{},
+		},
+		{
+			in:  in{luks: valid(func(l *Luks) { l.Name = "" })},
+			out: out{err: errors.ErrLuksNameRequired},
+		},
+		{
+			in:  in{luks: valid(func(l *Luks) { l.Name = "a/b" })},
+			out: out{err: errors.ErrLuksNameContainsSlash},
+		},
+		{
+			in:  in{luks: valid(func(l *Luks) { l.Device = "" })},
+			out: out{err: errors.ErrDiskDeviceRequired},
+		},
+		{
+			in:  in{luks: valid(func(l *Luks) { l.Device = "sdb1" })},
+			out: out{err: errors.ErrPathRelative},
+		},
+		{
+			in:  in{luks: valid(func(l *Luks) { l.KeyFile.Source = "" })},
+			out: out{err: errors.ErrLuksKeyFileRequired},
+		},
+		{
+			in:  in{luks: valid(func(l *Luks) { l.KeyFile.Template = true })},
+			out: out{err: errors.ErrTemplateUnsupported},
+		},
+		{
+			in:  in{luks: valid(func(l *Luks) { l.Label = strToPtr(strings.Repeat("a", 48)) })},
+			out: out{err: errors.ErrLuksLabelTooLong},
+		},
+		{
+			in:  in{luks: valid(func(l *Luks) { l.UUID = strToPtr("not-a-uuid") })},
+			out: out{err: errors.ErrDoesntMatchGUIDRegex},
+		},
+	}
+
+	for i, test := range tests {
+		r := report.Report{}
+		r.Merge(test.in.luks.ValidateName())
+		r.Merge(test.in.luks.ValidateDevice())
+		r.Merge(test.in.luks.ValidateKeyFile())
+		r.Merge(test.in.luks.ValidateLabel())
+		r.Merge(test.in.luks.ValidateUUID())
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), r) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, r)
+		}
+	}
+}
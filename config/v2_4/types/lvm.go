@@ -0,0 +1,154 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"strings"
+
+	"github.com/flatcar/ignition/config/shared/errors"
+	"github.com/flatcar/ignition/config/validate/report"
+)
+
+// validateLvmName checks a volume group or logical volume name, which
+// becomes a component of /dev/<vg>/<lv>.
+func validateLvmName(name string) bool {
+	return !strings.Contains(name, "/") && !strings.HasPrefix(name, "-")
+}
+
+func (v VolumeGroup) ValidateName() report.Report {
+	if v.Name == "" {
+		return report.ReportFromError(errors.ErrVolumeGroupNameRequired, report.EntryError)
+	}
+	if !validateLvmName(v.Name) {
+		return report.ReportFromError(errors.ErrVolumeGroupNameInvalid, report.EntryError)
+	}
+	return report.Report{}
+}
+
+func (v VolumeGroup) ValidatePhysicalVolumes() report.Report {
+	r := report.Report{}
+	if len(v.PhysicalVolumes) == 0 {
+		r.Add(report.Entry{
+			Message: errors.ErrVolumeGroupPVsRequired.Error(),
+			Kind:    report.EntryError,
+		})
+	}
+	for _, d := range v.PhysicalVolumes {
+		if err := validatePath(string(d)); err != nil {
+			r.Add(report.Entry{
+				Message: errors.ErrPathRelative.Error(),
+				Kind:    report.EntryError,
+			})
+		}
+	}
+	return r
+}
+
+func (v VolumeGroup) ValidateLogicalVolumes() report.Report {
+	r := report.Report{}
+	names := map[string]struct{}{}
+	pools := map[string]struct{}{}
+	for _, lv := range v.LogicalVolumes {
+		if _, ok := names[lv.Name]; ok {
+			r.Add(report.Entry{
+				Message: errors.ErrLogicalVolumeNamesCollide.Error(),
+				Kind:    report.EntryError,
+			})
+		}
+		names[lv.Name] = struct{}{}
+		if lv.Type == "thin-pool" {
+			pools[lv.Name] = struct{}{}
+		}
+	}
+	for _, lv := range v.LogicalVolumes {
+		if lv.Pool == nil {
+			continue
+		}
+		if _, ok := pools[*lv.Pool]; !ok {
+			r.Add(report.Entry{
+				Message: errors.ErrThinPoolUndefined.Error(),
+				Kind:    report.EntryError,
+			})
+		}
+	}
+	return r
+}
+
+func (l LogicalVolume) Validate() report.Report {
+	r := report.Report{}
+	add := func(err error) {
+		r.Add(report.Entry{
+			Message: err.Error(),
+			Kind:    report.EntryError,
+		})
+	}
+	if l.Type == "thin" {
+		if l.Pool == nil {
+			add(errors.ErrThinVolumeNeedsPool)
+		}
+		// the size of a thin volume is virtual, it can't be a share
+		// of the free space of the volume group
+		if l.SizeMiB == nil {
+			add(errors.ErrThinVolumeNeedsSize)
+		}
+		if l.PercentFree != nil {
+			add(errors.ErrLogicalVolumeSizeAndPercent)
+		}
+		return r
+	}
+	if l.Pool != nil {
+		add(errors.ErrPoolWithoutThinVolume)
+	}
+	switch {
+	case l.SizeMiB == nil && l.PercentFree == nil:
+		add(errors.ErrLogicalVolumeSizeRequired)
+	case l.SizeMiB != nil && l.PercentFree != nil:
+		add(errors.ErrLogicalVolumeSizeAndPercent)
+	}
+	return r
+}
+
+func (l LogicalVolume) ValidateName() report.Report {
+	if l.Name == "" {
+		return report.ReportFromError(errors.ErrLogicalVolumeNameRequired, report.EntryError)
+	}
+	if !validateLvmName(l.Name) {
+		return report.ReportFromError(errors.ErrLogicalVolumeNameInvalid, report.EntryError)
+	}
+	return report.Report{}
+}
+
+func (l LogicalVolume) ValidateType() report.Report {
+	switch l.Type {
+	case "", "linear", "thin-pool", "thin":
+		return report.Report{}
+	default:
+		return report.ReportFromError(errors.ErrLogicalVolumeTypeInvalid, report.EntryError)
+	}
+}
+
+func (l LogicalVolume) ValidateSizeMiB() report.Report {
+	if l.SizeMiB != nil && *l.SizeMiB <= 0 {
+		return report.ReportFromError(errors.ErrLogicalVolumeSizeInvalid, report.EntryError)
+	}
+	return report.Report{}
+}
+
+func (l LogicalVolume) ValidatePercentFree() report.Report {
+	if l.PercentFree != nil && (*l.PercentFree < 1 || *l.PercentFree > 100) {
+		return report.ReportFromError(errors.ErrLogicalVolumePercentInvalid, report.EntryError)
+	}
+	return report.Report{}
+}
@@ -0,0 +1,171 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flatcar/ignition/config/shared/errors"
+	"github.com/flatcar/ignition/config/validate/report"
+)
+
+func TestVolumeGroupValidate(t *testing.T) {
+	type in struct {
+		vg VolumeGroup
+	}
+	type out struct {
+		err error
+	}
+
+	strToPtr := func(s string) *string { return &s }
+	valid := func(f func(*VolumeGroup)) VolumeGroup {
+		v := VolumeGroup{
+			Name:            "data",
+			PhysicalVolumes: []Device{"/dev/md/data", "/dev/disk/by-partlabel/DATA"},
+			LogicalVolumes: []LogicalVolume{
+				{Name: "pool", Type: "thin-pool", PercentFree: intToPtr(100)},
+				{Name: "docker", Type: "thin", Pool: strToPtr("pool"), SizeMiB: intToPtr(10240)},
+			},
+		}
+		f(&v)
+		return v
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{vg: valid(func(v *VolumeGroup) {})},
+			out: out{},
+		},
+		{
+			in:  in{vg: valid(func(v *VolumeGroup) { v.Name = "" })},
+			out: out{err: errors.ErrVolumeGroupNameRequired},
+		},
+		{
+			in:  in{vg: valid(func(v *VolumeGroup) { v.Name = "a/b" })},
+			out: out{err: errors.ErrVolumeGroupNameInvalid},
+		},
+		{
+			in:  in{vg: valid(func(v *VolumeGroup) { v.PhysicalVolumes = nil })},
+			out: out{err: errors.ErrVolumeGroupPVsRequired},
+		},
+		{
+			in:  in{vg: valid(func(v *VolumeGroup) { v.PhysicalVolumes = []Device{"sdb"} })},
+			out: out{err: errors.ErrPathRelative},
+		},
+		{
+			in:  in{vg: valid(func(v *VolumeGroup) { v.LogicalVolumes[1].Name = "pool" })},
+			out: out{err: errors.ErrLogicalVolumeNamesCollide},
+		},
+		{
+			in:  in{vg: valid(func(v *VolumeGroup) { v.LogicalVolumes[1].Pool = strToPtr("docker") })},
+			out: out{err: errors.ErrThinPoolUndefined},
+		},
+	}
+
+	for i, test := range tests {
+		r := report.Report{}
+		r.Merge(test.in.vg.ValidateName())
+		r.Merge(test.in.vg.ValidatePhysicalVolumes())
+		r.Merge(test.in.vg.ValidateLogicalVolumes())
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), r) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, r)
+		}
+	}
+}
+
+func TestLogicalVolumeValidate(t *testing.T) {
+	type in struct {
+		lv LogicalVolume
+	}
+	type out struct {
+		err error
+	}
+
+	strToPtr := func(s string) *string { return &s }
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{lv: LogicalVolume{Name: "log", SizeMiB: intToPtr(512)}},
+			out: out{},
+		},
+		{
+			in:  in{lv: LogicalVolume{Name: "docker", Type: "linear", PercentFree: intToPtr(50)}},
+			out: out{},
+		},
+		{
+			in:  in{lv: LogicalVolume{Name: "thin", Type: "thin", Pool: strToPtr("pool"), SizeMiB: intToPtr(512)}},
+			out: out{},
+		},
+		{
+			in:  in{lv: LogicalVolume{SizeMiB: intToPtr(512)}},
+			out: out{err: errors.ErrLogicalVolumeNameRequired},
+		},
+		{
+			in:  in{lv: LogicalVolume{Name: "-log", SizeMiB: intToPtr(512)}},
+			out: out{err: errors.ErrLogicalVolumeNameInvalid},
+		},
+		{
+			in:  in{lv: LogicalVolume{Name: "log", Type: "mirror", SizeMiB: intToPtr(512)}},
+			out: out{err: errors.ErrLogicalVolumeTypeInvalid},
+		},
+		{
+			in:  in{lv: LogicalVolume{Name: "log"}},
+			out: out{err: errors.ErrLogicalVolumeSizeRequired},
+		},
+		{
+			in:  in{lv: LogicalVolume{Name: "log", SizeMiB: intToPtr(512), PercentFree: intToPtr(50)}},
+			out: out{err: errors.ErrLogicalVolumeSizeAndPercent},
+		},
+		{
+			in:  in{lv: LogicalVolume{Name: "log", SizeMiB: intToPtr(0)}},
+			out: out{err: errors.ErrLogicalVolumeSizeInvalid},
+		},
+		{
+			in:  in{lv: LogicalVolume{Name: "log", PercentFree: intToPtr(101)}},
+			out: out{err: errors.ErrLogicalVolumePercentInvalid},
+		},
+		{
+			in:  in{lv: LogicalVolume{Name: "thin", Type: "thin", SizeMiB: intToPtr(512)}},
+			out: out{err: errors.ErrThinVolumeNeedsPool},
+		},
+		{
+			in:  in{lv: LogicalVolume{Name: "thin", Type: "thin", Pool: strToPtr("pool")}},
+			out: out{err: errors.ErrThinVolumeNeedsSize},
+		},
+		{
+			in:  in{lv: LogicalVolume{Name: "log", Pool: strToPtr("pool"), SizeMiB: intToPtr(512)}},
+			out: out{err: errors.ErrPoolWithoutThinVolume},
+		},
+	}
+
+	for i, test := range tests {
+		r := report.Report{}
+		r.Merge(test.in.lv.Validate())
+		r.Merge(test.in.lv.ValidateName())
+		r.Merge(test.in.lv.ValidateType())
+		r.Merge(test.in.lv.ValidateSizeMiB())
+		r.Merge(test.in.lv.ValidatePercentFree())
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), r) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, r)
+		}
+	}
+}
@@ -16,11 +16,17 @@ package types
 
 import (
 	"path/filepath"
+	"regexp"
 
 	"github.com/flatcar/ignition/config/shared/errors"
+	"github.com/flatcar/ignition/config/shared/xattrs"
 	"github.com/flatcar/ignition/config/validate/report"
 )
 
+// seLinuxContextRegex matches user:role:type with an optional level, which
+// may contain colons itself.
+var seLinuxContextRegex = regexp.MustCompile(`^[^:\s]+:[^:\s]+:[^:\s]+(:\S+)?$`)
+
 func (n Node) ValidateFilesystem() report.Report {
 	r := report.Report{}
 	if n.Filesystem == "" {
@@ -43,6 +49,30 @@ func (n Node) ValidatePath() report.Report {
 	return r
 }
 
+func (n Node) ValidateXattrs() report.Report {
+	seen := map[string]bool{}
+	for _, x := range n.Xattrs {
+		if seen[x.Name] {
+			return report.ReportFromError(errors.ErrXattrNameDuplicate, report.EntryError)
+		}
+		seen[x.Name] = true
+	}
+	return report.Report{}
+}
+
+func (n Node) ValidateSELinuxContext() report.Report {
+	if n.SELinuxContext != "" && !seLinuxContextRegex.MatchString(n.SELinuxContext) {
+		return report.ReportFromError(errors.ErrSELinuxContextInvalid, report.EntryError)
+	}
+	return report.Report{}
+}
+
+// hasXattrs returns whether n sets any extended attributes, including the
+// SELinux context.
+func (n Node) hasXattrs() bool {
+	return len(n.Xattrs) > 0 || n.SELinuxContext != ""
+}
+
 func (n Node) Depth() int {
 	count := 0
 	for p := filepath.Clean(string(n.Path)); p != "/"; count++ {
@@ -71,3 +101,17 @@ func (ng NodeGroup) Validate() report.Report {
 	}
 	return r
 }
+
+func (x NodeXattr) ValidateName() report.Report {
+	if err := xattrs.ValidateName(x.Name); err != nil {
+		return report.ReportFromError(err, report.EntryError)
+	}
+	return report.Report{}
+}
+
+func (x NodeXattr) ValidateValue() report.Report {
+	if _, err := xattrs.DecodeValue(x.Value); err != nil {
+		return report.ReportFromError(err, report.EntryError)
+	}
+	return report.Report{}
+}
@@ -116,3 +116,96 @@ func TestNodeValidateGroup(t *testing.T) {
 		}
 	}
 }
+
+func TestNodeValidateXattrs(t *testing.T) {
+	tests := []struct {
+		in  []NodeXattr
+		out report.Report
+	}{
+		{
+			in:  nil,
+			out: report.Report{},
+		},
+		{
+			in:  []NodeXattr{{Name: "user.a"}, {Name: "user.b", Value: "b"}},
+			out: report.Report{},
+		},
+		{
+			in:  []NodeXattr{{Name: "user.a"}, {Name: "user.a", Value: "a"}},
+			out: report.ReportFromError(errors.ErrXattrNameDuplicate, report.EntryError),
+		},
+	}
+	for i, test := range tests {
+		if r := (Node{Xattrs: test.in}).ValidateXattrs(); !reflect.DeepEqual(test.out, r) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out, r)
+		}
+	}
+}
+
+func TestNodeXattrValidate(t *testing.T) {
+	tests := []struct {
+		in          NodeXattr
+		nameReport  report.Report
+		valueReport report.Report
+	}{
+		{
+			in: NodeXattr{Name: "user.agent.role", Value: "worker"},
+		},
+		{
+			in: NodeXattr{Name: "security.capability", Value: "0x0100000200040000000000000000000000000000"},
+		},
+		{
+			in:         NodeXattr{Name: "agent.role"},
+			nameReport: report.ReportFromError(errors.ErrXattrNameInvalid, report.EntryError),
+		},
+		{
+			in:         NodeXattr{Name: "security.selinux", Value: "system_u:object_r:bin_t:s0"},
+			nameReport: report.ReportFromError(errors.ErrXattrSELinux, report.EntryError),
+		},
+		{
+			in:          NodeXattr{Name: "user.data", Value: "0xnothex"},
+			valueReport: report.ReportFromError(errors.ErrXattrValueInvalid, report.EntryError),
+		},
+	}
+	for i, test := range tests {
+		if r := test.in.ValidateName(); !reflect.DeepEqual(test.nameReport, r) {
+			t.Errorf("#%d: bad name error: want %v, got %v", i, test.nameReport, r)
+		}
+		if r := test.in.ValidateValue(); !reflect.DeepEqual(test.valueReport, r) {
+			t.Errorf("#%d: bad value error: want %v, got %v", i, test.valueReport, r)
+		}
+	}
+}
+
+func TestNodeValidateSELinuxContext(t *testing.T) {
+	tests := []struct {
+		in  string
+		out report.Report
+	}{
+		{
+			in:  "",
+			out: report.Report{},
+		},
+		{
+			in:  "system_u:object_r:bin_t",
+			out: report.Report{},
+		},
+		{
+			in:  "system_u:object_r:container_file_t:s0:c1,c2",
+			out: report.Report{},
+		},
+		{
+			in:  "system_u:object_r",
+			out: report.ReportFromError(errors.ErrSELinuxContextInvalid, report.EntryError),
+		},
+		{
+			in:  "system_u:object_r:bin_t s0",
+			out: report.ReportFromError(errors.ErrSELinuxContextInvalid, report.EntryError),
+		},
+	}
+	for i, test := range tests {
+		if r := (Node{SELinuxContext: test.in}).ValidateSELinuxContext(); !reflect.DeepEqual(test.out, r) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out, r)
+		}
+	}
+}
@@ -17,9 +17,11 @@ package types
 import (
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/flatcar/ignition/config/shared/errors"
+	"github.com/flatcar/ignition/config/shared/partitions"
 	"github.com/flatcar/ignition/config/validate/report"
 )
 
@@ -27,6 +29,10 @@ const (
 	guidRegexStr = "^(|[[:xdigit:]]{8}-[[:xdigit:]]{4}-[[:xdigit:]]{4}-[[:xdigit:]]{4}-[[:xdigit:]]{12})$"
 )
 
+var (
+	mbrTypeRegex = regexp.MustCompile("^(0[xX])?[[:xdigit:]]{1,2}$")
+)
+
 func (p Partition) Validate() report.Report {
 	r := report.Report{}
 	if (p.Start != nil || p.Size != nil) && (p.StartMiB != nil || p.SizeMiB != nil) {
@@ -36,12 +42,24 @@ func (p Partition) Validate() report.Report {
 		})
 	}
 	if p.ShouldExist != nil && !*p.ShouldExist &&
-		(p.Label != nil || p.TypeGUID != "" || p.GUID != "" || p.Start != nil || p.Size != nil) {
+		(p.Label != nil || p.TypeGUID != "" || p.GUID != "" || len(p.Attributes) > 0 || p.MbrType != "" || p.Bootable || p.Start != nil || p.Size != nil || p.Image != nil || p.Resize) {
 		r.Add(report.Entry{
 			Message: errors.ErrShouldNotExistWithOthers.Error(),
 			Kind:    report.EntryError,
 		})
 	}
+	if p.Image != nil && p.Number == 0 {
+		r.Add(report.Entry{
+			Message: errors.ErrImageWithoutPartitionNumber.Error(),
+			Kind:    report.EntryError,
+		})
+	}
+	if p.Resize && p.Number == 0 {
+		r.Add(report.Entry{
+			Message: errors.ErrResizeWithoutNumber.Error(),
+			Kind:    report.EntryError,
+		})
+	}
 	return r
 }
 
@@ -87,13 +105,53 @@ func (p Partition) ValidateLabel() report.Report {
 }
 
 func (p Partition) ValidateTypeGUID() report.Report {
-	return validateGUID(p.TypeGUID)
+	return validateGUID(partitions.TypeGUID(p.TypeGUID))
 }
 
 func (p Partition) ValidateGUID() report.Report {
 	return validateGUID(p.GUID)
 }
 
+func (p Partition) ValidateAttributes() report.Report {
+	attrs := []string{}
+	for _, a := range p.Attributes {
+		attrs = append(attrs, string(a))
+	}
+	if _, err := partitions.ParseAttributes(attrs); err != nil {
+		return report.ReportFromError(err, report.EntryError)
+	}
+	return report.Report{}
+}
+
+func (p Partition) ValidateMbrType() report.Report {
+	if p.MbrType == "" {
+		return report.Report{}
+	}
+	if code, ok := parseMbrType(p.MbrType); !ok || code == 0 {
+		return report.ReportFromError(errors.ErrInvalidMBRType, report.EntryError)
+	}
+	return report.Report{}
+}
+
+// parseMbrType parses an MBR partition type code like "83" or "0x0c".
+func parseMbrType(t string) (byte, bool) {
+	if !mbrTypeRegex.MatchString(t) {
+		return 0, false
+	}
+	code, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(t), "0x"), 16, 8)
+	if err != nil {
+		return 0, false
+	}
+	return byte(code), true
+}
+
+// isExtended returns true if the partition has one of the MBR type codes for
+// extended partitions.
+func (p Partition) isExtended() bool {
+	code, ok := parseMbrType(p.MbrType)
+	return ok && (code == 0x05 || code == 0x0f || code == 0x85)
+}
+
 func validateGUID(guid string) report.Report {
 	r := report.Report{}
 	ok, err := regexp.MatchString(guidRegexStr, guid)
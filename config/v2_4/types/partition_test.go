@@ -90,6 +90,10 @@ func TestValidateTypeGUID(t *testing.T) {
 			in{""},
 			out{report.Report{}},
 		},
+		{
+			in{"flatcar-usr"},
+			out{report.Report{}},
+		},
 		{
 			in{"not-a-valid-typeguid"},
 			out{report.ReportFromError(errors.ErrDoesntMatchGUIDRegex, report.EntryError)},
@@ -198,3 +202,161 @@ func TestValidateStart(t *testing.T) {
 		}
 	}
 }
+
+func TestValidatePartitionImage(t *testing.T) {
+	no := false
+	type in struct {
+		partition Partition
+	}
+	type out struct {
+		report report.Report
+	}
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in{Partition{Number: 1, Image: &Image{Source: "https://example.com/a.img"}}},
+			out{report.Report{}},
+		},
+		{
+			in{Partition{Image: &Image{Source: "https://example.com/a.img"}}},
+			out{report.ReportFromError(errors.ErrImageWithoutPartitionNumber, report.EntryError)},
+		},
+		{
+			in{Partition{Number: 1, ShouldExist: &no, Image: &Image{Source: "https://example.com/a.img"}}},
+			out{report.ReportFromError(errors.ErrShouldNotExistWithOthers, report.EntryError)},
+		},
+	}
+	for i, test := range tests {
+		r := test.in.partition.Validate()
+		if !reflect.DeepEqual(r, test.out.report) {
+			t.Errorf("#%d: wanted %v, got %v", i, test.out.report, r)
+		}
+	}
+}
+
+func TestValidatePartitionResize(t *testing.T) {
+	no := false
+	type in struct {
+		partition Partition
+	}
+	type out struct {
+		report report.Report
+	}
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in{Partition{Number: 9, Resize: true}},
+			out{report.Report{}},
+		},
+		{
+			in{Partition{Resize: true}},
+			out{report.ReportFromError(errors.ErrResizeWithoutNumber, report.EntryError)},
+		},
+		{
+			in{Partition{Number: 9, ShouldExist: &no, Resize: true}},
+			out{report.ReportFromError(errors.ErrShouldNotExistWithOthers, report.EntryError)},
+		},
+	}
+	for i, test := range tests {
+		r := test.in.partition.Validate()
+		if !reflect.DeepEqual(r, test.out.report) {
+			t.Errorf("#%d: wanted %v, got %v", i, test.out.report, r)
+		}
+	}
+}
+
+func TestValidateMbrType(t *testing.T) {
+	type in struct {
+		mbrType string
+	}
+	type out struct {
+		report report.Report
+	}
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in{""},
+			out{report.Report{}},
+		},
+		{
+			in{"83"},
+			out{report.Report{}},
+		},
+		{
+			in{"0x0C"},
+			out{report.Report{}},
+		},
+		{
+			in{"c"},
+			out{report.Report{}},
+		},
+		{
+			in{"0"},
+			out{report.ReportFromError(errors.ErrInvalidMBRType, report.EntryError)},
+		},
+		{
+			in{"100"},
+			out{report.ReportFromError(errors.ErrInvalidMBRType, report.EntryError)},
+		},
+		{
+			in{"linux"},
+			out{report.ReportFromError(errors.ErrInvalidMBRType, report.EntryError)},
+		},
+	}
+	for i, test := range tests {
+		r := Partition{MbrType: test.in.mbrType}.ValidateMbrType()
+		if !reflect.DeepEqual(r, test.out.report) {
+			t.Errorf("#%d: wanted %v, got %v", i, test.out.report, r)
+		}
+	}
+}
+
+func TestValidateAttributes(t *testing.T) {
+	type in struct {
+		attributes []PartitionAttribute
+	}
+	type out struct {
+		report report.Report
+	}
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in{nil},
+			out{report.Report{}},
+		},
+		{
+			in{[]PartitionAttribute{"legacy-bios-bootable", "60", "priority=1", "tries=0", "successful"}},
+			out{report.Report{}},
+		},
+		{
+			in{[]PartitionAttribute{"64"}},
+			out{report.ReportFromError(errors.ErrInvalidPartitionAttribute, report.EntryError)},
+		},
+		{
+			in{[]PartitionAttribute{"bootable"}},
+			out{report.ReportFromError(errors.ErrInvalidPartitionAttribute, report.EntryError)},
+		},
+		{
+			in{[]PartitionAttribute{"priority=16"}},
+			out{report.ReportFromError(errors.ErrInvalidPartitionAttribute, report.EntryError)},
+		},
+		{
+			in{[]PartitionAttribute{"tries=1", "tries=2"}},
+			out{report.ReportFromError(errors.ErrAttributeFieldRepeated, report.EntryError)},
+		},
+	}
+	for i, test := range tests {
+		r := Partition{Attributes: test.in.attributes}.ValidateAttributes()
+		if !reflect.DeepEqual(r, test.out.report) {
+			t.Errorf("#%d: wanted %v, got %v", i, test.out.report, r)
+		}
+	}
+}
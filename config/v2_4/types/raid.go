@@ -55,3 +55,10 @@ func (n Raid) ValidateDevices() report.Report {
 	}
 	return r
 }
+
+func (n Raid) ValidateUUID() report.Report {
+	if n.UUID == nil {
+		return report.Report{}
+	}
+	return validateGUID(*n.UUID)
+}
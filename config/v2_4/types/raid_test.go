@@ -0,0 +1,82 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flatcar/ignition/config/shared/errors"
+	"github.com/flatcar/ignition/config/validate/report"
+)
+
+func TestRaidValidate(t *testing.T) {
+	type in struct {
+		raid Raid
+	}
+	type out struct {
+		err error
+	}
+
+	valid := func(f func(*Raid)) Raid {
+		n := Raid{
+			Name:    "data",
+			Level:   "raid1",
+			Devices: []Device{"/dev/sdb", "/dev/sdc"},
+		}
+		f(&n)
+		return n
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{raid: valid(func(n *Raid) {})},
+			out: out{},
+		},
+		{
+			in:  in{raid: valid(func(n *Raid) { n.UUID = strToPtrStrict("b8c4e0a2-57d3-4a41-9e5c-0d6f1f3a7b21") })},
+			out: out{},
+		},
+		{
+			in:  in{raid: valid(func(n *Raid) { n.UUID = strToPtrStrict("b8c4e0a2:57d34a41:9e5c0d6f:1f3a7b21") })},
+			out: out{err: errors.ErrDoesntMatchGUIDRegex},
+		},
+		{
+			in:  in{raid: valid(func(n *Raid) { n.Level = "raid7" })},
+			out: out{err: errors.ErrUnrecognizedRaidLevel},
+		},
+		{
+			in:  in{raid: valid(func(n *Raid) { n.Level = "raid0"; n.Spares = 1 })},
+			out: out{err: errors.ErrSparesUnsupportedForLevel},
+		},
+		{
+			in:  in{raid: valid(func(n *Raid) { n.Devices = []Device{"sdb"} })},
+			out: out{err: errors.ErrPathRelative},
+		},
+	}
+
+	for i, test := range tests {
+		r := report.Report{}
+		r.Merge(test.in.raid.ValidateLevel())
+		r.Merge(test.in.raid.ValidateDevices())
+		r.Merge(test.in.raid.ValidateUUID())
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), r) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, r)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"path"
+
+	"github.com/flatcar/ignition/config/shared/errors"
+	"github.com/flatcar/ignition/config/validate/report"
+)
+
+func (r Removal) ValidateFilesystem() report.Report {
+	if r.Filesystem == "" {
+		return report.ReportFromError(errors.ErrNoFilesystem, report.EntryError)
+	}
+	return report.Report{}
+}
+
+func (r Removal) ValidatePath() report.Report {
+	if err := validatePath(r.Path); err != nil {
+		return report.ReportFromError(err, report.EntryError)
+	}
+	if path.Clean(r.Path) == "/" {
+		return report.ReportFromError(errors.ErrRemoveFilesystemRoot, report.EntryError)
+	}
+	return report.Report{}
+}
@@ -0,0 +1,87 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flatcar/ignition/config/shared/errors"
+	"github.com/flatcar/ignition/config/validate/report"
+)
+
+func TestRemovalValidatePath(t *testing.T) {
+	type in struct {
+		path string
+	}
+	type out struct {
+		report report.Report
+	}
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in{"/etc/motd"},
+			out{report.Report{}},
+		},
+		{
+			in{"etc/motd"},
+			out{report.ReportFromError(errors.ErrPathRelative, report.EntryError)},
+		},
+		{
+			in{"/"},
+			out{report.ReportFromError(errors.ErrRemoveFilesystemRoot, report.EntryError)},
+		},
+		{
+			in{"/etc/.."},
+			out{report.ReportFromError(errors.ErrRemoveFilesystemRoot, report.EntryError)},
+		},
+	}
+	for i, test := range tests {
+		r := Removal{Path: test.in.path}.ValidatePath()
+		if !reflect.DeepEqual(r, test.out.report) {
+			t.Errorf("#%d: wanted %v, got %v", i, test.out.report, r)
+		}
+	}
+}
+
+func TestRemovalValidateFilesystem(t *testing.T) {
+	type in struct {
+		filesystem string
+	}
+	type out struct {
+		report report.Report
+	}
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in{"root"},
+			out{report.Report{}},
+		},
+		{
+			in{""},
+			out{report.ReportFromError(errors.ErrNoFilesystem, report.EntryError)},
+		},
+	}
+	for i, test := range tests {
+		r := Removal{Filesystem: test.in.filesystem}.ValidateFilesystem()
+		if !reflect.DeepEqual(r, test.out.report) {
+			t.Errorf("#%d: wanted %v, got %v", i, test.out.report, r)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"path/filepath"
+
+	"github.com/flatcar/ignition/config/shared/errors"
+	"github.com/flatcar/ignition/config/validate/report"
+)
+
+func (s Subvolume) ValidatePath() report.Report {
+	if err := validatePath(s.Path); err != nil {
+		return report.ReportFromError(err, report.EntryError)
+	}
+	if filepath.Clean(s.Path) == "/" {
+		return report.ReportFromError(errors.ErrSubvolumePathRoot, report.EntryError)
+	}
+	return report.Report{}
+}
+
+func (s Subvolume) ValidateQgroupLimitMiB() report.Report {
+	if s.QgroupLimitMiB != nil && *s.QgroupLimitMiB <= 0 {
+		return report.ReportFromError(errors.ErrSubvolumeQgroupLimit, report.EntryError)
+	}
+	return report.Report{}
+}
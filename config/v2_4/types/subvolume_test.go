@@ -0,0 +1,96 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flatcar/ignition/config/shared/errors"
+	"github.com/flatcar/ignition/config/validate/report"
+)
+
+func TestSubvolumeValidate(t *testing.T) {
+	type in struct {
+		subvolume Subvolume
+	}
+	type out struct {
+		report report.Report
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{Subvolume{Path: "/docker", QgroupLimitMiB: intToPtr(10240), NoCopyOnWrite: true}},
+			out: out{report.Report{}},
+		},
+		{
+			in:  in{Subvolume{Path: "docker"}},
+			out: out{report.ReportFromError(errors.ErrPathRelative, report.EntryError)},
+		},
+		{
+			in:  in{Subvolume{Path: "/"}},
+			out: out{report.ReportFromError(errors.ErrSubvolumePathRoot, report.EntryError)},
+		},
+		{
+			in:  in{Subvolume{Path: "/log", QgroupLimitMiB: intToPtr(0)}},
+			out: out{report.ReportFromError(errors.ErrSubvolumeQgroupLimit, report.EntryError)},
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.subvolume.ValidatePath()
+		r.Merge(test.in.subvolume.ValidateQgroupLimitMiB())
+		if !reflect.DeepEqual(test.out.report, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, test.out.report, r)
+		}
+	}
+}
+
+func TestMountValidateSubvolumes(t *testing.T) {
+	type in struct {
+		mount Mount
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{Mount{Format: "btrfs", Subvolumes: []Subvolume{{Path: "/docker"}, {Path: "/log"}}}},
+			out: out{},
+		},
+		{
+			in:  in{Mount{Format: "ext4", Subvolumes: []Subvolume{{Path: "/docker"}}}},
+			out: out{err: errors.ErrSubvolumesRequireBtrfs},
+		},
+		{
+			in:  in{Mount{Format: "btrfs", Subvolumes: []Subvolume{{Path: "/docker"}, {Path: "/docker/"}}}},
+			out: out{err: errors.ErrSubvolumePathsCollide},
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.mount.ValidateSubvolumes()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), r) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, r)
+		}
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"path"
 	"strings"
+	"text/template"
 
 	"github.com/coreos/go-systemd/unit"
 
@@ -29,7 +30,18 @@ import (
 func (u Unit) ValidateContents() report.Report {
 	r := report.Report{}
 	opts, err := validateUnitContent(u.Contents)
-	if err != nil {
+	if u.Template {
+		// templates are only valid units once rendered
+		if err := validateTemplate(u.Contents); err != nil {
+			r.Add(report.Entry{
+				Message: err.Error(),
+				Kind:    report.EntryError,
+			})
+		}
+		if err != nil {
+			return r
+		}
+	} else if err != nil {
 		r.Add(report.Entry{
 			Message: err.Error(),
 			Kind:    report.EntryError,
@@ -58,7 +70,14 @@ func (u Unit) ValidateName() report.Report {
 func (d SystemdDropin) Validate() report.Report {
 	r := report.Report{}
 
-	if _, err := validateUnitContent(d.Contents); err != nil {
+	if d.Template {
+		if err := validateTemplate(d.Contents); err != nil {
+			r.Add(report.Entry{
+				Message: err.Error(),
+				Kind:    report.EntryError,
+			})
+		}
+	} else if _, err := validateUnitContent(d.Contents); err != nil {
 		r.Add(report.Entry{
 			Message: err.Error(),
 			Kind:    report.EntryError,
@@ -129,3 +148,10 @@ func validateUnitContent(content string) ([]*unit.UnitOption, error) {
 	}
 	return opts, nil
 }
+
+func validateTemplate(content string) error {
+	if _, err := template.New("").Parse(content); err != nil {
+		return fmt.Errorf("invalid template: %s", err)
+	}
+	return nil
+}
@@ -47,6 +47,18 @@ func TestSystemdUnitValidateContents(t *testing.T) {
 			in:  in{unit: Unit{Name: "test.service", Contents: "", Dropins: []SystemdDropin{{}}}},
 			out: out{err: nil},
 		},
+		{
+			in:  in{unit: Unit{Name: "test.service", Contents: "[Foo]\nQux={{ .Metadata.hostname }}", Template: true}},
+			out: out{err: nil},
+		},
+		{
+			in:  in{unit: Unit{Name: "test.service", Contents: "{{ with .Cmdline.foo }}\n[Foo]\nQux={{ . }}\n{{ end }}", Template: true}},
+			out: out{err: nil},
+		},
+		{
+			in:  in{unit: Unit{Name: "test.service", Contents: "[Foo]\nQux={{ .Metadata.hostname", Template: true}},
+			out: out{err: fmt.Errorf("invalid template: template: :2: unclosed action")},
+		},
 	}
 
 	for i, test := range tests {
@@ -111,6 +123,14 @@ func TestSystemdUnitDropInValidate(t *testing.T) {
 			in:  in{unit: SystemdDropin{Name: "test.conf", Contents: "[Foo"}},
 			out: out{err: fmt.Errorf("invalid unit content: unable to find end of section")},
 		},
+		{
+			in:  in{unit: SystemdDropin{Name: "test.conf", Contents: "{{ if .SMBIOS.product_name }}[Foo]{{ end }}", Template: true}},
+			out: out{err: nil},
+		},
+		{
+			in:  in{unit: SystemdDropin{Name: "test.conf", Contents: "[Foo]\nQux={{ end }}", Template: true}},
+			out: out{err: fmt.Errorf("invalid template: template: :2: unexpected {{end}}")},
+		},
 	}
 
 	for i, test := range tests {
@@ -0,0 +1,106 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package v3_x parses configs written against the upstream Ignition 3.x
+// specification (3.0.0 through 3.4.0).
+package v3_x
+
+import (
+	"bytes"
+
+	"github.com/flatcar/ignition/config/shared/errors"
+	"github.com/flatcar/ignition/config/v3_x/types"
+	"github.com/flatcar/ignition/config/validate"
+	"github.com/flatcar/ignition/config/validate/report"
+
+	json "github.com/ajeddeloh/go-json"
+	"github.com/coreos/go-semver/semver"
+	"go4.org/errorutil"
+)
+
+// Detect returns true if the raw config is a JSON document declaring a 3.x
+// spec version. It doesn't validate the rest of the config.
+func Detect(rawConfig []byte) bool {
+	var config struct {
+		Ignition struct {
+			Version string `json:"version"`
+		} `json:"ignition"`
+	}
+	if err := json.Unmarshal(rawConfig, &config); err != nil {
+		return false
+	}
+	version, err := semver.NewVersion(config.Ignition.Version)
+	if err != nil {
+		return false
+	}
+	return version.Major == types.MaxVersion.Major
+}
+
+// Parse parses the raw config into a types.Config struct and generates a report of any
+// errors, warnings, info, and deprecations it encountered
+func Parse(rawConfig []byte) (types.Config, report.Report, error) {
+	if isEmpty(rawConfig) {
+		return types.Config{}, report.Report{}, errors.ErrEmpty
+	} else if isCloudConfig(rawConfig) {
+		return types.Config{}, report.Report{}, errors.ErrCloudConfig
+	} else if isScript(rawConfig) {
+		return types.Config{}, report.Report{}, errors.ErrScript
+	}
+
+	var config types.Config
+	if err := json.Unmarshal(rawConfig, &config); err != nil {
+		return types.Config{}, handleParseErrors(rawConfig, err), errors.ErrInvalid
+	}
+
+	version, err := semver.NewVersion(config.Ignition.Version)
+	if err != nil || version.Major != types.MaxVersion.Major ||
+		version.PreRelease != "" || types.MaxVersion.LessThan(*version) {
+		return types.Config{}, report.Report{}, errors.ErrUnknownVersion
+	}
+
+	rpt := validate.ValidateConfig(rawConfig, config)
+	if rpt.IsFatal() {
+		return types.Config{}, rpt, errors.ErrInvalid
+	}
+
+	return config, rpt, nil
+}
+
+// handleParseErrors converts json syntax and type errors into a report with
+// offset information.
+func handleParseErrors(rawConfig []byte, err error) report.Report {
+	var offset int64
+	switch jerr := err.(type) {
+	case *json.SyntaxError:
+		offset = jerr.Offset
+	case *json.UnmarshalTypeError:
+		offset = jerr.Offset
+	default:
+		return report.ReportFromError(err, report.EntryError)
+	}
+	line, col, highlight := errorutil.HighlightBytePosition(bytes.NewReader(rawConfig), offset)
+	return report.Report{
+		Entries: []report.Entry{{
+			Kind:      report.EntryError,
+			Message:   err.Error(),
+			Line:      line,
+			Column:    col,
+			Highlight: highlight,
+		}},
+	}
+}
+
+func isEmpty(userdata []byte) bool {
+	return len(userdata) == 0
+}
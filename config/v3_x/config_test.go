@@ -0,0 +1,136 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3_x
+
+import (
+	"testing"
+
+	"github.com/flatcar/ignition/config/shared/errors"
+	"github.com/flatcar/ignition/config/v3_x/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func strToPtr(s string) *string {
+	return &s
+}
+
+func TestParse(t *testing.T) {
+	type in struct {
+		config []byte
+	}
+	type out struct {
+		config types.Config
+		err    error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{config: []byte(`{"ignition": {"version": "3.0.0"}}`)},
+			out: out{config: types.Config{Ignition: types.Ignition{Version: "3.0.0"}}},
+		},
+		{
+			in:  in{config: []byte(`{"ignition": {"version": "3.4.0"}}`)},
+			out: out{config: types.Config{Ignition: types.Ignition{Version: "3.4.0"}}},
+		},
+		{
+			in:  in{config: []byte(`{"ignition": {"version": "3.5.0"}}`)},
+			out: out{err: errors.ErrUnknownVersion},
+		},
+		{
+			in:  in{config: []byte(`{"ignition": {"version": "3.4.0-experimental"}}`)},
+			out: out{err: errors.ErrUnknownVersion},
+		},
+		{
+			in:  in{config: []byte(`{"ignition": {"version": "2.4.0"}}`)},
+			out: out{err: errors.ErrUnknownVersion},
+		},
+		{
+			in:  in{config: []byte(`{"ignition": {"version": "3.0.0"},}`)},
+			out: out{err: errors.ErrInvalid},
+		},
+		{
+			in:  in{config: []byte(`{"ignition": {"version": "3.0.0"}, "storage": {"files": [{"path": "/a", "append": true}]}}`)},
+			out: out{err: errors.ErrInvalid},
+		},
+		{
+			in: in{config: []byte(`{"ignition": {"version": "3.1.0"}, "storage": {"files": [{"path": "/a", "contents": {"source": "data:,a"}}]}}`)},
+			out: out{config: types.Config{
+				Ignition: types.Ignition{Version: "3.1.0"},
+				Storage: types.Storage{
+					Files: []types.File{{
+						Node: types.Node{Path: "/a"},
+						FileEmbedded1: types.FileEmbedded1{
+							Contents: types.Resource{Source: strToPtr("data:,a")},
+						},
+					}},
+				},
+			}},
+		},
+		{
+			in:  in{config: []byte(`{"ignition": {"version": "3.1.0"}, "storage": {"luks": [{"name": "data", "device": "/dev/sdb"}]}}`)},
+			out: out{err: errors.ErrInvalid},
+		},
+		{
+			in:  in{config: []byte(`{"ignition": {"version": "3.2.0"}, "storage": {"files": [{"path": "/a"}, {"path": "/a"}]}}`)},
+			out: out{err: errors.ErrInvalid},
+		},
+		{
+			in:  in{config: []byte{}},
+			out: out{err: errors.ErrEmpty},
+		},
+		{
+			in:  in{config: []byte("#cloud-config")},
+			out: out{err: errors.ErrCloudConfig},
+		},
+		{
+			in:  in{config: []byte("#!/bin/sh")},
+			out: out{err: errors.ErrScript},
+		},
+	}
+
+	for i, test := range tests {
+		config, report, err := Parse(test.in.config)
+		if test.out.err != err {
+			t.Errorf("#%d: bad error: want %v, got %v, report: %+v", i, test.out.err, err, report)
+		}
+		assert.Equal(t, test.out.config, config, "#%d: bad config, report: %+v", i, report)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		in  string
+		out bool
+	}{
+		{`{"ignition": {"version": "3.0.0"}}`, true},
+		{`{"ignition": {"version": "3.3.0"}, "storage": {}}`, true},
+		{`{"ignition": {"version": "3.9.0"}}`, true},
+		{`{"ignition": {"version": "2.4.0"}}`, false},
+		{`{"ignitionVersion": 1}`, false},
+		{`{"ignition": {"version": 3}}`, false},
+		{`#cloud-config`, false},
+		{``, false},
+	}
+
+	for i, test := range tests {
+		if got := Detect([]byte(test.in)); got != test.out {
+			t.Errorf("#%d: want %t, got %t", i, test.out, got)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+
+	"github.com/coreos/go-semver/semver"
+
+	"github.com/flatcar/ignition/config/validate/report"
+)
+
+var (
+	// MinVersion is the oldest 3.x spec version that is understood.
+	MinVersion = semver.Version{
+		Major: 3,
+		Minor: 0,
+	}
+	// MaxVersion is the newest 3.x spec version that is understood.
+	MaxVersion = semver.Version{
+		Major: 3,
+		Minor: 4,
+	}
+)
+
+func (c Config) Validate() report.Report {
+	r := report.Report{}
+	rules := []rule{
+		checkVersionFeatures,
+		checkDuplicateNodes,
+		checkDuplicateUnits,
+		checkDuplicateFilesystems,
+		checkDuplicatePasswd,
+		checkDuplicateStorageDevices,
+	}
+
+	for _, rule := range rules {
+		rule(c, &r)
+	}
+	return r
+}
+
+type rule func(cfg Config, report *report.Report)
+
+func addDuplicateError(r *report.Report, kind, key string) {
+	r.Add(report.Entry{
+		Kind:    report.EntryError,
+		Message: fmt.Sprintf("%s %q is specified more than once", kind, key),
+	})
+}
+
+func checkDuplicateNodes(cfg Config, r *report.Report) {
+	paths := map[string]struct{}{}
+	check := func(path string) {
+		if _, ok := paths[path]; ok {
+			addDuplicateError(r, "path", path)
+		}
+		paths[path] = struct{}{}
+	}
+	for _, f := range cfg.Storage.Files {
+		check(f.Path)
+	}
+	for _, d := range cfg.Storage.Directories {
+		check(d.Path)
+	}
+	for _, l := range cfg.Storage.Links {
+		check(l.Path)
+	}
+}
+
+func checkDuplicateUnits(cfg Config, r *report.Report) {
+	units := map[string]struct{}{}
+	for _, u := range cfg.Systemd.Units {
+		if _, ok := units[u.Name]; ok {
+			addDuplicateError(r, "unit", u.Name)
+		}
+		units[u.Name] = struct{}{}
+
+		dropins := map[string]struct{}{}
+		for _, d := range u.Dropins {
+			if _, ok := dropins[d.Name]; ok {
+				addDuplicateError(r, "dropin", u.Name+"/"+d.Name)
+			}
+			dropins[d.Name] = struct{}{}
+		}
+	}
+}
+
+func checkDuplicateFilesystems(cfg Config, r *report.Report) {
+	devices := map[string]struct{}{}
+	paths := map[string]struct{}{}
+	for _, fs := range cfg.Storage.Filesystems {
+		if _, ok := devices[fs.Device]; ok {
+			addDuplicateError(r, "filesystem device", fs.Device)
+		}
+		devices[fs.Device] = struct{}{}
+		if fs.Path != nil {
+			if _, ok := paths[*fs.Path]; ok {
+				addDuplicateError(r, "filesystem path", *fs.Path)
+			}
+			paths[*fs.Path] = struct{}{}
+		}
+	}
+}
+
+func checkDuplicatePasswd(cfg Config, r *report.Report) {
+	users := map[string]struct{}{}
+	for _, u := range cfg.Passwd.Users {
+		if _, ok := users[u.Name]; ok {
+			addDuplicateError(r, "user", u.Name)
+		}
+		users[u.Name] = struct{}{}
+	}
+	groups := map[string]struct{}{}
+	for _, g := range cfg.Passwd.Groups {
+		if _, ok := groups[g.Name]; ok {
+			addDuplicateError(r, "group", g.Name)
+		}
+		groups[g.Name] = struct{}{}
+	}
+}
+
+func checkDuplicateStorageDevices(cfg Config, r *report.Report) {
+	disks := map[string]struct{}{}
+	for _, d := range cfg.Storage.Disks {
+		if _, ok := disks[d.Device]; ok {
+			addDuplicateError(r, "disk", d.Device)
+		}
+		disks[d.Device] = struct{}{}
+	}
+	raids := map[string]struct{}{}
+	for _, a := range cfg.Storage.Raid {
+		if _, ok := raids[a.Name]; ok {
+			addDuplicateError(r, "raid", a.Name)
+		}
+		raids[a.Name] = struct{}{}
+	}
+	luks := map[string]struct{}{}
+	for _, l := range cfg.Storage.Luks {
+		if _, ok := luks[l.Name]; ok {
+			addDuplicateError(r, "luks device", l.Name)
+		}
+		luks[l.Name] = struct{}{}
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flatcar/ignition/config/validate/report"
+)
+
+func strToPtr(s string) *string {
+	return &s
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		in  Config
+		out report.Report
+	}{
+		{
+			in:  Config{Ignition: Ignition{Version: "3.0.0"}},
+			out: report.Report{},
+		},
+		{
+			in: Config{
+				Ignition: Ignition{Version: "3.2.0"},
+				KernelArguments: KernelArguments{
+					ShouldExist: []KernelArgument{"quiet"},
+				},
+			},
+			out: report.Report{Entries: []report.Entry{{
+				Kind:    report.EntryError,
+				Message: "kernelArguments requires spec version 3.3.0 or newer (config is version 3.2.0)",
+			}}},
+		},
+		{
+			in: Config{
+				Ignition: Ignition{Version: "3.3.0"},
+				KernelArguments: KernelArguments{
+					ShouldExist: []KernelArgument{"quiet"},
+				},
+			},
+			out: report.Report{},
+		},
+		{
+			in: Config{
+				Ignition: Ignition{
+					Version: "3.0.0",
+					Proxy:   Proxy{HTTPProxy: strToPtr("http://proxy")},
+				},
+			},
+			out: report.Report{Entries: []report.Entry{{
+				Kind:    report.EntryError,
+				Message: "ignition.proxy requires spec version 3.1.0 or newer (config is version 3.0.0)",
+			}}},
+		},
+		{
+			in: Config{
+				Ignition: Ignition{Version: "3.0.0"},
+				Systemd: Systemd{
+					Units: []Unit{{Name: "a.service"}, {Name: "a.service"}},
+				},
+			},
+			out: report.Report{Entries: []report.Entry{{
+				Kind:    report.EntryError,
+				Message: `unit "a.service" is specified more than once`,
+			}}},
+		},
+		{
+			in: Config{
+				Ignition: Ignition{Version: "3.0.0"},
+				Storage: Storage{
+					Files:       []File{{Node: Node{Path: "/etc/a"}}},
+					Directories: []Directory{{Node: Node{Path: "/etc/a"}}},
+				},
+			},
+			out: report.Report{Entries: []report.Entry{{
+				Kind:    report.EntryError,
+				Message: `path "/etc/a" is specified more than once`,
+			}}},
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate()
+		if !reflect.DeepEqual(test.out, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, test.out, r)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"github.com/flatcar/ignition/config/shared/errors"
+	"github.com/flatcar/ignition/config/validate/report"
+)
+
+func (n Disk) ValidateDevice() report.Report {
+	if len(n.Device) == 0 {
+		return report.ReportFromError(errors.ErrDiskDeviceRequired, report.EntryError)
+	}
+	if err := validatePath(n.Device); err != nil {
+		return report.ReportFromError(err, report.EntryError)
+	}
+	return report.Report{}
+}
+
+func (n Disk) ValidatePartitions() report.Report {
+	r := report.Report{}
+	if n.partitionNumbersCollide() {
+		r.Add(report.Entry{
+			Message: errors.ErrPartitionNumbersCollide.Error(),
+			Kind:    report.EntryError,
+		})
+	}
+	if n.partitionsOverlap() {
+		r.Add(report.Entry{
+			Message: errors.ErrPartitionsOverlap.Error(),
+			Kind:    report.EntryError,
+		})
+	}
+	if n.partitionsMixZeroesAndNonexistence() {
+		r.Add(report.Entry{
+			Message: errors.ErrZeroesWithShouldNotExist.Error(),
+			Kind:    report.EntryError,
+		})
+	}
+	return r
+}
+
+// partitionNumbersCollide returns true if partition numbers in n.Partitions are not unique.
+func (n Disk) partitionNumbersCollide() bool {
+	m := map[int]int{}
+	for _, p := range n.Partitions {
+		if p.Number != 0 {
+			// a number of 0 means next available number, multiple devices can specify this
+			m[p.Number]++
+		}
+	}
+	for _, count := range m {
+		if count > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// end returns the last MiB of a partition. Only used by partitionsOverlap.
+// Requires non-nil StartMiB and SizeMiB.
+func (p Partition) end() int {
+	if *p.SizeMiB == 0 {
+		// a size of 0 means "fill available", just return the start as the end for those.
+		return *p.StartMiB
+	}
+	return *p.StartMiB + *p.SizeMiB - 1
+}
+
+// partitionsOverlap returns true if any explicitly dimensioned partitions overlap
+func (n Disk) partitionsOverlap() bool {
+	for i, p := range n.Partitions {
+		// Starts of 0 are placed by sgdisk into the "largest available block" at that time.
+		// We aren't going to check those for overlap since we don't have the disk geometry.
+		if p.StartMiB == nil || p.SizeMiB == nil || *p.StartMiB == 0 {
+			continue
+		}
+
+		for j, o := range n.Partitions {
+			if o.StartMiB == nil || o.SizeMiB == nil || i == j || *o.StartMiB == 0 {
+				continue
+			}
+
+			// is p.StartMiB within o?
+			if *p.StartMiB >= *o.StartMiB && *p.StartMiB <= o.end() {
+				return true
+			}
+
+			// is p.end() within o?
+			if p.end() >= *o.StartMiB && p.end() <= o.end() {
+				return true
+			}
+
+			// do p.StartMiB and p.end() straddle o?
+			if *p.StartMiB < *o.StartMiB && p.end() > o.end() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (n Disk) partitionsMixZeroesAndNonexistence() bool {
+	hasZero := false
+	hasShouldNotExist := false
+	for _, p := range n.Partitions {
+		hasShouldNotExist = hasShouldNotExist || (p.ShouldExist != nil && !*p.ShouldExist)
+		hasZero = hasZero || (p.Number == 0)
+	}
+	return hasZero && hasShouldNotExist
+}
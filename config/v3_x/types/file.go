@@ -0,0 +1,55 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"github.com/flatcar/ignition/config/shared/errors"
+	"github.com/flatcar/ignition/config/validate/report"
+)
+
+func (f File) Validate() report.Report {
+	r := report.Report{}
+	if f.Overwrite != nil && *f.Overwrite && f.Contents.Source == nil {
+		r.Add(report.Entry{
+			Message: errors.ErrOverwriteAndNilSource.Error(),
+			Kind:    report.EntryError,
+		})
+	}
+	return r
+}
+
+func (f File) ValidateMode() report.Report {
+	r := report.Report{}
+	if err := validateMode(f.Mode); err != nil {
+		r.Add(report.Entry{
+			Message: err.Error(),
+			Kind:    report.EntryError,
+		})
+	}
+	return r
+}
+
+func (f File) ValidateAppend() report.Report {
+	r := report.Report{}
+	for _, res := range f.Append {
+		if res.Source == nil {
+			r.Add(report.Entry{
+				Message: errors.ErrSourceRequired.Error(),
+				Kind:    report.EntryError,
+			})
+		}
+	}
+	return r
+}
@@ -0,0 +1,114 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"github.com/flatcar/ignition/config/shared/errors"
+	"github.com/flatcar/ignition/config/validate/report"
+)
+
+func (f Filesystem) ValidateDevice() report.Report {
+	if len(f.Device) == 0 {
+		return report.ReportFromError(errors.ErrDiskDeviceRequired, report.EntryError)
+	}
+	if err := validatePath(f.Device); err != nil {
+		return report.ReportFromError(err, report.EntryError)
+	}
+	return report.Report{}
+}
+
+func (f Filesystem) ValidateFormat() report.Report {
+	r := report.Report{}
+	if f.Format == nil {
+		if f.Path != nil || f.Label != nil || f.UUID != nil || len(f.Options) > 0 {
+			r.Add(report.Entry{
+				Message: errors.ErrFilesystemInvalidFormat.Error(),
+				Kind:    report.EntryError,
+			})
+		}
+		return r
+	}
+	switch *f.Format {
+	case "ext4", "btrfs", "xfs", "swap", "vfat", "none":
+	default:
+		r.Add(report.Entry{
+			Message: errors.ErrFilesystemInvalidFormat.Error(),
+			Kind:    report.EntryError,
+		})
+	}
+	return r
+}
+
+func (f Filesystem) ValidatePath() report.Report {
+	r := report.Report{}
+	if f.Path == nil {
+		return r
+	}
+	if err := validatePath(*f.Path); err != nil {
+		r.Add(report.Entry{
+			Message: err.Error(),
+			Kind:    report.EntryError,
+		})
+	}
+	if f.Format != nil && (*f.Format == "swap" || *f.Format == "none") {
+		r.Add(report.Entry{
+			Message: errors.ErrFilesystemNotMountable.Error(),
+			Kind:    report.EntryError,
+		})
+	}
+	return r
+}
+
+func (f Filesystem) ValidateLabel() report.Report {
+	r := report.Report{}
+	if f.Label == nil || f.Format == nil {
+		return r
+	}
+	var err error
+	switch *f.Format {
+	case "ext4":
+		// source: man mkfs.ext4
+		if len(*f.Label) > 16 {
+			err = errors.ErrExt4LabelTooLong
+		}
+	case "btrfs":
+		// source: man mkfs.btrfs
+		if len(*f.Label) > 256 {
+			err = errors.ErrBtrfsLabelTooLong
+		}
+	case "xfs":
+		// source: man mkfs.xfs
+		if len(*f.Label) > 12 {
+			err = errors.ErrXfsLabelTooLong
+		}
+	case "swap":
+		// mkswap truncates long labels to 15 characters
+		if len(*f.Label) > 15 {
+			err = errors.ErrSwapLabelTooLong
+		}
+	case "vfat":
+		// source: man mkfs.fat
+		if len(*f.Label) > 11 {
+			err = errors.ErrVfatLabelTooLong
+		}
+	}
+	if err != nil {
+		r.Add(report.Entry{
+			Message: err.Error(),
+			Kind:    report.EntryError,
+		})
+	}
+	return r
+}
@@ -0,0 +1,63 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"github.com/coreos/go-semver/semver"
+
+	"github.com/flatcar/ignition/config/shared/errors"
+	"github.com/flatcar/ignition/config/validate/report"
+)
+
+func (v Ignition) Semver() (*semver.Version, error) {
+	return semver.NewVersion(v.Version)
+}
+
+func (v Ignition) Validate() report.Report {
+	tv, err := v.Semver()
+	if err != nil {
+		return report.ReportFromError(errors.ErrInvalidVersion, report.EntryError)
+	}
+	if tv.LessThan(MinVersion) {
+		return report.ReportFromError(errors.ErrOldVersion, report.EntryError)
+	}
+	if MaxVersion.LessThan(*tv) {
+		return report.ReportFromError(errors.ErrNewVersion, report.EntryError)
+	}
+	if tv.PreRelease != "" || tv.Patch != 0 {
+		return report.ReportFromError(errors.ErrUnknownVersion, report.EntryError)
+	}
+	return report.Report{}
+}
+
+func (c IgnitionConfig) ValidateReplace() report.Report {
+	if c.Replace.Source == nil && (c.Replace.Verification.Hash != nil || len(c.Replace.HTTPHeaders) > 0 || c.Replace.Compression != nil) {
+		return report.ReportFromError(errors.ErrSourceRequired, report.EntryError)
+	}
+	return report.Report{}
+}
+
+func (c IgnitionConfig) ValidateMerge() report.Report {
+	r := report.Report{}
+	for _, m := range c.Merge {
+		if m.Source == nil {
+			r.Add(report.Entry{
+				Message: errors.ErrSourceRequired.Error(),
+				Kind:    report.EntryError,
+			})
+		}
+	}
+	return r
+}
@@ -0,0 +1,40 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"github.com/flatcar/ignition/config/shared/errors"
+	"github.com/flatcar/ignition/config/validate/report"
+)
+
+func (l Link) ValidateTarget() report.Report {
+	r := report.Report{}
+	if l.Target == nil || *l.Target == "" {
+		r.Add(report.Entry{
+			Message: errors.ErrLinkTargetRequired.Error(),
+			Kind:    report.EntryError,
+		})
+		return r
+	}
+	if l.Hard != nil && *l.Hard {
+		if err := validatePath(*l.Target); err != nil {
+			r.Add(report.Entry{
+				Message: err.Error(),
+				Kind:    report.EntryError,
+			})
+		}
+	}
+	return r
+}
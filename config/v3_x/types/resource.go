@@ -0,0 +1,89 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/flatcar/ignition/config/shared/errors"
+	"github.com/flatcar/ignition/config/validate/report"
+)
+
+func (res Resource) ValidateCompression() report.Report {
+	r := report.Report{}
+	if res.Compression == nil {
+		return r
+	}
+	switch *res.Compression {
+	case "", "gzip":
+	default:
+		r.Add(report.Entry{
+			Message: errors.ErrCompressionInvalid.Error(),
+			Kind:    report.EntryError,
+		})
+	}
+	return r
+}
+
+func (res Resource) ValidateSource() report.Report {
+	r := report.Report{}
+	if res.Source == nil {
+		return r
+	}
+	if err := validateURL(*res.Source); err != nil {
+		r.Add(report.Entry{
+			Message: fmt.Sprintf("invalid url %q: %v", *res.Source, err),
+			Kind:    report.EntryError,
+		})
+	}
+	return r
+}
+
+func (res Resource) ValidateHTTPHeaders() report.Report {
+	r := report.Report{}
+
+	if len(res.HTTPHeaders) < 1 {
+		return r
+	}
+
+	if res.Source == nil {
+		r.Add(report.Entry{
+			Message: errors.ErrUnsupportedSchemeForHTTPHeaders.Error(),
+			Kind:    report.EntryError,
+		})
+		return r
+	}
+
+	u, err := url.Parse(*res.Source)
+	if err != nil {
+		r.Add(report.Entry{
+			Message: errors.ErrInvalidUrl.Error(),
+			Kind:    report.EntryError,
+		})
+		return r
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+	default:
+		r.Add(report.Entry{
+			Message: errors.ErrUnsupportedSchemeForHTTPHeaders.Error(),
+			Kind:    report.EntryError,
+		})
+	}
+
+	return r
+}
@@ -0,0 +1,169 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+
+	"github.com/coreos/go-semver/semver"
+
+	"github.com/flatcar/ignition/config/validate/report"
+)
+
+// versionFeature is a field that was added to the spec after 3.0.0. Its check
+// returns true if the field is in use.
+type versionFeature struct {
+	name  string
+	minor int64
+	used  func(cfg Config) bool
+}
+
+var versionFeatures = []versionFeature{
+	{"ignition.proxy", 1, func(c Config) bool {
+		p := c.Ignition.Proxy
+		return p.HTTPProxy != nil || p.HTTPSProxy != nil || len(p.NoProxy) > 0
+	}},
+	{"httpHeaders", 1, func(c Config) bool {
+		return anyResource(c, func(res Resource) bool { return len(res.HTTPHeaders) > 0 })
+	}},
+	{"compression of config and certificate references", 1, func(c Config) bool {
+		for _, res := range configReferences(c) {
+			if res.Compression != nil {
+				return true
+			}
+		}
+		return false
+	}},
+	{"sha256 verification hashes", 1, func(c Config) bool {
+		return anyResource(c, func(res Resource) bool {
+			function, _, err := res.Verification.HashParts()
+			return err == nil && function == "sha256"
+		})
+	}},
+	{"filesystem mountOptions", 1, func(c Config) bool {
+		for _, fs := range c.Storage.Filesystems {
+			if len(fs.MountOptions) > 0 {
+				return true
+			}
+		}
+		return false
+	}},
+	{"filesystem format \"none\"", 1, func(c Config) bool {
+		for _, fs := range c.Storage.Filesystems {
+			if fs.Format != nil && *fs.Format == "none" {
+				return true
+			}
+		}
+		return false
+	}},
+	{"storage.luks", 2, func(c Config) bool {
+		return len(c.Storage.Luks) > 0
+	}},
+	{"partition resize", 2, func(c Config) bool {
+		for _, d := range c.Storage.Disks {
+			for _, p := range d.Partitions {
+				if p.Resize != nil {
+					return true
+				}
+			}
+		}
+		return false
+	}},
+	{"passwd shouldExist", 2, func(c Config) bool {
+		for _, u := range c.Passwd.Users {
+			if u.ShouldExist != nil {
+				return true
+			}
+		}
+		for _, g := range c.Passwd.Groups {
+			if g.ShouldExist != nil {
+				return true
+			}
+		}
+		return false
+	}},
+	{"kernelArguments", 3, func(c Config) bool {
+		return len(c.KernelArguments.ShouldExist) > 0 || len(c.KernelArguments.ShouldNotExist) > 0
+	}},
+	{"luks discard and openOptions", 4, func(c Config) bool {
+		for _, l := range c.Storage.Luks {
+			if l.Discard != nil || len(l.OpenOptions) > 0 {
+				return true
+			}
+		}
+		return false
+	}},
+	{"tang advertisement", 4, func(c Config) bool {
+		for _, l := range c.Storage.Luks {
+			for _, t := range l.Clevis.Tang {
+				if t.Advertisement != nil {
+					return true
+				}
+			}
+		}
+		return false
+	}},
+}
+
+// checkVersionFeatures reports fields that are not part of the spec version
+// declared by the config.
+func checkVersionFeatures(cfg Config, r *report.Report) {
+	version, err := cfg.Ignition.Semver()
+	if err != nil {
+		// reported by Ignition.Validate
+		return
+	}
+	for _, f := range versionFeatures {
+		if version.Minor < f.minor && f.used(cfg) {
+			r.Add(report.Entry{
+				Kind: report.EntryError,
+				Message: fmt.Sprintf("%s requires spec version %s or newer (config is version %s)",
+					f.name, semver.Version{Major: 3, Minor: f.minor}, version),
+			})
+		}
+	}
+}
+
+// configReferences returns the resources in the ignition section of the
+// config.
+func configReferences(c Config) []Resource {
+	refs := append([]Resource{c.Ignition.Config.Replace}, c.Ignition.Config.Merge...)
+	return append(refs, c.Ignition.Security.TLS.CertificateAuthorities...)
+}
+
+// anyResource returns true if pred is true for any resource in the config.
+func anyResource(c Config, pred func(Resource) bool) bool {
+	for _, res := range configReferences(c) {
+		if pred(res) {
+			return true
+		}
+	}
+	for _, f := range c.Storage.Files {
+		if pred(f.Contents) {
+			return true
+		}
+		for _, res := range f.Append {
+			if pred(res) {
+				return true
+			}
+		}
+	}
+	for _, l := range c.Storage.Luks {
+		if pred(l.KeyFile) {
+			return true
+		}
+	}
+	return false
+}
@@ -15,15 +15,39 @@
 package config
 
 import (
+	"github.com/flatcar/ignition/config/shared/errors"
 	currentExperimental "github.com/flatcar/ignition/config/v2_4"
+	"github.com/flatcar/ignition/config/v3_x"
 	"github.com/flatcar/ignition/config/validate/report"
 	"github.com/flatcar/ignition/internal/config/types"
 )
 
+// Parse parses a config of any supported spec version and translates it into
+// the internal config. Configs declaring a 3.x version are handled by
+// ParseV3.
 func Parse(rawConfig []byte) (types.Config, report.Report, error) {
+	if v3_x.Detect(rawConfig) {
+		return ParseV3(rawConfig)
+	}
 	cfg, rpt, err := currentExperimental.Parse(rawConfig)
 	if err != nil || rpt.IsFatal() {
 		return types.Config{}, rpt, err
 	}
 	return Translate(cfg), rpt, nil
 }
+
+// ParseV3 parses a 3.x config and translates it into the internal config.
+// Features without a 2.x equivalent are reported as errors and make the config
+// invalid.
+func ParseV3(rawConfig []byte) (types.Config, report.Report, error) {
+	cfg, rpt, err := v3_x.Parse(rawConfig)
+	if err != nil || rpt.IsFatal() {
+		return types.Config{}, rpt, err
+	}
+	translated, translateRpt := TranslateV3(cfg)
+	rpt.Merge(translateRpt)
+	if rpt.IsFatal() {
+		return types.Config{}, rpt, errors.ErrInvalid
+	}
+	return translated, rpt, nil
+}
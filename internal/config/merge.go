@@ -0,0 +1,304 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"reflect"
+	"strconv"
+
+	"github.com/coreos/go-semver/semver"
+
+	"github.com/flatcar/ignition/internal/config/types"
+)
+
+// keyedMergeMajorVersion is the first spec major version whose configs are
+// combined with Merge instead of Append.
+const keyedMergeMajorVersion = 3
+
+// UsesKeyedMerge reports whether configs declaring the given spec version opt
+// into key-aware merging.
+func UsesKeyedMerge(version string) bool {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return false
+	}
+	return v.Major >= keyedMergeMajorVersion
+}
+
+// Combine combines newConfig with oldConfig. If either config declares a spec
+// version that opts into key-aware merging, the configs are merged with Merge.
+// Otherwise newConfig is appended to oldConfig with Append.
+func Combine(oldConfig, newConfig types.Config) types.Config {
+	if UsesKeyedMerge(oldConfig.Ignition.Version) || UsesKeyedMerge(newConfig.Ignition.Version) {
+		return Merge(oldConfig, newConfig)
+	}
+	return Append(oldConfig, newConfig)
+}
+
+// Merge merges newConfig into oldConfig and returns the result. Unlike Append,
+// list entries that are identified by a key (e.g. the path of a file or the
+// name of a unit) are merged with the entry of the same key in oldConfig
+// instead of being added a second time:
+//
+//   - Fields that are set in the new entry replace the old value. Unset fields
+//     (nil pointers, empty strings, zero numbers and false) keep the old value.
+//   - Nested structs and keyed lists are merged recursively.
+//   - Lists of values that describe a set (e.g. SSH keys) are combined without
+//     duplicates. All other lists of values are replaced if set.
+//   - Files, directories and links share one key space. A node replaces an
+//     older node of a different type at the same path.
+//
+// Entries without a key, like content appended to a file or edits of a file,
+// are always added.
+// The version of the result is the version of the config that opted into
+// key-aware merging.
+func Merge(oldConfig, newConfig types.Config) types.Config {
+	oldConfig.Storage = removeReplacedNodes(oldConfig.Storage, newConfig.Storage)
+
+	res := mergeStruct(reflect.ValueOf(oldConfig), reflect.ValueOf(newConfig)).Interface().(types.Config)
+	res.Ignition.Version = oldConfig.Ignition.Version
+	if !UsesKeyedMerge(oldConfig.Ignition.Version) {
+		res.Ignition.Version = newConfig.Ignition.Version
+	}
+	return res
+}
+
+// mergeKeys maps the types of keyed list entries to a function returning their
+// key. Entries with an empty key are never merged.
+var mergeKeys = map[reflect.Type]func(reflect.Value) string{
+	reflect.TypeOf(types.File{}): func(v reflect.Value) string {
+		f := v.Interface().(types.File)
+		if f.Append || len(f.Edits) > 0 {
+			return ""
+		}
+		return nodeKey(f.Node)
+	},
+	reflect.TypeOf(types.Directory{}): func(v reflect.Value) string {
+		return nodeKey(v.Interface().(types.Directory).Node)
+	},
+	reflect.TypeOf(types.Link{}): func(v reflect.Value) string {
+		return nodeKey(v.Interface().(types.Link).Node)
+	},
+	reflect.TypeOf(types.Archive{}): func(v reflect.Value) string {
+		return nodeKey(v.Interface().(types.Archive).Node)
+	},
+	reflect.TypeOf(types.Removal{}): func(v reflect.Value) string {
+		r := v.Interface().(types.Removal)
+		return nodeKey(types.Node{Filesystem: r.Filesystem, Path: r.Path})
+	},
+	reflect.TypeOf(types.Disk{}):            fieldKey("Device"),
+	reflect.TypeOf(types.Raid{}):            fieldKey("Name"),
+	reflect.TypeOf(types.Luks{}):            fieldKey("Name"),
+	reflect.TypeOf(types.VolumeGroup{}):     fieldKey("Name"),
+	reflect.TypeOf(types.LogicalVolume{}):   fieldKey("Name"),
+	reflect.TypeOf(types.Filesystem{}):      fieldKey("Name"),
+	reflect.TypeOf(types.Unit{}):            fieldKey("Name"),
+	reflect.TypeOf(types.SystemdDropin{}):   fieldKey("Name"),
+	reflect.TypeOf(types.Networkdunit{}):    fieldKey("Name"),
+	reflect.TypeOf(types.NetworkdDropin{}):  fieldKey("Name"),
+	reflect.TypeOf(types.PasswdUser{}):      fieldKey("Name"),
+	reflect.TypeOf(types.PasswdGroup{}):     fieldKey("Name"),
+	reflect.TypeOf(types.HTTPHeader{}):      fieldKey("Name"),
+	reflect.TypeOf(types.NodeXattr{}):       fieldKey("Name"),
+	reflect.TypeOf(types.ConfigReference{}): fieldKey("Source"),
+	reflect.TypeOf(types.CaReference{}):     fieldKey("Source"),
+	reflect.TypeOf(types.Partition{}): func(v reflect.Value) string {
+		p := v.Interface().(types.Partition)
+		if p.Number != 0 {
+			return "number:" + strconv.Itoa(p.Number)
+		}
+		if p.Label != nil && *p.Label != "" {
+			return "label:" + *p.Label
+		}
+		return ""
+	},
+}
+
+// mergeSets lists the types of lists that are combined as sets.
+var mergeSets = map[reflect.Type]bool{
+	reflect.TypeOf([]types.SSHAuthorizedKey{}): true,
+	reflect.TypeOf([]types.Group{}):            true,
+	reflect.TypeOf([]types.UsercreateGroup{}):  true,
+	reflect.TypeOf([]types.NoProxyItem{}):      true,
+}
+
+// mergeAtomic lists the types of structs that are replaced as a whole if set,
+// since mixing their fields would describe something neither config asked for.
+var mergeAtomic = map[reflect.Type]bool{
+	reflect.TypeOf(types.FileContents{}): true,
+}
+
+func fieldKey(name string) func(reflect.Value) string {
+	return func(v reflect.Value) string {
+		return v.FieldByName(name).String()
+	}
+}
+
+func nodeKey(n types.Node) string {
+	if n.Path == "" {
+		return ""
+	}
+	return n.Filesystem + ":" + n.Path
+}
+
+// removeReplacedNodes removes nodes from oldStorage which are replaced by a
+// node of a different type in newStorage.
+func removeReplacedNodes(oldStorage, newStorage types.Storage) types.Storage {
+	files := map[string]bool{}
+	dirs := map[string]bool{}
+	links := map[string]bool{}
+	for _, f := range newStorage.Files {
+		if !f.Append && len(f.Edits) == 0 {
+			files[nodeKey(f.Node)] = true
+		}
+	}
+	for _, d := range newStorage.Directories {
+		dirs[nodeKey(d.Node)] = true
+	}
+	for _, l := range newStorage.Links {
+		links[nodeKey(l.Node)] = true
+	}
+
+	var resFiles []types.File
+	for _, f := range oldStorage.Files {
+		if key := nodeKey(f.Node); !dirs[key] && !links[key] {
+			resFiles = append(resFiles, f)
+		}
+	}
+	var resDirs []types.Directory
+	for _, d := range oldStorage.Directories {
+		if key := nodeKey(d.Node); !files[key] && !links[key] {
+			resDirs = append(resDirs, d)
+		}
+	}
+	var resLinks []types.Link
+	for _, l := range oldStorage.Links {
+		if key := nodeKey(l.Node); !files[key] && !dirs[key] {
+			resLinks = append(resLinks, l)
+		}
+	}
+	oldStorage.Files = resFiles
+	oldStorage.Directories = resDirs
+	oldStorage.Links = resLinks
+	return oldStorage
+}
+
+// mergeStruct is an internal helper function to Merge. Given two values of
+// structures of the same type, recursively merge every field. The field
+// "ignition.config" uses the new value, as in Append.
+func mergeStruct(vOld, vNew reflect.Value) reflect.Value {
+	t := vOld.Type()
+	vRes := reflect.New(t).Elem()
+
+	for i := 0; i < t.NumField(); i++ {
+		vfOld := vOld.Field(i)
+		vfNew := vNew.Field(i)
+
+		if t.Field(i).Name == "Config" && t == reflect.TypeOf(types.Ignition{}) {
+			vRes.Field(i).Set(vfNew)
+			continue
+		}
+		vRes.Field(i).Set(mergeValue(vfOld, vfNew))
+	}
+
+	return vRes
+}
+
+// mergeValue merges two values of the same type.
+func mergeValue(vOld, vNew reflect.Value) reflect.Value {
+	switch vOld.Kind() {
+	case reflect.Struct:
+		if mergeAtomic[vOld.Type()] {
+			if vNew.IsZero() {
+				return vOld
+			}
+			return vNew
+		}
+		return mergeStruct(vOld, vNew)
+	case reflect.Ptr:
+		if vNew.IsNil() {
+			return vOld
+		}
+		if vOld.IsNil() || vOld.Elem().Kind() != reflect.Struct {
+			return vNew
+		}
+		vRes := reflect.New(vOld.Elem().Type())
+		vRes.Elem().Set(mergeValue(vOld.Elem(), vNew.Elem()))
+		return vRes
+	case reflect.Slice:
+		return mergeSlice(vOld, vNew)
+	default:
+		if vNew.IsZero() {
+			return vOld
+		}
+		return vNew
+	}
+}
+
+// mergeSlice merges two slices of the same type according to the kind of
+// their elements.
+func mergeSlice(vOld, vNew reflect.Value) reflect.Value {
+	if vNew.Len() == 0 {
+		return vOld
+	}
+	if vOld.Len() == 0 {
+		return vNew
+	}
+
+	if mergeSets[vOld.Type()] {
+		vRes := reflect.AppendSlice(reflect.MakeSlice(vOld.Type(), 0, vOld.Len()+vNew.Len()), vOld)
+		for i := 0; i < vNew.Len(); i++ {
+			if !sliceContains(vRes, vNew.Index(i)) {
+				vRes = reflect.Append(vRes, vNew.Index(i))
+			}
+		}
+		return vRes
+	}
+
+	key, ok := mergeKeys[vOld.Type().Elem()]
+	if !ok {
+		return vNew
+	}
+
+	vRes := reflect.AppendSlice(reflect.MakeSlice(vOld.Type(), 0, vOld.Len()+vNew.Len()), vOld)
+	indices := map[string]int{}
+	for i := 0; i < vRes.Len(); i++ {
+		if k := key(vRes.Index(i)); k != "" {
+			indices[k] = i
+		}
+	}
+	for i := 0; i < vNew.Len(); i++ {
+		entry := vNew.Index(i)
+		k := key(entry)
+		if j, ok := indices[k]; ok && k != "" {
+			vRes.Index(j).Set(mergeValue(vRes.Index(j), entry))
+			continue
+		}
+		vRes = reflect.Append(vRes, entry)
+		if k != "" {
+			indices[k] = vRes.Len() - 1
+		}
+	}
+	return vRes
+}
+
+func sliceContains(slice, v reflect.Value) bool {
+	for i := 0; i < slice.Len(); i++ {
+		if slice.Index(i).Interface() == v.Interface() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,491 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flatcar/ignition/internal/config/types"
+)
+
+func TestUsesKeyedMerge(t *testing.T) {
+	tests := []struct {
+		in  string
+		out bool
+	}{
+		{in: "", out: false},
+		{in: "2.4.0", out: false},
+		{in: "3.0.0", out: true},
+		{in: "3.4.0", out: true},
+		{in: "bogus", out: false},
+	}
+
+	for i, test := range tests {
+		assert.Equal(t, test.out, UsesKeyedMerge(test.in), "#%d: bad result", i)
+	}
+}
+
+func TestCombine(t *testing.T) {
+	type in struct {
+		oldConfig types.Config
+		newConfig types.Config
+	}
+	type out struct {
+		config types.Config
+	}
+
+	unit2 := types.Config{
+		Ignition: types.Ignition{Version: "2.4.0"},
+		Systemd:  types.Systemd{Units: []types.Unit{{Name: "a.service", Contents: "new"}}},
+	}
+	unit3 := types.Config{
+		Ignition: types.Ignition{Version: "3.0.0"},
+		Systemd:  types.Systemd{Units: []types.Unit{{Name: "a.service", Contents: "new"}}},
+	}
+	base := types.Config{
+		Ignition: types.Ignition{Version: "2.4.0"},
+		Systemd:  types.Systemd{Units: []types.Unit{{Name: "a.service", Contents: "old", Enabled: boolToPtr(true)}}},
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		// 2.x configs are appended
+		{
+			in: in{oldConfig: base, newConfig: unit2},
+			out: out{config: types.Config{
+				Ignition: types.Ignition{Version: "2.4.0"},
+				Systemd: types.Systemd{Units: []types.Unit{
+					{Name: "a.service", Contents: "old", Enabled: boolToPtr(true)},
+					{Name: "a.service", Contents: "new"},
+				}},
+			}},
+		},
+		// a 3.x config opts into merging
+		{
+			in: in{oldConfig: base, newConfig: unit3},
+			out: out{config: types.Config{
+				Ignition: types.Ignition{Version: "3.0.0"},
+				Systemd: types.Systemd{Units: []types.Unit{
+					{Name: "a.service", Contents: "new", Enabled: boolToPtr(true)},
+				}},
+			}},
+		},
+		// the opt-in sticks to the result
+		{
+			in: in{oldConfig: Combine(base, unit3), newConfig: unit2},
+			out: out{config: types.Config{
+				Ignition: types.Ignition{Version: "3.0.0"},
+				Systemd: types.Systemd{Units: []types.Unit{
+					{Name: "a.service", Contents: "new", Enabled: boolToPtr(true)},
+				}},
+			}},
+		},
+	}
+
+	for i, test := range tests {
+		assert.Equal(t, test.out.config, Combine(test.in.oldConfig, test.in.newConfig), "#%d: bad config", i)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	type in struct {
+		oldConfig types.Config
+		newConfig types.Config
+	}
+	type out struct {
+		config types.Config
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		// empty
+		{
+			in:  in{},
+			out: out{},
+		},
+
+		// files are merged field by field, contents are replaced as a whole,
+		// appends and edits are added
+		{
+			in: in{
+				oldConfig: types.Config{
+					Storage: types.Storage{
+						Files: []types.File{
+							{
+								Node: types.Node{Filesystem: "root", Path: "/a", User: &types.NodeUser{Name: "core"}},
+								FileEmbedded1: types.FileEmbedded1{
+									Mode: intToPtr(0644),
+									Contents: types.FileContents{
+										Source:       "data:,old",
+										Verification: types.Verification{Hash: strToPtr("sha512-0123")},
+									},
+								},
+							},
+							{
+								Node:          types.Node{Filesystem: "root", Path: "/a"},
+								FileEmbedded1: types.FileEmbedded1{Append: true, Contents: types.FileContents{Source: "data:,appended"}},
+							},
+							{
+								Node: types.Node{Filesystem: "root", Path: "/b"},
+							},
+						},
+					},
+				},
+				newConfig: types.Config{
+					Storage: types.Storage{
+						Files: []types.File{
+							{
+								Node:          types.Node{Filesystem: "root", Path: "/a", Group: &types.NodeGroup{Name: "core"}},
+								FileEmbedded1: types.FileEmbedded1{Contents: types.FileContents{Source: "data:,new"}},
+							},
+							{
+								Node:          types.Node{Filesystem: "root", Path: "/a"},
+								FileEmbedded1: types.FileEmbedded1{Append: true, Contents: types.FileContents{Source: "data:,more"}},
+							},
+							{
+								Node:          types.Node{Filesystem: "root", Path: "/b"},
+								FileEmbedded1: types.FileEmbedded1{Edits: []types.FileEdit{{Action: "deleteLines", Regex: "^#"}}},
+							},
+							{
+								Node: types.Node{Filesystem: "root", Path: "/c"},
+							},
+						},
+					},
+				},
+			},
+			out: out{config: types.Config{
+				Storage: types.Storage{
+					Files: []types.File{
+						{
+							Node: types.Node{Filesystem: "root", Path: "/a", User: &types.NodeUser{Name: "core"}, Group: &types.NodeGroup{Name: "core"}},
+							FileEmbedded1: types.FileEmbedded1{
+								Mode:     intToPtr(0644),
+								Contents: types.FileContents{Source: "data:,new"},
+							},
+						},
+						{
+							Node:          types.Node{Filesystem: "root", Path: "/a"},
+							FileEmbedded1: types.FileEmbedded1{Append: true, Contents: types.FileContents{Source: "data:,appended"}},
+						},
+						{
+							Node: types.Node{Filesystem: "root", Path: "/b"},
+						},
+						{
+							Node:          types.Node{Filesystem: "root", Path: "/a"},
+							FileEmbedded1: types.FileEmbedded1{Append: true, Contents: types.FileContents{Source: "data:,more"}},
+						},
+						{
+							Node:          types.Node{Filesystem: "root", Path: "/b"},
+							FileEmbedded1: types.FileEmbedded1{Edits: []types.FileEdit{{Action: "deleteLines", Regex: "^#"}}},
+						},
+						{
+							Node: types.Node{Filesystem: "root", Path: "/c"},
+						},
+					},
+				},
+			}},
+		},
+
+		// nodes of a different type at the same path are replaced
+		{
+			in: in{
+				oldConfig: types.Config{
+					Storage: types.Storage{
+						Files:       []types.File{{Node: types.Node{Filesystem: "root", Path: "/a"}}},
+						Directories: []types.Directory{{Node: types.Node{Filesystem: "root", Path: "/b"}}},
+						Links:       []types.Link{{Node: types.Node{Filesystem: "oem", Path: "/a"}, LinkEmbedded1: types.LinkEmbedded1{Target: "/x"}}},
+					},
+				},
+				newConfig: types.Config{
+					Storage: types.Storage{
+						Links: []types.Link{
+							{Node: types.Node{Filesystem: "root", Path: "/a"}, LinkEmbedded1: types.LinkEmbedded1{Target: "/y"}},
+							{Node: types.Node{Filesystem: "root", Path: "/b"}, LinkEmbedded1: types.LinkEmbedded1{Target: "/z"}},
+						},
+					},
+				},
+			},
+			out: out{config: types.Config{
+				Storage: types.Storage{
+					Links: []types.Link{
+						{Node: types.Node{Filesystem: "oem", Path: "/a"}, LinkEmbedded1: types.LinkEmbedded1{Target: "/x"}},
+						{Node: types.Node{Filesystem: "root", Path: "/a"}, LinkEmbedded1: types.LinkEmbedded1{Target: "/y"}},
+						{Node: types.Node{Filesystem: "root", Path: "/b"}, LinkEmbedded1: types.LinkEmbedded1{Target: "/z"}},
+					},
+				},
+			}},
+		},
+
+		// disks, partitions, raid and filesystems
+		{
+			in: in{
+				oldConfig: types.Config{
+					Storage: types.Storage{
+						Disks: []types.Disk{{
+							Device: "/dev/sda",
+							Partitions: []types.Partition{
+								{Number: 1, Label: strToPtr("ROOT"), SizeMiB: intToPtr(1024)},
+								{Label: strToPtr("DATA")},
+							},
+						}},
+						Raid: []types.Raid{{Name: "md0", Level: "raid1", Devices: []types.Device{"/dev/sdb", "/dev/sdc"}}},
+						Filesystems: []types.Filesystem{{
+							Name:  "data",
+							Mount: &types.Mount{Device: "/dev/md/md0", Format: "ext4", Options: []types.MountOption{"-b", "4096"}},
+						}},
+					},
+				},
+				newConfig: types.Config{
+					Storage: types.Storage{
+						Disks: []types.Disk{{
+							Device:    "/dev/sda",
+							WipeTable: true,
+							Partitions: []types.Partition{
+								{Number: 1, SizeMiB: intToPtr(2048)},
+								{Label: strToPtr("DATA"), TypeGUID: "0FC63DAF-8483-4772-8E79-3D69D8477DE4"},
+							},
+						}},
+						Raid: []types.Raid{{Name: "md0", Devices: []types.Device{"/dev/sdd", "/dev/sde"}}},
+						Filesystems: []types.Filesystem{{
+							Name:  "data",
+							Mount: &types.Mount{Format: "xfs"},
+						}},
+					},
+				},
+			},
+			out: out{config: types.Config{
+				Storage: types.Storage{
+					Disks: []types.Disk{{
+						Device:    "/dev/sda",
+						WipeTable: true,
+						Partitions: []types.Partition{
+							{Number: 1, Label: strToPtr("ROOT"), SizeMiB: intToPtr(2048)},
+							{Label: strToPtr("DATA"), TypeGUID: "0FC63DAF-8483-4772-8E79-3D69D8477DE4"},
+						},
+					}},
+					Raid: []types.Raid{{Name: "md0", Level: "raid1", Devices: []types.Device{"/dev/sdd", "/dev/sde"}}},
+					Filesystems: []types.Filesystem{{
+						Name:  "data",
+						Mount: &types.Mount{Device: "/dev/md/md0", Format: "xfs", Options: []types.MountOption{"-b", "4096"}},
+					}},
+				},
+			}},
+		},
+
+		// volume groups and logical volumes
+		{
+			in: in{
+				oldConfig: types.Config{
+					Storage: types.Storage{
+						Lvm: types.Lvm{VolumeGroups: []types.VolumeGroup{{
+							Name:            "data",
+							PhysicalVolumes: []types.Device{"/dev/md/md0"},
+							LogicalVolumes: []types.LogicalVolume{
+								{Name: "docker", SizeMiB: intToPtr(10240)},
+								{Name: "log", PercentFree: intToPtr(100)},
+							},
+						}}},
+					},
+				},
+				newConfig: types.Config{
+					Storage: types.Storage{
+						Lvm: types.Lvm{VolumeGroups: []types.VolumeGroup{{
+							Name: "data",
+							LogicalVolumes: []types.LogicalVolume{
+								{Name: "docker", SizeMiB: intToPtr(20480)},
+								{Name: "cache", SizeMiB: intToPtr(1024)},
+							},
+						}}},
+					},
+				},
+			},
+			out: out{config: types.Config{
+				Storage: types.Storage{
+					Lvm: types.Lvm{VolumeGroups: []types.VolumeGroup{{
+						Name:            "data",
+						PhysicalVolumes: []types.Device{"/dev/md/md0"},
+						LogicalVolumes: []types.LogicalVolume{
+							{Name: "docker", SizeMiB: intToPtr(20480)},
+							{Name: "log", PercentFree: intToPtr(100)},
+							{Name: "cache", SizeMiB: intToPtr(1024)},
+						},
+					}}},
+				},
+			}},
+		},
+
+		// false booleans don't replace earlier values, false pointers do
+		{
+			in: in{
+				oldConfig: types.Config{
+					Storage: types.Storage{
+						Disks: []types.Disk{{Device: "/dev/sda", WipeTable: true}},
+						Filesystems: []types.Filesystem{{
+							Name:  "data",
+							Mount: &types.Mount{Device: "/dev/sdb", Format: "ext4", WipeFilesystem: true, GrowFilesystem: true},
+						}},
+					},
+					Systemd: types.Systemd{
+						Units: []types.Unit{{Name: "a.service", Enabled: boolToPtr(true), Mask: true}},
+					},
+				},
+				newConfig: types.Config{
+					Storage: types.Storage{
+						Disks: []types.Disk{{Device: "/dev/sda", WipeTable: false}},
+						Filesystems: []types.Filesystem{{
+							Name:  "data",
+							Mount: &types.Mount{WipeFilesystem: false, GrowFilesystem: false},
+						}},
+					},
+					Systemd: types.Systemd{
+						Units: []types.Unit{{Name: "a.service", Enabled: boolToPtr(false), Mask: false}},
+					},
+				},
+			},
+			out: out{config: types.Config{
+				Storage: types.Storage{
+					Disks: []types.Disk{{Device: "/dev/sda", WipeTable: true}},
+					Filesystems: []types.Filesystem{{
+						Name:  "data",
+						Mount: &types.Mount{Device: "/dev/sdb", Format: "ext4", WipeFilesystem: true, GrowFilesystem: true},
+					}},
+				},
+				Systemd: types.Systemd{
+					Units: []types.Unit{{Name: "a.service", Enabled: boolToPtr(false), Mask: true}},
+				},
+			}},
+		},
+
+		// users, groups and units
+		{
+			in: in{
+				oldConfig: types.Config{
+					Passwd: types.Passwd{
+						Users: []types.PasswdUser{{
+							Name:              "core",
+							SSHAuthorizedKeys: []types.SSHAuthorizedKey{"key1", "key2"},
+							Groups:            []types.Group{"wheel"},
+						}},
+						Groups: []types.PasswdGroup{{Name: "ops", Gid: intToPtr(2000)}},
+					},
+					Systemd: types.Systemd{
+						Units: []types.Unit{{
+							Name:     "a.service",
+							Contents: "[Service]",
+							Dropins:  []types.SystemdDropin{{Name: "10-a.conf", Contents: "old"}},
+						}},
+					},
+					Networkd: types.Networkd{
+						Units: []types.Networkdunit{{Name: "a.network", Contents: "old"}},
+					},
+				},
+				newConfig: types.Config{
+					Passwd: types.Passwd{
+						Users: []types.PasswdUser{
+							{
+								Name:              "core",
+								PasswordHash:      strToPtr("hash"),
+								SSHAuthorizedKeys: []types.SSHAuthorizedKey{"key2", "key3"},
+							},
+							{Name: "other"},
+						},
+						Groups: []types.PasswdGroup{{Name: "ops", System: true}},
+					},
+					Systemd: types.Systemd{
+						Units: []types.Unit{{
+							Name:    "a.service",
+							Enabled: boolToPtr(true),
+							Dropins: []types.SystemdDropin{{Name: "10-a.conf", Contents: "new"}, {Name: "20-b.conf"}},
+						}},
+					},
+					Networkd: types.Networkd{
+						Units: []types.Networkdunit{{Name: "a.network", Contents: "new"}},
+					},
+				},
+			},
+			out: out{config: types.Config{
+				Passwd: types.Passwd{
+					Users: []types.PasswdUser{
+						{
+							Name:              "core",
+							PasswordHash:      strToPtr("hash"),
+							SSHAuthorizedKeys: []types.SSHAuthorizedKey{"key1", "key2", "key3"},
+							Groups:            []types.Group{"wheel"},
+						},
+						{Name: "other"},
+					},
+					Groups: []types.PasswdGroup{{Name: "ops", Gid: intToPtr(2000), System: true}},
+				},
+				Systemd: types.Systemd{
+					Units: []types.Unit{{
+						Name:     "a.service",
+						Contents: "[Service]",
+						Enabled:  boolToPtr(true),
+						Dropins:  []types.SystemdDropin{{Name: "10-a.conf", Contents: "new"}, {Name: "20-b.conf"}},
+					}},
+				},
+				Networkd: types.Networkd{
+					Units: []types.Networkdunit{{Name: "a.network", Contents: "new"}},
+				},
+			}},
+		},
+
+		// ignition.config uses the new value, referenced CAs are merged
+		{
+			in: in{
+				oldConfig: types.Config{
+					Ignition: types.Ignition{
+						Version: "3.0.0",
+						Config: types.IgnitionConfig{
+							Append: []types.ConfigReference{{Source: "data:,a"}},
+						},
+						Security: types.Security{TLS: types.TLS{
+							CertificateAuthorities: []types.CaReference{{Source: "data:,ca1"}},
+						}},
+						Timeouts: types.Timeouts{HTTPTotal: intToPtr(10)},
+					},
+				},
+				newConfig: types.Config{
+					Ignition: types.Ignition{
+						Version: "3.1.0",
+						Security: types.Security{TLS: types.TLS{
+							CertificateAuthorities: []types.CaReference{{Source: "data:,ca1"}, {Source: "data:,ca2"}},
+						}},
+						Timeouts: types.Timeouts{HTTPResponseHeaders: intToPtr(5)},
+					},
+				},
+			},
+			out: out{config: types.Config{
+				Ignition: types.Ignition{
+					Version: "3.0.0",
+					Security: types.Security{TLS: types.TLS{
+						CertificateAuthorities: []types.CaReference{{Source: "data:,ca1"}, {Source: "data:,ca2"}},
+					}},
+					Timeouts: types.Timeouts{HTTPTotal: intToPtr(10), HTTPResponseHeaders: intToPtr(5)},
+				},
+			}},
+		},
+	}
+
+	for i, test := range tests {
+		assert.Equal(t, test.out.config, Merge(test.in.oldConfig, test.in.newConfig), "#%d: bad config", i)
+	}
+}
@@ -15,6 +15,7 @@
 package config
 
 import (
+	"github.com/flatcar/ignition/config/shared/partitions"
 	from "github.com/flatcar/ignition/config/v2_4/types"
 	"github.com/flatcar/ignition/internal/config/types"
 )
@@ -195,13 +196,25 @@ func Translate(old from.Config) types.Config {
 			Name: old.Name,
 		}
 	}
+	translateNodeXattrSlice := func(old []from.NodeXattr) []types.NodeXattr {
+		var res []types.NodeXattr
+		for _, x := range old {
+			res = append(res, types.NodeXattr{
+				Name:  x.Name,
+				Value: x.Value,
+			})
+		}
+		return res
+	}
 	translateNode := func(old from.Node) types.Node {
 		return types.Node{
-			Filesystem: old.Filesystem,
-			Group:      translateNodeGroup(old.Group),
-			Path:       old.Path,
-			User:       translateNodeUser(old.User),
-			Overwrite:  old.Overwrite,
+			Filesystem:     old.Filesystem,
+			Group:          translateNodeGroup(old.Group),
+			Path:           old.Path,
+			User:           translateNodeUser(old.User),
+			Overwrite:      old.Overwrite,
+			SELinuxContext: old.SELinuxContext,
+			Xattrs:         translateNodeXattrSlice(old.Xattrs),
 		}
 	}
 	translateDirectorySlice := func(old []from.Directory) []types.Directory {
@@ -216,35 +229,93 @@ func Translate(old from.Config) types.Config {
 		}
 		return res
 	}
+	translateImage := func(old *from.Image) *types.Image {
+		if old == nil {
+			return nil
+		}
+		return &types.Image{
+			Compression: old.Compression,
+			Source:      old.Source,
+			Verification: types.Verification{
+				Hash: old.Verification.Hash,
+			},
+			HTTPHeaders: translateHTTPHeaderSlice(old.HTTPHeaders),
+		}
+	}
+	translatePartitionAttributeSlice := func(old []from.PartitionAttribute) []types.PartitionAttribute {
+		var res []types.PartitionAttribute
+		for _, x := range old {
+			res = append(res, types.PartitionAttribute(x))
+		}
+		return res
+	}
 	translatePartitionSlice := func(old []from.Partition) []types.Partition {
 		var res []types.Partition
 		for _, x := range old {
 			res = append(res, types.Partition{
+				Attributes:         translatePartitionAttributeSlice(x.Attributes),
+				Bootable:           x.Bootable,
 				GUID:               x.GUID,
+				Image:              translateImage(x.Image),
 				Label:              x.Label,
+				MbrType:            x.MbrType,
 				Number:             x.Number,
+				Resize:             x.Resize,
 				Size:               x.Size,
 				SizeMiB:            x.SizeMiB,
 				Start:              x.Start,
 				StartMiB:           x.StartMiB,
-				TypeGUID:           x.TypeGUID,
+				TypeGUID:           partitions.TypeGUID(x.TypeGUID),
 				ShouldExist:        x.ShouldExist,
 				WipePartitionEntry: x.WipePartitionEntry,
 			})
 		}
 		return res
 	}
+	translateDiskSelector := func(old *from.DiskSelector) *types.DiskSelector {
+		if old == nil {
+			return nil
+		}
+		return &types.DiskSelector{
+			LargestUnused: old.LargestUnused,
+			MaxSizeMiB:    old.MaxSizeMiB,
+			MinSizeMiB:    old.MinSizeMiB,
+			Model:         old.Model,
+			Rotational:    old.Rotational,
+			Serial:        old.Serial,
+			Transport:     old.Transport,
+			WWN:           old.WWN,
+		}
+	}
 	translateDiskSlice := func(old []from.Disk) []types.Disk {
 		var res []types.Disk
 		for _, x := range old {
 			res = append(res, types.Disk{
 				Device:     x.Device,
+				Image:      translateImage(x.Image),
 				Partitions: translatePartitionSlice(x.Partitions),
+				Selector:   translateDiskSelector(x.Selector),
+				TableType:  x.TableType,
 				WipeTable:  x.WipeTable,
 			})
 		}
 		return res
 	}
+	translateFileEditSlice := func(old []from.FileEdit) []types.FileEdit {
+		var res []types.FileEdit
+		for _, x := range old {
+			res = append(res, types.FileEdit{
+				Action:      x.Action,
+				Key:         x.Key,
+				Line:        x.Line,
+				Regex:       x.Regex,
+				Replacement: x.Replacement,
+				Section:     x.Section,
+				Value:       x.Value,
+			})
+		}
+		return res
+	}
 	translateFileSlice := func(old []from.File) []types.File {
 		var res []types.File
 		for _, x := range old {
@@ -254,6 +325,7 @@ func Translate(old from.Config) types.Config {
 					Contents: types.FileContents{
 						Compression: x.Contents.Compression,
 						Source:      x.Contents.Source,
+						Template:    x.Contents.Template,
 						Verification: types.Verification{
 							Hash: x.Contents.Verification.Hash,
 						},
@@ -261,11 +333,47 @@ func Translate(old from.Config) types.Config {
 					},
 					Mode:   x.Mode,
 					Append: x.Append,
+					Edits:  translateFileEditSlice(x.Edits),
 				},
 			})
 		}
 		return res
 	}
+	translateArchiveSlice := func(old []from.Archive) []types.Archive {
+		var res []types.Archive
+		for _, x := range old {
+			res = append(res, types.Archive{
+				Node: translateNode(x.Node),
+				ArchiveEmbedded1: types.ArchiveEmbedded1{
+					Contents: types.FileContents{
+						Compression: x.Contents.Compression,
+						Source:      x.Contents.Source,
+						Template:    x.Contents.Template,
+						Verification: types.Verification{
+							Hash: x.Contents.Verification.Hash,
+						},
+						HTTPHeaders: translateHTTPHeaderSlice(x.Contents.HTTPHeaders),
+					},
+					DirectoryMode: x.DirectoryMode,
+					FileMode:      x.FileMode,
+					Format:        x.Format,
+				},
+			})
+		}
+		return res
+	}
+	translateRemovalSlice := func(old []from.Removal) []types.Removal {
+		var res []types.Removal
+		for _, x := range old {
+			res = append(res, types.Removal{
+				Filesystem: x.Filesystem,
+				MustExist:  x.MustExist,
+				Path:       x.Path,
+				Recursive:  x.Recursive,
+			})
+		}
+		return res
+	}
 	translateMountCreateOptionSlice := func(old []from.CreateOption) []types.CreateOption {
 		var res []types.CreateOption
 		for _, x := range old {
@@ -289,6 +397,18 @@ func Translate(old from.Config) types.Config {
 		}
 		return res
 	}
+	translateSubvolumeSlice := func(old []from.Subvolume) []types.Subvolume {
+		var res []types.Subvolume
+		for _, x := range old {
+			res = append(res, types.Subvolume{
+				Name:           x.Name,
+				NoCopyOnWrite:  x.NoCopyOnWrite,
+				Path:           x.Path,
+				QgroupLimitMiB: x.QgroupLimitMiB,
+			})
+		}
+		return res
+	}
 	translateMount := func(old *from.Mount) *types.Mount {
 		if old == nil {
 			return nil
@@ -297,8 +417,10 @@ func Translate(old from.Config) types.Config {
 			Create:         translateMountCreate(old.Create),
 			Device:         old.Device,
 			Format:         old.Format,
+			GrowFilesystem: old.GrowFilesystem,
 			Label:          old.Label,
 			Options:        translateMountOptionSlice(old.Options),
+			Subvolumes:     translateSubvolumeSlice(old.Subvolumes),
 			UUID:           old.UUID,
 			WipeFilesystem: old.WipeFilesystem,
 		}
@@ -345,11 +467,86 @@ func Translate(old from.Config) types.Config {
 		var res []types.Raid
 		for _, x := range old {
 			res = append(res, types.Raid{
-				Devices: translateDeviceSlice(x.Devices),
-				Level:   x.Level,
-				Name:    x.Name,
-				Spares:  x.Spares,
-				Options: translateRaidOptionSlice(x.Options),
+				Devices:        translateDeviceSlice(x.Devices),
+				Level:          x.Level,
+				Name:           x.Name,
+				Spares:         x.Spares,
+				Options:        translateRaidOptionSlice(x.Options),
+				UUID:           x.UUID,
+				WaitForSync:    x.WaitForSync,
+				WipeArray:      x.WipeArray,
+				WriteMdadmConf: x.WriteMdadmConf,
+			})
+		}
+		return res
+	}
+	translateLogicalVolumeOptionSlice := func(old []from.LogicalVolumeOption) []types.LogicalVolumeOption {
+		var res []types.LogicalVolumeOption
+		for _, x := range old {
+			res = append(res, types.LogicalVolumeOption(x))
+		}
+		return res
+	}
+	translateLogicalVolumeSlice := func(old []from.LogicalVolume) []types.LogicalVolume {
+		var res []types.LogicalVolume
+		for _, x := range old {
+			res = append(res, types.LogicalVolume{
+				Name:        x.Name,
+				Options:     translateLogicalVolumeOptionSlice(x.Options),
+				PercentFree: x.PercentFree,
+				Pool:        x.Pool,
+				SizeMiB:     x.SizeMiB,
+				Type:        x.Type,
+			})
+		}
+		return res
+	}
+	translateVolumeGroupOptionSlice := func(old []from.VolumeGroupOption) []types.VolumeGroupOption {
+		var res []types.VolumeGroupOption
+		for _, x := range old {
+			res = append(res, types.VolumeGroupOption(x))
+		}
+		return res
+	}
+	translateVolumeGroupSlice := func(old []from.VolumeGroup) []types.VolumeGroup {
+		var res []types.VolumeGroup
+		for _, x := range old {
+			res = append(res, types.VolumeGroup{
+				LogicalVolumes:  translateLogicalVolumeSlice(x.LogicalVolumes),
+				Name:            x.Name,
+				Options:         translateVolumeGroupOptionSlice(x.Options),
+				PhysicalVolumes: translateDeviceSlice(x.PhysicalVolumes),
+			})
+		}
+		return res
+	}
+	translateLuksOptionSlice := func(old []from.LuksOption) []types.LuksOption {
+		var res []types.LuksOption
+		for _, x := range old {
+			res = append(res, types.LuksOption(x))
+		}
+		return res
+	}
+	translateLuksSlice := func(old []from.Luks) []types.Luks {
+		var res []types.Luks
+		for _, x := range old {
+			res = append(res, types.Luks{
+				Device:  x.Device,
+				Discard: x.Discard,
+				KeyFile: types.FileContents{
+					Compression: x.KeyFile.Compression,
+					Source:      x.KeyFile.Source,
+					Template:    x.KeyFile.Template,
+					Verification: types.Verification{
+						Hash: x.KeyFile.Verification.Hash,
+					},
+					HTTPHeaders: translateHTTPHeaderSlice(x.KeyFile.HTTPHeaders),
+				},
+				Label:      x.Label,
+				Name:       x.Name,
+				Options:    translateLuksOptionSlice(x.Options),
+				UUID:       x.UUID,
+				WipeVolume: x.WipeVolume,
 			})
 		}
 		return res
@@ -360,6 +557,7 @@ func Translate(old from.Config) types.Config {
 			res = append(res, types.SystemdDropin{
 				Contents: x.Contents,
 				Name:     x.Name,
+				Template: x.Template,
 			})
 		}
 		return res
@@ -374,6 +572,7 @@ func Translate(old from.Config) types.Config {
 				Enabled:  x.Enabled,
 				Mask:     x.Mask,
 				Name:     x.Name,
+				Template: x.Template,
 			})
 		}
 		return res
@@ -408,12 +607,18 @@ func Translate(old from.Config) types.Config {
 			Users:  translatePasswdUserSlice(old.Passwd.Users),
 		},
 		Storage: types.Storage{
+			Archives:    translateArchiveSlice(old.Storage.Archives),
 			Directories: translateDirectorySlice(old.Storage.Directories),
 			Disks:       translateDiskSlice(old.Storage.Disks),
 			Files:       translateFileSlice(old.Storage.Files),
 			Filesystems: translateFilesystemSlice(old.Storage.Filesystems),
 			Links:       translateLinkSlice(old.Storage.Links),
-			Raid:        translateRaidSlice(old.Storage.Raid),
+			Luks:        translateLuksSlice(old.Storage.Luks),
+			Lvm: types.Lvm{
+				VolumeGroups: translateVolumeGroupSlice(old.Storage.Lvm.VolumeGroups),
+			},
+			Raid:   translateRaidSlice(old.Storage.Raid),
+			Remove: translateRemovalSlice(old.Storage.Remove),
 		},
 		Systemd: types.Systemd{
 			Units: translateSystemdUnitSlice(old.Systemd.Units),
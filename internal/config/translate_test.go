@@ -362,6 +362,7 @@ func TestTranslate(t *testing.T) {
 								{
 									Label:              util.StrToPtrStrict("ROOT"),
 									Number:             7,
+									Resize:             true,
 									Size:               util.IntToPtr(100),
 									Start:              util.IntToPtr(50),
 									TypeGUID:           "HI",
@@ -379,11 +380,45 @@ func TestTranslate(t *testing.T) {
 									WipePartitionEntry: false,
 									ShouldExist:        util.BoolToPtr(false),
 								},
+								{
+									Number:     13,
+									TypeGUID:   "esp",
+									Attributes: []from.PartitionAttribute{"legacy-bios-bootable", "priority=1"},
+								},
+							},
+						},
+						{
+							Selector: &from.DiskSelector{
+								MinSizeMiB: util.IntToPtr(102400),
+								Rotational: util.BoolToPtr(false),
+								Transport:  util.StrToPtrStrict("nvme"),
+								Model:      util.StrToPtrStrict("^Samsung"),
+							},
+							WipeTable: true,
+						},
+						{
+							Device:    "/dev/sdc",
+							TableType: "dos",
+							Partitions: []from.Partition{
+								{
+									Number:   1,
+									SizeMiB:  util.IntToPtr(512),
+									MbrType:  "0c",
+									Bootable: true,
+								},
 							},
 						},
 						{
 							Device:    "/dev/sdb",
 							WipeTable: true,
+							Image: &from.Image{
+								Source:      "https://example.com/disk.img.gz",
+								Compression: "gzip",
+								HTTPHeaders: from.HTTPHeaders{{Name: "Authorization", Value: "Basic"}},
+								Verification: from.Verification{
+									Hash: util.StrToPtr("sha512-0123"),
+								},
+							},
 						},
 					},
 				},
@@ -399,6 +434,7 @@ func TestTranslate(t *testing.T) {
 								{
 									Label:              util.StrToPtrStrict("ROOT"),
 									Number:             7,
+									Resize:             true,
 									Size:               util.IntToPtr(100),
 									Start:              util.IntToPtr(50),
 									TypeGUID:           "HI",
@@ -416,11 +452,45 @@ func TestTranslate(t *testing.T) {
 									WipePartitionEntry: false,
 									ShouldExist:        util.BoolToPtr(false),
 								},
+								{
+									Number:     13,
+									TypeGUID:   "C12A7328-F81F-11D2-BA4B-00A0C93EC93B",
+									Attributes: []types.PartitionAttribute{"legacy-bios-bootable", "priority=1"},
+								},
+							},
+						},
+						{
+							Selector: &types.DiskSelector{
+								MinSizeMiB: util.IntToPtr(102400),
+								Rotational: util.BoolToPtr(false),
+								Transport:  util.StrToPtrStrict("nvme"),
+								Model:      util.StrToPtrStrict("^Samsung"),
+							},
+							WipeTable: true,
+						},
+						{
+							Device:    "/dev/sdc",
+							TableType: "dos",
+							Partitions: []types.Partition{
+								{
+									Number:   1,
+									SizeMiB:  util.IntToPtr(512),
+									MbrType:  "0c",
+									Bootable: true,
+								},
 							},
 						},
 						{
 							Device:    "/dev/sdb",
 							WipeTable: true,
+							Image: &types.Image{
+								Source:      "https://example.com/disk.img.gz",
+								Compression: "gzip",
+								HTTPHeaders: types.HTTPHeaders{{Name: "Authorization", Value: "Basic"}},
+								Verification: types.Verification{
+									Hash: util.StrToPtr("sha512-0123"),
+								},
+							},
 						},
 					},
 				},
@@ -447,7 +517,11 @@ func TestTranslate(t *testing.T) {
 								from.Device("/dev/sde"),
 								from.Device("/dev/sdf"),
 							},
-							Spares: 3,
+							Spares:         3,
+							UUID:           util.StrToPtrStrict("b8c4e0a2-57d3-4a41-9e5c-0d6f1f3a7b21"),
+							WaitForSync:    true,
+							WipeArray:      true,
+							WriteMdadmConf: true,
 						},
 						{
 							Name:  "fast-and-durable",
@@ -477,10 +551,14 @@ func TestTranslate(t *testing.T) {
 							Spares:  2,
 						},
 						{
-							Name:    "durable",
-							Level:   "raid1",
-							Devices: []types.Device{types.Device("/dev/sde"), types.Device("/dev/sdf")},
-							Spares:  3,
+							Name:           "durable",
+							Level:          "raid1",
+							Devices:        []types.Device{types.Device("/dev/sde"), types.Device("/dev/sdf")},
+							Spares:         3,
+							UUID:           util.StrToPtrStrict("b8c4e0a2-57d3-4a41-9e5c-0d6f1f3a7b21"),
+							WaitForSync:    true,
+							WipeArray:      true,
+							WriteMdadmConf: true,
 						},
 						{
 							Name:  "fast-and-durable",
@@ -500,6 +578,50 @@ func TestTranslate(t *testing.T) {
 				},
 			}},
 		},
+		{
+			in: in{config: from.Config{
+				Ignition: from.Ignition{Version: from.MaxVersion.String()},
+				Storage: from.Storage{
+					Luks: []from.Luks{
+						{
+							Name:   "secret",
+							Device: "/dev/md/durable",
+							KeyFile: from.FileContents{
+								Source:      "https://example.com/key",
+								Compression: "gzip",
+								HTTPHeaders: from.HTTPHeaders{{Name: "Authorization", Value: "Basic"}},
+							},
+							Label:      strToPtr("SECRET"),
+							UUID:       strToPtr("8A7A6E26-5E8F-4CCA-A654-46215D4696AC"),
+							Options:    []from.LuksOption{"--pbkdf", "pbkdf2"},
+							WipeVolume: true,
+							Discard:    true,
+						},
+					},
+				},
+			}},
+			out: out{config: types.Config{
+				Ignition: types.Ignition{Version: types.MaxVersion.String()},
+				Storage: types.Storage{
+					Luks: []types.Luks{
+						{
+							Name:   "secret",
+							Device: "/dev/md/durable",
+							KeyFile: types.FileContents{
+								Source:      "https://example.com/key",
+								Compression: "gzip",
+								HTTPHeaders: types.HTTPHeaders{{Name: "Authorization", Value: "Basic"}},
+							},
+							Label:      strToPtr("SECRET"),
+							UUID:       strToPtr("8A7A6E26-5E8F-4CCA-A654-46215D4696AC"),
+							Options:    []types.LuksOption{"--pbkdf", "pbkdf2"},
+							WipeVolume: true,
+							Discard:    true,
+						},
+					},
+				},
+			}},
+		},
 		{
 			in: in{config: from.Config{
 				Ignition: from.Ignition{Version: from.MaxVersion.String()},
@@ -514,8 +636,12 @@ func TestTranslate(t *testing.T) {
 									Force:   true,
 									Options: []from.CreateOption{"-L", "ROOT"},
 								},
-								Label:          strToPtr("ROOT"),
-								Options:        []from.MountOption{"--nodiscard"},
+								Label:   strToPtr("ROOT"),
+								Options: []from.MountOption{"--nodiscard"},
+								Subvolumes: []from.Subvolume{
+									{Path: "/docker", Name: "docker", QgroupLimitMiB: util.IntToPtr(10240), NoCopyOnWrite: true},
+									{Path: "/log"},
+								},
 								UUID:           strToPtr("8A7A6E26-5E8F-4CCA-A654-46215D4696AC"),
 								WipeFilesystem: true,
 							},
@@ -525,6 +651,7 @@ func TestTranslate(t *testing.T) {
 							Mount: &from.Mount{
 								Device:         "/dev/disk/by-partlabel/DATA",
 								Format:         "ext4",
+								GrowFilesystem: true,
 								Label:          strToPtr("DATA"),
 								Options:        []from.MountOption{"-b", "1024"},
 								UUID:           strToPtr("8A7A6E26-5E8F-4CCA-A654-DEADBEEF0101"),
@@ -551,8 +678,12 @@ func TestTranslate(t *testing.T) {
 									Force:   true,
 									Options: []types.CreateOption{"-L", "ROOT"},
 								},
-								Label:          strToPtr("ROOT"),
-								Options:        []types.MountOption{"--nodiscard"},
+								Label:   strToPtr("ROOT"),
+								Options: []types.MountOption{"--nodiscard"},
+								Subvolumes: []types.Subvolume{
+									{Path: "/docker", Name: "docker", QgroupLimitMiB: util.IntToPtr(10240), NoCopyOnWrite: true},
+									{Path: "/log"},
+								},
 								UUID:           strToPtr("8A7A6E26-5E8F-4CCA-A654-46215D4696AC"),
 								WipeFilesystem: true,
 							},
@@ -562,6 +693,7 @@ func TestTranslate(t *testing.T) {
 							Mount: &types.Mount{
 								Device:         "/dev/disk/by-partlabel/DATA",
 								Format:         "ext4",
+								GrowFilesystem: true,
 								Label:          strToPtr("DATA"),
 								Options:        []types.MountOption{"-b", "1024"},
 								UUID:           strToPtr("8A7A6E26-5E8F-4CCA-A654-DEADBEEF0101"),
@@ -797,11 +929,15 @@ func TestTranslate(t *testing.T) {
 					Links: []from.Link{
 						{
 							Node: from.Node{
-								Filesystem: "filesystem-1",
-								Path:       "/opt/link1",
-								User:       &from.NodeUser{ID: intToPtr(500)},
-								Group:      &from.NodeGroup{ID: intToPtr(501)},
-								Overwrite:  boolToPtr(true),
+								Filesystem:     "filesystem-1",
+								Path:           "/opt/link1",
+								User:           &from.NodeUser{ID: intToPtr(500)},
+								Group:          &from.NodeGroup{ID: intToPtr(501)},
+								Overwrite:      boolToPtr(true),
+								SELinuxContext: "system_u:object_r:bin_t:s0",
+								Xattrs: []from.NodeXattr{
+									{Name: "security.capability", Value: "0x0100000200040000000000000000000000000000"},
+								},
 							},
 							LinkEmbedded1: from.LinkEmbedded1{
 								Hard:   false,
@@ -841,11 +977,15 @@ func TestTranslate(t *testing.T) {
 					Links: []types.Link{
 						{
 							Node: types.Node{
-								Filesystem: "filesystem-1",
-								Path:       "/opt/link1",
-								User:       &types.NodeUser{ID: intToPtr(500)},
-								Group:      &types.NodeGroup{ID: intToPtr(501)},
-								Overwrite:  boolToPtr(true),
+								Filesystem:     "filesystem-1",
+								Path:           "/opt/link1",
+								User:           &types.NodeUser{ID: intToPtr(500)},
+								Group:          &types.NodeGroup{ID: intToPtr(501)},
+								Overwrite:      boolToPtr(true),
+								SELinuxContext: "system_u:object_r:bin_t:s0",
+								Xattrs: []types.NodeXattr{
+									{Name: "security.capability", Value: "0x0100000200040000000000000000000000000000"},
+								},
 							},
 							LinkEmbedded1: types.LinkEmbedded1{
 								Hard:   false,
@@ -880,6 +1020,122 @@ func TestTranslate(t *testing.T) {
 				},
 			}},
 		},
+		{
+			in: in{config: from.Config{
+				Ignition: from.Ignition{Version: from.MaxVersion.String()},
+				Storage: from.Storage{
+					Remove: []from.Removal{
+						{
+							Filesystem: "filesystem-1",
+							Path:       "/etc/motd",
+							MustExist:  true,
+						},
+						{
+							Filesystem: "filesystem-2",
+							Path:       "/etc/ssh/sshd_config.d",
+							Recursive:  true,
+						},
+					},
+				},
+			}},
+			out: out{config: types.Config{
+				Ignition: types.Ignition{Version: types.MaxVersion.String()},
+				Storage: types.Storage{
+					Remove: []types.Removal{
+						{
+							Filesystem: "filesystem-1",
+							Path:       "/etc/motd",
+							MustExist:  true,
+						},
+						{
+							Filesystem: "filesystem-2",
+							Path:       "/etc/ssh/sshd_config.d",
+							Recursive:  true,
+						},
+					},
+				},
+			}},
+		},
+		{
+			in: in{config: from.Config{
+				Ignition: from.Ignition{Version: from.MaxVersion.String()},
+				Storage: from.Storage{
+					Archives: []from.Archive{
+						{
+							Node: from.Node{
+								Filesystem: "filesystem-1",
+								Path:       "/opt/app",
+								User:       &from.NodeUser{Name: "core"},
+								Overwrite:  boolToPtr(true),
+							},
+							ArchiveEmbedded1: from.ArchiveEmbedded1{
+								Format: "tar",
+								Contents: from.FileContents{
+									Source:      "https://example.com/app.tar.gz",
+									Compression: "gzip",
+									Verification: from.Verification{
+										Hash: strToPtr("foobar"),
+									},
+								},
+								FileMode: intToPtr(0644),
+							},
+						},
+						{
+							Node: from.Node{
+								Filesystem: "filesystem-2",
+								Path:       "/opt/web",
+							},
+							ArchiveEmbedded1: from.ArchiveEmbedded1{
+								Format: "zip",
+								Contents: from.FileContents{
+									Source: "https://example.com/web.zip",
+								},
+								DirectoryMode: intToPtr(0750),
+							},
+						},
+					},
+				},
+			}},
+			out: out{config: types.Config{
+				Ignition: types.Ignition{Version: types.MaxVersion.String()},
+				Storage: types.Storage{
+					Archives: []types.Archive{
+						{
+							Node: types.Node{
+								Filesystem: "filesystem-1",
+								Path:       "/opt/app",
+								User:       &types.NodeUser{Name: "core"},
+								Overwrite:  boolToPtr(true),
+							},
+							ArchiveEmbedded1: types.ArchiveEmbedded1{
+								Format: "tar",
+								Contents: types.FileContents{
+									Source:      "https://example.com/app.tar.gz",
+									Compression: "gzip",
+									Verification: types.Verification{
+										Hash: strToPtr("foobar"),
+									},
+								},
+								FileMode: intToPtr(0644),
+							},
+						},
+						{
+							Node: types.Node{
+								Filesystem: "filesystem-2",
+								Path:       "/opt/web",
+							},
+							ArchiveEmbedded1: types.ArchiveEmbedded1{
+								Format: "zip",
+								Contents: types.FileContents{
+									Source: "https://example.com/web.zip",
+								},
+								DirectoryMode: intToPtr(0750),
+							},
+						},
+					},
+				},
+			}},
+		},
 		{
 			in: in{from.Config{
 				Systemd: from.Systemd{
@@ -888,6 +1144,7 @@ func TestTranslate(t *testing.T) {
 							Name:     "test1.service",
 							Enable:   true,
 							Contents: "test1 contents",
+							Template: true,
 							Dropins: []from.SystemdDropin{
 								{
 									Name:     "conf1.conf",
@@ -896,6 +1153,7 @@ func TestTranslate(t *testing.T) {
 								{
 									Name:     "conf2.conf",
 									Contents: "conf2 contents",
+									Template: true,
 								},
 							},
 						},
@@ -919,6 +1177,7 @@ func TestTranslate(t *testing.T) {
 							Name:     "test1.service",
 							Enable:   true,
 							Contents: "test1 contents",
+							Template: true,
 							Dropins: []types.SystemdDropin{
 								{
 									Name:     "conf1.conf",
@@ -927,6 +1186,7 @@ func TestTranslate(t *testing.T) {
 								{
 									Name:     "conf2.conf",
 									Contents: "conf2 contents",
+									Template: true,
 								},
 							},
 						},
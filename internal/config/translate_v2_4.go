@@ -0,0 +1,614 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	to "github.com/flatcar/ignition/config/v2_4/types"
+	"github.com/flatcar/ignition/internal/config/types"
+)
+
+// TranslateToV2_4 translates the internal config back into the equivalent
+// config of spec version 2.4.0. It is the inverse of Translate.
+func TranslateToV2_4(old types.Config) to.Config {
+	translateHTTPHeaderSlice := func(old []types.HTTPHeader) []to.HTTPHeader {
+		var res []to.HTTPHeader
+		for _, x := range old {
+			res = append(res, to.HTTPHeader{
+				Name:  x.Name,
+				Value: x.Value,
+			})
+		}
+		return res
+	}
+	translateConfigReference := func(old *types.ConfigReference) *to.ConfigReference {
+		if old == nil {
+			return nil
+		}
+		return &to.ConfigReference{
+			Source: old.Source,
+			Verification: to.Verification{
+				Hash: old.Verification.Hash,
+			},
+			HTTPHeaders: translateHTTPHeaderSlice(old.HTTPHeaders),
+		}
+	}
+	translateConfigReferenceSlice := func(old []types.ConfigReference) []to.ConfigReference {
+		var res []to.ConfigReference
+		for _, c := range old {
+			res = append(res, *translateConfigReference(&c))
+		}
+		return res
+	}
+	translateCertificateAuthoritySlice := func(old []types.CaReference) []to.CaReference {
+		var res []to.CaReference
+		for _, x := range old {
+			res = append(res, to.CaReference{
+				Source: x.Source,
+				Verification: to.Verification{
+					Hash: x.Verification.Hash,
+				},
+				HTTPHeaders: translateHTTPHeaderSlice(x.HTTPHeaders),
+			})
+		}
+		return res
+	}
+	translateNoProxySlice := func(old []types.NoProxyItem) []to.NoProxyItem {
+		var res []to.NoProxyItem
+		for _, x := range old {
+			res = append(res, to.NoProxyItem(x))
+		}
+		return res
+	}
+	translateNetworkdDropinSlice := func(old []types.NetworkdDropin) []to.NetworkdDropin {
+		var res []to.NetworkdDropin
+		for _, x := range old {
+			res = append(res, to.NetworkdDropin{
+				Contents: x.Contents,
+				Name:     x.Name,
+			})
+		}
+		return res
+	}
+	translateNetworkdUnitSlice := func(old []types.Networkdunit) []to.Networkdunit {
+		var res []to.Networkdunit
+		for _, u := range old {
+			res = append(res, to.Networkdunit{
+				Contents: u.Contents,
+				Name:     u.Name,
+				Dropins:  translateNetworkdDropinSlice(u.Dropins),
+			})
+		}
+		return res
+	}
+	translatePasswdGroupSlice := func(old []types.PasswdGroup) []to.PasswdGroup {
+		var res []to.PasswdGroup
+		for _, g := range old {
+			res = append(res, to.PasswdGroup{
+				Gid:          g.Gid,
+				Name:         g.Name,
+				PasswordHash: g.PasswordHash,
+				System:       g.System,
+			})
+		}
+		return res
+	}
+	translatePasswdUsercreateGroupSlice := func(old []types.UsercreateGroup) []to.UsercreateGroup {
+		var res []to.UsercreateGroup
+		for _, g := range old {
+			res = append(res, to.UsercreateGroup(g))
+		}
+		return res
+	}
+	translatePasswdUsercreate := func(old *types.Usercreate) *to.Usercreate {
+		if old == nil {
+			return nil
+		}
+		return &to.Usercreate{
+			Gecos:        old.Gecos,
+			Groups:       translatePasswdUsercreateGroupSlice(old.Groups),
+			HomeDir:      old.HomeDir,
+			NoCreateHome: old.NoCreateHome,
+			NoLogInit:    old.NoLogInit,
+			NoUserGroup:  old.NoUserGroup,
+			PrimaryGroup: old.PrimaryGroup,
+			Shell:        old.Shell,
+			System:       old.System,
+			UID:          old.UID,
+		}
+	}
+	translatePasswdUserGroupSlice := func(old []types.Group) []to.Group {
+		var res []to.Group
+		for _, g := range old {
+			res = append(res, to.Group(g))
+		}
+		return res
+	}
+	translatePasswdSSHAuthorizedKeySlice := func(old []types.SSHAuthorizedKey) []to.SSHAuthorizedKey {
+		res := make([]to.SSHAuthorizedKey, len(old))
+		for i, k := range old {
+			res[i] = to.SSHAuthorizedKey(k)
+		}
+		return res
+	}
+	translatePasswdUserSlice := func(old []types.PasswdUser) []to.PasswdUser {
+		var res []to.PasswdUser
+		for _, u := range old {
+			res = append(res, to.PasswdUser{
+				Create:            translatePasswdUsercreate(u.Create),
+				Gecos:             u.Gecos,
+				Groups:            translatePasswdUserGroupSlice(u.Groups),
+				HomeDir:           u.HomeDir,
+				Name:              u.Name,
+				NoCreateHome:      u.NoCreateHome,
+				NoLogInit:         u.NoLogInit,
+				NoUserGroup:       u.NoUserGroup,
+				PasswordHash:      u.PasswordHash,
+				PrimaryGroup:      u.PrimaryGroup,
+				SSHAuthorizedKeys: translatePasswdSSHAuthorizedKeySlice(u.SSHAuthorizedKeys),
+				Shell:             u.Shell,
+				System:            u.System,
+				UID:               u.UID,
+			})
+		}
+		return res
+	}
+	translateNodeGroup := func(old *types.NodeGroup) *to.NodeGroup {
+		if old == nil {
+			return nil
+		}
+		return &to.NodeGroup{
+			ID:   old.ID,
+			Name: old.Name,
+		}
+	}
+	translateNodeUser := func(old *types.NodeUser) *to.NodeUser {
+		if old == nil {
+			return nil
+		}
+		return &to.NodeUser{
+			ID:   old.ID,
+			Name: old.Name,
+		}
+	}
+	translateNodeXattrSlice := func(old []types.NodeXattr) []to.NodeXattr {
+		var res []to.NodeXattr
+		for _, x := range old {
+			res = append(res, to.NodeXattr{
+				Name:  x.Name,
+				Value: x.Value,
+			})
+		}
+		return res
+	}
+	translateNode := func(old types.Node) to.Node {
+		return to.Node{
+			Filesystem:     old.Filesystem,
+			Group:          translateNodeGroup(old.Group),
+			Path:           old.Path,
+			User:           translateNodeUser(old.User),
+			Overwrite:      old.Overwrite,
+			SELinuxContext: old.SELinuxContext,
+			Xattrs:         translateNodeXattrSlice(old.Xattrs),
+		}
+	}
+	translateDirectorySlice := func(old []types.Directory) []to.Directory {
+		var res []to.Directory
+		for _, x := range old {
+			res = append(res, to.Directory{
+				Node: translateNode(x.Node),
+				DirectoryEmbedded1: to.DirectoryEmbedded1{
+					Mode: x.DirectoryEmbedded1.Mode,
+				},
+			})
+		}
+		return res
+	}
+	translateImage := func(old *types.Image) *to.Image {
+		if old == nil {
+			return nil
+		}
+		return &to.Image{
+			Compression: old.Compression,
+			Source:      old.Source,
+			Verification: to.Verification{
+				Hash: old.Verification.Hash,
+			},
+			HTTPHeaders: translateHTTPHeaderSlice(old.HTTPHeaders),
+		}
+	}
+	translatePartitionAttributeSlice := func(old []types.PartitionAttribute) []to.PartitionAttribute {
+		var res []to.PartitionAttribute
+		for _, x := range old {
+			res = append(res, to.PartitionAttribute(x))
+		}
+		return res
+	}
+	translatePartitionSlice := func(old []types.Partition) []to.Partition {
+		var res []to.Partition
+		for _, x := range old {
+			res = append(res, to.Partition{
+				Attributes:         translatePartitionAttributeSlice(x.Attributes),
+				Bootable:           x.Bootable,
+				GUID:               x.GUID,
+				Image:              translateImage(x.Image),
+				Label:              x.Label,
+				MbrType:            x.MbrType,
+				Number:             x.Number,
+				Resize:             x.Resize,
+				Size:               x.Size,
+				SizeMiB:            x.SizeMiB,
+				Start:              x.Start,
+				StartMiB:           x.StartMiB,
+				TypeGUID:           x.TypeGUID,
+				ShouldExist:        x.ShouldExist,
+				WipePartitionEntry: x.WipePartitionEntry,
+			})
+		}
+		return res
+	}
+	translateDiskSelector := func(old *types.DiskSelector) *to.DiskSelector {
+		if old == nil {
+			return nil
+		}
+		return &to.DiskSelector{
+			LargestUnused: old.LargestUnused,
+			MaxSizeMiB:    old.MaxSizeMiB,
+			MinSizeMiB:    old.MinSizeMiB,
+			Model:         old.Model,
+			Rotational:    old.Rotational,
+			Serial:        old.Serial,
+			Transport:     old.Transport,
+			WWN:           old.WWN,
+		}
+	}
+	translateDiskSlice := func(old []types.Disk) []to.Disk {
+		var res []to.Disk
+		for _, x := range old {
+			res = append(res, to.Disk{
+				Device:     x.Device,
+				Image:      translateImage(x.Image),
+				Partitions: translatePartitionSlice(x.Partitions),
+				Selector:   translateDiskSelector(x.Selector),
+				TableType:  x.TableType,
+				WipeTable:  x.WipeTable,
+			})
+		}
+		return res
+	}
+	translateFileEditSlice := func(old []types.FileEdit) []to.FileEdit {
+		var res []to.FileEdit
+		for _, x := range old {
+			res = append(res, to.FileEdit{
+				Action:      x.Action,
+				Key:         x.Key,
+				Line:        x.Line,
+				Regex:       x.Regex,
+				Replacement: x.Replacement,
+				Section:     x.Section,
+				Value:       x.Value,
+			})
+		}
+		return res
+	}
+	translateFileSlice := func(old []types.File) []to.File {
+		var res []to.File
+		for _, x := range old {
+			res = append(res, to.File{
+				Node: translateNode(x.Node),
+				FileEmbedded1: to.FileEmbedded1{
+					Contents: to.FileContents{
+						Compression: x.Contents.Compression,
+						Source:      x.Contents.Source,
+						Template:    x.Contents.Template,
+						Verification: to.Verification{
+							Hash: x.Contents.Verification.Hash,
+						},
+						HTTPHeaders: translateHTTPHeaderSlice(x.Contents.HTTPHeaders),
+					},
+					Mode:   x.Mode,
+					Append: x.Append,
+					Edits:  translateFileEditSlice(x.Edits),
+				},
+			})
+		}
+		return res
+	}
+	translateArchiveSlice := func(old []types.Archive) []to.Archive {
+		var res []to.Archive
+		for _, x := range old {
+			res = append(res, to.Archive{
+				Node: translateNode(x.Node),
+				ArchiveEmbedded1: to.ArchiveEmbedded1{
+					Contents: to.FileContents{
+						Compression: x.Contents.Compression,
+						Source:      x.Contents.Source,
+						Template:    x.Contents.Template,
+						Verification: to.Verification{
+							Hash: x.Contents.Verification.Hash,
+						},
+						HTTPHeaders: translateHTTPHeaderSlice(x.Contents.HTTPHeaders),
+					},
+					DirectoryMode: x.DirectoryMode,
+					FileMode:      x.FileMode,
+					Format:        x.Format,
+				},
+			})
+		}
+		return res
+	}
+	translateRemovalSlice := func(old []types.Removal) []to.Removal {
+		var res []to.Removal
+		for _, x := range old {
+			res = append(res, to.Removal{
+				Filesystem: x.Filesystem,
+				MustExist:  x.MustExist,
+				Path:       x.Path,
+				Recursive:  x.Recursive,
+			})
+		}
+		return res
+	}
+	translateMountCreateOptionSlice := func(old []types.CreateOption) []to.CreateOption {
+		var res []to.CreateOption
+		for _, x := range old {
+			res = append(res, to.CreateOption(x))
+		}
+		return res
+	}
+	translateMountCreate := func(old *types.Create) *to.Create {
+		if old == nil {
+			return nil
+		}
+		return &to.Create{
+			Force:   old.Force,
+			Options: translateMountCreateOptionSlice(old.Options),
+		}
+	}
+	translateMountOptionSlice := func(old []types.MountOption) []to.MountOption {
+		var res []to.MountOption
+		for _, x := range old {
+			res = append(res, to.MountOption(x))
+		}
+		return res
+	}
+	translateSubvolumeSlice := func(old []types.Subvolume) []to.Subvolume {
+		var res []to.Subvolume
+		for _, x := range old {
+			res = append(res, to.Subvolume{
+				Name:           x.Name,
+				NoCopyOnWrite:  x.NoCopyOnWrite,
+				Path:           x.Path,
+				QgroupLimitMiB: x.QgroupLimitMiB,
+			})
+		}
+		return res
+	}
+	translateMount := func(old *types.Mount) *to.Mount {
+		if old == nil {
+			return nil
+		}
+		return &to.Mount{
+			Create:         translateMountCreate(old.Create),
+			Device:         old.Device,
+			Format:         old.Format,
+			GrowFilesystem: old.GrowFilesystem,
+			Label:          old.Label,
+			Options:        translateMountOptionSlice(old.Options),
+			Subvolumes:     translateSubvolumeSlice(old.Subvolumes),
+			UUID:           old.UUID,
+			WipeFilesystem: old.WipeFilesystem,
+		}
+	}
+	translateFilesystemSlice := func(old []types.Filesystem) []to.Filesystem {
+		var res []to.Filesystem
+		for _, x := range old {
+			res = append(res, to.Filesystem{
+				Mount: translateMount(x.Mount),
+				Name:  x.Name,
+				Path:  x.Path,
+			})
+		}
+		return res
+	}
+	translateLinkSlice := func(old []types.Link) []to.Link {
+		var res []to.Link
+		for _, x := range old {
+			res = append(res, to.Link{
+				Node: translateNode(x.Node),
+				LinkEmbedded1: to.LinkEmbedded1{
+					Hard:   x.Hard,
+					Target: x.Target,
+				},
+			})
+		}
+		return res
+	}
+	translateDeviceSlice := func(old []types.Device) []to.Device {
+		var res []to.Device
+		for _, x := range old {
+			res = append(res, to.Device(x))
+		}
+		return res
+	}
+	translateRaidOptionSlice := func(old []types.RaidOption) []to.RaidOption {
+		var res []to.RaidOption
+		for _, x := range old {
+			res = append(res, to.RaidOption(x))
+		}
+		return res
+	}
+	translateRaidSlice := func(old []types.Raid) []to.Raid {
+		var res []to.Raid
+		for _, x := range old {
+			res = append(res, to.Raid{
+				Devices:        translateDeviceSlice(x.Devices),
+				Level:          x.Level,
+				Name:           x.Name,
+				Spares:         x.Spares,
+				Options:        translateRaidOptionSlice(x.Options),
+				UUID:           x.UUID,
+				WaitForSync:    x.WaitForSync,
+				WipeArray:      x.WipeArray,
+				WriteMdadmConf: x.WriteMdadmConf,
+			})
+		}
+		return res
+	}
+	translateLogicalVolumeOptionSlice := func(old []types.LogicalVolumeOption) []to.LogicalVolumeOption {
+		var res []to.LogicalVolumeOption
+		for _, x := range old {
+			res = append(res, to.LogicalVolumeOption(x))
+		}
+		return res
+	}
+	translateLogicalVolumeSlice := func(old []types.LogicalVolume) []to.LogicalVolume {
+		var res []to.LogicalVolume
+		for _, x := range old {
+			res = append(res, to.LogicalVolume{
+				Name:        x.Name,
+				Options:     translateLogicalVolumeOptionSlice(x.Options),
+				PercentFree: x.PercentFree,
+				Pool:        x.Pool,
+				SizeMiB:     x.SizeMiB,
+				Type:        x.Type,
+			})
+		}
+		return res
+	}
+	translateVolumeGroupOptionSlice := func(old []types.VolumeGroupOption) []to.VolumeGroupOption {
+		var res []to.VolumeGroupOption
+		for _, x := range old {
+			res = append(res, to.VolumeGroupOption(x))
+		}
+		return res
+	}
+	translateVolumeGroupSlice := func(old []types.VolumeGroup) []to.VolumeGroup {
+		var res []to.VolumeGroup
+		for _, x := range old {
+			res = append(res, to.VolumeGroup{
+				LogicalVolumes:  translateLogicalVolumeSlice(x.LogicalVolumes),
+				Name:            x.Name,
+				Options:         translateVolumeGroupOptionSlice(x.Options),
+				PhysicalVolumes: translateDeviceSlice(x.PhysicalVolumes),
+			})
+		}
+		return res
+	}
+	translateLuksOptionSlice := func(old []types.LuksOption) []to.LuksOption {
+		var res []to.LuksOption
+		for _, x := range old {
+			res = append(res, to.LuksOption(x))
+		}
+		return res
+	}
+	translateLuksSlice := func(old []types.Luks) []to.Luks {
+		var res []to.Luks
+		for _, x := range old {
+			res = append(res, to.Luks{
+				Device:  x.Device,
+				Discard: x.Discard,
+				KeyFile: to.FileContents{
+					Compression: x.KeyFile.Compression,
+					Source:      x.KeyFile.Source,
+					Template:    x.KeyFile.Template,
+					Verification: to.Verification{
+						Hash: x.KeyFile.Verification.Hash,
+					},
+					HTTPHeaders: translateHTTPHeaderSlice(x.KeyFile.HTTPHeaders),
+				},
+				Label:      x.Label,
+				Name:       x.Name,
+				Options:    translateLuksOptionSlice(x.Options),
+				UUID:       x.UUID,
+				WipeVolume: x.WipeVolume,
+			})
+		}
+		return res
+	}
+	translateSystemdDropinSlice := func(old []types.SystemdDropin) []to.SystemdDropin {
+		var res []to.SystemdDropin
+		for _, x := range old {
+			res = append(res, to.SystemdDropin{
+				Contents: x.Contents,
+				Name:     x.Name,
+				Template: x.Template,
+			})
+		}
+		return res
+	}
+	translateSystemdUnitSlice := func(old []types.Unit) []to.Unit {
+		var res []to.Unit
+		for _, x := range old {
+			res = append(res, to.Unit{
+				Contents: x.Contents,
+				Dropins:  translateSystemdDropinSlice(x.Dropins),
+				Enable:   x.Enable,
+				Enabled:  x.Enabled,
+				Mask:     x.Mask,
+				Name:     x.Name,
+				Template: x.Template,
+			})
+		}
+		return res
+	}
+	config := to.Config{
+		Ignition: to.Ignition{
+			Version: to.MaxVersion.String(),
+			Timeouts: to.Timeouts{
+				HTTPResponseHeaders: old.Ignition.Timeouts.HTTPResponseHeaders,
+				HTTPTotal:           old.Ignition.Timeouts.HTTPTotal,
+			},
+			Config: to.IgnitionConfig{
+				Replace: translateConfigReference(old.Ignition.Config.Replace),
+				Append:  translateConfigReferenceSlice(old.Ignition.Config.Append),
+			},
+			Security: to.Security{
+				TLS: to.TLS{
+					CertificateAuthorities: translateCertificateAuthoritySlice(old.Ignition.Security.TLS.CertificateAuthorities),
+				},
+			},
+			Proxy: to.Proxy{
+				HTTPProxy:  old.Ignition.Proxy.HTTPProxy,
+				HTTPSProxy: old.Ignition.Proxy.HTTPSProxy,
+				NoProxy:    translateNoProxySlice(old.Ignition.Proxy.NoProxy),
+			},
+		},
+		Networkd: to.Networkd{
+			Units: translateNetworkdUnitSlice(old.Networkd.Units),
+		},
+		Passwd: to.Passwd{
+			Groups: translatePasswdGroupSlice(old.Passwd.Groups),
+			Users:  translatePasswdUserSlice(old.Passwd.Users),
+		},
+		Storage: to.Storage{
+			Archives:    translateArchiveSlice(old.Storage.Archives),
+			Directories: translateDirectorySlice(old.Storage.Directories),
+			Disks:       translateDiskSlice(old.Storage.Disks),
+			Files:       translateFileSlice(old.Storage.Files),
+			Filesystems: translateFilesystemSlice(old.Storage.Filesystems),
+			Links:       translateLinkSlice(old.Storage.Links),
+			Luks:        translateLuksSlice(old.Storage.Luks),
+			Lvm: to.Lvm{
+				VolumeGroups: translateVolumeGroupSlice(old.Storage.Lvm.VolumeGroups),
+			},
+			Raid:   translateRaidSlice(old.Storage.Raid),
+			Remove: translateRemovalSlice(old.Storage.Remove),
+		},
+		Systemd: to.Systemd{
+			Units: translateSystemdUnitSlice(old.Systemd.Units),
+		},
+	}
+	return config
+}
@@ -0,0 +1,280 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	to "github.com/flatcar/ignition/config/v2_4/types"
+)
+
+func TestTranslateToV2_4(t *testing.T) {
+	tests := []to.Config{
+		{
+			Ignition: to.Ignition{Version: to.MaxVersion.String()},
+		},
+		{
+			Ignition: to.Ignition{
+				Version: to.MaxVersion.String(),
+				Config: to.IgnitionConfig{
+					Append: []to.ConfigReference{
+						{
+							Source:       "https://example.com/a.ign",
+							HTTPHeaders:  to.HTTPHeaders{{Name: "X-Auth", Value: "secret"}},
+							Verification: to.Verification{Hash: strToPtr("sha512-0123")},
+						},
+					},
+					Replace: &to.ConfigReference{Source: "data:,replace"},
+				},
+				Proxy: to.Proxy{
+					HTTPProxy: "http://proxy",
+					NoProxy:   []to.NoProxyItem{"example.com"},
+				},
+				Security: to.Security{
+					TLS: to.TLS{
+						CertificateAuthorities: []to.CaReference{{Source: "data:,ca"}},
+					},
+				},
+				Timeouts: to.Timeouts{HTTPTotal: intToPtr(10)},
+			},
+			Storage: to.Storage{
+				Disks: []to.Disk{
+					{
+						Device:    "/dev/sda",
+						WipeTable: true,
+						Partitions: []to.Partition{
+							{
+								Label:       strToPtr("ROOT"),
+								Number:      1,
+								Resize:      true,
+								SizeMiB:     intToPtr(1024),
+								StartMiB:    intToPtr(0),
+								TypeGUID:    "4F68BCE3-E8CD-4DB1-96E7-FBCAF984B709",
+								Attributes:  []to.PartitionAttribute{"2", "priority=1", "successful"},
+								ShouldExist: boolToPtr(true),
+								Image: &to.Image{
+									Source:      "https://example.com/root.img.bz2",
+									Compression: "bzip2",
+								},
+							},
+						},
+					},
+					{
+						Selector: &to.DiskSelector{
+							LargestUnused: true,
+							MaxSizeMiB:    intToPtr(2097152),
+							Serial:        strToPtr("^S4EW"),
+							WWN:           strToPtr("^eui\\."),
+						},
+					},
+					{
+						Device:    "/dev/sde",
+						TableType: "dos",
+						Partitions: []to.Partition{
+							{
+								Number:   1,
+								SizeMiB:  intToPtr(512),
+								MbrType:  "0c",
+								Bootable: true,
+							},
+						},
+					},
+					{
+						Device: "/dev/sdd",
+						Image: &to.Image{
+							Source:      "https://example.com/disk.img",
+							HTTPHeaders: to.HTTPHeaders{{Name: "Authorization", Value: "Basic"}},
+							Verification: to.Verification{
+								Hash: strToPtr("sha512-00000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000"),
+							},
+						},
+					},
+				},
+				Raid: []to.Raid{
+					{
+						Name:           "md0",
+						Level:          "raid1",
+						Devices:        []to.Device{"/dev/sdb", "/dev/sdc"},
+						Spares:         1,
+						Options:        []to.RaidOption{"--verbose"},
+						UUID:           strToPtr("b8c4e0a2-57d3-4a41-9e5c-0d6f1f3a7b21"),
+						WriteMdadmConf: true,
+					},
+				},
+				Lvm: to.Lvm{
+					VolumeGroups: []to.VolumeGroup{
+						{
+							Name:            "data",
+							PhysicalVolumes: []to.Device{"/dev/disk/by-id/dm-name-secret"},
+							Options:         []to.VolumeGroupOption{"--physicalextentsize", "8m"},
+							LogicalVolumes: []to.LogicalVolume{
+								{Name: "pool", Type: "thin-pool", PercentFree: intToPtr(90)},
+								{Name: "docker", Type: "thin", Pool: strToPtr("pool"), SizeMiB: intToPtr(10240)},
+								{Name: "log", SizeMiB: intToPtr(1024), Options: []to.LogicalVolumeOption{"--zero", "y"}},
+							},
+						},
+					},
+				},
+				Luks: []to.Luks{
+					{
+						Name:   "secret",
+						Device: "/dev/md/md0",
+						KeyFile: to.FileContents{
+							Source: "https://example.com/key",
+							Verification: to.Verification{
+								Hash: strToPtr("sha512-00000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000"),
+							},
+						},
+						Label:      strToPtr("SECRET"),
+						UUID:       strToPtr("8A7A6E26-5E8F-4CCA-A654-46215D4696AC"),
+						Options:    []to.LuksOption{"--cipher", "aes-xts-plain64"},
+						WipeVolume: true,
+						Discard:    true,
+					},
+				},
+				Filesystems: []to.Filesystem{
+					{
+						Name: "data",
+						Mount: &to.Mount{
+							Device:         "/dev/md/md0",
+							Format:         "ext4",
+							GrowFilesystem: true,
+							Label:          strToPtr("DATA"),
+							WipeFilesystem: true,
+							Options:        []to.MountOption{"-b", "4096"},
+						},
+					},
+					{
+						Name: "legacy",
+						Path: strToPtr("/legacy"),
+					},
+				},
+				Files: []to.File{
+					{
+						Node: to.Node{
+							Filesystem:     "data",
+							Path:           "/motd",
+							Overwrite:      boolToPtr(false),
+							User:           &to.NodeUser{Name: "core"},
+							Group:          &to.NodeGroup{ID: intToPtr(500)},
+							SELinuxContext: "system_u:object_r:etc_t:s0",
+							Xattrs: []to.NodeXattr{
+								{Name: "user.agent.role", Value: "worker"},
+								{Name: "user.empty"},
+							},
+						},
+						FileEmbedded1: to.FileEmbedded1{
+							Append: true,
+							Mode:   intToPtr(0644),
+							Contents: to.FileContents{
+								Compression: "gzip",
+								Source:      "https://example.com/motd.gz",
+								Template:    true,
+							},
+						},
+					},
+					{
+						Node: to.Node{Filesystem: "root", Path: "/etc/systemd/journald.conf"},
+						FileEmbedded1: to.FileEmbedded1{
+							Edits: []to.FileEdit{
+								{Action: "setKey", Section: "Journal", Key: "Storage", Value: "persistent"},
+								{Action: "unsetKey", Section: "Journal", Key: "SystemMaxUse"},
+								{Action: "replace", Regex: "^#(Compress)=.*$", Replacement: "${1}=yes"},
+								{Action: "ensureLine", Line: "ForwardToSyslog=no", Regex: "^#?ForwardToSyslog="},
+								{Action: "deleteLines", Regex: "^#"},
+							},
+						},
+					},
+				},
+				Directories: []to.Directory{
+					{
+						Node:               to.Node{Filesystem: "root", Path: "/opt"},
+						DirectoryEmbedded1: to.DirectoryEmbedded1{Mode: intToPtr(0755)},
+					},
+				},
+				Links: []to.Link{
+					{
+						Node:          to.Node{Filesystem: "root", Path: "/etc/localtime"},
+						LinkEmbedded1: to.LinkEmbedded1{Target: "/usr/share/zoneinfo/UTC", Hard: false},
+					},
+				},
+				Remove: []to.Removal{
+					{Filesystem: "root", Path: "/etc/motd", MustExist: true},
+					{Filesystem: "root", Path: "/etc/ssh/sshd_config.d", Recursive: true},
+				},
+				Archives: []to.Archive{
+					{
+						Node: to.Node{Filesystem: "root", Path: "/opt/app", Overwrite: boolToPtr(true)},
+						ArchiveEmbedded1: to.ArchiveEmbedded1{
+							Format: "tar",
+							Contents: to.FileContents{
+								Compression: "gzip",
+								Source:      "https://example.com/app.tar.gz",
+								Verification: to.Verification{
+									Hash: strToPtr("sha512-0123"),
+								},
+							},
+							DirectoryMode: intToPtr(0755),
+							FileMode:      intToPtr(0644),
+						},
+					},
+				},
+			},
+			Systemd: to.Systemd{
+				Units: []to.Unit{
+					{
+						Name:     "example.service",
+						Enabled:  boolToPtr(true),
+						Contents: "[Service]\nType=oneshot",
+						Template: true,
+						Dropins:  []to.SystemdDropin{{Name: "10-env.conf", Contents: "[Service]", Template: true}},
+					},
+					{
+						Name: "other.service",
+						Mask: true,
+					},
+				},
+			},
+			Networkd: to.Networkd{
+				Units: []to.Networkdunit{
+					{
+						Name:     "static.network",
+						Contents: "[Match]",
+						Dropins:  []to.NetworkdDropin{{Name: "10-dns.conf"}},
+					},
+				},
+			},
+			Passwd: to.Passwd{
+				Users: []to.PasswdUser{
+					{
+						Name:              "core",
+						PasswordHash:      strToPtr("hash"),
+						SSHAuthorizedKeys: []to.SSHAuthorizedKey{"ssh-rsa AAAA"},
+						Groups:            []to.Group{"wheel"},
+						UID:               intToPtr(1000),
+						Create:            &to.Usercreate{Shell: "/bin/bash", Groups: []to.UsercreateGroup{"sudo"}},
+					},
+				},
+				Groups: []to.PasswdGroup{{Name: "ops", Gid: intToPtr(2000), System: true}},
+			},
+		},
+	}
+
+	for i, test := range tests {
+		assert.Equal(t, test, TranslateToV2_4(Translate(test)), "#%d: bad round trip", i)
+	}
+}
@@ -0,0 +1,509 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	from "github.com/flatcar/ignition/config/v3_x/types"
+	"github.com/flatcar/ignition/config/validate/report"
+	"github.com/flatcar/ignition/internal/config/types"
+)
+
+// TranslateV3 translates a 3.x config into the internal config. Features of
+// the 3.x spec which have no equivalent in the 2.x spec that Ignition executes
+// are reported as errors.
+func TranslateV3(old from.Config) (types.Config, report.Report) {
+	r := report.Report{}
+	unsupported := func(format string, args ...interface{}) {
+		r.Add(report.Entry{
+			Kind:    report.EntryError,
+			Message: fmt.Sprintf(format, args...) + " has no equivalent in spec 2.x and is not supported",
+		})
+	}
+	str := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	boolean := func(b *bool) bool {
+		return b != nil && *b
+	}
+	integer := func(i *int) int {
+		if i == nil {
+			return 0
+		}
+		return *i
+	}
+
+	translateHTTPHeaderSlice := func(old from.HTTPHeaders) []types.HTTPHeader {
+		var res []types.HTTPHeader
+		for _, x := range old {
+			res = append(res, types.HTTPHeader{
+				Name:  x.Name,
+				Value: str(x.Value),
+			})
+		}
+		return res
+	}
+	translateConfigReference := func(old from.Resource, field string) *types.ConfigReference {
+		if old.Source == nil {
+			return nil
+		}
+		if old.Compression != nil && *old.Compression != "" {
+			unsupported("compression of %s", field)
+		}
+		return &types.ConfigReference{
+			Source: *old.Source,
+			Verification: types.Verification{
+				Hash: old.Verification.Hash,
+			},
+			HTTPHeaders: translateHTTPHeaderSlice(old.HTTPHeaders),
+		}
+	}
+	translateConfigReferenceSlice := func(old []from.Resource) []types.ConfigReference {
+		var res []types.ConfigReference
+		for _, c := range old {
+			if ref := translateConfigReference(c, "ignition.config.merge"); ref != nil {
+				res = append(res, *ref)
+			}
+		}
+		return res
+	}
+	translateCertificateAuthoritySlice := func(old []from.Resource) []types.CaReference {
+		var res []types.CaReference
+		for _, x := range old {
+			if x.Compression != nil && *x.Compression != "" {
+				unsupported("compression of certificate authorities")
+			}
+			res = append(res, types.CaReference{
+				Source: str(x.Source),
+				Verification: types.Verification{
+					Hash: x.Verification.Hash,
+				},
+				HTTPHeaders: translateHTTPHeaderSlice(x.HTTPHeaders),
+			})
+		}
+		return res
+	}
+	translateNoProxySlice := func(old []from.NoProxyItem) []types.NoProxyItem {
+		var res []types.NoProxyItem
+		for _, x := range old {
+			res = append(res, types.NoProxyItem(x))
+		}
+		return res
+	}
+	translatePasswdGroupSlice := func(old []from.PasswdGroup) []types.PasswdGroup {
+		var res []types.PasswdGroup
+		for _, g := range old {
+			if g.ShouldExist != nil && !*g.ShouldExist {
+				unsupported("removing group %q", g.Name)
+				continue
+			}
+			res = append(res, types.PasswdGroup{
+				Gid:          g.Gid,
+				Name:         g.Name,
+				PasswordHash: str(g.PasswordHash),
+				System:       boolean(g.System),
+			})
+		}
+		return res
+	}
+	translatePasswdUserGroupSlice := func(old []from.Group) []types.Group {
+		var res []types.Group
+		for _, g := range old {
+			res = append(res, types.Group(g))
+		}
+		return res
+	}
+	translatePasswdSSHAuthorizedKeySlice := func(old []from.SSHAuthorizedKey) []types.SSHAuthorizedKey {
+		res := make([]types.SSHAuthorizedKey, len(old))
+		for i, k := range old {
+			res[i] = types.SSHAuthorizedKey(k)
+		}
+		return res
+	}
+	translatePasswdUserSlice := func(old []from.PasswdUser) []types.PasswdUser {
+		var res []types.PasswdUser
+		for _, u := range old {
+			if u.ShouldExist != nil && !*u.ShouldExist {
+				unsupported("removing user %q", u.Name)
+				continue
+			}
+			res = append(res, types.PasswdUser{
+				Gecos:             str(u.Gecos),
+				Groups:            translatePasswdUserGroupSlice(u.Groups),
+				HomeDir:           str(u.HomeDir),
+				Name:              u.Name,
+				NoCreateHome:      boolean(u.NoCreateHome),
+				NoLogInit:         boolean(u.NoLogInit),
+				NoUserGroup:       boolean(u.NoUserGroup),
+				PasswordHash:      u.PasswordHash,
+				PrimaryGroup:      str(u.PrimaryGroup),
+				SSHAuthorizedKeys: translatePasswdSSHAuthorizedKeySlice(u.SSHAuthorizedKeys),
+				Shell:             str(u.Shell),
+				System:            boolean(u.System),
+				UID:               u.UID,
+			})
+		}
+		return res
+	}
+
+	// In 3.x, nodes are addressed by their absolute path and land on the
+	// filesystem with the longest matching mount path. In 2.x, nodes name
+	// their filesystem and are relative to it, so each 3.x filesystem is
+	// given a name derived from its device.
+	mounted := map[string]from.Filesystem{}
+	var mountPaths []string
+	for _, fs := range old.Storage.Filesystems {
+		if fs.Path == nil || path.Clean(*fs.Path) == "/" {
+			continue
+		}
+		p := path.Clean(*fs.Path)
+		mounted[p] = fs
+		mountPaths = append(mountPaths, p)
+	}
+	// longest paths first
+	sort.Slice(mountPaths, func(i, j int) bool { return len(mountPaths[i]) > len(mountPaths[j]) })
+	resolveFilesystem := func(p string) (string, string) {
+		p = path.Clean(p)
+		for _, mnt := range mountPaths {
+			if p == mnt || strings.HasPrefix(p, mnt+"/") {
+				fs := mounted[mnt]
+				if fs.Format == nil || *fs.Format == "none" {
+					unsupported("placing %q on filesystem %q without a format", p, fs.Device)
+				}
+				return fs.Device, path.Join("/", strings.TrimPrefix(p, mnt))
+			}
+		}
+		return "root", p
+	}
+	translateNodeGroup := func(old from.NodeGroup) *types.NodeGroup {
+		if old.ID == nil && old.Name == nil {
+			return nil
+		}
+		return &types.NodeGroup{
+			ID:   old.ID,
+			Name: str(old.Name),
+		}
+	}
+	translateNodeUser := func(old from.NodeUser) *types.NodeUser {
+		if old.ID == nil && old.Name == nil {
+			return nil
+		}
+		return &types.NodeUser{
+			ID:   old.ID,
+			Name: str(old.Name),
+		}
+	}
+	translateNode := func(old from.Node) types.Node {
+		// overwrite defaults to false in 3.x
+		overwrite := false
+		if old.Overwrite != nil {
+			overwrite = *old.Overwrite
+		}
+		fs, p := resolveFilesystem(old.Path)
+		return types.Node{
+			Filesystem: fs,
+			Group:      translateNodeGroup(old.Group),
+			Path:       p,
+			User:       translateNodeUser(old.User),
+			Overwrite:  &overwrite,
+		}
+	}
+	translateDirectorySlice := func(old []from.Directory) []types.Directory {
+		var res []types.Directory
+		for _, x := range old {
+			res = append(res, types.Directory{
+				Node: translateNode(x.Node),
+				DirectoryEmbedded1: types.DirectoryEmbedded1{
+					Mode: x.DirectoryEmbedded1.Mode,
+				},
+			})
+		}
+		return res
+	}
+	translatePartitionSlice := func(old []from.Partition) []types.Partition {
+		var res []types.Partition
+		for _, x := range old {
+			res = append(res, types.Partition{
+				GUID:               str(x.GUID),
+				Label:              x.Label,
+				Number:             x.Number,
+				Resize:             boolean(x.Resize),
+				SizeMiB:            x.SizeMiB,
+				StartMiB:           x.StartMiB,
+				TypeGUID:           str(x.TypeGUID),
+				ShouldExist:        x.ShouldExist,
+				WipePartitionEntry: boolean(x.WipePartitionEntry),
+			})
+		}
+		return res
+	}
+	translateDiskSlice := func(old []from.Disk) []types.Disk {
+		var res []types.Disk
+		for _, x := range old {
+			res = append(res, types.Disk{
+				Device:     x.Device,
+				Partitions: translatePartitionSlice(x.Partitions),
+				WipeTable:  boolean(x.WipeTable),
+			})
+		}
+		return res
+	}
+	translateFileContents := func(old from.Resource) types.FileContents {
+		return types.FileContents{
+			Compression: str(old.Compression),
+			Source:      str(old.Source),
+			Verification: types.Verification{
+				Hash: old.Verification.Hash,
+			},
+			HTTPHeaders: translateHTTPHeaderSlice(old.HTTPHeaders),
+		}
+	}
+	translateFileSlice := func(old []from.File) []types.File {
+		var res []types.File
+		for _, x := range old {
+			node := translateNode(x.Node)
+			// 3.x files may set contents and a list of resources to append
+			// to them. 2.x expresses appending as separate entries for the
+			// same path, which are executed in order.
+			switch {
+			case x.Contents.Source == nil && len(x.Append) == 0 && !*node.Overwrite:
+				// Without contents, 3.x keeps an existing file and
+				// only applies the mode and owner, or creates an
+				// empty file. Appending nothing does the same.
+				keepNode := node
+				keepNode.Overwrite = nil
+				res = append(res, types.File{
+					Node: keepNode,
+					FileEmbedded1: types.FileEmbedded1{
+						Mode:   x.Mode,
+						Append: true,
+					},
+				})
+			case x.Contents.Source != nil || len(x.Append) == 0:
+				res = append(res, types.File{
+					Node: node,
+					FileEmbedded1: types.FileEmbedded1{
+						Contents: translateFileContents(x.Contents),
+						Mode:     x.Mode,
+					},
+				})
+			}
+			for _, a := range x.Append {
+				appendNode := node
+				appendNode.Overwrite = nil
+				res = append(res, types.File{
+					Node: appendNode,
+					FileEmbedded1: types.FileEmbedded1{
+						Contents: translateFileContents(a),
+						Mode:     x.Mode,
+						Append:   true,
+					},
+				})
+			}
+		}
+		return res
+	}
+	translateMountOptionSlice := func(old []from.FilesystemOption) []types.MountOption {
+		var res []types.MountOption
+		for _, x := range old {
+			res = append(res, types.MountOption(x))
+		}
+		return res
+	}
+	translateFilesystemSlice := func(old []from.Filesystem) []types.Filesystem {
+		var res []types.Filesystem
+		for _, x := range old {
+			if len(x.MountOptions) > 0 {
+				unsupported("mountOptions for filesystem %q", x.Device)
+			}
+			if x.Format == nil || *x.Format == "none" {
+				if boolean(x.WipeFilesystem) {
+					unsupported("wiping filesystem %q without a format", x.Device)
+				}
+				// nodes on such filesystems are reported by
+				// resolveFilesystem
+				continue
+			}
+			res = append(res, types.Filesystem{
+				Name: x.Device,
+				Mount: &types.Mount{
+					Device:         x.Device,
+					Format:         *x.Format,
+					Label:          x.Label,
+					Options:        translateMountOptionSlice(x.Options),
+					UUID:           x.UUID,
+					WipeFilesystem: boolean(x.WipeFilesystem),
+				},
+			})
+		}
+		return res
+	}
+	translateLinkSlice := func(old []from.Link) []types.Link {
+		var res []types.Link
+		for _, x := range old {
+			target := str(x.Target)
+			if boolean(x.Hard) {
+				// hard link targets are absolute paths in 3.x, resolve them
+				// the same way as the link itself
+				_, target = resolveFilesystem(target)
+			}
+			res = append(res, types.Link{
+				Node: translateNode(x.Node),
+				LinkEmbedded1: types.LinkEmbedded1{
+					Hard:   boolean(x.Hard),
+					Target: target,
+				},
+			})
+		}
+		return res
+	}
+	translateDeviceSlice := func(old []from.Device) []types.Device {
+		var res []types.Device
+		for _, x := range old {
+			res = append(res, types.Device(x))
+		}
+		return res
+	}
+	translateRaidOptionSlice := func(old []from.RaidOption) []types.RaidOption {
+		var res []types.RaidOption
+		for _, x := range old {
+			res = append(res, types.RaidOption(x))
+		}
+		return res
+	}
+	translateRaidSlice := func(old []from.Raid) []types.Raid {
+		var res []types.Raid
+		for _, x := range old {
+			res = append(res, types.Raid{
+				Devices: translateDeviceSlice(x.Devices),
+				Level:   str(x.Level),
+				Name:    x.Name,
+				Spares:  integer(x.Spares),
+				Options: translateRaidOptionSlice(x.Options),
+			})
+		}
+		return res
+	}
+	translateLuksOptionSlice := func(old []from.LuksOption) []types.LuksOption {
+		var res []types.LuksOption
+		for _, x := range old {
+			res = append(res, types.LuksOption(x))
+		}
+		return res
+	}
+	translateLuksSlice := func(old []from.Luks) []types.Luks {
+		var res []types.Luks
+		for _, x := range old {
+			clevis := x.Clevis
+			if len(clevis.Tang) > 0 || boolean(clevis.Tpm2) || clevis.Custom.Pin != nil {
+				unsupported("clevis for luks volume %q", x.Name)
+			}
+			if len(x.OpenOptions) > 0 {
+				unsupported("openOptions for luks volume %q", x.Name)
+			}
+			if x.KeyFile.Source == nil {
+				// 2.x volumes are only unlocked with a key file
+				unsupported("luks volume %q without a keyFile", x.Name)
+				continue
+			}
+			res = append(res, types.Luks{
+				Device:     str(x.Device),
+				Discard:    boolean(x.Discard),
+				KeyFile:    translateFileContents(x.KeyFile),
+				Label:      x.Label,
+				Name:       x.Name,
+				Options:    translateLuksOptionSlice(x.Options),
+				UUID:       x.UUID,
+				WipeVolume: boolean(x.WipeVolume),
+			})
+		}
+		return res
+	}
+	translateSystemdDropinSlice := func(old []from.Dropin) []types.SystemdDropin {
+		var res []types.SystemdDropin
+		for _, x := range old {
+			res = append(res, types.SystemdDropin{
+				Contents: str(x.Contents),
+				Name:     x.Name,
+			})
+		}
+		return res
+	}
+	translateSystemdUnitSlice := func(old []from.Unit) []types.Unit {
+		var res []types.Unit
+		for _, x := range old {
+			res = append(res, types.Unit{
+				Contents: str(x.Contents),
+				Dropins:  translateSystemdDropinSlice(x.Dropins),
+				Enabled:  x.Enabled,
+				Mask:     boolean(x.Mask),
+				Name:     x.Name,
+			})
+		}
+		return res
+	}
+
+	if len(old.KernelArguments.ShouldExist) > 0 || len(old.KernelArguments.ShouldNotExist) > 0 {
+		unsupported("kernelArguments")
+	}
+
+	config := types.Config{
+		Ignition: types.Ignition{
+			Version: old.Ignition.Version,
+			Timeouts: types.Timeouts{
+				HTTPResponseHeaders: old.Ignition.Timeouts.HTTPResponseHeaders,
+				HTTPTotal:           old.Ignition.Timeouts.HTTPTotal,
+			},
+			Config: types.IgnitionConfig{
+				Replace: translateConfigReference(old.Ignition.Config.Replace, "ignition.config.replace"),
+				Append:  translateConfigReferenceSlice(old.Ignition.Config.Merge),
+			},
+			Security: types.Security{
+				TLS: types.TLS{
+					CertificateAuthorities: translateCertificateAuthoritySlice(old.Ignition.Security.TLS.CertificateAuthorities),
+				},
+			},
+			Proxy: types.Proxy{
+				HTTPProxy:  str(old.Ignition.Proxy.HTTPProxy),
+				HTTPSProxy: str(old.Ignition.Proxy.HTTPSProxy),
+				NoProxy:    translateNoProxySlice(old.Ignition.Proxy.NoProxy),
+			},
+		},
+		Passwd: types.Passwd{
+			Groups: translatePasswdGroupSlice(old.Passwd.Groups),
+			Users:  translatePasswdUserSlice(old.Passwd.Users),
+		},
+		Storage: types.Storage{
+			Directories: translateDirectorySlice(old.Storage.Directories),
+			Disks:       translateDiskSlice(old.Storage.Disks),
+			Files:       translateFileSlice(old.Storage.Files),
+			Filesystems: translateFilesystemSlice(old.Storage.Filesystems),
+			Links:       translateLinkSlice(old.Storage.Links),
+			Luks:        translateLuksSlice(old.Storage.Luks),
+			Raid:        translateRaidSlice(old.Storage.Raid),
+		},
+		Systemd: types.Systemd{
+			Units: translateSystemdUnitSlice(old.Systemd.Units),
+		},
+	}
+	return config, r
+}
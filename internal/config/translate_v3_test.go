@@ -0,0 +1,298 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flatcar/ignition/config/util"
+	from "github.com/flatcar/ignition/config/v3_x/types"
+	"github.com/flatcar/ignition/config/validate/report"
+	"github.com/flatcar/ignition/internal/config/types"
+)
+
+func TestTranslateV3(t *testing.T) {
+	type in struct {
+		config from.Config
+	}
+	type out struct {
+		config types.Config
+		report report.Report
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{config: from.Config{Ignition: from.Ignition{Version: "3.0.0"}}},
+			out: out{config: types.Config{Ignition: types.Ignition{Version: "3.0.0"}}},
+		},
+		{
+			in: in{config: from.Config{
+				Ignition: from.Ignition{
+					Version: "3.1.0",
+					Config: from.IgnitionConfig{
+						Merge: []from.Resource{
+							{Source: util.StrToPtr("https://example.com/a.ign")},
+						},
+					},
+					Proxy: from.Proxy{
+						HTTPProxy: util.StrToPtr("http://proxy"),
+					},
+				},
+			}},
+			out: out{config: types.Config{
+				Ignition: types.Ignition{
+					Version: "3.1.0",
+					Config: types.IgnitionConfig{
+						Append: []types.ConfigReference{
+							{Source: "https://example.com/a.ign"},
+						},
+					},
+					Proxy: types.Proxy{
+						HTTPProxy: "http://proxy",
+					},
+				},
+			}},
+		},
+		{
+			in: in{config: from.Config{
+				Ignition: from.Ignition{Version: "3.0.0"},
+				Storage: from.Storage{
+					Filesystems: []from.Filesystem{{
+						Device: "/dev/disk/by-label/VAR",
+						Format: util.StrToPtr("xfs"),
+						Path:   util.StrToPtr("/var"),
+						Label:  util.StrToPtr("VAR"),
+					}},
+					Files: []from.File{
+						{
+							Node: from.Node{Path: "/var/lib/a"},
+							FileEmbedded1: from.FileEmbedded1{
+								Contents: from.Resource{Source: util.StrToPtr("data:,a")},
+								Append:   []from.Resource{{Source: util.StrToPtr("data:,b")}},
+								Mode:     util.IntToPtr(0644),
+							},
+						},
+						{
+							Node: from.Node{
+								Path:      "/etc/b",
+								Overwrite: util.BoolToPtr(true),
+								User:      from.NodeUser{Name: util.StrToPtr("core")},
+							},
+							FileEmbedded1: from.FileEmbedded1{
+								Contents: from.Resource{Source: util.StrToPtr("data:,b")},
+							},
+						},
+						{
+							Node: from.Node{
+								Path:  "/etc/c",
+								Group: from.NodeGroup{ID: util.IntToPtr(4)},
+							},
+							FileEmbedded1: from.FileEmbedded1{
+								Mode: util.IntToPtr(0640),
+							},
+						},
+						{
+							Node: from.Node{
+								Path:      "/etc/d",
+								Overwrite: util.BoolToPtr(true),
+							},
+						},
+					},
+				},
+			}},
+			out: out{config: types.Config{
+				Ignition: types.Ignition{Version: "3.0.0"},
+				Storage: types.Storage{
+					Filesystems: []types.Filesystem{{
+						Name: "/dev/disk/by-label/VAR",
+						Mount: &types.Mount{
+							Device: "/dev/disk/by-label/VAR",
+							Format: "xfs",
+							Label:  util.StrToPtr("VAR"),
+						},
+					}},
+					Files: []types.File{
+						{
+							Node: types.Node{
+								Filesystem: "/dev/disk/by-label/VAR",
+								Path:       "/lib/a",
+								Overwrite:  util.BoolToPtr(false),
+							},
+							FileEmbedded1: types.FileEmbedded1{
+								Contents: types.FileContents{Source: "data:,a"},
+								Mode:     util.IntToPtr(0644),
+							},
+						},
+						{
+							Node: types.Node{
+								Filesystem: "/dev/disk/by-label/VAR",
+								Path:       "/lib/a",
+							},
+							FileEmbedded1: types.FileEmbedded1{
+								Contents: types.FileContents{Source: "data:,b"},
+								Mode:     util.IntToPtr(0644),
+								Append:   true,
+							},
+						},
+						{
+							Node: types.Node{
+								Filesystem: "root",
+								Path:       "/etc/b",
+								Overwrite:  util.BoolToPtr(true),
+								User:       &types.NodeUser{Name: "core"},
+							},
+							FileEmbedded1: types.FileEmbedded1{
+								Contents: types.FileContents{Source: "data:,b"},
+							},
+						},
+						// an existing file only gets the mode and owner
+						{
+							Node: types.Node{
+								Filesystem: "root",
+								Path:       "/etc/c",
+								Group:      &types.NodeGroup{ID: util.IntToPtr(4)},
+							},
+							FileEmbedded1: types.FileEmbedded1{
+								Mode:   util.IntToPtr(0640),
+								Append: true,
+							},
+						},
+						{
+							Node: types.Node{
+								Filesystem: "root",
+								Path:       "/etc/d",
+								Overwrite:  util.BoolToPtr(true),
+							},
+						},
+					},
+				},
+			}},
+		},
+		{
+			in: in{config: from.Config{
+				Ignition: from.Ignition{Version: "3.2.0"},
+				Storage: from.Storage{
+					Disks: []from.Disk{{
+						Device: "/dev/vda",
+						Partitions: []from.Partition{{
+							Label:  util.StrToPtr("ROOT"),
+							Number: 9,
+							Resize: util.BoolToPtr(true),
+						}},
+					}},
+				},
+			}},
+			out: out{config: types.Config{
+				Ignition: types.Ignition{Version: "3.2.0"},
+				Storage: types.Storage{
+					Disks: []types.Disk{{
+						Device: "/dev/vda",
+						Partitions: []types.Partition{{
+							Label:  util.StrToPtr("ROOT"),
+							Number: 9,
+							Resize: true,
+						}},
+					}},
+				},
+			}},
+		},
+		{
+			in: in{config: from.Config{
+				Ignition: from.Ignition{Version: "3.2.0"},
+				Storage: from.Storage{
+					Luks: []from.Luks{
+						{
+							Name:   "data",
+							Device: util.StrToPtr("/dev/vdb"),
+							KeyFile: from.Resource{
+								Source: util.StrToPtr("data:,secret"),
+							},
+							Label:      util.StrToPtr("DATA"),
+							Options:    []from.LuksOption{"--iter-time", "1000"},
+							WipeVolume: util.BoolToPtr(true),
+						},
+						{
+							Name:   "tang",
+							Device: util.StrToPtr("/dev/vdc"),
+							Clevis: from.Clevis{
+								Tang: []from.Tang{{URL: "http://tang.example.com"}},
+							},
+						},
+					},
+				},
+			}},
+			out: out{
+				config: types.Config{
+					Ignition: types.Ignition{Version: "3.2.0"},
+					Storage: types.Storage{
+						Luks: []types.Luks{{
+							Name:       "data",
+							Device:     "/dev/vdb",
+							KeyFile:    types.FileContents{Source: "data:,secret"},
+							Label:      util.StrToPtr("DATA"),
+							Options:    []types.LuksOption{"--iter-time", "1000"},
+							WipeVolume: true,
+						}},
+					},
+				},
+				report: report.Report{Entries: []report.Entry{
+					{
+						Kind:    report.EntryError,
+						Message: `clevis for luks volume "tang" has no equivalent in spec 2.x and is not supported`,
+					},
+					{
+						Kind:    report.EntryError,
+						Message: `luks volume "tang" without a keyFile has no equivalent in spec 2.x and is not supported`,
+					},
+				}},
+			},
+		},
+		{
+			in: in{config: from.Config{
+				Ignition: from.Ignition{Version: "3.3.0"},
+				KernelArguments: from.KernelArguments{
+					ShouldExist: []from.KernelArgument{"quiet"},
+				},
+				Passwd: from.Passwd{
+					Users: []from.PasswdUser{{Name: "core", ShouldExist: util.BoolToPtr(false)}},
+				},
+			}},
+			out: out{
+				config: types.Config{Ignition: types.Ignition{Version: "3.3.0"}},
+				report: report.Report{Entries: []report.Entry{
+					{
+						Kind:    report.EntryError,
+						Message: "kernelArguments has no equivalent in spec 2.x and is not supported",
+					},
+					{
+						Kind:    report.EntryError,
+						Message: `removing user "core" has no equivalent in spec 2.x and is not supported`,
+					},
+				}},
+			},
+		},
+	}
+
+	for i, test := range tests {
+		config, r := TranslateV3(test.in.config)
+		assert.Equal(t, test.out.config, config, "#%d: bad config", i)
+		assert.Equal(t, test.out.report, r, "#%d: bad report", i)
+	}
+}
@@ -2,6 +2,18 @@ package types
 
 // generated by "schematyper --package=types schema/ignition.json -o internal/config/types/schema.go --root-type=Config" -- DO NOT EDIT
 
+type Archive struct {
+	Node
+	ArchiveEmbedded1
+}
+
+type ArchiveEmbedded1 struct {
+	Contents      FileContents `json:"contents,omitempty"`
+	DirectoryMode *int         `json:"directoryMode,omitempty"`
+	FileMode      *int         `json:"fileMode,omitempty"`
+	Format        string       `json:"format"`
+}
+
 type CaReference struct {
 	HTTPHeaders  HTTPHeaders  `json:"httpHeaders,omitempty"`
 	Source       string       `json:"source"`
@@ -41,9 +53,23 @@ type DirectoryEmbedded1 struct {
 }
 
 type Disk struct {
-	Device     string      `json:"device"`
-	Partitions []Partition `json:"partitions,omitempty"`
-	WipeTable  bool        `json:"wipeTable,omitempty"`
+	Device     string        `json:"device,omitempty"`
+	Image      *Image        `json:"image,omitempty"`
+	Partitions []Partition   `json:"partitions,omitempty"`
+	Selector   *DiskSelector `json:"selector,omitempty"`
+	TableType  string        `json:"tableType,omitempty"`
+	WipeTable  bool          `json:"wipeTable,omitempty"`
+}
+
+type DiskSelector struct {
+	LargestUnused bool    `json:"largestUnused,omitempty"`
+	MaxSizeMiB    *int    `json:"maxSizeMiB,omitempty"`
+	MinSizeMiB    *int    `json:"minSizeMiB,omitempty"`
+	Model         *string `json:"model,omitempty"`
+	Rotational    *bool   `json:"rotational,omitempty"`
+	Serial        *string `json:"serial,omitempty"`
+	Transport     *string `json:"transport,omitempty"`
+	WWN           *string `json:"wwn,omitempty"`
 }
 
 type File struct {
@@ -55,12 +81,24 @@ type FileContents struct {
 	Compression  string       `json:"compression,omitempty"`
 	HTTPHeaders  HTTPHeaders  `json:"httpHeaders,omitempty"`
 	Source       string       `json:"source,omitempty"`
+	Template     bool         `json:"template,omitempty"`
 	Verification Verification `json:"verification,omitempty"`
 }
 
+type FileEdit struct {
+	Action      string `json:"action"`
+	Key         string `json:"key,omitempty"`
+	Line        string `json:"line,omitempty"`
+	Regex       string `json:"regex,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+	Section     string `json:"section,omitempty"`
+	Value       string `json:"value,omitempty"`
+}
+
 type FileEmbedded1 struct {
 	Append   bool         `json:"append,omitempty"`
 	Contents FileContents `json:"contents,omitempty"`
+	Edits    []FileEdit   `json:"edits,omitempty"`
 	Mode     *int         `json:"mode,omitempty"`
 }
 
@@ -92,6 +130,13 @@ type IgnitionConfig struct {
 	Replace *ConfigReference  `json:"replace,omitempty"`
 }
 
+type Image struct {
+	Compression  string       `json:"compression,omitempty"`
+	HTTPHeaders  HTTPHeaders  `json:"httpHeaders,omitempty"`
+	Source       string       `json:"source"`
+	Verification Verification `json:"verification,omitempty"`
+}
+
 type Link struct {
 	Node
 	LinkEmbedded1
@@ -102,12 +147,42 @@ type LinkEmbedded1 struct {
 	Target string `json:"target"`
 }
 
+type LogicalVolume struct {
+	Name        string                `json:"name"`
+	Options     []LogicalVolumeOption `json:"options,omitempty"`
+	PercentFree *int                  `json:"percentFree,omitempty"`
+	Pool        *string               `json:"pool,omitempty"`
+	SizeMiB     *int                  `json:"sizeMiB,omitempty"`
+	Type        string                `json:"type,omitempty"`
+}
+
+type LogicalVolumeOption string
+
+type Luks struct {
+	Device     string       `json:"device"`
+	Discard    bool         `json:"discard,omitempty"`
+	KeyFile    FileContents `json:"keyFile"`
+	Label      *string      `json:"label,omitempty"`
+	Name       string       `json:"name"`
+	Options    []LuksOption `json:"options,omitempty"`
+	UUID       *string      `json:"uuid,omitempty"`
+	WipeVolume bool         `json:"wipeVolume,omitempty"`
+}
+
+type LuksOption string
+
+type Lvm struct {
+	VolumeGroups []VolumeGroup `json:"volumeGroups,omitempty"`
+}
+
 type Mount struct {
 	Create         *Create       `json:"create,omitempty"`
 	Device         string        `json:"device"`
 	Format         string        `json:"format"`
+	GrowFilesystem bool          `json:"growFilesystem,omitempty"`
 	Label          *string       `json:"label,omitempty"`
 	Options        []MountOption `json:"options,omitempty"`
+	Subvolumes     []Subvolume   `json:"subvolumes,omitempty"`
 	UUID           *string       `json:"uuid,omitempty"`
 	WipeFilesystem bool          `json:"wipeFilesystem,omitempty"`
 }
@@ -132,11 +207,13 @@ type Networkdunit struct {
 type NoProxyItem string
 
 type Node struct {
-	Filesystem string     `json:"filesystem"`
-	Group      *NodeGroup `json:"group,omitempty"`
-	Overwrite  *bool      `json:"overwrite,omitempty"`
-	Path       string     `json:"path"`
-	User       *NodeUser  `json:"user,omitempty"`
+	Filesystem     string      `json:"filesystem"`
+	Group          *NodeGroup  `json:"group,omitempty"`
+	Overwrite      *bool       `json:"overwrite,omitempty"`
+	Path           string      `json:"path"`
+	SELinuxContext string      `json:"seLinuxContext,omitempty"`
+	User           *NodeUser   `json:"user,omitempty"`
+	Xattrs         []NodeXattr `json:"xattrs,omitempty"`
 }
 
 type NodeGroup struct {
@@ -149,19 +226,31 @@ type NodeUser struct {
 	Name string `json:"name,omitempty"`
 }
 
-type Partition struct {
-	GUID               string  `json:"guid,omitempty"`
-	Label              *string `json:"label,omitempty"`
-	Number             int     `json:"number,omitempty"`
-	ShouldExist        *bool   `json:"shouldExist,omitempty"`
-	Size               *int    `json:"size,omitempty"`
-	SizeMiB            *int    `json:"sizeMiB,omitempty"`
-	Start              *int    `json:"start,omitempty"`
-	StartMiB           *int    `json:"startMiB,omitempty"`
-	TypeGUID           string  `json:"typeGuid,omitempty"`
-	WipePartitionEntry bool    `json:"wipePartitionEntry,omitempty"`
+type NodeXattr struct {
+	Name  string `json:"name"`
+	Value string `json:"value,omitempty"`
 }
 
+type Partition struct {
+	Attributes         []PartitionAttribute `json:"attributes,omitempty"`
+	Bootable           bool                 `json:"bootable,omitempty"`
+	GUID               string               `json:"guid,omitempty"`
+	Image              *Image               `json:"image,omitempty"`
+	Label              *string              `json:"label,omitempty"`
+	MbrType            string               `json:"mbrType,omitempty"`
+	Number             int                  `json:"number,omitempty"`
+	Resize             bool                 `json:"resize,omitempty"`
+	ShouldExist        *bool                `json:"shouldExist,omitempty"`
+	Size               *int                 `json:"size,omitempty"`
+	SizeMiB            *int                 `json:"sizeMiB,omitempty"`
+	Start              *int                 `json:"start,omitempty"`
+	StartMiB           *int                 `json:"startMiB,omitempty"`
+	TypeGUID           string               `json:"typeGuid,omitempty"`
+	WipePartitionEntry bool                 `json:"wipePartitionEntry,omitempty"`
+}
+
+type PartitionAttribute string
+
 type Passwd struct {
 	Groups []PasswdGroup `json:"groups,omitempty"`
 	Users  []PasswdUser  `json:"users,omitempty"`
@@ -198,15 +287,26 @@ type Proxy struct {
 }
 
 type Raid struct {
-	Devices []Device     `json:"devices"`
-	Level   string       `json:"level"`
-	Name    string       `json:"name"`
-	Options []RaidOption `json:"options,omitempty"`
-	Spares  int          `json:"spares,omitempty"`
+	Devices        []Device     `json:"devices"`
+	Level          string       `json:"level"`
+	Name           string       `json:"name"`
+	Options        []RaidOption `json:"options,omitempty"`
+	Spares         int          `json:"spares,omitempty"`
+	UUID           *string      `json:"uuid,omitempty"`
+	WaitForSync    bool         `json:"waitForSync,omitempty"`
+	WipeArray      bool         `json:"wipeArray,omitempty"`
+	WriteMdadmConf bool         `json:"writeMdadmConf,omitempty"`
 }
 
 type RaidOption string
 
+type Removal struct {
+	Filesystem string `json:"filesystem"`
+	MustExist  bool   `json:"mustExist,omitempty"`
+	Path       string `json:"path"`
+	Recursive  bool   `json:"recursive,omitempty"`
+}
+
 type SSHAuthorizedKey string
 
 type Security struct {
@@ -214,12 +314,23 @@ type Security struct {
 }
 
 type Storage struct {
+	Archives    []Archive    `json:"archives,omitempty"`
 	Directories []Directory  `json:"directories,omitempty"`
 	Disks       []Disk       `json:"disks,omitempty"`
 	Files       []File       `json:"files,omitempty"`
 	Filesystems []Filesystem `json:"filesystems,omitempty"`
 	Links       []Link       `json:"links,omitempty"`
+	Luks        []Luks       `json:"luks,omitempty"`
+	Lvm         Lvm          `json:"lvm,omitempty"`
 	Raid        []Raid       `json:"raid,omitempty"`
+	Remove      []Removal    `json:"remove,omitempty"`
+}
+
+type Subvolume struct {
+	Name           string `json:"name,omitempty"`
+	NoCopyOnWrite  bool   `json:"noCopyOnWrite,omitempty"`
+	Path           string `json:"path"`
+	QgroupLimitMiB *int   `json:"qgroupLimitMiB,omitempty"`
 }
 
 type Systemd struct {
@@ -229,6 +340,7 @@ type Systemd struct {
 type SystemdDropin struct {
 	Contents string `json:"contents,omitempty"`
 	Name     string `json:"name"`
+	Template bool   `json:"template,omitempty"`
 }
 
 type TLS struct {
@@ -247,6 +359,7 @@ type Unit struct {
 	Enabled  *bool           `json:"enabled,omitempty"`
 	Mask     bool            `json:"mask,omitempty"`
 	Name     string          `json:"name"`
+	Template bool            `json:"template,omitempty"`
 }
 
 type Usercreate struct {
@@ -267,3 +380,12 @@ type UsercreateGroup string
 type Verification struct {
 	Hash *string `json:"hash,omitempty"`
 }
+
+type VolumeGroup struct {
+	LogicalVolumes  []LogicalVolume     `json:"logicalVolumes,omitempty"`
+	Name            string              `json:"name"`
+	Options         []VolumeGroupOption `json:"options,omitempty"`
+	PhysicalVolumes []Device            `json:"physicalVolumes"`
+}
+
+type VolumeGroupOption string
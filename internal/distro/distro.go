@@ -33,11 +33,20 @@ var (
 	systemConfigDir = "/usr/lib/ignition"
 	// initramfs directory to check before retrieving file from OEM partition
 	oemLookasideDir = "/usr/share/oem"
+	// initramfs directory holding LUKS key files until the files stage
+	luksInitramfsKeyFilePath = "/run/ignition/luks-keyfiles/"
+	// directory in the real root where LUKS key files are kept for crypttab
+	luksRealRootKeyFilePath = "/etc/luks/"
 
 	// Helper programs
+	blockdevCmd   = "/usr/sbin/blockdev"
+	chattrCmd     = "/usr/bin/chattr"
+	chconCmd      = "/usr/bin/chcon"
 	chrootCmd     = "/usr/bin/chroot"
+	cryptsetupCmd = "/usr/sbin/cryptsetup"
 	groupaddCmd   = "/usr/sbin/groupadd"
 	idCmd         = "/usr/bin/id"
+	lvmCmd        = "/usr/sbin/lvm"
 	mdadmCmd      = "/usr/sbin/mdadm"
 	mountCmd      = "/usr/bin/mount"
 	sgdiskCmd     = "/usr/sbin/sgdisk"
@@ -53,9 +62,20 @@ var (
 	vfatMkfsCmd  = "/usr/sbin/mkfs.vfat"
 	xfsMkfsCmd   = "/usr/sbin/mkfs.xfs"
 
+	// Filesystem grow tools
+	btrfsCmd     = "/usr/sbin/btrfs"
+	resize2fsCmd = "/usr/sbin/resize2fs"
+	xfsGrowfsCmd = "/usr/sbin/xfs_growfs"
+
+	// Partitioning backend, "sgdisk" or "gpt" for the built-in GPT writer
+	partitioner = "sgdisk"
+
 	// Flags
 	selinuxRelabel  = "false"
 	blackboxTesting = "false"
+	// Whether EC2 metadata endpoints which do not hand out IMDSv2 session
+	// tokens may be used with IMDSv1
+	ec2IMDSv1Fallback = "true"
 )
 
 func DiskByLabelDir() string    { return diskByLabelDir }
@@ -66,9 +86,17 @@ func KernelCmdlinePath() string { return kernelCmdlinePath }
 func SystemConfigDir() string   { return fromEnv("SYSTEM_CONFIG_DIR", systemConfigDir) }
 func OEMLookasideDir() string   { return fromEnv("OEM_LOOKASIDE_DIR", oemLookasideDir) }
 
+func LuksInitramfsKeyFilePath() string { return luksInitramfsKeyFilePath }
+func LuksRealRootKeyFilePath() string  { return luksRealRootKeyFilePath }
+
+func BlockdevCmd() string   { return blockdevCmd }
+func ChattrCmd() string     { return chattrCmd }
+func ChconCmd() string      { return chconCmd }
 func ChrootCmd() string     { return chrootCmd }
+func CryptsetupCmd() string { return cryptsetupCmd }
 func GroupaddCmd() string   { return groupaddCmd }
 func IdCmd() string         { return idCmd }
+func LvmCmd() string        { return lvmCmd }
 func MdadmCmd() string      { return mdadmCmd }
 func MountCmd() string      { return mountCmd }
 func SgdiskCmd() string     { return sgdiskCmd }
@@ -83,8 +111,15 @@ func SwapMkfsCmd() string  { return swapMkfsCmd }
 func VfatMkfsCmd() string  { return vfatMkfsCmd }
 func XfsMkfsCmd() string   { return xfsMkfsCmd }
 
-func SelinuxRelabel() bool  { return bakedStringToBool(selinuxRelabel) }
-func BlackboxTesting() bool { return bakedStringToBool(blackboxTesting) }
+func BtrfsCmd() string     { return btrfsCmd }
+func Resize2fsCmd() string { return resize2fsCmd }
+func XfsGrowfsCmd() string { return xfsGrowfsCmd }
+
+func Partitioner() string { return fromEnv("PARTITIONER", partitioner) }
+
+func SelinuxRelabel() bool    { return bakedStringToBool(selinuxRelabel) }
+func BlackboxTesting() bool   { return bakedStringToBool(blackboxTesting) }
+func EC2IMDSv1Fallback() bool { return bakedStringToBool(ec2IMDSv1Fallback) }
 
 func fromEnv(nameSuffix, defaultValue string) string {
 	value := os.Getenv("IGNITION_" + nameSuffix)
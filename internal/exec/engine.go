@@ -47,19 +47,98 @@ const (
 // Engine represents the entity that fetches and executes a configuration.
 type Engine struct {
 	ConfigCache  string
+	ResultCache  string
 	FetchTimeout time.Duration
 	Logger       *log.Logger
 	Root         string
 	OEMConfig    oem.Config
 	Fetcher      *resource.Fetcher
+
+	// dryRun prevents the config cache from being written.
+	dryRun bool
+	// newResult is set if the config was fetched instead of read from the
+	// cache, which starts a new result instead of adding to the cached one.
+	newResult bool
+	// configs records the referenced configs fetched during this run.
+	configs []ConfigResult
 }
 
 // Run executes the stage of the given name. It returns true if the stage
-// successfully ran and false if there were any errors.
+// successfully ran and false if there were any errors. The outcome is added
+// to the result (see Result) if a result cache is configured.
 func (e Engine) Run(stageName string) error {
+	start := time.Now()
+	err := e.run(stageName)
+	if e.Logger != nil {
+		e.recordResult(stageName, start, err)
+	}
+	return err
+}
+
+func (e *Engine) run(stageName string) error {
+	fullConfig, err := e.fullConfig()
+	if err != nil {
+		return err
+	}
+
+	e.Logger.PushPrefix(stageName)
+	defer e.Logger.PopPrefix()
+
+	if err = stages.Get(stageName).Create(e.Logger, e.Root, *e.Fetcher).Run(fullConfig); err != nil {
+		// e.Logger could be nil
+		fmt.Fprintf(os.Stderr, "%s failed", stageName)
+		tmp, jsonerr := json.MarshalIndent(fullConfig, "", "  ")
+		if jsonerr != nil {
+			// Nothing else to do with this error
+			fmt.Fprintf(os.Stderr, "Could not marshal full config: %v", err)
+		} else {
+			fmt.Fprintf(os.Stderr, "Full config:\n%s", string(tmp))
+		}
+		return err
+	}
+	e.Logger.Info("%s passed", stageName)
+	return nil
+}
+
+// Plan fetches and renders the config like Run, but instead of executing the
+// stages of the given names it returns the steps they would perform. Stages
+// which don't implement stages.Planner are skipped. The config cache is read
+// but never written.
+func (e Engine) Plan(stageNames ...string) ([]stages.Step, error) {
+	e.dryRun = true
+	fullConfig, err := e.fullConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var steps []stages.Step
+	for _, stageName := range stageNames {
+		stage := stages.Get(stageName).Create(e.Logger, e.Root, *e.Fetcher)
+		planner, ok := stage.(stages.Planner)
+		if !ok {
+			e.Logger.Info("%s does not support planning, skipping", stageName)
+			continue
+		}
+
+		e.Logger.PushPrefix(stageName)
+		stageSteps, err := planner.Plan(fullConfig)
+		e.Logger.PopPrefix()
+		if err != nil {
+			return nil, fmt.Errorf("failed to plan %s: %v", stageName, err)
+		}
+		for _, step := range stageSteps {
+			step.Stage = stageName
+			steps = append(steps, step)
+		}
+	}
+	return steps, nil
+}
+
+// fullConfig acquires the config and combines it with the base configs.
+func (e *Engine) fullConfig() (types.Config, error) {
 	if e.Fetcher == nil || e.Logger == nil {
 		fmt.Fprintf(os.Stderr, "engine incorrectly configured\n")
-		return errors.ErrEngineConfiguration
+		return types.Config{}, errors.ErrEngineConfiguration
 	}
 	baseConfig := types.Config{
 		Ignition: types.Ignition{Version: types.MaxVersion.String()},
@@ -75,7 +154,7 @@ func (e Engine) Run(stageName string) error {
 	e.logReport(r)
 	if err != nil && err != providers.ErrNoProvider {
 		e.Logger.Crit("failed to acquire system base config: %v", err)
-		return err
+		return types.Config{}, err
 	}
 
 	cfg, err := e.acquireConfig()
@@ -87,31 +166,14 @@ func (e Engine) Run(stageName string) error {
 		e.logReport(r)
 		if err != nil && err != providers.ErrNoProvider {
 			e.Logger.Crit("failed to acquire default config: %v", err)
-			return err
+			return types.Config{}, err
 		}
 	default:
 		e.Logger.Crit("failed to acquire config: %v", err)
-		return err
+		return types.Config{}, err
 	}
 
-	e.Logger.PushPrefix(stageName)
-	defer e.Logger.PopPrefix()
-
-	fullConfig := config.Append(baseConfig, config.Append(systemBaseConfig, cfg))
-	if err = stages.Get(stageName).Create(e.Logger, e.Root, *e.Fetcher).Run(fullConfig); err != nil {
-		// e.Logger could be nil
-		fmt.Fprintf(os.Stderr, "%s failed", stageName)
-		tmp, jsonerr := json.MarshalIndent(fullConfig, "", "  ")
-		if jsonerr != nil {
-			// Nothing else to do with this error
-			fmt.Fprintf(os.Stderr, "Could not marshal full config: %v", err)
-		} else {
-			fmt.Fprintf(os.Stderr, "Full config:\n%s", string(tmp))
-		}
-		return err
-	}
-	e.Logger.Info("%s passed", stageName)
-	return nil
+	return config.Combine(baseConfig, config.Combine(systemBaseConfig, cfg)), nil
 }
 
 // acquireConfig returns the configuration, first checking a local cache
@@ -145,6 +207,7 @@ func (e *Engine) acquireConfig() (cfg types.Config, err error) {
 	}
 
 	// (Re)Fetch the config if the cache is unreadable.
+	e.newResult = true
 	cfg, err = e.fetchProviderConfig()
 	if err != nil {
 		e.Logger.Warning("failed to fetch config: %s", err)
@@ -165,6 +228,10 @@ func (e *Engine) acquireConfig() (cfg types.Config, err error) {
 		return
 	}
 
+	if e.dryRun {
+		return
+	}
+
 	// Populate the config cache.
 	b, err = json.Marshal(cfg)
 	if err != nil {
@@ -223,9 +290,9 @@ func (e *Engine) fetchProviderConfig() (types.Config, error) {
 // in the given config and returns the result. If "ignition.config.replace" is
 // set, the referenced and evaluted config will be returned. Otherwise, if
 // "ignition.config.append" is set, each of the referenced configs will be
-// evaluated and appended to the provided config. If neither option is set, the
-// provided config will be returned unmodified. An updated fetcher will be
-// returned with any new timeouts set.
+// evaluated and combined with the provided config (see config.Combine). If
+// neither option is set, the provided config will be returned unmodified. An
+// updated fetcher will be returned with any new timeouts set.
 func (e *Engine) renderConfig(cfg types.Config) (types.Config, error) {
 	if cfgRef := cfg.Ignition.Config.Replace; cfgRef != nil {
 		newCfg, err := e.fetchReferencedConfig(*cfgRef)
@@ -253,7 +320,7 @@ func (e *Engine) renderConfig(cfg types.Config) (types.Config, error) {
 		// Append the old config with the new config before the new config has
 		// been rendered, so we can use the new config's timeouts and CAs when
 		// fetching more configs.
-		cfgForFetcherSettings := config.Append(appendedCfg, newCfg)
+		cfgForFetcherSettings := config.Combine(appendedCfg, newCfg)
 		err = e.Fetcher.UpdateHttpTimeoutsAndCAs(cfgForFetcherSettings.Ignition.Timeouts, cfgForFetcherSettings.Ignition.Security.TLS.CertificateAuthorities, cfgForFetcherSettings.Ignition.Proxy)
 		if err != nil {
 			return types.Config{}, err
@@ -264,7 +331,7 @@ func (e *Engine) renderConfig(cfg types.Config) (types.Config, error) {
 			return types.Config{}, err
 		}
 
-		appendedCfg = config.Append(appendedCfg, newCfg)
+		appendedCfg = config.Combine(appendedCfg, newCfg)
 	}
 	return appendedCfg, nil
 }
@@ -309,12 +376,18 @@ func (e *Engine) fetchReferencedConfig(cfgRef types.ConfigReference) (types.Conf
 	}
 
 	hash := sha512.Sum512(rawCfg)
+	fetched := ConfigResult{
+		Source: cfgRef.Source,
+		SHA512: hex.EncodeToString(hash[:]),
+	}
 	if u.Scheme != "data" {
 		e.Logger.Debug("fetched referenced config at %s with SHA512: %s", cfgRef.Source, hex.EncodeToString(hash[:]))
 	} else {
 		// data url's might contain secrets
 		e.Logger.Debug("fetched referenced config from data url with SHA512: %s", hex.EncodeToString(hash[:]))
+		fetched.Source = "data:"
 	}
+	e.configs = append(e.configs, fetched)
 
 	if err := util.AssertValid(cfgRef.Verification, rawCfg); err != nil {
 		return types.Config{}, err
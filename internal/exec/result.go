@@ -0,0 +1,115 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/flatcar/ignition/internal/log"
+)
+
+const (
+	// ResultPath is the path of the result file, relative to the target root.
+	ResultPath = "/etc/.ignition-result.json"
+
+	outcomeSuccess = "success"
+	outcomeFailure = "failure"
+)
+
+// Result is the machine-readable record of how a machine was provisioned.
+// Every stage adds itself to the result, so it is kept in the result cache
+// between the invocations of Ignition and copied into the target root.
+type Result struct {
+	Provider string         `json:"provider"`
+	Configs  []ConfigResult `json:"configs,omitempty"`
+	Stages   []StageResult  `json:"stages"`
+}
+
+// ConfigResult records a config which was referenced by another config.
+// The source of data URLs is recorded as "data:" since they might contain
+// secrets.
+type ConfigResult struct {
+	Source string `json:"source"`
+	SHA512 string `json:"sha512"`
+}
+
+// StageResult records a single stage.
+type StageResult struct {
+	Name       string          `json:"name"`
+	Start      time.Time       `json:"start"`
+	End        time.Time       `json:"end"`
+	Outcome    string          `json:"outcome"`
+	Error      string          `json:"error,omitempty"`
+	Operations []log.Operation `json:"operations,omitempty"`
+}
+
+// recordResult adds the stage to the result in the result cache and writes
+// the result into the target root, if its /etc exists. Failures are only
+// logged since they must not fail the provisioning.
+func (e *Engine) recordResult(stageName string, start time.Time, stageErr error) {
+	if e.ResultCache == "" {
+		return
+	}
+
+	var res Result
+	if !e.newResult {
+		b, err := ioutil.ReadFile(e.ResultCache)
+		if err == nil {
+			if err := json.Unmarshal(b, &res); err != nil {
+				e.Logger.Warning("failed to parse result cache, starting a new result: %v", err)
+				res = Result{}
+			}
+		} else if !os.IsNotExist(err) {
+			e.Logger.Warning("failed to read result cache: %v", err)
+		}
+	}
+
+	res.Provider = e.OEMConfig.Name()
+	res.Configs = append(res.Configs, e.configs...)
+	stage := StageResult{
+		Name:       stageName,
+		Start:      start,
+		End:        time.Now(),
+		Outcome:    outcomeSuccess,
+		Operations: e.Logger.Operations(),
+	}
+	if stageErr != nil {
+		stage.Outcome = outcomeFailure
+		stage.Error = stageErr.Error()
+	}
+	res.Stages = append(res.Stages, stage)
+
+	b, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		e.Logger.Warning("failed to marshal result: %v", err)
+		return
+	}
+	if err := ioutil.WriteFile(e.ResultCache, b, 0600); err != nil {
+		e.Logger.Warning("failed to write result cache: %v", err)
+		return
+	}
+
+	// The target root might not be mounted yet.
+	if info, err := os.Stat(filepath.Join(e.Root, "etc")); err != nil || !info.IsDir() {
+		return
+	}
+	if err := ioutil.WriteFile(filepath.Join(e.Root, ResultPath), b, 0600); err != nil {
+		e.Logger.Warning("failed to write result: %v", err)
+	}
+}
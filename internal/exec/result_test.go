@@ -0,0 +1,105 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flatcar/ignition/internal/log"
+	"github.com/flatcar/ignition/internal/oem"
+)
+
+func readResult(t *testing.T, path string) Result {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res Result
+	if err := json.Unmarshal(b, &res); err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestRecordResult(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ignition-result")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	root := filepath.Join(dir, "root")
+	if err := os.MkdirAll(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	cache := filepath.Join(dir, "result.json")
+
+	logger := log.New(true)
+	newEngine := func() Engine {
+		return Engine{
+			Root:        root,
+			ResultCache: cache,
+			Logger:      &logger,
+			OEMConfig:   oem.MustGet("qemu"),
+		}
+	}
+
+	// the first stage fetches the config; the root isn't mounted yet
+	e := newEngine()
+	e.newResult = true
+	e.configs = []ConfigResult{{Source: "http://example.com/a.ign", SHA512: "0123"}}
+	logger.LogOp(func() error { return nil }, "doing %s", "something")
+	e.recordResult("fetch", time.Now(), nil)
+	if _, err := os.Stat(filepath.Join(root, ResultPath)); !os.IsNotExist(err) {
+		t.Fatalf("result written before the root was mounted: %v", err)
+	}
+
+	// the next stage adds to the cached result and writes it into the root
+	if err := os.MkdirAll(filepath.Join(root, "etc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	e = newEngine()
+	e.recordResult("files", time.Now(), errors.New("broken"))
+
+	res := readResult(t, filepath.Join(root, ResultPath))
+	assert.Equal(t, res, readResult(t, cache))
+	assert.Equal(t, "qemu", res.Provider)
+	assert.Equal(t, []ConfigResult{{Source: "http://example.com/a.ign", SHA512: "0123"}}, res.Configs)
+	if assert.Len(t, res.Stages, 2) {
+		assert.Equal(t, "fetch", res.Stages[0].Name)
+		assert.Equal(t, outcomeSuccess, res.Stages[0].Outcome)
+		if assert.Len(t, res.Stages[0].Operations, 1) {
+			assert.Equal(t, "doing something", res.Stages[0].Operations[0].Description)
+		}
+		assert.Equal(t, "files", res.Stages[1].Name)
+		assert.Equal(t, outcomeFailure, res.Stages[1].Outcome)
+		assert.Equal(t, "broken", res.Stages[1].Error)
+	}
+
+	// refetching the config starts a new result
+	e = newEngine()
+	e.newResult = true
+	e.recordResult("fetch", time.Now(), nil)
+	res = readResult(t, filepath.Join(root, ResultPath))
+	assert.Len(t, res.Stages, 1)
+	assert.Empty(t, res.Configs)
+}
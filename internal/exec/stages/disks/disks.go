@@ -13,8 +13,9 @@
 // limitations under the License.
 
 // The storage stage is responsible for partitioning disks, creating RAID
-// arrays, formatting partitions, writing files, writing systemd units, and
-// writing network units.
+// arrays, creating LUKS volumes, creating LVM volume groups, formatting
+// partitions, writing files, writing systemd units, and writing network
+// units.
 
 package disks
 
@@ -74,10 +75,17 @@ func (s stage) Run(config types.Config) error {
 	// filesystems is 1.
 	if len(config.Storage.Disks) == 0 &&
 		len(config.Storage.Raid) == 0 &&
+		len(config.Storage.Luks) == 0 &&
+		len(config.Storage.Lvm.VolumeGroups) == 0 &&
 		len(config.Storage.Filesystems) == 1 {
 		return nil
 	}
 
+	config, err := s.resolveDiskSelectors(config)
+	if err != nil {
+		return fmt.Errorf("failed to select disks: %v", err)
+	}
+
 	if err := s.createPartitions(config); err != nil {
 		return fmt.Errorf("create partitions failed: %v", err)
 	}
@@ -86,6 +94,14 @@ func (s stage) Run(config types.Config) error {
 		return fmt.Errorf("failed to create raids: %v", err)
 	}
 
+	if err := s.createLuks(config); err != nil {
+		return fmt.Errorf("failed to create luks volumes: %v", err)
+	}
+
+	if err := s.createLvm(config); err != nil {
+		return fmt.Errorf("failed to create lvm volumes: %v", err)
+	}
+
 	if err := s.createFilesystems(config); err != nil {
 		return fmt.Errorf("failed to create filesystems: %v", err)
 	}
@@ -21,9 +21,12 @@ package disks
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"os/exec"
 	"runtime"
 	"strings"
+	"syscall"
 
 	"github.com/flatcar/ignition/internal/config/types"
 	"github.com/flatcar/ignition/internal/distro"
@@ -97,26 +100,15 @@ func (s stage) createFilesystem(fs types.Mount) error {
 		return err
 	}
 
-	if fs.Create != nil {
-		// If we are using 2.0.0 semantics...
-
-		if !fs.Create.Force && info.format != "" {
-			s.Logger.Err("filesystem detected at %q (found %s) and force was not requested", fs.Device, info.format)
-			return ErrBadFilesystem
-		}
-	} else if !fs.WipeFilesystem {
-		// If the filesystem isn't forcefully being created, then we need
-		// to check if it is of the correct type or that no filesystem exists.
-
-		if (info.format == fs.Format || info.label == "OEM") &&
-			(fs.Label == nil || info.label == *fs.Label) &&
-			(fs.UUID == nil || canonicalizeFilesystemUUID(info.format, info.uuid) == canonicalizeFilesystemUUID(fs.Format, *fs.UUID)) {
-			s.Logger.Info("filesystem at %q is already correctly formatted. Skipping mkfs...", fs.Device)
-			return nil
-		} else if info.format != "" {
-			s.Logger.Err("filesystem at %q is not of the correct type, label, or UUID (found %s, %q, %s) and a filesystem wipe was not requested", fs.Device, info.format, info.label, info.uuid)
-			return ErrBadFilesystem
+	if format, err := s.shouldFormat(fs, info); err != nil {
+		return err
+	} else if !format {
+		if fs.GrowFilesystem && info.format == fs.Format {
+			if err := s.growFilesystem(fs); err != nil {
+				return err
+			}
 		}
+		return s.createSubvolumes(fs)
 	}
 
 	mkfs := ""
@@ -210,7 +202,93 @@ func (s stage) createFilesystem(fs types.Mount) error {
 		return err
 	}
 
-	return nil
+	return s.createSubvolumes(fs)
+}
+
+// withMountedFilesystem mounts the filesystem on fs.Device on a temporary
+// mount point and calls f with it. btrfs filesystems are mounted at their top
+// level, regardless of their default subvolume.
+func (s stage) withMountedFilesystem(fs types.Mount, f func(mnt string) error) (err error) {
+	devAlias := util.DeviceAlias(string(fs.Device))
+
+	mnt, err := ioutil.TempDir("", "ignition-disks")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %v", err)
+	}
+	defer os.Remove(mnt)
+
+	data := ""
+	if fs.Format == "btrfs" {
+		data = "subvolid=5"
+	}
+	if err := s.Logger.LogOp(
+		func() error { return syscall.Mount(devAlias, mnt, fs.Format, 0, data) },
+		"mounting %q at %q", devAlias, mnt,
+	); err != nil {
+		return fmt.Errorf("failed to mount %q: %v", devAlias, err)
+	}
+	defer func() {
+		if uerr := s.Logger.LogOp(
+			func() error { return syscall.Unmount(mnt, 0) },
+			"unmounting %q at %q", devAlias, mnt,
+		); uerr != nil && err == nil {
+			err = fmt.Errorf("failed to unmount %q: %v", devAlias, uerr)
+		}
+	}()
+
+	return f(mnt)
+}
+
+// growFilesystem grows the existing filesystem on fs.Device to fill the
+// device. All supported filesystems are grown online, so the filesystem is
+// mounted while it is resized.
+func (s stage) growFilesystem(fs types.Mount) error {
+	devAlias := util.DeviceAlias(string(fs.Device))
+	return s.withMountedFilesystem(fs, func(mnt string) error {
+		var cmd *exec.Cmd
+		switch fs.Format {
+		case "btrfs":
+			cmd = exec.Command(distro.BtrfsCmd(), "filesystem", "resize", "max", mnt)
+		case "ext4":
+			cmd = exec.Command(distro.Resize2fsCmd(), devAlias)
+		case "xfs":
+			cmd = exec.Command(distro.XfsGrowfsCmd(), mnt)
+		default:
+			return fmt.Errorf("growing %q filesystems is not supported", fs.Format)
+		}
+		if _, err := s.Logger.LogCmd(cmd, "growing %q filesystem on %q", fs.Format, devAlias); err != nil {
+			return fmt.Errorf("growing filesystem failed: %v", err)
+		}
+		return nil
+	})
+}
+
+// shouldFormat determines whether fs needs to be formatted, given info about
+// the filesystem currently on its device. ErrBadFilesystem is returned if
+// the existing filesystem doesn't match and may not be destroyed.
+func (s stage) shouldFormat(fs types.Mount, info filesystemInfo) (bool, error) {
+	if fs.Create != nil {
+		// If we are using 2.0.0 semantics...
+
+		if !fs.Create.Force && info.format != "" {
+			s.Logger.Err("filesystem detected at %q (found %s) and force was not requested", fs.Device, info.format)
+			return false, ErrBadFilesystem
+		}
+	} else if !fs.WipeFilesystem {
+		// If the filesystem isn't forcefully being created, then we need
+		// to check if it is of the correct type or that no filesystem exists.
+
+		if (info.format == fs.Format || info.label == "OEM") &&
+			(fs.Label == nil || info.label == *fs.Label) &&
+			(fs.UUID == nil || canonicalizeFilesystemUUID(info.format, info.uuid) == canonicalizeFilesystemUUID(fs.Format, *fs.UUID)) {
+			s.Logger.Info("filesystem at %q is already correctly formatted. Skipping mkfs...", fs.Device)
+			return false, nil
+		} else if info.format != "" {
+			s.Logger.Err("filesystem at %q is not of the correct type, label, or UUID (found %s, %q, %s) and a filesystem wipe was not requested", fs.Device, info.format, info.label, info.uuid)
+			return false, ErrBadFilesystem
+		}
+	}
+	return true, nil
 }
 
 // golang--
@@ -0,0 +1,137 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/flatcar/ignition/internal/config/types"
+	"github.com/flatcar/ignition/internal/distro"
+)
+
+// sysClassBlock is where the kernel lists block devices and their partitions.
+const sysClassBlock = "/sys/class/block"
+
+// writeDiskImage streams the image of dev onto devAlias and makes the kernel
+// and udev pick up the partition table it contains.
+func (s stage) writeDiskImage(dev types.Disk, devAlias string) error {
+	if err := s.Logger.LogOp(
+		func() error { return s.writeImage(*dev.Image, devAlias) },
+		"writing image %q to %q", dev.Image.Source, devAlias,
+	); err != nil {
+		return err
+	}
+
+	if _, err := s.Logger.LogCmd(
+		exec.Command(distro.BlockdevCmd(), "--rereadpt", devAlias),
+		"rereading partition table of %q", devAlias,
+	); err != nil {
+		return fmt.Errorf("blockdev failed: %v", err)
+	}
+
+	return s.waitForUdev(devAlias, "writeDiskImage")
+}
+
+// writePartitionImages streams the images of the partitions of dev onto
+// them. The partitions must already exist on devAlias.
+func (s stage) writePartitionImages(dev types.Disk, devAlias string) error {
+	for _, part := range dev.Partitions {
+		if part.Image == nil {
+			continue
+		}
+
+		partDev, err := partitionDevice(devAlias, part.Number)
+		if err != nil {
+			return err
+		}
+
+		if err := s.Logger.LogOp(
+			func() error { return s.writeImage(*part.Image, partDev) },
+			"writing image %q to partition %d of %q", part.Image.Source, part.Number, devAlias,
+		); err != nil {
+			return err
+		}
+
+		// Only the contents of the partition changed, the partition
+		// table is untouched.
+		if err := s.waitForUdev(partDev, "writePartitionImages"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeImage fetches image directly onto the block device at path. The image
+// is decompressed and its hash verified while it is written.
+func (s stage) writeImage(image types.Image, path string) error {
+	fetchOp := s.PrepareFetch(s.Logger, types.File{
+		Node: types.Node{Path: path},
+		FileEmbedded1: types.FileEmbedded1{
+			Contents: types.FileContents{
+				Compression:  image.Compression,
+				HTTPHeaders:  image.HTTPHeaders,
+				Source:       image.Source,
+				Verification: image.Verification,
+			},
+		},
+	})
+	if fetchOp == nil {
+		return fmt.Errorf("failed to resolve image %q", image.Source)
+	}
+
+	dest, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+
+	if err := s.Fetcher.Fetch(fetchOp.Url, dest, fetchOp.FetchOptions); err != nil {
+		return err
+	}
+
+	return dest.Sync()
+}
+
+// partitionDevice returns the device node of partition number on disk.
+func partitionDevice(disk string, number int) (string, error) {
+	dev, err := filepath.EvalSymlinks(disk)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %q: %v", disk, err)
+	}
+	name := filepath.Base(dev)
+
+	entries, err := filepath.Glob(filepath.Join(sysClassBlock, name, name+"*", "partition"))
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range entries {
+		contents, err := ioutil.ReadFile(entry)
+		if err != nil {
+			return "", err
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(string(contents))); err == nil && n == number {
+			return filepath.Join("/dev", filepath.Base(filepath.Dir(entry))), nil
+		}
+	}
+
+	return "", fmt.Errorf("partition %d of %q not found", number, disk)
+}
@@ -0,0 +1,142 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/flatcar/ignition/internal/config/types"
+	"github.com/flatcar/ignition/internal/exec/util"
+	"github.com/flatcar/ignition/internal/log"
+	"github.com/flatcar/ignition/internal/resource"
+)
+
+func TestWriteImage(t *testing.T) {
+	type in struct {
+		image types.Image
+	}
+	type out struct {
+		contents []byte
+		err      bool
+	}
+
+	strToPtr := func(s string) *string { return &s }
+	raw := []byte("raw disk image\n")
+	sum := "sha512-46c1bf4d93b6996c2b15a154570066853f5591d02fc9a62a10e0256cf7422ceb2105337f92a11a1fa6dea987e60e1c622968b11eccc37c8ebccfeede0a53b55a"
+
+	// the streamed image is large enough to take several reads
+	large := bytes.Repeat([]byte("0123456789abcdef"), 1<<18)
+	images := map[string]string{
+		"/large":     string(large),
+		"/image.gz":  "H4sIAAAAAAACAytKLFdIySzOVsjMTUxP5QIAkcGEiQ8AAAA=",
+		"/image.bz2": "QlpoOTFBWSZTWYusJxkAAAZRgAAQQAAmqhiAIAAxA0DQIAGmknR1jXBJavF3JFOFCQi6wnGQ",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		image, ok := images[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.URL.Path == "/large" {
+			w.Write([]byte(image))
+			return
+		}
+		data, err := base64.StdEncoding.DecodeString(image)
+		if err != nil {
+			t.Error(err)
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{types.Image{Source: "data:,raw%20disk%20image%0A"}},
+			out: out{contents: raw},
+		},
+		{
+			in:  in{types.Image{Source: server.URL + "/large"}},
+			out: out{contents: large},
+		},
+		{
+			in: in{types.Image{
+				Source:      server.URL + "/image.gz",
+				Compression: "gzip",
+			}},
+			out: out{contents: raw},
+		},
+		{
+			in: in{types.Image{
+				Source:       server.URL + "/image.bz2",
+				Compression:  "bzip2",
+				Verification: types.Verification{Hash: strToPtr(sum)},
+			}},
+			out: out{contents: raw},
+		},
+		{
+			in: in{types.Image{
+				Source:       "data:,corrupted%20image%0A",
+				Verification: types.Verification{Hash: strToPtr(sum)},
+			}},
+			out: out{err: true},
+		},
+	}
+
+	logger := log.New(true)
+	s := stage{Util: util.Util{Fetcher: resource.Fetcher{Logger: &logger}, Logger: &logger}}
+	for i, test := range tests {
+		// a regular file stands in for the block device, whose contents
+		// past the image are left alone
+		dev, err := ioutil.TempFile("", "ignition-image-")
+		if err != nil {
+			t.Fatal(err)
+		}
+		trailer := bytes.Repeat([]byte{0xff}, 512)
+		if _, err := dev.Write(append(make([]byte, len(test.out.contents)), trailer...)); err != nil {
+			t.Fatal(err)
+		}
+		dev.Close()
+
+		err = s.writeImage(test.in.image, dev.Name())
+		contents, readErr := ioutil.ReadFile(dev.Name())
+		os.Remove(dev.Name())
+		if readErr != nil {
+			t.Fatal(readErr)
+		}
+
+		if test.out.err {
+			if err == nil {
+				t.Errorf("#%d: expected an error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if !bytes.Equal(append(test.out.contents, trailer...), contents) {
+			t.Errorf("#%d: bad contents: got %d bytes starting with %q", i, len(contents), contents[:16])
+		}
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/flatcar/ignition/internal/config/types"
+	"github.com/flatcar/ignition/internal/distro"
+	"github.com/flatcar/ignition/internal/exec/util"
+)
+
+// createLuks creates and opens the LUKS volumes described in
+// config.Storage.Luks. Each volume is unlocked with its key file, which is
+// kept in the initramfs so the files stage can install it in the root.
+func (s stage) createLuks(config types.Config) error {
+	if len(config.Storage.Luks) == 0 {
+		return nil
+	}
+	s.Logger.PushPrefix("createLuks")
+	defer s.Logger.PopPrefix()
+
+	devs := []string{}
+	for _, luks := range config.Storage.Luks {
+		devs = append(devs, luks.Device)
+	}
+
+	if err := s.waitOnDevicesAndCreateAliases(devs, "luks"); err != nil {
+		return err
+	}
+
+	for _, luks := range config.Storage.Luks {
+		keyFile, err := s.fetchLuksKeyFile(luks)
+		if err != nil {
+			return fmt.Errorf("failed to fetch key file for %q: %v", luks.Name, err)
+		}
+
+		device := util.DeviceAlias(luks.Device)
+		format := luks.WipeVolume
+		if !format {
+			// isLuks exits with 1 if the device has no LUKS header
+			code, err := s.Logger.LogCmd(
+				exec.Command(distro.CryptsetupCmd(), "isLuks", device),
+				"checking if %q is a LUKS device", luks.Device,
+			)
+			switch {
+			case code == 1:
+				// like filesystems, only blank devices are formatted
+				// unless a wipe was requested
+				info, err := s.readFilesystemInfo(types.Mount{Device: device})
+				if err != nil {
+					return err
+				}
+				if err := luksCanFormat(info, luks); err != nil {
+					s.Logger.Err("%v", err)
+					return fmt.Errorf("cannot create LUKS volume %q: %v", luks.Name, err)
+				}
+				format = true
+			case err != nil:
+				return fmt.Errorf("cryptsetup failed: %v", err)
+			default:
+				info, err := s.readLuksInfo(device)
+				if err != nil {
+					return err
+				}
+				if err := luksMatches(info, luks); err != nil {
+					return fmt.Errorf("LUKS volume %q didn't match: %v", luks.Name, err)
+				}
+				s.Logger.Info("reusing existing LUKS volume on %q", luks.Device)
+			}
+		}
+
+		if format {
+			if _, err := s.Logger.LogCmd(
+				exec.Command(distro.CryptsetupCmd(), luksFormatArgs(luks, device, keyFile)...),
+				"formatting %q as LUKS volume %q", luks.Device, luks.Name,
+			); err != nil {
+				return fmt.Errorf("cryptsetup failed: %v", err)
+			}
+			if err := s.waitForUdev(device, "luks"); err != nil {
+				return err
+			}
+		}
+
+		args := []string{"luksOpen", "--key-file", keyFile}
+		if luks.Discard {
+			args = append(args, "--allow-discards")
+		}
+		args = append(args, device, luks.Name)
+		if _, err := s.Logger.LogCmd(
+			exec.Command(distro.CryptsetupCmd(), args...),
+			"opening LUKS volume %q", luks.Name,
+		); err != nil {
+			return fmt.Errorf("cryptsetup failed: %v", err)
+		}
+
+		// Wait for the mapped device to show up, no udev race
+		// prevention required because this node did not exist before.
+		if err := s.waitOnDevices([]string{luksMappedDevice(luks)}, "luks"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readLuksInfo reads the UUID and label of the LUKS header on device.
+func (s stage) readLuksInfo(device string) (filesystemInfo, error) {
+	info := filesystemInfo{format: "crypto_LUKS"}
+	err := s.Logger.LogOp(
+		func() error {
+			var err error
+			info.uuid, err = util.FilesystemUUID(device)
+			if err != nil {
+				return err
+			}
+			info.label, err = util.FilesystemLabel(device)
+			return err
+		},
+		"reading LUKS header of %q", device,
+	)
+	return info, err
+}
+
+// luksMatches determines if the existing LUKS volume has the UUID and label
+// of spec, if they are specified.
+func luksMatches(existing filesystemInfo, spec types.Luks) error {
+	if spec.UUID != nil && strings.ToLower(*spec.UUID) != strings.ToLower(existing.uuid) {
+		return fmt.Errorf("UUID did not match (specified %q, got %q)", *spec.UUID, existing.uuid)
+	}
+	if spec.Label != nil && *spec.Label != existing.label {
+		return fmt.Errorf("label did not match (specified %q, got %q)", *spec.Label, existing.label)
+	}
+	return nil
+}
+
+// luksCanFormat determines if a device without a LUKS header may be formatted
+// although a wipe of the volume wasn't requested, which is the case if the
+// device holds no other filesystem or signature either.
+func luksCanFormat(existing filesystemInfo, spec types.Luks) error {
+	if existing.format != "" {
+		return fmt.Errorf("found %s on %q and a volume wipe was not requested", existing.format, spec.Device)
+	}
+	return nil
+}
+
+// fetchLuksKeyFile writes the key file of luks to the initramfs and returns
+// its path.
+func (s stage) fetchLuksKeyFile(luks types.Luks) (string, error) {
+	path := filepath.Join(distro.LuksInitramfsKeyFilePath(), luks.Name)
+	mode := 0400
+	f := types.File{
+		Node: types.Node{
+			Path: path,
+		},
+		FileEmbedded1: types.FileEmbedded1{
+			Contents: luks.KeyFile,
+			Mode:     &mode,
+		},
+	}
+
+	// the key file belongs to the initramfs rather than the root (but not
+	// if we're running locally through blackbox tests)
+	u := s.Util
+	if !distro.BlackboxTesting() {
+		u.DestDir = "/"
+	}
+
+	fetchOp := u.PrepareFetch(s.Logger, f)
+	if fetchOp == nil {
+		return "", fmt.Errorf("failed to resolve key file %q", luks.KeyFile.Source)
+	}
+	if err := s.Logger.LogOp(
+		func() error { return u.PerformFetch(fetchOp) },
+		"writing key file for %q", luks.Name,
+	); err != nil {
+		return "", err
+	}
+
+	return u.JoinPath(path)
+}
+
+// luksFormatArgs returns the cryptsetup arguments to create the LUKS2 volume
+// described by luks on device.
+func luksFormatArgs(luks types.Luks, device, keyFile string) []string {
+	args := []string{
+		"luksFormat",
+		"--type", "luks2",
+		"--batch-mode",
+		"--key-file", keyFile,
+	}
+	if luks.Label != nil {
+		args = append(args, "--label", *luks.Label)
+	}
+	if luks.UUID != nil {
+		args = append(args, "--uuid", *luks.UUID)
+	}
+	for _, o := range luks.Options {
+		args = append(args, string(o))
+	}
+	return append(args, device)
+}
+
+// luksMappedDevice returns the path of the opened volume.
+func luksMappedDevice(luks types.Luks) string {
+	return "/dev/disk/by-id/dm-name-" + luks.Name
+}
@@ -0,0 +1,102 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"testing"
+
+	"github.com/flatcar/ignition/internal/config/types"
+)
+
+func TestLuksMatches(t *testing.T) {
+	type in struct {
+		existing filesystemInfo
+		spec     types.Luks
+	}
+	type out struct {
+		match bool
+	}
+
+	strToPtr := func(s string) *string { return &s }
+	existing := filesystemInfo{format: "crypto_LUKS", uuid: "5ad6e5a8-7f51-4b6f-9f2e-c1b6aab9ab5c", label: "data"}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{existing, types.Luks{Name: "data"}},
+			out: out{true},
+		},
+		{
+			in:  in{existing, types.Luks{Name: "data", UUID: strToPtr("5AD6E5A8-7F51-4B6F-9F2E-C1B6AAB9AB5C"), Label: strToPtr("data")}},
+			out: out{true},
+		},
+		{
+			in:  in{existing, types.Luks{Name: "data", UUID: strToPtr("0e1d1a0c-64d8-e1b5-5a5e-1c344c0e8b65")}},
+			out: out{false},
+		},
+		{
+			in:  in{existing, types.Luks{Name: "data", Label: strToPtr("other")}},
+			out: out{false},
+		},
+		// LUKS1 headers have no label
+		{
+			in:  in{filesystemInfo{format: "crypto_LUKS", uuid: existing.uuid}, types.Luks{Name: "data", Label: strToPtr("data")}},
+			out: out{false},
+		},
+	}
+
+	for i, test := range tests {
+		err := luksMatches(test.in.existing, test.in.spec)
+		if test.out.match != (err == nil) {
+			t.Errorf("#%d: bad match: want %v, got %v", i, test.out.match, err)
+		}
+	}
+}
+
+func TestLuksCanFormat(t *testing.T) {
+	type in struct {
+		existing filesystemInfo
+	}
+	type out struct {
+		format bool
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{filesystemInfo{}},
+			out: out{true},
+		},
+		{
+			in:  in{filesystemInfo{format: "ext4", uuid: "8a7a6e26-5e8f-4cca-a654-46215d4696ac", label: "DATA"}},
+			out: out{false},
+		},
+		{
+			in:  in{filesystemInfo{format: "LVM2_member"}},
+			out: out{false},
+		},
+	}
+
+	for i, test := range tests {
+		err := luksCanFormat(test.in.existing, types.Luks{Name: "data", Device: "/dev/sdb1"})
+		if test.out.format != (err == nil) {
+			t.Errorf("#%d: bad result: want %v, got %v", i, test.out.format, err)
+		}
+	}
+}
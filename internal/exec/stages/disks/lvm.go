@@ -0,0 +1,356 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"fmt"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/flatcar/ignition/internal/config/types"
+	"github.com/flatcar/ignition/internal/distro"
+	"github.com/flatcar/ignition/internal/exec/util"
+	"github.com/flatcar/ignition/internal/log"
+)
+
+// logicalVolumeInfo is an existing logical volume as reported by lvs.
+type logicalVolumeInfo struct {
+	sizeMiB float64
+	segtype string
+	pool    string
+}
+
+// createLvm creates the volume groups and logical volumes described in
+// config.Storage.Lvm. Existing volume groups and logical volumes are reused
+// if they match the config, see volumeGroupMatches and
+// logicalVolumeMatches.
+func (s stage) createLvm(config types.Config) error {
+	if len(config.Storage.Lvm.VolumeGroups) == 0 {
+		return nil
+	}
+	s.Logger.PushPrefix("createLvm")
+	defer s.Logger.PopPrefix()
+
+	devs := []string{}
+	for _, vg := range config.Storage.Lvm.VolumeGroups {
+		for _, dev := range vg.PhysicalVolumes {
+			devs = append(devs, string(dev))
+		}
+	}
+
+	if err := s.waitOnDevicesAndCreateAliases(devs, "lvm"); err != nil {
+		return err
+	}
+
+	for _, vg := range config.Storage.Lvm.VolumeGroups {
+		if err := s.Logger.LogOp(
+			func() error { return s.createVolumeGroup(vg) },
+			"creating volume group %q", vg.Name,
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createVolumeGroup creates or reuses vg and its logical volumes.
+func (s stage) createVolumeGroup(vg types.VolumeGroup) error {
+	existing, err := s.getVolumeGroupPVs(vg.Name)
+	if err != nil {
+		return err
+	}
+
+	if existing == nil {
+		// vgcreate would offer to wipe other signatures on the
+		// physical volumes, so refuse devices which hold any. Nothing
+		// answers its prompts, so it doesn't wipe anything itself.
+		for _, dev := range vg.PhysicalVolumes {
+			info, err := s.readFilesystemInfo(types.Mount{Device: util.DeviceAlias(string(dev))})
+			if err != nil {
+				return err
+			}
+			if err := physicalVolumeIsBlank(info, string(dev)); err != nil {
+				return fmt.Errorf("cannot create volume group %q: %v", vg.Name, err)
+			}
+		}
+
+		args := []string{"vgcreate"}
+		for _, o := range vg.Options {
+			args = append(args, string(o))
+		}
+		args = append(args, vg.Name)
+		for _, dev := range vg.PhysicalVolumes {
+			args = append(args, util.DeviceAlias(string(dev)))
+		}
+		if _, err := s.Logger.LogCmd(
+			exec.Command(distro.LvmCmd(), args...),
+			"creating volume group %q", vg.Name,
+		); err != nil {
+			return fmt.Errorf("vgcreate failed: %v", err)
+		}
+	} else {
+		wanted := []string{}
+		for _, dev := range vg.PhysicalVolumes {
+			path, err := filepath.EvalSymlinks(util.DeviceAlias(string(dev)))
+			if err != nil {
+				return fmt.Errorf("failed to resolve physical volume %q: %v", dev, err)
+			}
+			wanted = append(wanted, path)
+		}
+		if err := volumeGroupMatches(existing, wanted); err != nil {
+			return fmt.Errorf("volume group %q didn't match: %v", vg.Name, err)
+		}
+		s.Logger.Info("volume group %q found with correct physical volumes", vg.Name)
+	}
+
+	lvs, err := s.getLogicalVolumes(vg.Name)
+	if err != nil {
+		return err
+	}
+	extentMiB, err := s.getExtentSizeMiB(vg.Name)
+	if err != nil {
+		return err
+	}
+
+	for _, lv := range sortLogicalVolumes(vg.LogicalVolumes) {
+		if info, ok := lvs[lv.Name]; ok {
+			if err := logicalVolumeMatches(info, lv, extentMiB); err != nil {
+				return fmt.Errorf("logical volume %q didn't match: %v", lv.Name, err)
+			}
+			s.Logger.Info("logical volume %q found with correct specifications", lv.Name)
+			continue
+		}
+
+		if _, err := s.Logger.LogCmd(
+			exec.Command(distro.LvmCmd(), lvcreateArgs(vg.Name, lv)...),
+			"creating logical volume %q", lv.Name,
+		); err != nil {
+			return fmt.Errorf("lvcreate failed: %v", err)
+		}
+
+		// Thin pools are not exposed as a device node. Other volumes
+		// did not exist before, so no udev race prevention is
+		// required.
+		if lv.Type != "thin-pool" {
+			if err := s.waitOnDevices([]string{logicalVolumeDevice(vg.Name, lv.Name)}, "lvm"); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// lvmReport runs an lvm reporting command and returns its rows, split into
+// the requested fields.
+func (s stage) lvmReport(args ...string) ([][]string, error) {
+	args = append(args, "--noheadings", "--separator", ":")
+	cmd := exec.Command(distro.LvmCmd(), args...)
+	s.Logger.Debug("executing: %s", log.QuotedCmd(cmd))
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("%v: %s", err, exitErr.Stderr)
+		}
+		return nil, err
+	}
+	return parseLvmReport(string(out)), nil
+}
+
+// getVolumeGroupPVs returns the resolved physical volumes of the volume group
+// name, or nil if it doesn't exist.
+func (s stage) getVolumeGroupPVs(name string) ([]string, error) {
+	rows, err := s.lvmReport("pvs", "-o", "pv_name,vg_name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list physical volumes: %v", err)
+	}
+	var pvs []string
+	for _, row := range rows {
+		if len(row) != 2 || row[1] != name {
+			continue
+		}
+		path, err := filepath.EvalSymlinks(row[0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve physical volume %q: %v", row[0], err)
+		}
+		pvs = append(pvs, path)
+	}
+	return pvs, nil
+}
+
+// getLogicalVolumes returns the logical volumes of the volume group vg,
+// indexed by name.
+func (s stage) getLogicalVolumes(vg string) (map[string]logicalVolumeInfo, error) {
+	rows, err := s.lvmReport("lvs", "--units", "m", "--nosuffix", "-o", "lv_name,lv_size,segtype,pool_lv", vg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list logical volumes of %q: %v", vg, err)
+	}
+	lvs := map[string]logicalVolumeInfo{}
+	for _, row := range rows {
+		if len(row) != 4 {
+			return nil, fmt.Errorf("unexpected lvs output %q", strings.Join(row, ":"))
+		}
+		size, err := strconv.ParseFloat(row[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse size of logical volume %q: %v", row[0], err)
+		}
+		lvs[row[0]] = logicalVolumeInfo{
+			sizeMiB: size,
+			segtype: row[2],
+			pool:    row[3],
+		}
+	}
+	return lvs, nil
+}
+
+// getExtentSizeMiB returns the physical extent size of the volume group vg.
+func (s stage) getExtentSizeMiB(vg string) (float64, error) {
+	rows, err := s.lvmReport("vgs", "--units", "m", "--nosuffix", "-o", "vg_extent_size", vg)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read extent size of %q: %v", vg, err)
+	}
+	if len(rows) != 1 || len(rows[0]) != 1 {
+		return 0, fmt.Errorf("unexpected vgs output for %q", vg)
+	}
+	return strconv.ParseFloat(rows[0][0], 64)
+}
+
+// parseLvmReport splits the output of an lvm reporting command run with
+// --noheadings and --separator ":" into rows of fields.
+func parseLvmReport(out string) [][]string {
+	var rows [][]string
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		rows = append(rows, strings.Split(line, ":"))
+	}
+	return rows
+}
+
+// physicalVolumeIsBlank determines if a device can be used as a new physical
+// volume, which is the case if it holds nothing or an unused physical volume.
+func physicalVolumeIsBlank(existing filesystemInfo, dev string) error {
+	switch existing.format {
+	case "", "LVM2_member":
+		return nil
+	default:
+		return fmt.Errorf("found %s on physical volume %q", existing.format, dev)
+	}
+}
+
+// volumeGroupMatches determines if the existing physical volumes of a volume
+// group are the wanted ones. Both lists hold resolved device paths.
+func volumeGroupMatches(existing, wanted []string) error {
+	have := map[string]bool{}
+	for _, pv := range existing {
+		have[pv] = true
+	}
+	for _, pv := range wanted {
+		if !have[pv] {
+			return fmt.Errorf("%q is not a physical volume of the group", pv)
+		}
+		delete(have, pv)
+	}
+	extra := []string{}
+	for pv := range have {
+		extra = append(extra, pv)
+	}
+	if len(extra) > 0 {
+		sort.Strings(extra)
+		return fmt.Errorf("%s are additional physical volumes of the group", strings.Join(extra, ", "))
+	}
+	return nil
+}
+
+// logicalVolumeMatches determines if the existing logical volume matches the
+// spec given. Sizes are compared after rounding the spec up to whole extents,
+// the way lvcreate does. Volumes sized by percentFree match in any size.
+func logicalVolumeMatches(existing logicalVolumeInfo, spec types.LogicalVolume, extentMiB float64) error {
+	switch spec.Type {
+	case "", "linear":
+		// striped volumes are linear volumes created with --stripes
+		if existing.segtype != "linear" && existing.segtype != "striped" {
+			return fmt.Errorf("type %q doesn't match linear", existing.segtype)
+		}
+	default:
+		if existing.segtype != spec.Type {
+			return fmt.Errorf("type %q doesn't match %q", existing.segtype, spec.Type)
+		}
+	}
+	if spec.Pool != nil && existing.pool != *spec.Pool {
+		return fmt.Errorf("pool %q doesn't match %q", existing.pool, *spec.Pool)
+	}
+	if spec.SizeMiB != nil && extentMiB > 0 {
+		want := math.Ceil(float64(*spec.SizeMiB)/extentMiB) * extentMiB
+		if math.Abs(existing.sizeMiB-want) > 0.01 {
+			return fmt.Errorf("size %.2f MiB doesn't match %d MiB", existing.sizeMiB, *spec.SizeMiB)
+		}
+	}
+	return nil
+}
+
+// sortLogicalVolumes orders lvs the way they have to be created: volumes
+// with a fixed size first, then those taking a share of the remaining free
+// space, then thin volumes which need their pool to exist.
+func sortLogicalVolumes(lvs []types.LogicalVolume) []types.LogicalVolume {
+	rank := func(lv types.LogicalVolume) int {
+		switch {
+		case lv.Type == "thin":
+			return 2
+		case lv.PercentFree != nil:
+			return 1
+		default:
+			return 0
+		}
+	}
+	sorted := append([]types.LogicalVolume{}, lvs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return rank(sorted[i]) < rank(sorted[j])
+	})
+	return sorted
+}
+
+// lvcreateArgs returns the lvm arguments to create lv in the volume group vg.
+func lvcreateArgs(vg string, lv types.LogicalVolume) []string {
+	args := []string{"lvcreate", "--yes", "--name", lv.Name}
+	switch {
+	case lv.Type == "thin":
+		// the size of a thin volume is the virtual size it reports
+		args = append(args, "--type", "thin", "--virtualsize", fmt.Sprintf("%dm", *lv.SizeMiB), "--thinpool", *lv.Pool)
+	case lv.SizeMiB != nil:
+		args = append(args, "--size", fmt.Sprintf("%dm", *lv.SizeMiB))
+	default:
+		args = append(args, "--extents", fmt.Sprintf("%d%%FREE", *lv.PercentFree))
+	}
+	if lv.Type == "thin-pool" {
+		args = append(args, "--type", "thin-pool")
+	}
+	for _, o := range lv.Options {
+		args = append(args, string(o))
+	}
+	return append(args, vg)
+}
+
+// logicalVolumeDevice returns the device node of the logical volume lv.
+func logicalVolumeDevice(vg, lv string) string {
+	return filepath.Join("/dev", vg, lv)
+}
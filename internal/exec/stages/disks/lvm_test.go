@@ -0,0 +1,128 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flatcar/ignition/internal/config/types"
+)
+
+func TestLogicalVolumeMatches(t *testing.T) {
+	type in struct {
+		existing logicalVolumeInfo
+		spec     types.LogicalVolume
+	}
+	type out struct {
+		match bool
+	}
+
+	intToPtr := func(i int) *int { return &i }
+	strToPtr := func(s string) *string { return &s }
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{logicalVolumeInfo{sizeMiB: 1024, segtype: "linear"}, types.LogicalVolume{Name: "a", SizeMiB: intToPtr(1024)}},
+			out: out{true},
+		},
+		// lvcreate rounds up to whole 4 MiB extents
+		{
+			in:  in{logicalVolumeInfo{sizeMiB: 1028, segtype: "linear"}, types.LogicalVolume{Name: "a", SizeMiB: intToPtr(1025)}},
+			out: out{true},
+		},
+		{
+			in:  in{logicalVolumeInfo{sizeMiB: 2048, segtype: "linear"}, types.LogicalVolume{Name: "a", SizeMiB: intToPtr(1024)}},
+			out: out{false},
+		},
+		{
+			in:  in{logicalVolumeInfo{sizeMiB: 2048, segtype: "striped"}, types.LogicalVolume{Name: "a", PercentFree: intToPtr(50)}},
+			out: out{true},
+		},
+		{
+			in:  in{logicalVolumeInfo{sizeMiB: 2048, segtype: "thin-pool"}, types.LogicalVolume{Name: "a", PercentFree: intToPtr(50)}},
+			out: out{false},
+		},
+		{
+			in:  in{logicalVolumeInfo{sizeMiB: 512, segtype: "thin", pool: "pool"}, types.LogicalVolume{Name: "a", Type: "thin", Pool: strToPtr("pool"), SizeMiB: intToPtr(512)}},
+			out: out{true},
+		},
+		{
+			in:  in{logicalVolumeInfo{sizeMiB: 512, segtype: "thin", pool: "other"}, types.LogicalVolume{Name: "a", Type: "thin", Pool: strToPtr("pool"), SizeMiB: intToPtr(512)}},
+			out: out{false},
+		},
+	}
+
+	for i, test := range tests {
+		err := logicalVolumeMatches(test.in.existing, test.in.spec, 4)
+		if test.out.match != (err == nil) {
+			t.Errorf("#%d: bad match: want %v, got %v", i, test.out.match, err)
+		}
+	}
+}
+
+func TestVolumeGroupMatches(t *testing.T) {
+	if err := volumeGroupMatches([]string{"/dev/sdb1", "/dev/md127"}, []string{"/dev/md127", "/dev/sdb1"}); err != nil {
+		t.Errorf("same physical volumes: %v", err)
+	}
+	if err := volumeGroupMatches([]string{"/dev/sdb1"}, []string{"/dev/sdb1", "/dev/sdc1"}); err == nil {
+		t.Errorf("missing physical volume matched")
+	}
+	if err := volumeGroupMatches([]string{"/dev/sdb1", "/dev/sdc1"}, []string{"/dev/sdb1"}); err == nil {
+		t.Errorf("additional physical volume matched")
+	}
+}
+
+func TestPhysicalVolumeIsBlank(t *testing.T) {
+	if err := physicalVolumeIsBlank(filesystemInfo{}, "/dev/sdb1"); err != nil {
+		t.Errorf("blank device: %v", err)
+	}
+	if err := physicalVolumeIsBlank(filesystemInfo{format: "LVM2_member"}, "/dev/sdb1"); err != nil {
+		t.Errorf("unused physical volume: %v", err)
+	}
+	if err := physicalVolumeIsBlank(filesystemInfo{format: "ext4", label: "DATA"}, "/dev/sdb1"); err == nil {
+		t.Errorf("device with a filesystem was accepted")
+	}
+}
+
+func TestSortLogicalVolumes(t *testing.T) {
+	intToPtr := func(i int) *int { return &i }
+	lvs := []types.LogicalVolume{
+		{Name: "thin", Type: "thin"},
+		{Name: "rest", PercentFree: intToPtr(100)},
+		{Name: "a", SizeMiB: intToPtr(1)},
+		{Name: "pool", Type: "thin-pool", PercentFree: intToPtr(50)},
+		{Name: "b", SizeMiB: intToPtr(1)},
+	}
+	names := []string{}
+	for _, lv := range sortLogicalVolumes(lvs) {
+		names = append(names, lv.Name)
+	}
+	want := []string{"a", "b", "rest", "pool", "thin"}
+	if !reflect.DeepEqual(want, names) {
+		t.Errorf("bad order: want %v, got %v", want, names)
+	}
+}
+
+func TestParseLvmReport(t *testing.T) {
+	out := "  /dev/sdb1:data\n  /dev/sdc1:\n\n"
+	want := [][]string{{"/dev/sdb1", "data"}, {"/dev/sdc1", ""}}
+	if rows := parseLvmReport(out); !reflect.DeepEqual(want, rows) {
+		t.Errorf("bad rows: want %q, got %q", want, rows)
+	}
+}
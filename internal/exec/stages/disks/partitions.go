@@ -19,22 +19,19 @@
 package disks
 
 import (
-	"errors"
 	"fmt"
-	"regexp"
 	"sort"
-	"strconv"
 	"strings"
 
 	"github.com/flatcar/ignition/internal/config/types"
+	"github.com/flatcar/ignition/internal/distro"
 	"github.com/flatcar/ignition/internal/exec/util"
+	"github.com/flatcar/ignition/internal/gpt"
+	"github.com/flatcar/ignition/internal/mbr"
+	"github.com/flatcar/ignition/internal/partitioners"
 	"github.com/flatcar/ignition/internal/sgdisk"
 )
 
-var (
-	ErrBadSgdiskOutput = errors.New("sgdisk had unexpected output")
-)
-
 // createPartitions creates the partitions described in config.Storage.Disks.
 func (s stage) createPartitions(config types.Config) error {
 	if len(config.Storage.Disks) == 0 {
@@ -55,12 +52,24 @@ func (s stage) createPartitions(config types.Config) error {
 	for _, dev := range config.Storage.Disks {
 		devAlias := util.DeviceAlias(string(dev.Device))
 
+		// The partitions of the config are applied on top of the
+		// partition table in the disk image.
+		if dev.Image != nil {
+			if err := s.writeDiskImage(dev, devAlias); err != nil {
+				return err
+			}
+		}
+
 		err := s.Logger.LogOp(func() error {
 			return s.partitionDisk(dev, devAlias)
 		}, "partitioning %q", devAlias)
 		if err != nil {
 			return err
 		}
+
+		if err := s.writePartitionImages(dev, devAlias); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -88,42 +97,114 @@ func partitionMatches(existing, spec types.Partition) error {
 	if spec.Label != nil && *spec.Label != *existing.Label {
 		return fmt.Errorf("label did not match (specified %q, got %q)", *spec.Label, *existing.Label)
 	}
+	if len(spec.Attributes) > 0 {
+		specBits, err := spec.ParseAttributes()
+		if err != nil {
+			return err
+		}
+		existingBits, err := existing.ParseAttributes()
+		if err != nil {
+			return err
+		}
+		if specBits != existingBits {
+			return fmt.Errorf("attributes did not match (specified %016x, got %016x)", specBits, existingBits)
+		}
+	}
+	if spec.MbrType != "" && !mbrTypesMatch(spec.MbrType, existing.MbrType) {
+		return fmt.Errorf("MBR type did not match (specified %q, got %q)", spec.MbrType, existing.MbrType)
+	}
+	if spec.Bootable && !existing.Bootable {
+		return fmt.Errorf("partition is not bootable")
+	}
 	return nil
 }
 
+// mbrTypesMatch returns whether the MBR type codes a and b are the same, e.g. "c" and "0x0C".
+func mbrTypesMatch(a, b string) bool {
+	ta, errA := mbr.ParseType(a)
+	tb, errB := mbr.ParseType(b)
+	return errA == nil && errB == nil && ta == tb
+}
+
+// partitionGrows determines if the existing partition can be resized to the spec given, i.e. whether it matches
+// the spec in everything but its size and is not larger than specified. It returns true if the partition needs
+// to be grown. spec must have a non-nil Size.
+func partitionGrows(existing, spec types.Partition) (bool, error) {
+	size := spec.Size
+	spec.Size = nil
+	if err := partitionMatches(existing, spec); err != nil {
+		return false, err
+	}
+	if *size < *existing.Size {
+		return false, fmt.Errorf("size did not match (specified %d, got %d) and partitions can only be grown", *size, *existing.Size)
+	}
+	return *size > *existing.Size, nil
+}
+
+// grownPartition returns the partition to create in place of existing when growing it to the size of spec. The
+// label, GUIDs and attributes of the existing partition are kept unless the spec sets them.
+func grownPartition(existing, spec types.Partition) types.Partition {
+	if spec.Label == nil {
+		spec.Label = existing.Label
+	}
+	if spec.GUID == "" {
+		spec.GUID = existing.GUID
+	}
+	if spec.TypeGUID == "" {
+		spec.TypeGUID = existing.TypeGUID
+	}
+	if len(spec.Attributes) == 0 {
+		spec.Attributes = existing.Attributes
+	}
+	return spec
+}
+
 // partitionShouldBeInspected returns if the partition has zeroes that need to be resolved to sectors.
+// Partitions which are resized are always inspected.
 func partitionShouldBeInspected(part types.Partition) bool {
 	if part.Number == 0 {
 		return false
 	}
-	return (part.Start != nil && *part.Start == 0) ||
+	return part.Resize ||
+		(part.Start != nil && *part.Start == 0) ||
 		(part.StartMiB != nil && *part.StartMiB == 0) ||
 		(part.Size != nil && *part.Size == 0) ||
 		(part.SizeMiB != nil && *part.SizeMiB == 0)
 }
 
 // getRealStartAndSize returns a map of partition numbers to a struct that contains what their real start
-// and end sector should be. It runs sgdisk --pretend to determine what the partitions would look like if
-// everything specified were to be (re)created.
-func (s stage) getRealStartAndSize(dev types.Disk, devAlias string, existanceMap map[int]types.Partition) ([]types.Partition, error) {
-	op := sgdisk.Begin(s.Logger, devAlias)
+// and end sector should be. It pretends the operation to determine what the partitions would look like if
+// everything specified were to be (re)created. If wipe is set, the partition table is pretended to be
+// wiped first. Existing partitions which are resized keep their start and, unless a size is specified,
+// grow into all of the free space following them.
+func (s stage) getRealStartAndSize(dev types.Disk, devAlias string, existanceMap map[int]types.Partition, wipe bool) ([]types.Partition, error) {
+	op, err := s.beginPartitionOp(dev, devAlias)
+	if err != nil {
+		return nil, err
+	}
+	op.WipeTable(wipe)
+	op.MoveSecondHeader(diskHasResize(dev))
 	for _, part := range dev.Partitions {
 		info, exists := existanceMap[part.Number]
 		if exists {
 			// delete all existing partitions
 			op.DeletePartition(part.Number)
-			if part.Start == nil && part.StartMiB == nil && !part.WipePartitionEntry {
+			if part.Start == nil && part.StartMiB == nil && (!part.WipePartitionEntry || part.Resize) {
 				// don't care means keep the same if we can't wipe, otherwise stick it at start 0
 				part.StartMiB = nil
 				part.Start = info.Start
 			}
-			if part.Size == nil && part.SizeMiB == nil && !part.WipePartitionEntry {
+			if part.Size == nil && part.SizeMiB == nil && part.Resize {
+				zero := 0
+				part.Size = &zero
+			} else if part.Size == nil && part.SizeMiB == nil && !part.WipePartitionEntry {
 				part.SizeMiB = nil
 				part.Size = info.Size
 			}
 		}
 		if partitionShouldExist(part) {
-			// Clear the label. sgdisk doesn't escape control characters. This makes parsing easier
+			// Clear the label. sgdisk doesn't escape control characters in its output,
+			// which could break parsing it.
 			part.Label = nil
 			op.CreatePartition(part)
 		}
@@ -138,12 +219,7 @@ func (s stage) getRealStartAndSize(dev types.Disk, devAlias string, existanceMap
 		}
 	}
 
-	output, err := op.Pretend()
-	if err != nil {
-		return nil, err
-	}
-
-	realDimensions, err := parseSgdiskPretend(output, partitionsToInspect)
+	realDimensions, err := op.Pretend()
 	if err != nil {
 		return nil, err
 	}
@@ -151,13 +227,18 @@ func (s stage) getRealStartAndSize(dev types.Disk, devAlias string, existanceMap
 	result := []types.Partition{}
 	for _, part := range dev.Partitions {
 		if dims, ok := realDimensions[part.Number]; ok {
+			if part.Resize {
+				// resized partitions always take the inspected dimensions
+				part.Start = &dims.Start
+				part.Size = &dims.Size
+			}
 			if part.Start != nil {
 				part.StartMiB = nil
-				part.Start = &dims.start
+				part.Start = &dims.Start
 			}
 			if part.Size != nil {
 				part.SizeMiB = nil
-				part.Size = &dims.size
+				part.Size = &dims.Size
 			}
 		}
 		result = append(result, part)
@@ -165,91 +246,21 @@ func (s stage) getRealStartAndSize(dev types.Disk, devAlias string, existanceMap
 	return result, nil
 }
 
-type sgdiskOutput struct {
-	start int
-	size  int
-}
-
-// parseLine takes a regexp that captures an int and a string to match on. On success it returns
-// the captured int and nil. If the regexp does not match it returns -1 and nil. If it encountered
-// an error it returns 0 and the error.
-func parseLine(r *regexp.Regexp, line string) (int, error) {
-	matches := r.FindStringSubmatch(line)
-	switch len(matches) {
-	case 0:
-		return -1, nil
-	case 2:
-		return strconv.Atoi(matches[1])
-	default:
-		return 0, ErrBadSgdiskOutput
+// beginPartitionOp begins a partitioning operation on devAlias. DOS partition
+// tables are always written by the built-in MBR writer, GUID partition tables
+// by the partitioner of the distro, either sgdisk or the built-in GPT writer.
+func (s stage) beginPartitionOp(dev types.Disk, devAlias string) (partitioners.Operation, error) {
+	if dev.TableType == "dos" {
+		return mbr.Begin(s.Logger, devAlias), nil
 	}
-}
-
-// parseSgdiskPretend parses the output of running sgdisk pretend with --info specified for each partition
-// number specified in partitionNumbers. E.g. if paritionNumbers is [1,4,5], it is expected that the sgdisk
-// output was from running `sgdisk --pretend <commands> --info=1 --info=4 --info=5`. It assumes the the
-// partition labels are well behaved (i.e. contain no control characters). It returns a list of partitions
-// matching the partition numbers specified, but with the start and size information as determined by sgdisk.
-// The partition numbers need to passed in because sgdisk includes them in its output.
-func parseSgdiskPretend(sgdiskOut string, partitionNumbers []int) (map[int]sgdiskOutput, error) {
-	if len(partitionNumbers) == 0 {
-		return nil, nil
-	}
-	startRegex := regexp.MustCompile("^First sector: (\\d*) \\(.*\\)$")
-	endRegex := regexp.MustCompile("^Last sector: (\\d*) \\(.*\\)$")
-	const (
-		START             = iota
-		END               = iota
-		FAIL_ON_START_END = iota
-	)
-
-	output := map[int]sgdiskOutput{}
-	state := START
-	current := sgdiskOutput{}
-	i := 0
-
-	lines := strings.Split(sgdiskOut, "\n")
-	for _, line := range lines {
-		switch state {
-		case START:
-			start, err := parseLine(startRegex, line)
-			if err != nil {
-				return nil, err
-			}
-			if start != -1 {
-				current.start = start
-				state = END
-			}
-		case END:
-			end, err := parseLine(endRegex, line)
-			if err != nil {
-				return nil, err
-			}
-			if end != -1 {
-				current.size = 1 + end - current.start
-				output[partitionNumbers[i]] = current
-				i++
-				if i == len(partitionNumbers) {
-					state = FAIL_ON_START_END
-				} else {
-					current = sgdiskOutput{}
-					state = START
-				}
-			}
-		case FAIL_ON_START_END:
-			if len(startRegex.FindStringSubmatch(line)) != 0 ||
-				len(endRegex.FindStringSubmatch(line)) != 0 {
-				return nil, ErrBadSgdiskOutput
-			}
-		}
-	}
-
-	if state != FAIL_ON_START_END {
-		// We stopped parsing in the middle of a info block. Something is wrong
-		return nil, ErrBadSgdiskOutput
+	switch partitioner := distro.Partitioner(); partitioner {
+	case "sgdisk":
+		return sgdisk.Begin(s.Logger, devAlias), nil
+	case "gpt":
+		return gpt.Begin(s.Logger, devAlias), nil
+	default:
+		return nil, fmt.Errorf("unknown partitioner %q", partitioner)
 	}
-
-	return output, nil
 }
 
 // partitionShouldExist returns whether a bool is indicating if a partition should exist or not.
@@ -258,12 +269,17 @@ func partitionShouldExist(part types.Partition) bool {
 	return part.ShouldExist == nil || *part.ShouldExist
 }
 
-// getPartitionMap returns a map of partitions on device, indexed by partition number
-func (s stage) getPartitionMap(device string) (map[int]types.Partition, error) {
+// getPartitionMap returns a map of partitions on device, indexed by partition number.
+// tableType is the type of partition table expected on device.
+func (s stage) getPartitionMap(device, tableType string) (map[int]types.Partition, error) {
 	parts := []types.Partition{}
 	err := s.Logger.LogOp(
 		func() error {
-			p, err := util.DumpPartitionTable(device)
+			dump := util.DumpPartitionTable
+			if tableType == "dos" {
+				dump = mbr.DumpPartitionTable
+			}
+			p, err := dump(device)
 			if err != nil {
 				return err
 			}
@@ -280,6 +296,16 @@ func (s stage) getPartitionMap(device string) (map[int]types.Partition, error) {
 	return m, nil
 }
 
+// diskHasResize returns whether any partition of dev is to be resized.
+func diskHasResize(dev types.Disk) bool {
+	for _, part := range dev.Partitions {
+		if part.Resize {
+			return true
+		}
+	}
+	return false
+}
+
 // Allow sorting partitions (must be a stable sort) so partition number 0 happens last
 // regardless of where it was in the list.
 type PartitionList []types.Partition
@@ -301,34 +327,68 @@ func (p PartitionList) Swap(i, j int) {
 // partitionDisk partitions devAlias according to the spec given by dev
 func (s stage) partitionDisk(dev types.Disk, devAlias string) error {
 	if dev.WipeTable {
-		op := sgdisk.Begin(s.Logger, devAlias)
+		op, err := s.beginPartitionOp(dev, devAlias)
+		if err != nil {
+			return err
+		}
 		s.Logger.Info("wiping partition table requested on %q", devAlias)
 		op.WipeTable(true)
 		op.Commit()
 	}
 
+	originalParts, err := s.getPartitionMap(devAlias, dev.TableType)
+	if err != nil {
+		return err
+	}
+
+	op, err := s.buildPartitionOp(dev, devAlias, originalParts, false)
+	if err != nil {
+		return err
+	}
+
+	if err := op.Commit(); err != nil {
+		return fmt.Errorf("commit failure: %v", err)
+	}
+
+	// It's best to wait here for the /dev/ABC entries to be
+	// (re)created, not only for other parts of the initramfs but
+	// also because s.waitOnDevices() can still race with udev's
+	// partition entry recreation.
+	if err := s.waitForUdev(devAlias, "createPartitions"); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// buildPartitionOp returns the partitioning operation which makes the partitions on devAlias match the spec
+// given by dev, with originalParts being the existing partitions. If wipe is set, the operation wipes
+// the partition table first and originalParts is expected to be empty.
+func (s stage) buildPartitionOp(dev types.Disk, devAlias string, originalParts map[int]types.Partition, wipe bool) (partitioners.Operation, error) {
 	// Ensure all partitions with number 0 are last
 	sort.Stable(PartitionList(dev.Partitions))
 
-	op := sgdisk.Begin(s.Logger, devAlias)
-
-	originalParts, err := s.getPartitionMap(devAlias)
+	op, err := s.beginPartitionOp(dev, devAlias)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	op.WipeTable(wipe)
 
 	// get a list of parititions that have size and start 0 replaced with the real sizes
 	// that would be used if all specified partitions were to be created anew.
-	resolvedPartitions, err := s.getRealStartAndSize(dev, devAlias, originalParts)
+	resolvedPartitions, err := s.getRealStartAndSize(dev, devAlias, originalParts, wipe)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	for _, part := range resolvedPartitions {
 		shouldExist := partitionShouldExist(part)
 		info, exists := originalParts[part.Number]
 		var matchErr error
-		if exists {
+		grow := false
+		if exists && part.Resize {
+			grow, matchErr = partitionGrows(info, part)
+		} else if exists {
 			matchErr = partitionMatches(info, part)
 		}
 		matches := exists && matchErr == nil
@@ -340,34 +400,27 @@ func (s stage) partitionDisk(dev types.Disk, devAlias string) error {
 		case !exists && shouldExist:
 			op.CreatePartition(part)
 		case exists && !shouldExist && !part.WipePartitionEntry:
-			return fmt.Errorf("partition %d exists but is specified as nonexistant and wipePartitionEntry is false", part.Number)
+			return nil, fmt.Errorf("partition %d exists but is specified as nonexistant and wipePartitionEntry is false", part.Number)
 		case exists && !shouldExist && part.WipePartitionEntry:
 			op.DeletePartition(part.Number)
+		case exists && shouldExist && matches && grow:
+			s.Logger.Info("partition %d is smaller than specified, growing it", part.Number)
+			op.MoveSecondHeader(true)
+			op.DeletePartition(part.Number)
+			op.CreatePartition(grownPartition(info, part))
 		case exists && shouldExist && matches:
 			s.Logger.Info("partition %d found with correct specifications", part.Number)
 		case exists && shouldExist && !part.WipePartitionEntry && !matches:
-			return fmt.Errorf("Partition %d didn't match: %v", part.Number, matchErr)
+			return nil, fmt.Errorf("Partition %d didn't match: %v", part.Number, matchErr)
 		case exists && shouldExist && part.WipePartitionEntry && !matches:
 			s.Logger.Info("partition %d did not meet specifications, wiping partition entry and recreating", part.Number)
 			op.DeletePartition(part.Number)
 			op.CreatePartition(part)
 		default:
 			// unfortunatey, golang doesn't check that all cases are handled exhaustively
-			return fmt.Errorf("Unreachable code reached when processing partition %d. golang--", part.Number)
+			return nil, fmt.Errorf("Unreachable code reached when processing partition %d. golang--", part.Number)
 		}
 	}
 
-	if err := op.Commit(); err != nil {
-		return fmt.Errorf("commit failure: %v", err)
-	}
-
-	// It's best to wait here for the /dev/ABC entries to be
-	// (re)created, not only for other parts of the initramfs but
-	// also because s.waitOnDevices() can still race with udev's
-	// partition entry recreation.
-	if err := s.waitForUdev(devAlias, "createPartitions"); err != nil {
-		return err
-	}
-
-	return nil
+	return op, nil
 }
@@ -0,0 +1,247 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/flatcar/ignition/internal/config/types"
+	"github.com/flatcar/ignition/internal/exec/util"
+	"github.com/flatcar/ignition/internal/gpt"
+	"github.com/flatcar/ignition/internal/log"
+	"github.com/flatcar/ignition/internal/mbr"
+	"github.com/flatcar/ignition/internal/sgdisk"
+)
+
+func TestPartitionGrows(t *testing.T) {
+	type in struct {
+		existing types.Partition
+		spec     types.Partition
+	}
+	type out struct {
+		grow bool
+		err  bool
+	}
+
+	intToPtr := func(i int) *int { return &i }
+	strToPtr := func(s string) *string { return &s }
+	existing := types.Partition{
+		Number:   9,
+		Start:    intToPtr(4096),
+		Size:     intToPtr(2048),
+		Label:    strToPtr("ROOT"),
+		GUID:     "8a7a6e26-5e8f-4cca-a654-46215d4696ac",
+		TypeGUID: "3884dd41-8582-4404-b9a8-e9b84f2df50e",
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{existing, types.Partition{Number: 9, Start: intToPtr(4096), Size: intToPtr(8192), Resize: true}},
+			out: out{grow: true},
+		},
+		{
+			in:  in{existing, types.Partition{Number: 9, Start: intToPtr(4096), Size: intToPtr(2048), Resize: true}},
+			out: out{grow: false},
+		},
+		{
+			// partitions are never shrunk
+			in:  in{existing, types.Partition{Number: 9, Start: intToPtr(4096), Size: intToPtr(1024), Resize: true}},
+			out: out{err: true},
+		},
+		{
+			in:  in{existing, types.Partition{Number: 9, Start: intToPtr(4096), Size: intToPtr(8192), Label: strToPtr("DATA"), Resize: true}},
+			out: out{err: true},
+		},
+		{
+			in:  in{existing, types.Partition{Number: 9, Start: intToPtr(6144), Size: intToPtr(8192), Resize: true}},
+			out: out{err: true},
+		},
+	}
+
+	for i, test := range tests {
+		grow, err := partitionGrows(test.in.existing, test.in.spec)
+		if grow != test.out.grow || (err != nil) != test.out.err {
+			t.Errorf("#%d: expected grow %t and error %t, got %t and %v", i, test.out.grow, test.out.err, grow, err)
+		}
+	}
+}
+
+func TestGrownPartition(t *testing.T) {
+	intToPtr := func(i int) *int { return &i }
+	strToPtr := func(s string) *string { return &s }
+	existing := types.Partition{
+		Number:     9,
+		Start:      intToPtr(4096),
+		Size:       intToPtr(2048),
+		Label:      strToPtr("ROOT"),
+		GUID:       "8a7a6e26-5e8f-4cca-a654-46215d4696ac",
+		TypeGUID:   "3884dd41-8582-4404-b9a8-e9b84f2df50e",
+		Attributes: []types.PartitionAttribute{"48", "56"},
+	}
+	spec := types.Partition{Number: 9, Start: intToPtr(4096), Size: intToPtr(8192), Resize: true}
+
+	expected := types.Partition{
+		Number:     9,
+		Start:      intToPtr(4096),
+		Size:       intToPtr(8192),
+		Label:      strToPtr("ROOT"),
+		GUID:       "8a7a6e26-5e8f-4cca-a654-46215d4696ac",
+		TypeGUID:   "3884dd41-8582-4404-b9a8-e9b84f2df50e",
+		Attributes: []types.PartitionAttribute{"48", "56"},
+		Resize:     true,
+	}
+	if actual := grownPartition(existing, spec); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+}
+
+func TestPartitionMatchesMBR(t *testing.T) {
+	type in struct {
+		spec types.Partition
+	}
+	type out struct {
+		err bool
+	}
+
+	intToPtr := func(i int) *int { return &i }
+	existing := types.Partition{
+		Number:   1,
+		Start:    intToPtr(2048),
+		Size:     intToPtr(16384),
+		MbrType:  "0c",
+		Bootable: true,
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{types.Partition{Number: 1, Start: intToPtr(2048), MbrType: "0xC", Bootable: true}},
+			out: out{},
+		},
+		{
+			// not requiring the bootable flag keeps it
+			in:  in{types.Partition{Number: 1, Size: intToPtr(16384)}},
+			out: out{},
+		},
+		{
+			in:  in{types.Partition{Number: 1, MbrType: "83"}},
+			out: out{err: true},
+		},
+		{
+			in:  in{types.Partition{Number: 1, Bootable: true}},
+			out: out{},
+		},
+	}
+
+	for i, test := range tests {
+		err := partitionMatches(existing, test.in.spec)
+		if (err != nil) != test.out.err {
+			t.Errorf("#%d: expected error %t, got %v", i, test.out.err, err)
+		}
+	}
+	existing.Bootable = false
+	if err := partitionMatches(existing, types.Partition{Number: 1, Bootable: true}); err == nil {
+		t.Errorf("partition without bootable flag matched")
+	}
+}
+
+func TestPartitionMatchesAttributes(t *testing.T) {
+	existing := types.Partition{
+		Number:     3,
+		Attributes: []types.PartitionAttribute{"2", "48", "56"},
+	}
+
+	tests := []struct {
+		attributes []types.PartitionAttribute
+		err        bool
+	}{
+		{nil, false},
+		{[]types.PartitionAttribute{"legacy-bios-bootable", "priority=1", "successful"}, false},
+		{[]types.PartitionAttribute{"priority=1", "successful"}, true},
+		{[]types.PartitionAttribute{"legacy-bios-bootable", "priority=2", "successful"}, true},
+	}
+
+	for i, test := range tests {
+		err := partitionMatches(existing, types.Partition{Number: 3, Attributes: test.attributes})
+		if (err != nil) != test.err {
+			t.Errorf("#%d: expected error %t, got %v", i, test.err, err)
+		}
+	}
+}
+
+func TestBeginPartitionOp(t *testing.T) {
+	type in struct {
+		partitioner string
+		tableType   string
+	}
+	type out struct {
+		op  interface{}
+		err bool
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{},
+			out: out{op: &sgdisk.Operation{}},
+		},
+		{
+			in:  in{partitioner: "sgdisk"},
+			out: out{op: &sgdisk.Operation{}},
+		},
+		{
+			in:  in{partitioner: "gpt"},
+			out: out{op: &gpt.Operation{}},
+		},
+		{
+			in:  in{partitioner: "parted"},
+			out: out{err: true},
+		},
+		{
+			in:  in{partitioner: "parted", tableType: "dos"},
+			out: out{op: &mbr.Operation{}},
+		},
+	}
+
+	defer os.Unsetenv("IGNITION_PARTITIONER")
+	logger := log.New(true)
+	s := stage{Util: util.Util{Logger: &logger}}
+	for i, test := range tests {
+		os.Setenv("IGNITION_PARTITIONER", test.in.partitioner)
+		op, err := s.beginPartitionOp(types.Disk{TableType: test.in.tableType}, "/dev/vda")
+		if test.out.err {
+			if err == nil {
+				t.Errorf("#%d: expected an error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if reflect.TypeOf(op) != reflect.TypeOf(test.out.op) {
+			t.Errorf("#%d: bad partitioner: want %T, got %T", i, test.out.op, op)
+		}
+	}
+}
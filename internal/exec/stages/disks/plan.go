@@ -0,0 +1,377 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/flatcar/ignition/internal/config/types"
+	"github.com/flatcar/ignition/internal/exec/stages"
+)
+
+// Plan returns the images, partitions, RAID arrays, LUKS volumes, LVM volumes
+// and filesystems that Run would write, create, delete or format. Devices are
+// only inspected, nothing is written to them and no device aliases are
+// created.
+func (s stage) Plan(config types.Config) ([]stages.Step, error) {
+	var steps []stages.Step
+
+	config, err := s.resolveDiskSelectors(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select disks: %v", err)
+	}
+
+	partSteps, err := s.planPartitions(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to plan partitions: %v", err)
+	}
+	steps = append(steps, partSteps...)
+
+	for _, md := range config.Storage.Raid {
+		step, err := s.planRaid(md)
+		if err != nil {
+			return nil, fmt.Errorf("failed to plan RAID arrays: %v", err)
+		}
+		steps = append(steps, step)
+	}
+
+	for _, luks := range config.Storage.Luks {
+		steps = append(steps, planLuks(luks))
+	}
+
+	for _, vg := range config.Storage.Lvm.VolumeGroups {
+		steps = append(steps, s.planVolumeGroup(vg)...)
+	}
+
+	fsSteps, err := s.planFilesystems(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to plan filesystems: %v", err)
+	}
+	steps = append(steps, fsSteps...)
+
+	return steps, nil
+}
+
+// planPartitions returns the partitions which would be deleted and created
+// on each disk, with their location computed by pretending the operation.
+func (s stage) planPartitions(config types.Config) ([]stages.Step, error) {
+	var steps []stages.Step
+	for _, dev := range config.Storage.Disks {
+		device := string(dev.Device)
+
+		if dev.Image != nil {
+			// The partition table comes from the image, so the
+			// partitions can't be computed before it is written.
+			steps = append(steps, planImage(*dev.Image, device))
+			for _, part := range dev.Partitions {
+				steps = append(steps, stages.Step{
+					Action: "apply partition",
+					Target: fmt.Sprintf("%s partition %d", device, part.Number),
+					Detail: "on top of the partition table of the image",
+				})
+			}
+			steps = append(steps, planPartitionImages(dev)...)
+			continue
+		}
+
+		existing, err := s.getPartitionMap(device, dev.TableType)
+		if err != nil {
+			return nil, err
+		}
+
+		originalParts := existing
+		if dev.WipeTable {
+			steps = append(steps, stages.Step{
+				Action: "wipe partition table",
+				Target: device,
+				Detail: fmt.Sprintf("%d existing partitions", len(existing)),
+			})
+			originalParts = map[int]types.Partition{}
+		}
+
+		op, err := s.buildPartitionOp(dev, device, originalParts, dev.WipeTable)
+		if err != nil {
+			return nil, err
+		}
+
+		// Grown partitions are deleted and recreated at the same start.
+		grown := map[int]bool{}
+		for _, part := range op.Creations() {
+			if _, ok := originalParts[part.Number]; ok && part.Resize {
+				grown[part.Number] = true
+			}
+		}
+
+		for _, num := range op.Deletions() {
+			if grown[num] {
+				continue
+			}
+			steps = append(steps, stages.Step{
+				Action: "delete partition",
+				Target: fmt.Sprintf("%s partition %d", device, num),
+			})
+		}
+
+		created := op.Creations()
+		if len(created) == 0 {
+			steps = append(steps, planPartitionImages(dev)...)
+			continue
+		}
+		for _, part := range created {
+			if part.Number != 0 {
+				op.Info(part.Number)
+			}
+		}
+		dims, err := op.Pretend()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, part := range created {
+			target := fmt.Sprintf("%s partition %d", device, part.Number)
+			if part.Number == 0 {
+				target = fmt.Sprintf("%s next free partition", device)
+			}
+			details := []string{}
+			if d, ok := dims[part.Number]; ok {
+				details = append(details, fmt.Sprintf("start sector %d, %d sectors", d.Start, d.Size))
+			}
+			if part.Label != nil {
+				details = append(details, fmt.Sprintf("label %q", *part.Label))
+			}
+			if part.TypeGUID != "" {
+				details = append(details, "type "+part.TypeGUID)
+			}
+			if len(part.Attributes) > 0 {
+				attrs := []string{}
+				for _, attr := range part.Attributes {
+					attrs = append(attrs, string(attr))
+				}
+				details = append(details, "attributes "+strings.Join(attrs, " "))
+			}
+			if part.MbrType != "" {
+				details = append(details, "MBR type "+part.MbrType)
+			}
+			if part.Bootable {
+				details = append(details, "bootable")
+			}
+			action := "create partition"
+			if grown[part.Number] {
+				action = "grow partition"
+			}
+			steps = append(steps, stages.Step{
+				Action: action,
+				Target: target,
+				Detail: strings.Join(details, ", "),
+			})
+		}
+		steps = append(steps, planPartitionImages(dev)...)
+	}
+	return steps, nil
+}
+
+// planPartitionImages returns the images which would be written to the
+// partitions of dev.
+func planPartitionImages(dev types.Disk) []stages.Step {
+	var steps []stages.Step
+	for _, part := range dev.Partitions {
+		if part.Image != nil {
+			steps = append(steps, planImage(*part.Image, fmt.Sprintf("%s partition %d", dev.Device, part.Number)))
+		}
+	}
+	return steps
+}
+
+// planImage returns the step writing image to target.
+func planImage(image types.Image, target string) stages.Step {
+	detail := image.Source
+	if image.Compression != "" {
+		detail += ", " + image.Compression + " compressed"
+	}
+	return stages.Step{
+		Action: "write image",
+		Target: target,
+		Detail: detail,
+	}
+}
+
+// planFilesystems returns the filesystems which would be (re)formatted or
+// grown, and the subvolumes which would be created on them.
+func (s stage) planFilesystems(config types.Config) ([]stages.Step, error) {
+	var steps []stages.Step
+	for _, fs := range config.Storage.Filesystems {
+		if fs.Mount == nil {
+			continue
+		}
+
+		info, err := s.readFilesystemInfo(*fs.Mount)
+		if err != nil {
+			// The device may only be created by an earlier step.
+			steps = append(steps, stages.Step{
+				Action: "format",
+				Target: fs.Mount.Device,
+				Detail: fmt.Sprintf("%s, device could not be inspected: %v", fs.Mount.Format, err),
+			})
+			steps = append(steps, planSubvolumes(*fs.Mount)...)
+			continue
+		}
+
+		format, err := s.shouldFormat(*fs.Mount, info)
+		if err != nil {
+			return nil, fmt.Errorf("filesystem %q: %v", fs.Mount.Device, err)
+		}
+		if !format {
+			if fs.Mount.GrowFilesystem && info.format == fs.Mount.Format {
+				steps = append(steps, stages.Step{
+					Action: "grow filesystem",
+					Target: fs.Mount.Device,
+					Detail: fs.Mount.Format,
+				})
+			}
+			steps = append(steps, planSubvolumes(*fs.Mount)...)
+			continue
+		}
+		detail := fs.Mount.Format
+		if info.format != "" {
+			detail += fmt.Sprintf(", destroying the existing %s filesystem", info.format)
+		}
+		steps = append(steps, stages.Step{
+			Action: "format",
+			Target: fs.Mount.Device,
+			Detail: detail,
+		})
+		steps = append(steps, planSubvolumes(*fs.Mount)...)
+	}
+	return steps, nil
+}
+
+// planSubvolumes returns the subvolumes of fs which would be created, or
+// reused if they exist already.
+func planSubvolumes(fs types.Mount) []stages.Step {
+	var steps []stages.Step
+	for _, sv := range fs.Subvolumes {
+		details := []string{}
+		if sv.QgroupLimitMiB != nil {
+			details = append(details, fmt.Sprintf("limited to %d MiB", *sv.QgroupLimitMiB))
+		}
+		if sv.NoCopyOnWrite {
+			details = append(details, "no copy-on-write")
+		}
+		steps = append(steps, stages.Step{
+			Action: "create subvolume",
+			Target: fmt.Sprintf("%s:%s", fs.Device, sv.Path),
+			Detail: strings.Join(details, ", "),
+		})
+	}
+	return steps
+}
+
+// planLuks returns the step creating or reusing luks. Whether an existing
+// volume is reused is only known once earlier steps ran, so it is not
+// inspected.
+func planLuks(luks types.Luks) stages.Step {
+	detail := fmt.Sprintf("on %s, opened as %s", luks.Device, luksMappedDevice(luks))
+	if luks.WipeVolume {
+		detail += ", wiping the device"
+	}
+	return stages.Step{
+		Action: "create LUKS volume",
+		Target: luks.Name,
+		Detail: detail,
+	}
+}
+
+// planRaid returns whether the array md would be created or reused. Devices
+// which can't be examined, e.g. partitions which are yet to be created, are
+// assumed to have no superblock.
+func (s stage) planRaid(md types.Raid) (stages.Step, error) {
+	devs := []string{}
+	for _, dev := range md.Devices {
+		devs = append(devs, string(dev))
+	}
+	step := stages.Step{
+		Action: "create RAID array",
+		Target: md.Name,
+		Detail: fmt.Sprintf("%s on %s with %d spares", md.Level, strings.Join(devs, ", "), md.Spares),
+	}
+	if md.WipeArray {
+		step.Detail += ", replacing any existing array"
+		return step, nil
+	}
+
+	superblocks, err := s.examineRaidDevices(md, false)
+	if err != nil {
+		s.Logger.Warning("RAID array %q could not be inspected: %v", md.Name, err)
+		return step, nil
+	}
+	if len(superblocks) == 0 {
+		return step, nil
+	}
+	if err := raidMatches(md, superblocks); err != nil {
+		return stages.Step{}, fmt.Errorf("RAID array %q didn't match: %v", md.Name, err)
+	}
+	step.Action = "reuse RAID array"
+	return step, nil
+}
+
+// planVolumeGroup returns the volume group and logical volumes which would
+// be created. Existing ones are left out if lvm can report them, they are
+// only checked against the config by Run.
+func (s stage) planVolumeGroup(vg types.VolumeGroup) []stages.Step {
+	var steps []stages.Step
+	existing, err := s.getVolumeGroupPVs(vg.Name)
+	if err != nil {
+		s.Logger.Warning("volume group %q could not be inspected: %v", vg.Name, err)
+	}
+	lvs := map[string]logicalVolumeInfo{}
+	if existing == nil {
+		devs := []string{}
+		for _, dev := range vg.PhysicalVolumes {
+			devs = append(devs, string(dev))
+		}
+		steps = append(steps, stages.Step{
+			Action: "create volume group",
+			Target: vg.Name,
+			Detail: "on " + strings.Join(devs, ", "),
+		})
+	} else if lvs, err = s.getLogicalVolumes(vg.Name); err != nil {
+		s.Logger.Warning("logical volumes of %q could not be inspected: %v", vg.Name, err)
+	}
+
+	for _, lv := range sortLogicalVolumes(vg.LogicalVolumes) {
+		if _, ok := lvs[lv.Name]; ok {
+			continue
+		}
+		var detail string
+		switch {
+		case lv.Type == "thin":
+			detail = fmt.Sprintf("thin, %d MiB in pool %s", *lv.SizeMiB, *lv.Pool)
+		case lv.SizeMiB != nil:
+			detail = fmt.Sprintf("%d MiB", *lv.SizeMiB)
+		default:
+			detail = fmt.Sprintf("%d%% of the free space", *lv.PercentFree)
+		}
+		if lv.Type == "thin-pool" {
+			detail = "thin pool, " + detail
+		}
+		steps = append(steps, stages.Step{
+			Action: "create logical volume",
+			Target: vg.Name + "/" + lv.Name,
+			Detail: detail,
+		})
+	}
+	return steps
+}
@@ -15,20 +15,35 @@
 // The storage stage is responsible for partitioning disks, creating RAID
 // arrays, formatting partitions, writing files, writing systemd units, and
 // writing network units.
-// createRaids creates the raid arrays described in config.Storage.Raid.
 
 package disks
 
 import (
 	"fmt"
+	"os"
 	"os/exec"
+	"sort"
+	"strconv"
 	"strings"
+	"syscall"
 
 	"github.com/flatcar/ignition/internal/config/types"
 	"github.com/flatcar/ignition/internal/distro"
 	"github.com/flatcar/ignition/internal/exec/util"
+	"github.com/flatcar/ignition/internal/log"
 )
 
+// raidSuperblock is the md superblock of a device as reported by
+// mdadm --examine.
+type raidSuperblock struct {
+	uuid    string
+	level   string
+	devices int
+}
+
+// createRaids creates the raid arrays described in config.Storage.Raid.
+// Existing arrays are reused if the superblocks of their devices match the
+// config, see raidMatches, unless wipeArray is set.
 func (s stage) createRaids(config types.Config) error {
 	if len(config.Storage.Raid) == 0 {
 		return nil
@@ -48,45 +63,238 @@ func (s stage) createRaids(config types.Config) error {
 	}
 
 	for _, md := range config.Storage.Raid {
-		args := []string{
-			"--create", md.Name,
-			"--force",
-			"--run",
-			"--homehost", "any",
-			"--level", md.Level,
-			"--raid-devices", fmt.Sprintf("%d", len(md.Devices)-md.Spares),
+		if err := s.createRaid(md); err != nil {
+			return err
 		}
-
-		if md.Spares > 0 {
-			args = append(args, "--spare-devices", fmt.Sprintf("%d", md.Spares))
+		if md.WaitForSync {
+			if err := s.waitForRaidSync(util.RaidDevice(md.Name)); err != nil {
+				return err
+			}
 		}
+	}
 
-		for _, o := range md.Options {
-			args = append(args, string(o))
-		}
+	return nil
+}
 
-		for _, dev := range md.Devices {
-			args = append(args, util.DeviceAlias(string(dev)))
-		}
+// createRaid creates or reuses the array md.
+func (s stage) createRaid(md types.Raid) error {
+	devName := util.RaidDevice(md.Name)
 
+	if !md.WipeArray {
+		superblocks, err := s.examineRaidDevices(md, true)
+		if err != nil {
+			return err
+		}
+		if len(superblocks) > 0 {
+			if err := raidMatches(md, superblocks); err != nil {
+				return fmt.Errorf("RAID array %q didn't match: %v", md.Name, err)
+			}
+			s.Logger.Info("RAID array %q found with correct devices", md.Name)
+			return s.assembleRaid(md)
+		}
+	} else if _, err := os.Stat(devName); err == nil {
+		// the devices of a running array can't be reused for a new one
 		if _, err := s.Logger.LogCmd(
-			exec.Command(distro.MdadmCmd(), args...),
-			"creating %q", md.Name,
+			exec.Command(distro.MdadmCmd(), "--stop", devName),
+			"stopping %q", md.Name,
 		); err != nil {
 			return fmt.Errorf("mdadm failed: %v", err)
 		}
+	}
+
+	args := []string{
+		"--create", md.Name,
+		"--force",
+		"--run",
+		"--homehost", "any",
+		"--level", md.Level,
+		"--raid-devices", fmt.Sprintf("%d", len(md.Devices)-md.Spares),
+	}
 
-		devName := md.Name
-		if !strings.HasPrefix(devName, "/dev") {
-			devName = "/dev/md/" + md.Name
+	if md.Spares > 0 {
+		args = append(args, "--spare-devices", fmt.Sprintf("%d", md.Spares))
+	}
+
+	if md.UUID != nil {
+		args = append(args, "--uuid", *md.UUID)
+	}
+
+	for _, o := range md.Options {
+		args = append(args, string(o))
+	}
+
+	for _, dev := range md.Devices {
+		args = append(args, util.DeviceAlias(string(dev)))
+	}
+
+	if _, err := s.Logger.LogCmd(
+		exec.Command(distro.MdadmCmd(), args...),
+		"creating %q", md.Name,
+	); err != nil {
+		return fmt.Errorf("mdadm failed: %v", err)
+	}
+
+	// Wait for the created device node to show up, no udev
+	// race prevention required because this node did not
+	// exist before.
+	return s.waitOnDevices([]string{devName}, "raids")
+}
+
+// assembleRaid starts the existing array md, unless udev already assembled
+// it.
+func (s stage) assembleRaid(md types.Raid) error {
+	devName := util.RaidDevice(md.Name)
+	if _, err := os.Stat(devName); err == nil {
+		return nil
+	}
+
+	args := []string{"--assemble", devName, "--run"}
+	for _, dev := range md.Devices {
+		args = append(args, util.DeviceAlias(string(dev)))
+	}
+	if _, err := s.Logger.LogCmd(
+		exec.Command(distro.MdadmCmd(), args...),
+		"assembling %q", md.Name,
+	); err != nil {
+		return fmt.Errorf("mdadm failed: %v", err)
+	}
+
+	return s.waitOnDevices([]string{devName}, "raids")
+}
+
+// waitForRaidSync waits for the initial resync of the array devName to
+// finish.
+func (s stage) waitForRaidSync(devName string) error {
+	return s.Logger.LogOp(func() error {
+		cmd := exec.Command(distro.MdadmCmd(), "--wait", devName)
+		s.Logger.Debug("executing: %s", log.QuotedCmd(cmd))
+		if out, err := cmd.CombinedOutput(); err != nil {
+			// mdadm exits with 1 if there was nothing to wait for
+			if exitErr, ok := err.(*exec.ExitError); ok && exitErr.Sys().(syscall.WaitStatus).ExitStatus() == 1 {
+				return nil
+			}
+			return fmt.Errorf("%v: %s", err, out)
 		}
-		// Wait for the created device node to show up, no udev
-		// race prevention required because this node did not
-		// exist before.
-		if err := s.waitOnDevices([]string{devName}, "raids"); err != nil {
-			return err
+		return nil
+	}, "waiting for %q to sync", devName)
+}
+
+// examineRaidDevices returns the md superblocks found on the devices of md,
+// indexed by device. Devices without a superblock are left out. If aliased
+// is set, the devices are read through their device aliases.
+func (s stage) examineRaidDevices(md types.Raid, aliased bool) (map[string]raidSuperblock, error) {
+	superblocks := map[string]raidSuperblock{}
+	for _, dev := range md.Devices {
+		path := string(dev)
+		if aliased {
+			path = util.DeviceAlias(path)
+		}
+		cmd := exec.Command(distro.MdadmCmd(), "--examine", "--export", path)
+		s.Logger.Debug("executing: %s", log.QuotedCmd(cmd))
+		out, err := cmd.Output()
+		if err != nil {
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				if strings.Contains(string(exitErr.Stderr), "No md superblock") {
+					continue
+				}
+				return nil, fmt.Errorf("failed to examine %q: %v: %s", dev, err, exitErr.Stderr)
+			}
+			return nil, fmt.Errorf("failed to examine %q: %v", dev, err)
+		}
+		sb, err := parseRaidSuperblock(string(out))
+		if err != nil {
+			return nil, fmt.Errorf("failed to examine %q: %v", dev, err)
+		}
+		superblocks[string(dev)] = sb
+	}
+	return superblocks, nil
+}
+
+// parseRaidSuperblock parses the output of mdadm --examine --export.
+func parseRaidSuperblock(out string) (raidSuperblock, error) {
+	vars := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(parts) == 2 {
+			vars[parts[0]] = parts[1]
+		}
+	}
+	sb := raidSuperblock{
+		uuid:  vars["MD_UUID"],
+		level: vars["MD_LEVEL"],
+	}
+	if sb.uuid == "" || sb.level == "" {
+		return raidSuperblock{}, fmt.Errorf("unexpected mdadm output %q", out)
+	}
+	if devices, ok := vars["MD_DEVICES"]; ok {
+		n, err := strconv.Atoi(devices)
+		if err != nil {
+			return raidSuperblock{}, fmt.Errorf("failed to parse number of devices: %v", err)
 		}
+		sb.devices = n
 	}
+	return sb, nil
+}
 
+// raidMatches determines if the superblocks found on the devices of md make
+// up the array described by md: every device is a member of the same array,
+// which has the level, the number of active devices and, if specified, the
+// UUID of md.
+func raidMatches(md types.Raid, superblocks map[string]raidSuperblock) error {
+	missing := []string{}
+	var uuid string
+	for _, dev := range md.Devices {
+		sb, ok := superblocks[string(dev)]
+		if !ok {
+			missing = append(missing, string(dev))
+			continue
+		}
+		if uuid == "" {
+			uuid = sb.uuid
+		} else if normalizeRaidUUID(sb.uuid) != normalizeRaidUUID(uuid) {
+			return fmt.Errorf("%q is a member of array %s, not %s", dev, sb.uuid, uuid)
+		}
+		if raidLevel(sb.level) != raidLevel(md.Level) {
+			return fmt.Errorf("level %q of %q doesn't match %q", sb.level, dev, md.Level)
+		}
+		if want := len(md.Devices) - md.Spares; sb.devices != want {
+			return fmt.Errorf("%d active devices of %q don't match %d", sb.devices, dev, want)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("%s have no RAID superblock", strings.Join(missing, ", "))
+	}
+	if md.UUID != nil && normalizeRaidUUID(*md.UUID) != normalizeRaidUUID(uuid) {
+		return fmt.Errorf("UUID %s doesn't match %s", uuid, *md.UUID)
+	}
 	return nil
 }
+
+// normalizeRaidUUID returns the hex digits of uuid in lower case, since
+// mdadm prints UUIDs as four colon-separated words.
+func normalizeRaidUUID(uuid string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= '0' && r <= '9', r >= 'a' && r <= 'f':
+			return r
+		case r >= 'A' && r <= 'F':
+			return r - 'A' + 'a'
+		}
+		return -1
+	}, uuid)
+}
+
+// raidLevel returns the name mdadm reports for level, which may be any of
+// the aliases accepted in the config.
+func raidLevel(level string) string {
+	switch level {
+	case "0", "stripe":
+		return "raid0"
+	case "1", "mirror":
+		return "raid1"
+	case "4", "5", "6", "10":
+		return "raid" + level
+	}
+	return level
+}
@@ -0,0 +1,134 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flatcar/ignition/internal/config/types"
+)
+
+func TestParseRaidSuperblock(t *testing.T) {
+	type in struct {
+		out string
+	}
+	type out struct {
+		sb  raidSuperblock
+		err bool
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{"MD_LEVEL=raid1\nMD_DEVICES=2\nMD_NAME=any:data\nMD_ARRAY_SIZE=1022.00MiB\nMD_UUID=b8c4e0a2:57d34a41:9e5c0d6f:1f3a7b21\nMD_DEV_UUID=0e1d1a0c:64d8e1b5:5a5e1c34:4c0e8b65\n"},
+			out: out{sb: raidSuperblock{uuid: "b8c4e0a2:57d34a41:9e5c0d6f:1f3a7b21", level: "raid1", devices: 2}},
+		},
+		{
+			in:  in{"MD_LEVEL=raid1\n"},
+			out: out{err: true},
+		},
+		{
+			in:  in{"MD_LEVEL=raid1\nMD_DEVICES=two\nMD_UUID=b8c4e0a2:57d34a41:9e5c0d6f:1f3a7b21\n"},
+			out: out{err: true},
+		},
+	}
+
+	for i, test := range tests {
+		sb, err := parseRaidSuperblock(test.in.out)
+		if test.out.err != (err != nil) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+		if !reflect.DeepEqual(test.out.sb, sb) {
+			t.Errorf("#%d: bad superblock: want %+v, got %+v", i, test.out.sb, sb)
+		}
+	}
+}
+
+func TestRaidMatches(t *testing.T) {
+	type in struct {
+		md          types.Raid
+		superblocks map[string]raidSuperblock
+	}
+	type out struct {
+		match bool
+	}
+
+	strToPtr := func(s string) *string { return &s }
+	mirror := types.Raid{Name: "data", Level: "mirror", Devices: []types.Device{"/dev/sdb", "/dev/sdc", "/dev/sdd"}, Spares: 1}
+	member := raidSuperblock{uuid: "b8c4e0a2:57d34a41:9e5c0d6f:1f3a7b21", level: "raid1", devices: 2}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{mirror, map[string]raidSuperblock{"/dev/sdb": member, "/dev/sdc": member, "/dev/sdd": member}},
+			out: out{true},
+		},
+		{
+			in: in{
+				types.Raid{Name: "data", Level: "raid1", Devices: []types.Device{"/dev/sdb", "/dev/sdc"}, UUID: strToPtr("B8C4E0A2-57D3-4A41-9E5C-0D6F1F3A7B21")},
+				map[string]raidSuperblock{"/dev/sdb": member, "/dev/sdc": member},
+			},
+			out: out{true},
+		},
+		{
+			in: in{
+				types.Raid{Name: "data", Level: "raid1", Devices: []types.Device{"/dev/sdb", "/dev/sdc"}, UUID: strToPtr("0e1d1a0c-64d8-e1b5-5a5e-1c344c0e8b65")},
+				map[string]raidSuperblock{"/dev/sdb": member, "/dev/sdc": member},
+			},
+			out: out{false},
+		},
+		// a device was replaced
+		{
+			in:  in{mirror, map[string]raidSuperblock{"/dev/sdb": member, "/dev/sdc": member}},
+			out: out{false},
+		},
+		{
+			in: in{mirror, map[string]raidSuperblock{
+				"/dev/sdb": member,
+				"/dev/sdc": member,
+				"/dev/sdd": {uuid: "0e1d1a0c:64d8e1b5:5a5e1c34:4c0e8b65", level: "raid1", devices: 2},
+			}},
+			out: out{false},
+		},
+		{
+			in: in{
+				types.Raid{Name: "data", Level: "raid5", Devices: []types.Device{"/dev/sdb", "/dev/sdc"}},
+				map[string]raidSuperblock{"/dev/sdb": member, "/dev/sdc": member},
+			},
+			out: out{false},
+		},
+		// the spare became an active device
+		{
+			in: in{mirror, map[string]raidSuperblock{
+				"/dev/sdb": {uuid: member.uuid, level: "raid1", devices: 3},
+				"/dev/sdc": {uuid: member.uuid, level: "raid1", devices: 3},
+				"/dev/sdd": {uuid: member.uuid, level: "raid1", devices: 3},
+			}},
+			out: out{false},
+		},
+	}
+
+	for i, test := range tests {
+		err := raidMatches(test.in.md, test.in.superblocks)
+		if test.out.match != (err == nil) {
+			t.Errorf("#%d: bad match: want %v, got %v", i, test.out.match, err)
+		}
+	}
+}
@@ -0,0 +1,354 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/flatcar/ignition/internal/config/types"
+	"github.com/flatcar/ignition/internal/distro"
+	"github.com/flatcar/ignition/internal/log"
+)
+
+const (
+	sysfsBlockDir = "/sys/block"
+)
+
+// blockDevice describes a whole disk as found in sysfs.
+type blockDevice struct {
+	name       string
+	sizeMiB    int
+	rotational bool
+	transport  string
+	model      string
+	serial     string
+	wwn        string
+	// unused is set if the disk has neither partitions nor holders.
+	unused bool
+}
+
+func (d blockDevice) String() string {
+	kind := "non-rotational"
+	if d.rotational {
+		kind = "rotational"
+	}
+	return fmt.Sprintf("%s (%d MiB, %s, transport %q, model %q, serial %q, wwn %q)",
+		d.name, d.sizeMiB, kind, d.transport, d.model, d.serial, d.wwn)
+}
+
+// resolveDiskSelectors returns config with the device of every disk which
+// uses a selector set to the disk the selector matched. Disks which are
+// selected or referenced by device path are not considered for the
+// remaining selectors.
+func (s stage) resolveDiskSelectors(config types.Config) (types.Config, error) {
+	hasSelector := false
+	for _, disk := range config.Storage.Disks {
+		if disk.Selector != nil {
+			hasSelector = true
+		}
+	}
+	if !hasSelector {
+		return config, nil
+	}
+
+	// Disks are only enumerated once, so wait for the initial udev
+	// events of all disks to be processed.
+	if _, err := s.Logger.LogCmd(
+		exec.Command(distro.UdevadmCmd(), "settle"),
+		"waiting for disks to settle",
+	); err != nil {
+		return config, fmt.Errorf("udevadm settle failed: %v", err)
+	}
+
+	devs, err := listBlockDevices(sysfsBlockDir, s.udevSerial)
+	if err != nil {
+		return config, err
+	}
+
+	taken := map[string]bool{}
+	for _, disk := range config.Storage.Disks {
+		if disk.Selector != nil {
+			continue
+		}
+		// The device may only appear later, it can't be selected then.
+		if path, err := filepath.EvalSymlinks(disk.Device); err == nil {
+			taken[filepath.Base(path)] = true
+		}
+	}
+
+	disks := make([]types.Disk, len(config.Storage.Disks))
+	copy(disks, config.Storage.Disks)
+	for i, disk := range disks {
+		if disk.Selector == nil {
+			continue
+		}
+		candidates := []blockDevice{}
+		for _, dev := range devs {
+			if !taken[dev.name] {
+				candidates = append(candidates, dev)
+			}
+		}
+		dev, reason, err := selectDisk(*disk.Selector, candidates)
+		if err != nil {
+			return config, fmt.Errorf("disk %d: %v", i, err)
+		}
+		s.Logger.Info("disk %d: selected %s, %s", i, dev, reason)
+		taken[dev.name] = true
+		disks[i].Device = filepath.Join("/dev", dev.name)
+	}
+	config.Storage.Disks = disks
+	return config, nil
+}
+
+// selectDisk returns the only disk in devs that matches sel and a
+// description of how it was chosen.
+func selectDisk(sel types.DiskSelector, devs []blockDevice) (blockDevice, string, error) {
+	matches := []blockDevice{}
+	for _, dev := range devs {
+		ok, err := selectorMatches(sel, dev)
+		if err != nil {
+			return blockDevice{}, "", err
+		}
+		if ok {
+			matches = append(matches, dev)
+		}
+	}
+	reason := fmt.Sprintf("the only one of %d disks matching the selector", len(devs))
+
+	if sel.LargestUnused && len(matches) > 0 {
+		unused := []blockDevice{}
+		for _, dev := range matches {
+			if dev.unused {
+				unused = append(unused, dev)
+			}
+		}
+		sort.SliceStable(unused, func(i, j int) bool {
+			return unused[i].sizeMiB > unused[j].sizeMiB
+		})
+		switch {
+		case len(unused) == 0:
+			return blockDevice{}, "", fmt.Errorf("none of the disks matching the selector is unused: %s", describeDevices(matches))
+		case len(unused) > 1 && unused[0].sizeMiB == unused[1].sizeMiB:
+			return blockDevice{}, "", fmt.Errorf("several unused disks of the largest size match the selector: %s", describeDevices(unused))
+		}
+		matches = unused[:1]
+		reason = fmt.Sprintf("the largest of %d unused disks matching the selector", len(unused))
+	}
+
+	switch len(matches) {
+	case 0:
+		return blockDevice{}, "", fmt.Errorf("no disk matches the selector, found: %s", describeDevices(devs))
+	case 1:
+		return matches[0], reason, nil
+	default:
+		return blockDevice{}, "", fmt.Errorf("several disks match the selector: %s", describeDevices(matches))
+	}
+}
+
+// selectorMatches returns whether dev has all attributes specified by sel.
+func selectorMatches(sel types.DiskSelector, dev blockDevice) (bool, error) {
+	if sel.MinSizeMiB != nil && dev.sizeMiB < *sel.MinSizeMiB {
+		return false, nil
+	}
+	if sel.MaxSizeMiB != nil && dev.sizeMiB > *sel.MaxSizeMiB {
+		return false, nil
+	}
+	if sel.Rotational != nil && dev.rotational != *sel.Rotational {
+		return false, nil
+	}
+	if sel.Transport != nil && dev.transport != *sel.Transport {
+		return false, nil
+	}
+	for _, attr := range []struct {
+		expr  *string
+		value string
+	}{
+		{sel.Model, dev.model},
+		{sel.Serial, dev.serial},
+		{sel.WWN, dev.wwn},
+	} {
+		if attr.expr == nil {
+			continue
+		}
+		ok, err := regexp.MatchString(*attr.expr, attr.value)
+		if err != nil {
+			return false, fmt.Errorf("invalid regular expression %q: %v", *attr.expr, err)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func describeDevices(devs []blockDevice) string {
+	if len(devs) == 0 {
+		return "none"
+	}
+	descs := []string{}
+	for _, dev := range devs {
+		descs = append(descs, dev.String())
+	}
+	return strings.Join(descs, ", ")
+}
+
+// listBlockDevices returns the disks found in the sysfs block directory dir.
+// Virtual block devices like loop devices, device mapper and md devices have
+// no backing device and are skipped, as are empty drives. udevSerial returns
+// the serial number udev knows for a disk, or "" if it has none.
+func listBlockDevices(dir string, udevSerial func(name string) string) ([]blockDevice, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list block devices: %v", err)
+	}
+	devs := []blockDevice{}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if _, err := os.Stat(filepath.Join(path, "device")); os.IsNotExist(err) {
+			continue
+		}
+		dev, err := readBlockDevice(path, udevSerial)
+		if err != nil {
+			return nil, err
+		}
+		if dev.sizeMiB > 0 {
+			devs = append(devs, dev)
+		}
+	}
+	return devs, nil
+}
+
+// readBlockDevice reads the attributes of the disk at the sysfs path.
+func readBlockDevice(path string, udevSerial func(name string) string) (blockDevice, error) {
+	dev := blockDevice{name: filepath.Base(path)}
+
+	sectors, err := strconv.Atoi(readSysfsAttr(path, "size"))
+	if err != nil {
+		return dev, fmt.Errorf("failed to read size of %q: %v", dev.name, err)
+	}
+	// sysfs always counts 512 byte sectors
+	dev.sizeMiB = sectors / 2048
+	dev.rotational = readSysfsAttr(path, "queue/rotational") == "1"
+	dev.model = readSysfsAttr(path, "device/model")
+	dev.serial = udevSerial(dev.name)
+	if dev.serial == "" {
+		dev.serial = readSysfsSerial(path)
+	}
+	dev.wwn = readSysfsAttr(path, "wwid")
+	if dev.wwn == "" {
+		dev.wwn = readSysfsAttr(path, "device/wwid")
+	}
+
+	// The resolved path describes how the disk is attached, e.g.
+	// /sys/devices/pci0000:00/0000:00:1f.2/ata1/host0/... for SATA.
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return dev, fmt.Errorf("failed to resolve %q: %v", path, err)
+	}
+	dev.transport = blockDeviceTransport(dev.name, realPath)
+
+	holders, err := ioutil.ReadDir(filepath.Join(path, "holders"))
+	if err != nil && !os.IsNotExist(err) {
+		return dev, fmt.Errorf("failed to read holders of %q: %v", dev.name, err)
+	}
+	dev.unused = len(holders) == 0
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return dev, fmt.Errorf("failed to read %q: %v", path, err)
+	}
+	for _, entry := range entries {
+		if _, err := os.Stat(filepath.Join(path, entry.Name(), "partition")); err == nil {
+			dev.unused = false
+		}
+	}
+	return dev, nil
+}
+
+// readSysfsSerial returns the serial number of the disk at the sysfs path.
+// NVMe and MMC devices have a serial attribute, SCSI and SATA disks report
+// it in VPD page 0x80 and virtio disks have it on the block device itself.
+func readSysfsSerial(path string) string {
+	if serial := readSysfsAttr(path, "device/serial"); serial != "" {
+		return serial
+	}
+	// the page starts with a four byte header, the serial is padded
+	// with spaces
+	if page, err := ioutil.ReadFile(filepath.Join(path, "device/vpd_pg80")); err == nil && len(page) > 4 {
+		if serial := strings.TrimSpace(string(page[4:])); serial != "" {
+			return serial
+		}
+	}
+	return readSysfsAttr(path, "serial")
+}
+
+// udevSerial returns the ID_SERIAL_SHORT property udev assigned to the disk
+// name, or "" if it has none. udev reads the serial numbers of ATA disks,
+// which are missing from sysfs, directly from the drive.
+func (s stage) udevSerial(name string) string {
+	cmd := exec.Command(distro.UdevadmCmd(), "info", "--query=property", "--name="+name)
+	s.Logger.Debug("executing: %s", log.QuotedCmd(cmd))
+	out, err := cmd.Output()
+	if err != nil {
+		s.Logger.Debug("could not read udev properties of %q: %v", name, err)
+		return ""
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.HasPrefix(line, "ID_SERIAL_SHORT=") {
+			return strings.TrimPrefix(line, "ID_SERIAL_SHORT=")
+		}
+	}
+	return ""
+}
+
+// blockDeviceTransport returns the bus a disk is attached with, based on its
+// name and resolved sysfs path.
+func blockDeviceTransport(name, realPath string) string {
+	switch {
+	case strings.HasPrefix(name, "nvme"):
+		return "nvme"
+	case strings.HasPrefix(name, "mmcblk"):
+		return "mmc"
+	case strings.HasPrefix(name, "vd"):
+		return "virtio"
+	case strings.Contains(realPath, "/usb"):
+		return "usb"
+	case strings.Contains(realPath, "/ata"):
+		return "sata"
+	case strings.Contains(realPath, "/end_device-"):
+		return "sas"
+	case strings.Contains(realPath, "/host"):
+		return "scsi"
+	default:
+		return ""
+	}
+}
+
+// readSysfsAttr returns the trimmed contents of the attribute file at
+// path/attr, or an empty string if it can't be read.
+func readSysfsAttr(path, attr string) string {
+	b, err := ioutil.ReadFile(filepath.Join(path, attr))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
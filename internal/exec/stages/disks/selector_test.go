@@ -0,0 +1,299 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/flatcar/ignition/internal/config/types"
+)
+
+// fakeDisk describes a disk to be created in a fake sysfs tree.
+type fakeDisk struct {
+	name       string
+	devicePath string
+	attrs      map[string]string
+	partitions []string
+	holders    []string
+}
+
+// createFakeSysfs creates a sysfs tree with a block directory linking to the
+// devices of disks, and returns the path of the block directory.
+func createFakeSysfs(t *testing.T, root string, disks []fakeDisk) string {
+	blockDir := filepath.Join(root, "block")
+	if err := os.MkdirAll(blockDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, disk := range disks {
+		path := filepath.Join(root, "devices", disk.devicePath, "block", disk.name)
+		if disk.devicePath == "" {
+			path = filepath.Join(root, "devices", "virtual", "block", disk.name)
+		}
+		for attr, value := range disk.attrs {
+			if err := os.MkdirAll(filepath.Dir(filepath.Join(path, attr)), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(filepath.Join(path, attr), []byte(value+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, part := range disk.partitions {
+			if err := os.MkdirAll(filepath.Join(path, part), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(filepath.Join(path, part, "partition"), []byte("1\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, holder := range disk.holders {
+			if err := os.MkdirAll(filepath.Join(path, "holders", holder), 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.Symlink(path, filepath.Join(blockDir, disk.name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return blockDir
+}
+
+func TestListBlockDevices(t *testing.T) {
+	root, err := ioutil.TempDir("", "ignition-sysfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	blockDir := createFakeSysfs(t, root, []fakeDisk{
+		{
+			name:       "sda",
+			devicePath: "pci0000:00/0000:00:1f.2/ata1/host0/target0:0:0/0:0:0:0",
+			attrs: map[string]string{
+				"size":             "3907029168",
+				"queue/rotational": "1",
+				"device/model":     "ST2000DM008-2FR1",
+				"device/wwid":      "naa.5000c500c3a1b2c3",
+				"device/vendor":    "ATA",
+			},
+			partitions: []string{"sda1", "sda2"},
+		},
+		{
+			name:       "sdb",
+			devicePath: "pci0000:00/0000:00:14.0/usb2/2-1/2-1:1.0/host6/target6:0:0/6:0:0:0",
+			attrs: map[string]string{
+				"size":             "62521344",
+				"queue/rotational": "0",
+				"device/model":     "Ultra Fit",
+			},
+			holders: []string{"dm-0"},
+		},
+		{
+			name:       "nvme0n1",
+			devicePath: "pci0000:00/0000:00:1d.0/0000:3d:00.0/nvme/nvme0",
+			attrs: map[string]string{
+				"size":             "1000215216",
+				"queue/rotational": "0",
+				"wwid":             "eui.002538b581b3c4d5",
+				"device/model":     "Samsung SSD 970 EVO Plus 500GB",
+				"device/serial":    "S4EVNF0M123456A",
+			},
+		},
+		{
+			name:       "sdd",
+			devicePath: "pci0000:00/0000:00:1f.2/ata2/host1/target1:0:0/1:0:0:0",
+			attrs: map[string]string{
+				"size":             "1953525168",
+				"queue/rotational": "1",
+				"device/model":     "WDC WD10EZEX-08W",
+				"device/vpd_pg80":  "\x00\x80\x00\x14     WD-WCC6Y1234567",
+			},
+		},
+		{
+			name:       "vda",
+			devicePath: "pci0000:00/0000:00:04.0/virtio1",
+			attrs: map[string]string{
+				"size":             "41943040",
+				"queue/rotational": "1",
+				"serial":           "ignition-data",
+				"device/vendor":    "0x1af4",
+			},
+		},
+		{
+			// no backing device
+			name: "loop0",
+			attrs: map[string]string{
+				"size":             "2097152",
+				"queue/rotational": "0",
+			},
+		},
+		{
+			// empty card reader
+			name:       "sdc",
+			devicePath: "pci0000:00/0000:00:14.0/usb2/2-2/2-2:1.0/host7/target7:0:0/7:0:0:0",
+			attrs: map[string]string{
+				"size":             "0",
+				"queue/rotational": "0",
+				"device/model":     "SD/MMC Reader",
+			},
+		},
+	})
+	// udev reads the serial of USB disks from their descriptors
+	udevSerial := func(name string) string {
+		if name == "sdb" {
+			return "4C530001230405117450"
+		}
+		return ""
+	}
+	devs, err := listBlockDevices(blockDir, udevSerial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []blockDevice{
+		{
+			name:      "nvme0n1",
+			sizeMiB:   488386,
+			transport: "nvme",
+			model:     "Samsung SSD 970 EVO Plus 500GB",
+			serial:    "S4EVNF0M123456A",
+			wwn:       "eui.002538b581b3c4d5",
+			unused:    true,
+		},
+		{
+			name:       "sda",
+			sizeMiB:    1907729,
+			rotational: true,
+			transport:  "sata",
+			model:      "ST2000DM008-2FR1",
+			wwn:        "naa.5000c500c3a1b2c3",
+		},
+		{
+			name:      "sdb",
+			sizeMiB:   30528,
+			transport: "usb",
+			model:     "Ultra Fit",
+			serial:    "4C530001230405117450",
+		},
+		{
+			name:       "sdd",
+			sizeMiB:    953869,
+			rotational: true,
+			transport:  "sata",
+			model:      "WDC WD10EZEX-08W",
+			serial:     "WD-WCC6Y1234567",
+			unused:     true,
+		},
+		{
+			name:       "vda",
+			sizeMiB:    20480,
+			rotational: true,
+			transport:  "virtio",
+			serial:     "ignition-data",
+			unused:     true,
+		},
+	}
+	if !reflect.DeepEqual(expected, devs) {
+		t.Errorf("expected %v, got %v", expected, devs)
+	}
+}
+
+func TestSelectDisk(t *testing.T) {
+	type in struct {
+		selector types.DiskSelector
+	}
+	type out struct {
+		name string
+		err  bool
+	}
+
+	intToPtr := func(i int) *int { return &i }
+	strToPtr := func(s string) *string { return &s }
+	boolToPtr := func(b bool) *bool { return &b }
+	devs := []blockDevice{
+		{name: "sda", sizeMiB: 1907729, rotational: true, transport: "sata", model: "ST2000DM008-2FR1", wwn: "naa.5000c500c3a1b2c3", unused: true},
+		{name: "sdb", sizeMiB: 1907729, rotational: true, transport: "sata", model: "ST2000DM008-2FR1", wwn: "naa.5000c500c3a1d4e5", unused: true},
+		{name: "sdc", sizeMiB: 3815447, rotational: true, transport: "sas", model: "ST4000NM0023", unused: false},
+		{name: "nvme0n1", sizeMiB: 488386, transport: "nvme", model: "Samsung SSD 970 EVO Plus 500GB", serial: "S4EVNF0M123456A", unused: true},
+		{name: "nvme1n1", sizeMiB: 953869, transport: "nvme", model: "Samsung SSD 970 EVO Plus 1TB", serial: "S4EWNX0N654321B", unused: true},
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{types.DiskSelector{Rotational: boolToPtr(false), MaxSizeMiB: intToPtr(600000)}},
+			out: out{name: "nvme0n1"},
+		},
+		{
+			in:  in{types.DiskSelector{Transport: strToPtr("sas")}},
+			out: out{name: "sdc"},
+		},
+		{
+			in:  in{types.DiskSelector{Model: strToPtr("^Samsung .* 1TB$")}},
+			out: out{name: "nvme1n1"},
+		},
+		{
+			in:  in{types.DiskSelector{Serial: strToPtr("^S4EV")}},
+			out: out{name: "nvme0n1"},
+		},
+		{
+			in:  in{types.DiskSelector{WWN: strToPtr("d4e5$")}},
+			out: out{name: "sdb"},
+		},
+		{
+			// several disks match
+			in:  in{types.DiskSelector{Transport: strToPtr("nvme")}},
+			out: out{err: true},
+		},
+		{
+			in:  in{types.DiskSelector{Transport: strToPtr("nvme"), LargestUnused: true}},
+			out: out{name: "nvme1n1"},
+		},
+		{
+			// the largest disk is in use
+			in:  in{types.DiskSelector{Rotational: boolToPtr(true), LargestUnused: true, MinSizeMiB: intToPtr(2000000)}},
+			out: out{err: true},
+		},
+		{
+			// the largest unused disks are of the same size
+			in:  in{types.DiskSelector{Rotational: boolToPtr(true), LargestUnused: true}},
+			out: out{err: true},
+		},
+		{
+			// no disk matches
+			in:  in{types.DiskSelector{Transport: strToPtr("usb")}},
+			out: out{err: true},
+		},
+		{
+			in:  in{types.DiskSelector{Model: strToPtr("(")}},
+			out: out{err: true},
+		},
+	}
+
+	for i, test := range tests {
+		dev, _, err := selectDisk(test.in.selector, devs)
+		if (err != nil) != test.out.err {
+			t.Errorf("#%d: expected error %t, got %v", i, test.out.err, err)
+			continue
+		}
+		if dev.name != test.out.name {
+			t.Errorf("#%d: expected %q, got %q", i, test.out.name, dev.name)
+		}
+	}
+}
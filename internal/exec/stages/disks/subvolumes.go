@@ -0,0 +1,122 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"github.com/flatcar/ignition/internal/config/types"
+	"github.com/flatcar/ignition/internal/distro"
+)
+
+const (
+	// btrfsSubvolumeInode is the inode number of the root directory of
+	// every btrfs subvolume.
+	btrfsSubvolumeInode = 256
+)
+
+// createSubvolumes creates the subvolumes of the btrfs filesystem fs which
+// don't exist yet, and applies the copy-on-write setting and qgroup limit of
+// every subvolume.
+func (s stage) createSubvolumes(fs types.Mount) error {
+	if len(fs.Subvolumes) == 0 {
+		return nil
+	}
+
+	return s.withMountedFilesystem(fs, func(mnt string) error {
+		for _, sv := range fs.Subvolumes {
+			if sv.QgroupLimitMiB != nil {
+				if _, err := s.Logger.LogCmd(
+					exec.Command(distro.BtrfsCmd(), "quota", "enable", mnt),
+					"enabling quotas on %q", fs.Device,
+				); err != nil {
+					return fmt.Errorf("enabling quotas failed: %v", err)
+				}
+				break
+			}
+		}
+
+		for _, sv := range fs.Subvolumes {
+			if err := s.createSubvolume(mnt, sv); err != nil {
+				return fmt.Errorf("subvolume %q: %v", sv.Path, err)
+			}
+		}
+		return nil
+	})
+}
+
+// createSubvolume creates sv on the btrfs filesystem mounted at mnt, unless
+// it already exists, and applies its settings.
+func (s stage) createSubvolume(mnt string, sv types.Subvolume) error {
+	path := filepath.Join(mnt, sv.Path)
+	exists, err := isSubvolume(path)
+	if err != nil {
+		return err
+	}
+	if exists {
+		s.Logger.Info("subvolume %q already exists, reusing it", sv.Path)
+	} else {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to create parent directory: %v", err)
+		}
+		if _, err := s.Logger.LogCmd(
+			exec.Command(distro.BtrfsCmd(), "subvolume", "create", path),
+			"creating subvolume %q", sv.Path,
+		); err != nil {
+			return fmt.Errorf("creating subvolume failed: %v", err)
+		}
+	}
+
+	// The attribute is inherited by files created in the subvolume
+	// afterwards, existing files keep copy-on-write.
+	if sv.NoCopyOnWrite {
+		if _, err := s.Logger.LogCmd(
+			exec.Command(distro.ChattrCmd(), "+C", path),
+			"disabling copy-on-write for subvolume %q", sv.Path,
+		); err != nil {
+			return fmt.Errorf("disabling copy-on-write failed: %v", err)
+		}
+	}
+
+	if sv.QgroupLimitMiB != nil {
+		if _, err := s.Logger.LogCmd(
+			exec.Command(distro.BtrfsCmd(), "qgroup", "limit", strconv.Itoa(*sv.QgroupLimitMiB)+"M", path),
+			"limiting subvolume %q to %d MiB", sv.Path, *sv.QgroupLimitMiB,
+		); err != nil {
+			return fmt.Errorf("setting qgroup limit failed: %v", err)
+		}
+	}
+	return nil
+}
+
+// isSubvolume returns whether path is the root of a btrfs subvolume. It
+// returns an error if path exists but is something else.
+func isSubvolume(path string) (bool, error) {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && info.IsDir() && stat.Ino == btrfsSubvolumeInode {
+		return true, nil
+	}
+	return false, fmt.Errorf("%q exists but is not a subvolume", path)
+}
@@ -17,6 +17,7 @@ package files
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"strings"
 
@@ -60,6 +61,14 @@ func (creator) Name() string {
 type stage struct {
 	util.Util
 	toRelabel []string
+	contexts  []pathContext
+}
+
+// pathContext is a path with an explicit SELinux context, which has to be
+// set again after relabeling.
+type pathContext struct {
+	path    string
+	context string
 }
 
 func (stage) Name() string {
@@ -79,6 +88,14 @@ func (s stage) Run(config types.Config) error {
 		return fmt.Errorf("failed to create files: %v", err)
 	}
 
+	if err := s.createCrypttab(config); err != nil {
+		return fmt.Errorf("failed to create crypttab: %v", err)
+	}
+
+	if err := s.createMdadmConf(config); err != nil {
+		return fmt.Errorf("failed to create mdadm.conf: %v", err)
+	}
+
 	if err := s.createUnits(config); err != nil {
 		return fmt.Errorf("failed to create units: %v", err)
 	}
@@ -125,6 +142,14 @@ func (s *stage) relabel(paths ...string) {
 	}
 }
 
+// keepContext records that path has an explicit SELinux context, so it isn't
+// lost when the relabel unit runs restorecon.
+func (s *stage) keepContext(path, context string) {
+	if s.toRelabel != nil {
+		s.contexts = append(s.contexts, pathContext{path: path, context: context})
+	}
+}
+
 // addRelabelUnit creates and enables a runtime systemd unit to run restorecon
 // if there are files that need to be relabeled.
 func (s *stage) addRelabelUnit(config types.Config) error {
@@ -147,7 +172,8 @@ OnFailureJobMode=replace-irreversibly
 
 [Service]
 Type=oneshot
-ExecStart=` + distro.RestoreconCmd() + ` -0vRif /etc/selinux/ignition.relabel
+ExecStart=` + distro.RestoreconCmd() + ` -0vRif /etc/selinux/ignition.relabel` +
+			s.chconCommands() + `
 ExecStart=/usr/bin/rm /etc/selinux/ignition.relabel
 RemainAfterExit=yes`,
 	}
@@ -175,3 +201,57 @@ RemainAfterExit=yes`,
 	_, err = f.WriteString(strings.Join(s.toRelabel, "\000") + "\000")
 	return err
 }
+
+// chconCommands returns the ExecStart lines which set the explicit SELinux
+// contexts again after restorecon relabeled them.
+func (s *stage) chconCommands() string {
+	var cmds string
+	for _, c := range s.contexts {
+		cmds += "\nExecStart=" + distro.ChconCmd() + " -h " + quoteExecArg(c.context) + " " + quoteExecArg(c.path)
+	}
+	return cmds
+}
+
+var execArgReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "%", "%%", "$", "$$")
+
+// quoteExecArg quotes s as a single argument of a unit's Exec line, so systemd
+// doesn't split it or expand specifiers and environment variables in it.
+func quoteExecArg(s string) string {
+	return `"` + execArgReplacer.Replace(s) + `"`
+}
+
+// appendMissingLines appends the lines which aren't in the file at path in
+// the root yet to it, creating it with mode if needed.
+func (s *stage) appendMissingLines(path string, mode os.FileMode, lines []string) error {
+	path, err := s.JoinPath(path)
+	if err != nil {
+		return err
+	}
+	existing, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	present := map[string]bool{}
+	for _, line := range strings.Split(string(existing), "\n") {
+		present[strings.TrimSpace(line)] = true
+	}
+
+	if err := util.MkdirForFile(path); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, mode)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	for _, line := range lines {
+		if present[line] {
+			continue
+		}
+		if _, err := fmt.Fprintln(f, line); err != nil {
+			return err
+		}
+		present[line] = true
+	}
+	return nil
+}
@@ -15,6 +15,9 @@
 package files
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"testing"
@@ -35,6 +38,14 @@ func TestMapEntriesToFilesystems(t *testing.T) {
 
 	fs1 := "/fs1"
 	fs2 := "/fs2"
+	btrfs := &types.Mount{
+		Device: "/dev/sdb",
+		Format: "btrfs",
+		Subvolumes: []types.Subvolume{
+			{Name: "var", Path: "/@var"},
+			{Path: "/@home"},
+		},
+	}
 
 	tests := []struct {
 		in  in
@@ -89,6 +100,35 @@ func TestMapEntriesToFilesystems(t *testing.T) {
 				},
 			}},
 		},
+		{
+			in: in{config: types.Config{Storage: types.Storage{
+				Filesystems: []types.Filesystem{{Name: "data", Mount: btrfs}},
+				Directories: []types.Directory{
+					{Node: types.Node{Filesystem: "var", Path: "/log"}},
+					{Node: types.Node{Filesystem: "data", Path: "/a/b/c"}},
+				},
+				Files: []types.File{
+					{Node: types.Node{Filesystem: "var", Path: "/foo"}},
+					{Node: types.Node{Filesystem: "data", Path: "/bar"}},
+				},
+				Archives: []types.Archive{
+					{Node: types.Node{Filesystem: "var", Path: "/lib/app"}},
+				},
+				Remove: []types.Removal{
+					{Filesystem: "var", Path: "/tmp"},
+				},
+			}}},
+			out: out{files: map[types.Filesystem][]filesystemEntry{
+				{Name: "data", Mount: btrfs}: {
+					dirEntry(types.Directory{Node: types.Node{Filesystem: "var", Path: "/@var/log"}}),
+					dirEntry(types.Directory{Node: types.Node{Filesystem: "data", Path: "/a/b/c"}}),
+					removeEntry{Removal: types.Removal{Filesystem: "var", Path: "/@var/tmp"}, root: "/@var"},
+					fileEntry(types.File{Node: types.Node{Filesystem: "var", Path: "/@var/foo"}}),
+					fileEntry(types.File{Node: types.Node{Filesystem: "data", Path: "/bar"}}),
+					&archiveEntry{Archive: types.Archive{Node: types.Node{Filesystem: "var", Path: "/@var/lib/app"}}},
+				},
+			}},
+		},
 	}
 
 	for i, test := range tests {
@@ -145,3 +185,121 @@ func TestDirectorySort(t *testing.T) {
 		}
 	}
 }
+
+func TestRemoveEntry(t *testing.T) {
+	type in struct {
+		removal types.Removal
+		// subvolume is the root of the removal, if it isn't the
+		// filesystem
+		subvolume string
+	}
+	type out struct {
+		removed []string
+		kept    []string
+		err     bool
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{removal: types.Removal{Path: "/etc/motd"}},
+			out: out{removed: []string{"/etc/motd"}, kept: []string{"/usr/share/motd"}},
+		},
+		{
+			in:  in{removal: types.Removal{Path: "/etc/missing"}},
+			out: out{kept: []string{"/etc/motd"}},
+		},
+		{
+			in:  in{removal: types.Removal{Path: "/etc/missing", MustExist: true}},
+			out: out{err: true},
+		},
+		{
+			in:  in{removal: types.Removal{Path: "/etc/ssh/sshd_config.d"}},
+			out: out{kept: []string{"/etc/ssh/sshd_config.d/50-defaults.conf"}, err: true},
+		},
+		{
+			in:  in{removal: types.Removal{Path: "/etc/ssh/sshd_config.d", Recursive: true}},
+			out: out{removed: []string{"/etc/ssh/sshd_config.d"}, kept: []string{"/etc/ssh"}},
+		},
+		{
+			in:  in{removal: types.Removal{Path: "/etc/link"}},
+			out: out{removed: []string{"/etc/link"}, kept: []string{"/usr/share/motd"}},
+		},
+		{
+			in:  in{removal: types.Removal{Path: "/etc/link/..", Recursive: true}},
+			out: out{removed: []string{"/usr/share"}, kept: []string{"/etc/link"}},
+		},
+		{
+			in:  in{removal: types.Removal{Path: "/etc/root/..", Recursive: true}},
+			out: out{kept: []string{"/etc/motd"}, err: true},
+		},
+		// "/" on a named subvolume is the subvolume itself
+		{
+			in:  in{removal: types.Removal{Filesystem: "var", Path: "/@var", Recursive: true}, subvolume: "/@var"},
+			out: out{kept: []string{"/@var/tmp"}, err: true},
+		},
+		{
+			in:  in{removal: types.Removal{Filesystem: "var", Path: "/@var/tmp", Recursive: true}, subvolume: "/@var"},
+			out: out{removed: []string{"/@var/tmp"}, kept: []string{"/@var"}},
+		},
+	}
+
+	for i, test := range tests {
+		root, err := ioutil.TempDir("", "ignition-remove")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(root)
+		for _, dir := range []string{"/etc/ssh/sshd_config.d", "/usr/share", "/@var/tmp"} {
+			if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, file := range []string{"/etc/motd", "/usr/share/motd", "/etc/ssh/sshd_config.d/50-defaults.conf"} {
+			if err := ioutil.WriteFile(filepath.Join(root, file), nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.Symlink("/usr/share/motd", filepath.Join(root, "/etc/link")); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink("/", filepath.Join(root, "/etc/root")); err != nil {
+			t.Fatal(err)
+		}
+
+		logger := log.New(false)
+		u := util.Util{DestDir: root, IsRoot: true, Logger: &logger}
+		subvolume := test.in.subvolume
+		if subvolume == "" {
+			subvolume = "/"
+		}
+		err = removeEntry{Removal: test.in.removal, root: subvolume}.create(&logger, u)
+		if test.out.err != (err != nil) {
+			t.Errorf("#%d: bad error: %v", i, err)
+		}
+		for _, path := range test.out.removed {
+			if _, err := os.Lstat(filepath.Join(root, path)); !os.IsNotExist(err) {
+				t.Errorf("#%d: %q wasn't removed: %v", i, path, err)
+			}
+		}
+		for _, path := range test.out.kept {
+			if _, err := os.Lstat(filepath.Join(root, path)); err != nil {
+				t.Errorf("#%d: %q was removed: %v", i, path, err)
+			}
+		}
+	}
+}
+
+func TestChconCommands(t *testing.T) {
+	s := stage{contexts: []pathContext{
+		{path: "/usr/local/bin/helper", context: "system_u:object_r:bin_t:s0"},
+		{path: `/srv/100% "odd" $dir`, context: "system_u:object_r:var_t:s0:c1,c2"},
+	}}
+	expected := "\nExecStart=/usr/bin/chcon -h \"system_u:object_r:bin_t:s0\" \"/usr/local/bin/helper\"" +
+		"\nExecStart=/usr/bin/chcon -h \"system_u:object_r:var_t:s0:c1,c2\" \"/srv/100%% \\\"odd\\\" $$dir\""
+	if cmds := s.chconCommands(); cmds != expected {
+		t.Errorf("bad commands: want %q, got %q", expected, cmds)
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"syscall"
 
 	configUtil "github.com/flatcar/ignition/config/util"
@@ -28,7 +29,8 @@ import (
 	"github.com/flatcar/ignition/internal/log"
 )
 
-// createFilesystemsEntries creates the files described in config.Storage.{Files,Directories}.
+// createFilesystemsEntries creates the files described in config.Storage.{Files,Directories,Links,Archives}
+// and removes the nodes in config.Storage.Remove.
 func (s *stage) createFilesystemsEntries(config types.Config) error {
 	if len(config.Storage.Filesystems) == 0 {
 		return nil
@@ -50,7 +52,7 @@ func (s *stage) createFilesystemsEntries(config types.Config) error {
 	return nil
 }
 
-// filesystemEntry represent a thing that knows how to create itself.
+// filesystemEntry represent a thing that knows how to create (or remove) itself.
 type filesystemEntry interface {
 	create(l *log.Logger, u util.Util) error
 	getPath() string
@@ -65,6 +67,16 @@ func (tmp fileEntry) getPath() string {
 func (tmp fileEntry) create(l *log.Logger, u util.Util) error {
 	f := types.File(tmp)
 
+	if len(f.Edits) > 0 {
+		if err := l.LogOp(
+			func() error { return u.EditFile(f) },
+			"editing file %q", f.Path,
+		); err != nil {
+			return fmt.Errorf("failed to edit file %q: %v", f.Path, err)
+		}
+		return nil
+	}
+
 	fetchOp := u.PrepareFetch(l, f)
 	if fetchOp == nil {
 		return fmt.Errorf("failed to resolve file %q", f.Path)
@@ -147,7 +159,7 @@ func (tmp dirEntry) create(l *log.Logger, u util.Util) error {
 			}
 		}
 
-		return nil
+		return u.SetXattrs(d.Node, path)
 	}, "creating directory %q", string(d.Path))
 	if err != nil {
 		return fmt.Errorf("failed to create directory %q: %v", d.Path, err)
@@ -181,6 +193,101 @@ func (tmp linkEntry) create(l *log.Logger, u util.Util) error {
 	return nil
 }
 
+// removeEntry is a removal together with the root of its filesystem, which is
+// the path of the subvolume if the removal is on a named subvolume.
+type removeEntry struct {
+	types.Removal
+	root string
+}
+
+func (tmp removeEntry) getPath() string {
+	return tmp.Path
+}
+
+func (tmp removeEntry) create(l *log.Logger, u util.Util) error {
+	r := tmp.Removal
+
+	err := l.LogOp(func() error {
+		path, err := u.JoinPath(r.Path)
+		if err != nil {
+			return err
+		}
+		root, err := u.JoinPath(tmp.root)
+		if err != nil {
+			return err
+		}
+
+		// never remove the filesystem or subvolume itself, even if
+		// symlinks resolve to it
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+			return fmt.Errorf("%q resolves to %q, which is not within the filesystem", r.Path, path)
+		}
+
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			if r.MustExist {
+				return fmt.Errorf("%q does not exist", r.Path)
+			}
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if r.Recursive {
+			return os.RemoveAll(path)
+		}
+		return os.Remove(path)
+	}, "removing %q", r.Path)
+	if err != nil {
+		return fmt.Errorf("failed to remove %q: %v", r.Path, err)
+	}
+
+	return nil
+}
+
+// archiveEntry is a pointer so the paths extracted by create are kept for
+// relabeling.
+type archiveEntry struct {
+	types.Archive
+	created []string
+}
+
+func (tmp *archiveEntry) getPath() string {
+	return tmp.Path
+}
+
+func (tmp *archiveEntry) create(l *log.Logger, u util.Util) error {
+	a := tmp.Archive
+
+	if err := l.LogOp(
+		func() error {
+			created, err := u.ExtractArchive(l, a)
+			tmp.created = created
+			return err
+		}, "extracting %s archive to %q", a.Format, a.Path,
+	); err != nil {
+		return fmt.Errorf("failed to extract archive %q: %v", a.Path, err)
+	}
+
+	return nil
+}
+
+// seLinuxContext returns the explicit SELinux context of e, if it has one.
+func seLinuxContext(e filesystemEntry) string {
+	switch x := e.(type) {
+	case fileEntry:
+		return x.SELinuxContext
+	case dirEntry:
+		return x.SELinuxContext
+	case linkEntry:
+		return x.SELinuxContext
+	}
+	return ""
+}
+
 // ByDirectorySegments is used to sort directories so /foo gets created before /foo/bar if they are both specified.
 type ByDirectorySegments []types.Directory
 
@@ -204,17 +311,37 @@ func depth(n types.Node) uint {
 
 // mapEntriesToFilesystems builds a map of filesystems to files. If multiple
 // definitions of the same filesystem are present, only the final definition is
-// used. The directories are sorted to ensure /foo gets created before /foo/bar.
+// used. Nodes on a named btrfs subvolume are mapped to the filesystem of the
+// subvolume, with their path prefixed by the path of the subvolume. The
+// directories are sorted to ensure /foo gets created before /foo/bar.
 func (s stage) mapEntriesToFilesystems(config types.Config) (map[types.Filesystem][]filesystemEntry, error) {
 	filesystems := map[string]types.Filesystem{}
+	subvolumes := map[string]string{}
 	for _, fs := range config.Storage.Filesystems {
 		filesystems[fs.Name] = fs
+		delete(subvolumes, fs.Name)
+		if fs.Mount == nil {
+			continue
+		}
+		for _, sv := range fs.Mount.Subvolumes {
+			if sv.Name != "" {
+				filesystems[sv.Name] = fs
+				subvolumes[sv.Name] = sv.Path
+			}
+		}
 	}
 
 	entryMap := map[types.Filesystem][]filesystemEntry{}
 
 	// Sort directories to ensure /a gets created before /a/b.
 	sortedDirs := config.Storage.Directories
+	if len(subvolumes) > 0 {
+		sortedDirs = make([]types.Directory, 0, len(config.Storage.Directories))
+		for _, d := range config.Storage.Directories {
+			d.Node = subvolumeNode(d.Node, subvolumes)
+			sortedDirs = append(sortedDirs, d)
+		}
+	}
 	sort.Stable(ByDirectorySegments(sortedDirs))
 
 	// Add directories first to ensure they are created before files.
@@ -227,7 +354,21 @@ func (s stage) mapEntriesToFilesystems(config types.Config) (map[types.Filesyste
 		}
 	}
 
+	// Removals come after directories but before files and links, so a
+	// removed default can be replaced by a node of the config.
+	for _, r := range config.Storage.Remove {
+		r.Path = subvolumeNode(types.Node{Filesystem: r.Filesystem, Path: r.Path}, subvolumes).Path
+		root := subvolumeNode(types.Node{Filesystem: r.Filesystem, Path: "/"}, subvolumes).Path
+		if fs, ok := filesystems[r.Filesystem]; ok {
+			entryMap[fs] = append(entryMap[fs], removeEntry{Removal: r, root: root})
+		} else {
+			s.Logger.Crit("the filesystem (%q), was not defined", r.Filesystem)
+			return nil, ErrFilesystemUndefined
+		}
+	}
+
 	for _, f := range config.Storage.Files {
+		f.Node = subvolumeNode(f.Node, subvolumes)
 		if fs, ok := filesystems[f.Filesystem]; ok {
 			entryMap[fs] = append(entryMap[fs], fileEntry(f))
 		} else {
@@ -237,6 +378,7 @@ func (s stage) mapEntriesToFilesystems(config types.Config) (map[types.Filesyste
 	}
 
 	for _, sy := range config.Storage.Links {
+		sy.Node = subvolumeNode(sy.Node, subvolumes)
 		if fs, ok := filesystems[sy.Filesystem]; ok {
 			entryMap[fs] = append(entryMap[fs], linkEntry(sy))
 		} else {
@@ -245,9 +387,28 @@ func (s stage) mapEntriesToFilesystems(config types.Config) (map[types.Filesyste
 		}
 	}
 
+	for _, a := range config.Storage.Archives {
+		a.Node = subvolumeNode(a.Node, subvolumes)
+		if fs, ok := filesystems[a.Filesystem]; ok {
+			entryMap[fs] = append(entryMap[fs], &archiveEntry{Archive: a})
+		} else {
+			s.Logger.Crit("the filesystem (%q), was not defined", a.Filesystem)
+			return nil, ErrFilesystemUndefined
+		}
+	}
+
 	return entryMap, nil
 }
 
+// subvolumeNode returns node with the path of the subvolume prepended to its
+// path, if its filesystem is the name of one of subvolumes.
+func subvolumeNode(node types.Node, subvolumes map[string]string) types.Node {
+	if prefix, ok := subvolumes[node.Filesystem]; ok {
+		node.Path = filepath.Join(prefix, node.Path)
+	}
+	return node
+}
+
 func (s *stage) mountAuto(dev, mnt string) error {
 	var err error
 	// try to mount all possible formats from config/v2_*/types/filesystem.go (without "swap")
@@ -300,8 +461,9 @@ func (s *stage) createEntries(fs types.Filesystem, files []filesystemEntry) erro
 
 	for _, e := range files {
 		path := e.getPath()
-		// only relabel things on the root filesystem
-		if fs.Name == "root" && s.relabeling() {
+		// only relabel things on the root filesystem, and there's nothing
+		// left to relabel for removed nodes
+		if _, ok := e.(removeEntry); !ok && fs.Name == "root" && s.relabeling() {
 			// relabel from the first parent dir that we'll have to create --
 			// alternatively, we could make `MkdirForFile` fancier instead of
 			// using `os.MkdirAll`, though that's quite a lot of levels to plumb
@@ -326,6 +488,13 @@ func (s *stage) createEntries(fs types.Filesystem, files []filesystemEntry) erro
 		if err := e.create(s.Logger, u); err != nil {
 			return err
 		}
+		// relabel everything extracted from archives as well
+		if a, ok := e.(*archiveEntry); ok && fs.Name == "root" && s.relabeling() {
+			s.relabel(a.created...)
+		}
+		if context := seLinuxContext(e); context != "" && fs.Name == "root" && s.relabeling() {
+			s.keepContext(path, context)
+		}
 	}
 	return nil
 }
@@ -0,0 +1,136 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/flatcar/ignition/internal/config/types"
+	"github.com/flatcar/ignition/internal/distro"
+	"github.com/flatcar/ignition/internal/exec/stages"
+	"github.com/flatcar/ignition/internal/exec/util"
+)
+
+// createCrypttab installs the key files of the LUKS volumes created by the
+// disks stage in the root and adds the volumes to /etc/crypttab so they are
+// unlocked on every boot.
+func (s *stage) createCrypttab(config types.Config) error {
+	if len(config.Storage.Luks) == 0 {
+		return nil
+	}
+	s.Logger.PushPrefix("createCrypttab")
+	defer s.Logger.PopPrefix()
+
+	// the key files were written to the initramfs (or to the test root when
+	// running locally through blackbox tests)
+	initramfs := util.Util{DestDir: "/"}
+	if distro.BlackboxTesting() {
+		initramfs.DestDir = s.DestDir
+	}
+
+	var entries []string
+	for _, luks := range config.Storage.Luks {
+		src, err := initramfs.JoinPath(distro.LuksInitramfsKeyFilePath(), luks.Name)
+		if err != nil {
+			return err
+		}
+		keyFile := filepath.Join(distro.LuksRealRootKeyFilePath(), luks.Name)
+		if err := s.Logger.LogOp(
+			func() error { return s.installLuksKeyFile(src, keyFile) },
+			"writing key file for %q at %q", luks.Name, keyFile,
+		); err != nil {
+			return err
+		}
+
+		out := &bytes.Buffer{}
+		cmd := exec.Command(distro.CryptsetupCmd(), "luksUUID", luks.Device)
+		cmd.Stdout = out
+		if _, err := s.Logger.LogCmd(cmd, "reading UUID of LUKS volume %q", luks.Name); err != nil {
+			return fmt.Errorf("failed to read UUID of LUKS volume %q: %v", luks.Name, err)
+		}
+		entries = append(entries, crypttabEntry(luks, strings.TrimSpace(out.String()), keyFile))
+	}
+
+	if err := s.Logger.LogOp(
+		func() error { return s.appendCrypttab(entries) },
+		"adding %d LUKS volumes to %q", len(entries), "/etc/crypttab",
+	); err != nil {
+		return err
+	}
+	s.relabel(filepath.Clean(distro.LuksRealRootKeyFilePath()), "/etc/crypttab")
+
+	return nil
+}
+
+// installLuksKeyFile copies the key file src into the root at dst, readable
+// only by root.
+func (s *stage) installLuksKeyFile(src, dst string) error {
+	key, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	path, err := s.JoinPath(dst)
+	if err != nil {
+		return err
+	}
+	if err := util.MkdirForFile(path); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, key, 0400); err != nil {
+		return err
+	}
+	// WriteFile leaves the mode of an existing file untouched
+	return os.Chmod(path, 0400)
+}
+
+// appendCrypttab appends the entries which aren't in /etc/crypttab yet to
+// it, so running Ignition again doesn't duplicate them.
+func (s *stage) appendCrypttab(entries []string) error {
+	return s.appendMissingLines("/etc/crypttab", 0600, entries)
+}
+
+// crypttabEntry returns the crypttab(5) line unlocking the volume with the
+// given LUKS UUID using keyFile.
+func crypttabEntry(luks types.Luks, uuid, keyFile string) string {
+	options := "luks"
+	if luks.Discard {
+		options += ",discard"
+	}
+	return fmt.Sprintf("%s UUID=%s %s %s", luks.Name, uuid, keyFile, options)
+}
+
+// planCrypttab returns the key files and crypttab entries which would be
+// written for the LUKS volumes.
+func planCrypttab(config types.Config) []stages.Step {
+	var steps []stages.Step
+	for _, luks := range config.Storage.Luks {
+		steps = append(steps, stages.Step{
+			Action: "write LUKS key file",
+			Target: filepath.Join(distro.LuksRealRootKeyFilePath(), luks.Name),
+		})
+		steps = append(steps, stages.Step{
+			Action: "add crypttab entry",
+			Target: luks.Name,
+			Detail: "for " + luks.Device,
+		})
+	}
+	return steps
+}
@@ -0,0 +1,83 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/flatcar/ignition/internal/config/types"
+	"github.com/flatcar/ignition/internal/exec/util"
+	"github.com/flatcar/ignition/internal/log"
+)
+
+func TestCrypttabEntry(t *testing.T) {
+	type in struct {
+		luks types.Luks
+	}
+	type out struct {
+		entry string
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{luks: types.Luks{Name: "data", Device: "/dev/vdb"}},
+			out: out{entry: "data UUID=5ad6e5a8-7f51-4b6f-9f2e-c1b6aab9ab5c /etc/luks/data luks"},
+		},
+		{
+			in:  in{luks: types.Luks{Name: "data", Device: "/dev/vdb", Discard: true}},
+			out: out{entry: "data UUID=5ad6e5a8-7f51-4b6f-9f2e-c1b6aab9ab5c /etc/luks/data luks,discard"},
+		},
+	}
+
+	for i, test := range tests {
+		entry := crypttabEntry(test.in.luks, "5ad6e5a8-7f51-4b6f-9f2e-c1b6aab9ab5c", "/etc/luks/data")
+		if entry != test.out.entry {
+			t.Errorf("#%d: bad entry: want %q, got %q", i, test.out.entry, entry)
+		}
+	}
+}
+
+func TestAppendCrypttab(t *testing.T) {
+	root, err := ioutil.TempDir("", "ignition-crypttab-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	logger := log.New(false)
+	s := stage{Util: util.Util{DestDir: root, Logger: &logger}}
+	if err := s.appendCrypttab([]string{"a UUID=1 /etc/luks/a luks"}); err != nil {
+		t.Fatal(err)
+	}
+	// entries already present are not added again
+	if err := s.appendCrypttab([]string{"a UUID=1 /etc/luks/a luks", "b UUID=2 /etc/luks/b luks,discard"}); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join(root, "etc/crypttab"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "a UUID=1 /etc/luks/a luks\nb UUID=2 /etc/luks/b luks,discard\n"
+	if string(contents) != want {
+		t.Errorf("bad crypttab: want %q, got %q", want, contents)
+	}
+}
@@ -0,0 +1,257 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/flatcar/ignition/internal/config/types"
+	"github.com/flatcar/ignition/internal/exec/stages"
+	"github.com/flatcar/ignition/internal/exec/util"
+)
+
+// Plan returns the groups, users, files, directories, links, archives, LUKS
+// key files and units that Run would create, modify or remove. Filesystems other than
+// the root filesystem are not mounted, so nodes on them are listed without
+// checking what exists.
+func (s stage) Plan(config types.Config) ([]stages.Step, error) {
+	var steps []stages.Step
+
+	for _, g := range config.Passwd.Groups {
+		steps = append(steps, stages.Step{Action: "add group", Target: g.Name})
+	}
+
+	for _, u := range config.Passwd.Users {
+		exists, err := s.CheckIfUserExists(u)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check if user %q exists: %v", u.Name, err)
+		}
+		action := "add user"
+		if exists {
+			action = "modify user"
+		}
+		steps = append(steps, stages.Step{Action: action, Target: u.Name})
+	}
+
+	nodeSteps, err := s.planFilesystemsEntries(config)
+	if err != nil {
+		return nil, err
+	}
+	steps = append(steps, nodeSteps...)
+
+	steps = append(steps, planCrypttab(config)...)
+
+	steps = append(steps, planMdadmConf(config)...)
+
+	steps = append(steps, planUnits(config)...)
+
+	return steps, nil
+}
+
+// planFilesystemsEntries returns the nodes which would be written, in the
+// order of the filesystems in the config.
+func (s stage) planFilesystemsEntries(config types.Config) ([]stages.Step, error) {
+	entryMap, err := s.mapEntriesToFilesystems(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var steps []stages.Step
+	seen := map[types.Filesystem]bool{}
+	for _, fs := range config.Storage.Filesystems {
+		if seen[fs] {
+			continue
+		}
+		seen[fs] = true
+
+		var u *util.Util
+		if fs.Path != nil {
+			u = &util.Util{DestDir: *fs.Path, Root: s.Util.Root, Logger: s.Logger}
+		}
+		for _, e := range entryMap[fs] {
+			step, err := planEntry(u, fs, e)
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, step)
+		}
+	}
+	return steps, nil
+}
+
+// planEntry describes how e would be created. If u is nil the filesystem of
+// e can't be inspected.
+func planEntry(u *util.Util, fs types.Filesystem, e filesystemEntry) (stages.Step, error) {
+	var step stages.Step
+	var node types.Node
+	switch x := e.(type) {
+	case fileEntry:
+		node = x.Node
+		step.Action = "write file"
+		if x.Append {
+			step.Action = "append to file"
+		}
+		if x.Contents.Template {
+			step.Detail = "rendered from a template"
+		}
+		if len(x.Edits) > 0 {
+			step.Action = "edit file"
+			step.Detail = editsDetail(x.Edits)
+		}
+	case dirEntry:
+		node = x.Node
+		step.Action = "create directory"
+	case linkEntry:
+		node = x.Node
+		step.Action = "create link"
+		step.Detail = "to " + x.Target
+	case *archiveEntry:
+		node = x.Node
+		step.Action = "extract archive"
+		step.Detail = x.Format
+	case removeEntry:
+		node = types.Node{Filesystem: x.Filesystem, Path: x.Path}
+		step.Action = "remove"
+		if x.Recursive {
+			step.Detail = "recursively"
+		}
+	}
+
+	if u == nil {
+		step.Target = fmt.Sprintf("%s:%s", fs.Name, node.Path)
+		return step, nil
+	}
+	path, err := u.JoinPath(node.Path)
+	if err != nil {
+		return stages.Step{}, err
+	}
+	step.Target = path
+
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		if x, ok := e.(removeEntry); ok {
+			if x.MustExist {
+				return stages.Step{}, fmt.Errorf("%q does not exist", path)
+			}
+			step.Detail = joinDetail(step.Detail, "does not exist")
+		}
+		// an earlier entry may still create the file
+		if x, ok := e.(fileEntry); ok && len(x.Edits) > 0 {
+			step.Detail = joinDetail(step.Detail, "does not exist")
+		}
+		return step, nil
+	} else if err != nil {
+		return stages.Step{}, err
+	}
+
+	overwrite := node.Overwrite != nil && *node.Overwrite
+	switch x := e.(type) {
+	case fileEntry:
+		// Files are replaced unless overwrite is explicitly false.
+		if x.Append || len(x.Edits) > 0 {
+			return step, nil
+		}
+		if node.Overwrite == nil || overwrite {
+			step.Detail = joinDetail(step.Detail, "replaces the existing node")
+			return step, nil
+		}
+	case dirEntry:
+		if overwrite {
+			step.Detail = joinDetail(step.Detail, "replaces the existing node")
+			return step, nil
+		}
+		if info.IsDir() {
+			step.Detail = joinDetail(step.Detail, "already exists")
+			return step, nil
+		}
+	case linkEntry:
+		if overwrite {
+			step.Detail = joinDetail(step.Detail, "replaces the existing node")
+			return step, nil
+		}
+	case removeEntry:
+		return step, nil
+	case *archiveEntry:
+		if overwrite {
+			step.Detail = joinDetail(step.Detail, "replaces the existing node")
+			return step, nil
+		}
+		if info.IsDir() {
+			step.Detail = joinDetail(step.Detail, "merges into the existing directory")
+			return step, nil
+		}
+	}
+	return stages.Step{}, fmt.Errorf("%q already exists and overwrite is false", path)
+}
+
+// editsDetail lists the actions of edits, e.g. "setKey, unsetKey".
+func editsDetail(edits []types.FileEdit) string {
+	var actions []string
+	for _, e := range edits {
+		actions = append(actions, e.Action)
+	}
+	return strings.Join(actions, ", ")
+}
+
+func joinDetail(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + ", " + b
+}
+
+func templateDetail(template bool) string {
+	if template {
+		return "rendered from a template"
+	}
+	return ""
+}
+
+// planUnits returns the units and dropins which would be written, enabled,
+// disabled or masked.
+func planUnits(config types.Config) []stages.Step {
+	var steps []stages.Step
+	for _, unit := range config.Systemd.Units {
+		for _, dropin := range unit.Dropins {
+			if dropin.Contents != "" {
+				steps = append(steps, stages.Step{Action: "write drop-in", Target: unit.Name + "/" + dropin.Name, Detail: templateDetail(dropin.Template)})
+			}
+		}
+		if unit.Contents != "" {
+			steps = append(steps, stages.Step{Action: "write unit", Target: unit.Name, Detail: templateDetail(unit.Template)})
+		}
+		if unit.Enable || (unit.Enabled != nil && *unit.Enabled) {
+			steps = append(steps, stages.Step{Action: "enable unit", Target: unit.Name})
+		} else if unit.Enabled != nil {
+			steps = append(steps, stages.Step{Action: "disable unit", Target: unit.Name})
+		}
+		if unit.Mask {
+			steps = append(steps, stages.Step{Action: "mask unit", Target: unit.Name})
+		}
+	}
+	for _, unit := range config.Networkd.Units {
+		for _, dropin := range unit.Dropins {
+			if dropin.Contents != "" {
+				steps = append(steps, stages.Step{Action: "write networkd drop-in", Target: unit.Name + "/" + dropin.Name})
+			}
+		}
+		if unit.Contents != "" {
+			steps = append(steps, stages.Step{Action: "write networkd unit", Target: unit.Name})
+		}
+	}
+	return steps
+}
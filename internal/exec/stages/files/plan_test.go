@@ -0,0 +1,178 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/flatcar/ignition/internal/config/types"
+	"github.com/flatcar/ignition/internal/exec/stages"
+	"github.com/flatcar/ignition/internal/exec/util"
+	"github.com/flatcar/ignition/internal/log"
+)
+
+func TestPlanFilesystemsEntries(t *testing.T) {
+	root, err := ioutil.TempDir("", "ignition-plan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	if err := ioutil.WriteFile(filepath.Join(root, "existing"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	type in struct {
+		storage types.Storage
+	}
+	type out struct {
+		steps []stages.Step
+		err   bool
+	}
+
+	yes := true
+	no := false
+	filesystems := []types.Filesystem{{Name: "root", Path: &root}, {Name: "oem"}}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in: in{storage: types.Storage{
+				Filesystems: filesystems,
+				Files: []types.File{
+					{Node: types.Node{Filesystem: "root", Path: "/new"}},
+					{Node: types.Node{Filesystem: "root", Path: "/existing"}},
+					{Node: types.Node{Filesystem: "root", Path: "/existing"}, FileEmbedded1: types.FileEmbedded1{Append: true}},
+					{Node: types.Node{Filesystem: "root", Path: "/hostname"}, FileEmbedded1: types.FileEmbedded1{Contents: types.FileContents{Template: true}}},
+					{Node: types.Node{Filesystem: "root", Path: "/existing"}, FileEmbedded1: types.FileEmbedded1{Edits: []types.FileEdit{{Action: "setKey", Key: "A"}, {Action: "unsetKey", Key: "B"}}}},
+					{Node: types.Node{Filesystem: "root", Path: "/missing.conf"}, FileEmbedded1: types.FileEmbedded1{Edits: []types.FileEdit{{Action: "deleteLines", Regex: "^#"}}}},
+					{Node: types.Node{Filesystem: "oem", Path: "/grub.cfg"}},
+				},
+				Directories: []types.Directory{
+					{Node: types.Node{Filesystem: "root", Path: "/dir"}},
+				},
+				Links: []types.Link{
+					{Node: types.Node{Filesystem: "root", Path: "/existing", Overwrite: &yes}, LinkEmbedded1: types.LinkEmbedded1{Target: "/new"}},
+				},
+				Remove: []types.Removal{
+					{Filesystem: "root", Path: "/dir", Recursive: true},
+					{Filesystem: "root", Path: "/gone"},
+				},
+				Archives: []types.Archive{
+					{Node: types.Node{Filesystem: "root", Path: "/dir"}, ArchiveEmbedded1: types.ArchiveEmbedded1{Format: "tar"}},
+					{Node: types.Node{Filesystem: "root", Path: "/app"}, ArchiveEmbedded1: types.ArchiveEmbedded1{Format: "zip"}},
+				},
+			}},
+			out: out{steps: []stages.Step{
+				{Action: "create directory", Target: filepath.Join(root, "dir"), Detail: "already exists"},
+				{Action: "remove", Target: filepath.Join(root, "dir"), Detail: "recursively"},
+				{Action: "remove", Target: filepath.Join(root, "gone"), Detail: "does not exist"},
+				{Action: "write file", Target: filepath.Join(root, "new")},
+				{Action: "write file", Target: filepath.Join(root, "existing"), Detail: "replaces the existing node"},
+				{Action: "append to file", Target: filepath.Join(root, "existing")},
+				{Action: "write file", Target: filepath.Join(root, "hostname"), Detail: "rendered from a template"},
+				{Action: "edit file", Target: filepath.Join(root, "existing"), Detail: "setKey, unsetKey"},
+				{Action: "edit file", Target: filepath.Join(root, "missing.conf"), Detail: "deleteLines, does not exist"},
+				{Action: "create link", Target: filepath.Join(root, "existing"), Detail: "to /new, replaces the existing node"},
+				{Action: "extract archive", Target: filepath.Join(root, "dir"), Detail: "tar, merges into the existing directory"},
+				{Action: "extract archive", Target: filepath.Join(root, "app"), Detail: "zip"},
+				{Action: "write file", Target: "oem:/grub.cfg"},
+			}},
+		},
+		{
+			in: in{storage: types.Storage{
+				Filesystems: filesystems,
+				Files: []types.File{
+					{Node: types.Node{Filesystem: "root", Path: "/existing", Overwrite: &no}},
+				},
+			}},
+			out: out{err: true},
+		},
+		{
+			in: in{storage: types.Storage{
+				Filesystems: filesystems,
+				Links: []types.Link{
+					{Node: types.Node{Filesystem: "root", Path: "/dir"}, LinkEmbedded1: types.LinkEmbedded1{Target: "/new"}},
+				},
+			}},
+			out: out{err: true},
+		},
+		{
+			in: in{storage: types.Storage{
+				Filesystems: filesystems,
+				Archives: []types.Archive{
+					{Node: types.Node{Filesystem: "root", Path: "/existing"}, ArchiveEmbedded1: types.ArchiveEmbedded1{Format: "tar"}},
+				},
+			}},
+			out: out{err: true},
+		},
+		{
+			in: in{storage: types.Storage{
+				Filesystems: filesystems,
+				Remove: []types.Removal{
+					{Filesystem: "root", Path: "/gone", MustExist: true},
+				},
+			}},
+			out: out{err: true},
+		},
+	}
+
+	for i, test := range tests {
+		logger := log.New(false)
+		steps, err := stage{Util: util.Util{Logger: &logger}}.planFilesystemsEntries(types.Config{Storage: test.in.storage})
+		if test.out.err != (err != nil) {
+			t.Errorf("#%d: bad error: %v", i, err)
+		}
+		if !reflect.DeepEqual(test.out.steps, steps) {
+			t.Errorf("#%d: bad steps: want %v, got %v", i, test.out.steps, steps)
+		}
+	}
+}
+
+func TestPlanUnits(t *testing.T) {
+	yes := true
+	no := false
+	config := types.Config{
+		Systemd: types.Systemd{Units: []types.Unit{
+			{Name: "a.service", Contents: "[Service]", Enabled: &yes, Dropins: []types.SystemdDropin{{Name: "10-a.conf", Contents: "[Unit]"}, {Name: "20-a.conf", Contents: "[Unit]", Template: true}, {Name: "empty.conf"}}},
+			{Name: "b.service", Enabled: &no},
+			{Name: "c.service", Mask: true},
+		}},
+		Networkd: types.Networkd{Units: []types.Networkdunit{
+			{Name: "a.network", Contents: "[Match]"},
+		}},
+	}
+	want := []stages.Step{
+		{Action: "write drop-in", Target: "a.service/10-a.conf"},
+		{Action: "write drop-in", Target: "a.service/20-a.conf", Detail: "rendered from a template"},
+		{Action: "write unit", Target: "a.service"},
+		{Action: "enable unit", Target: "a.service"},
+		{Action: "disable unit", Target: "b.service"},
+		{Action: "mask unit", Target: "c.service"},
+		{Action: "write networkd unit", Target: "a.network"},
+	}
+
+	if steps := planUnits(config); !reflect.DeepEqual(want, steps) {
+		t.Errorf("bad steps: want %v, got %v", want, steps)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/flatcar/ignition/internal/config/types"
+	"github.com/flatcar/ignition/internal/distro"
+	"github.com/flatcar/ignition/internal/exec/stages"
+	"github.com/flatcar/ignition/internal/exec/util"
+)
+
+const mdadmConfPath = "/etc/mdadm.conf"
+
+// createMdadmConf adds the RAID arrays with writeMdadmConf to /etc/mdadm.conf
+// in the root, so they are assembled under their name on every boot.
+func (s *stage) createMdadmConf(config types.Config) error {
+	var arrays []types.Raid
+	for _, md := range config.Storage.Raid {
+		if md.WriteMdadmConf {
+			arrays = append(arrays, md)
+		}
+	}
+	if len(arrays) == 0 {
+		return nil
+	}
+	s.Logger.PushPrefix("createMdadmConf")
+	defer s.Logger.PopPrefix()
+
+	var entries []string
+	for _, md := range arrays {
+		out := &bytes.Buffer{}
+		cmd := exec.Command(distro.MdadmCmd(), "--detail", "--brief", util.RaidDevice(md.Name))
+		cmd.Stdout = out
+		if _, err := s.Logger.LogCmd(cmd, "reading details of RAID array %q", md.Name); err != nil {
+			return fmt.Errorf("failed to read details of RAID array %q: %v", md.Name, err)
+		}
+		entries = append(entries, strings.TrimSpace(out.String()))
+	}
+
+	if err := s.Logger.LogOp(
+		func() error { return s.appendMdadmConf(entries) },
+		"adding %d RAID arrays to %q", len(entries), mdadmConfPath,
+	); err != nil {
+		return err
+	}
+	s.relabel(mdadmConfPath)
+
+	return nil
+}
+
+// appendMdadmConf appends the entries which aren't in /etc/mdadm.conf yet to
+// it, so running Ignition again doesn't duplicate them.
+func (s *stage) appendMdadmConf(entries []string) error {
+	return s.appendMissingLines(mdadmConfPath, 0644, entries)
+}
+
+// planMdadmConf returns the mdadm.conf entries which would be written for the
+// RAID arrays.
+func planMdadmConf(config types.Config) []stages.Step {
+	var steps []stages.Step
+	for _, md := range config.Storage.Raid {
+		if md.WriteMdadmConf {
+			steps = append(steps, stages.Step{
+				Action: "add mdadm.conf entry",
+				Target: md.Name,
+				Detail: "for " + util.RaidDevice(md.Name),
+			})
+		}
+	}
+	return steps
+}
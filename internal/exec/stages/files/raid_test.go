@@ -0,0 +1,54 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/flatcar/ignition/internal/exec/util"
+	"github.com/flatcar/ignition/internal/log"
+)
+
+func TestAppendMdadmConf(t *testing.T) {
+	root, err := ioutil.TempDir("", "ignition-mdadm-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	logger := log.New(false)
+	s := stage{Util: util.Util{DestDir: root, Logger: &logger}}
+	a := "ARRAY /dev/md/a metadata=1.2 name=any:a UUID=b8c4e0a2:57d34a41:9e5c0d6f:1f3a7b21"
+	b := "ARRAY /dev/md/b metadata=1.2 name=any:b UUID=0e1d1a0c:64d8e1b5:5a5e1c34:4c0e8b65"
+	if err := s.appendMdadmConf([]string{a}); err != nil {
+		t.Fatal(err)
+	}
+	// entries already present are not added again
+	if err := s.appendMdadmConf([]string{a, b}); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join(root, "etc/mdadm.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := a + "\n" + b + "\n"
+	if string(contents) != want {
+		t.Errorf("bad mdadm.conf: want %q, got %q", want, contents)
+	}
+}
@@ -15,6 +15,8 @@
 package stages
 
 import (
+	"fmt"
+
 	"github.com/flatcar/ignition/internal/config/types"
 	"github.com/flatcar/ignition/internal/log"
 	"github.com/flatcar/ignition/internal/registry"
@@ -27,6 +29,29 @@ type Stage interface {
 	Name() string
 }
 
+// Planner is implemented by stages which can describe the changes they would
+// make to the system without making them.
+type Planner interface {
+	Plan(config types.Config) ([]Step, error)
+}
+
+// Step describes a single change a stage would make. Stage is filled in by
+// the engine.
+type Step struct {
+	Stage  string `json:"stage"`
+	Action string `json:"action"`
+	Target string `json:"target"`
+	Detail string `json:"detail,omitempty"`
+}
+
+func (s Step) String() string {
+	str := fmt.Sprintf("%s: %s %s", s.Stage, s.Action, s.Target)
+	if s.Detail != "" {
+		str += " (" + s.Detail + ")"
+	}
+	return str
+}
+
 // StageCreator is responsible for instantiating a particular stage given a
 // logger and root path under the root partition.
 type StageCreator interface {
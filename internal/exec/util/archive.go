@@ -0,0 +1,376 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/flatcar/ignition/internal/config/types"
+	"github.com/flatcar/ignition/internal/log"
+)
+
+var (
+	ErrArchiveEscape = errors.New("archive member resolves to a path outside of the archive directory")
+)
+
+// archiveMember is a single entry of a tar or zip archive.
+type archiveMember struct {
+	name     string
+	mode     os.FileMode
+	uid      int
+	gid      int
+	linkname string // target of a symlink, or member name of a hard link's target
+	hardlink bool
+	contents io.Reader
+}
+
+// ExtractArchive fetches the archive described by a and extracts it into the
+// directory at a.Path, creating the directory if it doesn't exist. Members
+// which would resolve to a path outside of that directory are refused. If
+// a.Overwrite is true the directory is replaced, otherwise the archive is
+// merged into it and fails if a member would replace something which already
+// exists. The paths which were created are returned as paths within the
+// filesystem.
+func (u Util) ExtractArchive(l *log.Logger, a types.Archive) ([]string, error) {
+	// Archives are fetched like files, so reuse their fetch preparation for
+	// the hash, compression and http headers.
+	fetchOp := u.PrepareFetch(l, types.File{
+		Node:          a.Node,
+		FileEmbedded1: types.FileEmbedded1{Contents: a.Contents},
+	})
+	if fetchOp == nil {
+		return nil, fmt.Errorf("failed to resolve archive %q", a.Path)
+	}
+
+	dir, err := u.JoinPath(a.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.DeletePathOnOverwrite(a.Node); err != nil {
+		return nil, err
+	}
+
+	if err := MkdirForFile(dir); err != nil {
+		return nil, err
+	}
+
+	// Fetch into a temporary file in the parent directory, zip archives
+	// can't be read as a stream.
+	tmp, err := ioutil.TempFile(filepath.Dir(dir), "tmp")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := u.Fetcher.Fetch(fetchOp.Url, tmp, fetchOp.FetchOptions); err != nil {
+		u.Crit("Error fetching archive %q: %v", a.Path, err)
+		return nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	uid, gid, err := u.ResolveNodeUidAndGid(a.Node, -1, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	x := extractor{
+		Util:    u,
+		archive: a,
+		dir:     dir,
+		uid:     uid,
+		gid:     gid,
+		created: map[string]bool{},
+	}
+
+	info, err := os.Lstat(dir)
+	switch {
+	case os.IsNotExist(err):
+		if err := x.mkdirAll(dir); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	case !info.IsDir():
+		return nil, fmt.Errorf("error creating %q: something else exists at that path", a.Path)
+	}
+
+	switch a.Format {
+	case "tar":
+		err = walkTar(tmp, x.extract)
+	case "zip":
+		err = walkZip(tmp, x.extract)
+	default:
+		err = fmt.Errorf("unsupported archive format %q", a.Format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return x.paths, nil
+}
+
+// extractor extracts the members of an archive into dir.
+type extractor struct {
+	Util
+	archive types.Archive
+	dir     string
+	// uid and gid override the owners of the members, unless they are -1.
+	uid     int
+	gid     int
+	created map[string]bool
+	paths   []string
+}
+
+func (x *extractor) extract(m archiveMember) error {
+	path, err := x.resolve(m.name)
+	if err != nil {
+		return err
+	}
+	if path == x.dir {
+		return nil
+	}
+
+	if err := x.mkdirAll(filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	info, err := os.Lstat(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return err
+	case m.mode.IsDir() && info.IsDir() && !x.created[path]:
+		// Merge into directories which already exist.
+		return nil
+	case m.mode.IsDir() && info.IsDir():
+		// The directory was implicitly created by an earlier member.
+		return x.setOwnerAndMode(path, m, x.archive.DirectoryMode)
+	case !x.created[path]:
+		return fmt.Errorf("error extracting %q: something else exists at that path", m.name)
+	default:
+		// Later members replace earlier ones of the same name.
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case m.hardlink:
+		target, err := x.resolve(m.linkname)
+		if err != nil {
+			return err
+		}
+		if err := os.Link(target, path); err != nil {
+			return err
+		}
+		x.record(path)
+		return nil
+	case m.mode.IsDir():
+		if err := os.Mkdir(path, 0700); err != nil {
+			return err
+		}
+		x.record(path)
+		return x.setOwnerAndMode(path, m, x.archive.DirectoryMode)
+	case m.mode&os.ModeSymlink != 0:
+		if err := os.Symlink(m.linkname, path); err != nil {
+			return err
+		}
+		x.record(path)
+		uid, gid := x.owner(m)
+		return os.Lchown(path, uid, gid)
+	case m.mode.IsRegular():
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return err
+		}
+		x.record(path)
+		_, err = io.Copy(f, m.contents)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		return x.setOwnerAndMode(path, m, x.archive.FileMode)
+	default:
+		x.Warning("skipping archive member %q: unsupported file type %v", m.name, m.mode.Type())
+		return nil
+	}
+}
+
+// resolve returns the path a member name refers to, after resolving symlinks
+// like JoinPath. Names which are absolute or resolve to a path outside of the
+// archive directory are refused.
+func (x *extractor) resolve(name string) (string, error) {
+	if filepath.IsAbs(name) || wantsToEscape(name) {
+		return "", fmt.Errorf("refusing to extract %q: %v", name, ErrArchiveEscape)
+	}
+	path, err := x.JoinPath(x.archive.Path, name)
+	if err != nil {
+		return "", err
+	}
+	if path != x.dir && !strings.HasPrefix(path, x.dir+string(filepath.Separator)) {
+		return "", fmt.Errorf("refusing to extract %q: %v", name, ErrArchiveEscape)
+	}
+	return path, nil
+}
+
+// mkdirAll creates path and its missing parents with the directory mode of
+// the archive, or the default directory permissions.
+func (x *extractor) mkdirAll(path string) error {
+	var newPaths []string
+	for p := path; ; p = filepath.Dir(p) {
+		_, err := os.Lstat(p)
+		if err == nil {
+			break
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		newPaths = append(newPaths, p)
+	}
+
+	m := archiveMember{mode: os.ModeDir | DefaultDirectoryPermissions}
+	for i := len(newPaths) - 1; i >= 0; i-- {
+		if err := os.Mkdir(newPaths[i], 0700); err != nil {
+			return err
+		}
+		x.record(newPaths[i])
+		if err := x.setOwnerAndMode(newPaths[i], m, x.archive.DirectoryMode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setOwnerAndMode sets the owner of path to the owner of m, unless it is
+// overridden by the archive, and its mode to mode, or the mode of m if mode
+// is nil.
+func (x *extractor) setOwnerAndMode(path string, m archiveMember, mode *int) error {
+	uid, gid := x.owner(m)
+	if err := os.Chown(path, uid, gid); err != nil {
+		return err
+	}
+	perm := m.mode & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+	if mode != nil {
+		perm = os.FileMode(*mode)
+	}
+	return os.Chmod(path, perm)
+}
+
+func (x *extractor) owner(m archiveMember) (int, int) {
+	uid, gid := m.uid, m.gid
+	if x.uid != -1 {
+		uid = x.uid
+	}
+	if x.gid != -1 {
+		gid = x.gid
+	}
+	return uid, gid
+}
+
+// record marks path as created. The returned paths are relative to DestDir,
+// like the paths of nodes.
+func (x *extractor) record(path string) {
+	x.created[path] = true
+	rel, err := filepath.Rel(x.DestDir, path)
+	if err != nil {
+		rel = path
+	}
+	x.paths = append(x.paths, filepath.Join("/", rel))
+}
+
+// walkTar calls fn for every member of the tar archive read from r.
+func walkTar(r io.Reader, fn func(archiveMember) error) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+
+		m := archiveMember{
+			name:     hdr.Name,
+			mode:     hdr.FileInfo().Mode(),
+			uid:      hdr.Uid,
+			gid:      hdr.Gid,
+			linkname: hdr.Linkname,
+			hardlink: hdr.Typeflag == tar.TypeLink,
+			contents: tr,
+		}
+		if err := fn(m); err != nil {
+			return err
+		}
+	}
+}
+
+// walkZip calls fn for every member of the zip archive f. Zip archives don't
+// record owners, so all members are owned by root unless overridden.
+func walkZip(f *os.File, fn func(archiveMember) error) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	zr, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		return err
+	}
+
+	for _, zf := range zr.File {
+		if err := walkZipMember(zf, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func walkZipMember(zf *zip.File, fn func(archiveMember) error) error {
+	rc, err := zf.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	m := archiveMember{
+		name:     zf.Name,
+		mode:     zf.Mode(),
+		contents: rc,
+	}
+	// Zip archives store the target of symlinks as their contents.
+	if m.mode&os.ModeSymlink != 0 {
+		target, err := ioutil.ReadAll(rc)
+		if err != nil {
+			return err
+		}
+		m.linkname = string(target)
+	}
+	return fn(m)
+}
@@ -0,0 +1,377 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/flatcar/ignition/internal/config/types"
+	"github.com/flatcar/ignition/internal/log"
+	"github.com/flatcar/ignition/internal/resource"
+)
+
+type testMember struct {
+	name     string
+	typ      byte
+	mode     int64
+	uid      int
+	linkname string
+	contents string
+}
+
+func makeTar(t *testing.T, members []testMember) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, m := range members {
+		hdr := &tar.Header{
+			Name:     m.name,
+			Typeflag: m.typ,
+			Mode:     m.mode,
+			Uid:      m.uid,
+			Gid:      m.uid,
+			Linkname: m.linkname,
+			Size:     int64(len(m.contents)),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(m.contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func makeZip(t *testing.T, members []testMember) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, m := range members {
+		hdr := &zip.FileHeader{Name: m.name}
+		mode := os.FileMode(m.mode)
+		if m.typ == tar.TypeSymlink {
+			mode |= os.ModeSymlink
+		}
+		hdr.SetMode(mode)
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(m.contents + m.linkname)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func dataURL(b []byte) string {
+	return "data:;base64," + base64.StdEncoding.EncodeToString(b)
+}
+
+func TestExtractArchive(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("test requires root for chown")
+	}
+
+	tarball := makeTar(t, []testMember{
+		{name: "bin/", typ: tar.TypeDir, mode: 0750, uid: 10},
+		{name: "bin/app", typ: tar.TypeReg, mode: 0755, uid: 10, contents: "app"},
+		{name: "bin/app-link", typ: tar.TypeSymlink, linkname: "app"},
+		{name: "bin/app-hard", typ: tar.TypeLink, linkname: "bin/app"},
+		{name: "share/doc/README", typ: tar.TypeReg, mode: 0644, contents: "readme"},
+	})
+	sum := sha512.Sum512(tarball)
+
+	type in struct {
+		archive  types.Archive
+		existing map[string]string
+	}
+	type out struct {
+		paths []string
+		modes map[string]os.FileMode
+		uids  map[string]int
+		err   bool
+	}
+
+	yes := true
+	no := false
+	hash := "sha512-" + hex.EncodeToString(sum[:])
+	badHash := "sha512-" + hex.EncodeToString(make([]byte, sha512.Size))
+	uid := 500
+	fileMode := 0600
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in: in{archive: types.Archive{
+				Node: types.Node{Path: "/opt/app"},
+				ArchiveEmbedded1: types.ArchiveEmbedded1{
+					Format:   "tar",
+					Contents: types.FileContents{Source: dataURL(tarball), Verification: types.Verification{Hash: &hash}},
+				},
+			}},
+			out: out{
+				paths: []string{
+					"/opt/app",
+					"/opt/app/bin",
+					"/opt/app/bin/app",
+					"/opt/app/bin/app-link",
+					"/opt/app/bin/app-hard",
+					"/opt/app/share",
+					"/opt/app/share/doc",
+					"/opt/app/share/doc/README",
+				},
+				modes: map[string]os.FileMode{
+					"/opt/app":                  os.ModeDir | 0755,
+					"/opt/app/bin":              os.ModeDir | 0750,
+					"/opt/app/bin/app":          0755,
+					"/opt/app/share/doc/README": 0644,
+				},
+				uids: map[string]int{
+					"/opt/app/bin":              10,
+					"/opt/app/bin/app":          10,
+					"/opt/app/share/doc/README": 0,
+				},
+			},
+		},
+		{
+			in: in{archive: types.Archive{
+				Node: types.Node{Path: "/opt/app", User: &types.NodeUser{ID: &uid}},
+				ArchiveEmbedded1: types.ArchiveEmbedded1{
+					Format:   "tar",
+					Contents: types.FileContents{Source: dataURL(tarball)},
+					FileMode: &fileMode,
+				},
+			}},
+			out: out{
+				paths: []string{
+					"/opt/app",
+					"/opt/app/bin",
+					"/opt/app/bin/app",
+					"/opt/app/bin/app-link",
+					"/opt/app/bin/app-hard",
+					"/opt/app/share",
+					"/opt/app/share/doc",
+					"/opt/app/share/doc/README",
+				},
+				modes: map[string]os.FileMode{
+					"/opt/app/bin":     os.ModeDir | 0750,
+					"/opt/app/bin/app": 0600,
+				},
+				uids: map[string]int{
+					"/opt/app/bin":              500,
+					"/opt/app/share/doc/README": 500,
+				},
+			},
+		},
+		{
+			in: in{archive: types.Archive{
+				Node: types.Node{Path: "/opt/app"},
+				ArchiveEmbedded1: types.ArchiveEmbedded1{
+					Format:   "tar",
+					Contents: types.FileContents{Source: dataURL(tarball), Verification: types.Verification{Hash: &badHash}},
+				},
+			}},
+			out: out{err: true},
+		},
+		{
+			in: in{
+				archive: types.Archive{
+					Node: types.Node{Path: "/opt/app"},
+					ArchiveEmbedded1: types.ArchiveEmbedded1{
+						Format:   "tar",
+						Contents: types.FileContents{Source: dataURL(tarball)},
+					},
+				},
+				existing: map[string]string{"/opt/app/etc/config": "config"},
+			},
+			out: out{
+				paths: []string{
+					"/opt/app/bin",
+					"/opt/app/bin/app",
+					"/opt/app/bin/app-link",
+					"/opt/app/bin/app-hard",
+					"/opt/app/share",
+					"/opt/app/share/doc",
+					"/opt/app/share/doc/README",
+				},
+			},
+		},
+		{
+			in: in{
+				archive: types.Archive{
+					Node: types.Node{Path: "/opt/app", Overwrite: &no},
+					ArchiveEmbedded1: types.ArchiveEmbedded1{
+						Format:   "tar",
+						Contents: types.FileContents{Source: dataURL(tarball)},
+					},
+				},
+				existing: map[string]string{"/opt/app/bin/app": "old"},
+			},
+			out: out{err: true},
+		},
+		{
+			in: in{
+				archive: types.Archive{
+					Node: types.Node{Path: "/opt/app", Overwrite: &yes},
+					ArchiveEmbedded1: types.ArchiveEmbedded1{
+						Format:   "zip",
+						Contents: types.FileContents{Source: dataURL(makeZip(t, []testMember{{name: "run.sh", mode: 0700, contents: "#!/bin/sh"}}))},
+					},
+				},
+				existing: map[string]string{"/opt/app/bin/app": "old"},
+			},
+			out: out{
+				paths: []string{"/opt/app", "/opt/app/run.sh"},
+				modes: map[string]os.FileMode{"/opt/app/run.sh": 0700},
+			},
+		},
+		{
+			in: in{archive: types.Archive{
+				Node: types.Node{Path: "/opt/app"},
+				ArchiveEmbedded1: types.ArchiveEmbedded1{
+					Format: "tar",
+					Contents: types.FileContents{Source: dataURL(makeTar(t, []testMember{
+						{name: "../escape", typ: tar.TypeReg, mode: 0644},
+					}))},
+				},
+			}},
+			out: out{err: true},
+		},
+		{
+			in: in{archive: types.Archive{
+				Node: types.Node{Path: "/opt/app"},
+				ArchiveEmbedded1: types.ArchiveEmbedded1{
+					Format: "tar",
+					Contents: types.FileContents{Source: dataURL(makeTar(t, []testMember{
+						{name: "/etc/escape", typ: tar.TypeReg, mode: 0644},
+					}))},
+				},
+			}},
+			out: out{err: true},
+		},
+		{
+			in: in{archive: types.Archive{
+				Node: types.Node{Path: "/opt/app"},
+				ArchiveEmbedded1: types.ArchiveEmbedded1{
+					Format: "tar",
+					Contents: types.FileContents{Source: dataURL(makeTar(t, []testMember{
+						{name: "etc", typ: tar.TypeSymlink, linkname: "/etc"},
+						{name: "etc/escape", typ: tar.TypeReg, mode: 0644},
+					}))},
+				},
+			}},
+			out: out{err: true},
+		},
+		{
+			in: in{archive: types.Archive{
+				Node: types.Node{Path: "/opt/app"},
+				ArchiveEmbedded1: types.ArchiveEmbedded1{
+					Format: "zip",
+					Contents: types.FileContents{Source: dataURL(makeZip(t, []testMember{
+						{name: "up", typ: tar.TypeSymlink, linkname: ".."},
+						{name: "up/escape", mode: 0644},
+					}))},
+				},
+			}},
+			out: out{err: true},
+		},
+		{
+			in: in{archive: types.Archive{
+				Node: types.Node{Path: "/opt/app"},
+				ArchiveEmbedded1: types.ArchiveEmbedded1{
+					Format: "tar",
+					Contents: types.FileContents{Source: dataURL(makeTar(t, []testMember{
+						{name: "passwd", typ: tar.TypeLink, linkname: "../../etc/passwd"},
+					}))},
+				},
+			}},
+			out: out{err: true},
+		},
+	}
+
+	for i, test := range tests {
+		root, err := ioutil.TempDir("", "ignition-archive")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(root)
+		for path, contents := range test.in.existing {
+			if err := MkdirForFile(filepath.Join(root, path)); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(filepath.Join(root, path), []byte(contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		logger := log.New(false)
+		u := Util{
+			DestDir: root,
+			IsRoot:  true,
+			Fetcher: resource.Fetcher{Logger: &logger},
+			Logger:  &logger,
+		}
+		paths, err := u.ExtractArchive(&logger, test.in.archive)
+		if test.out.err != (err != nil) {
+			t.Errorf("#%d: bad error: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(test.out.paths, paths) {
+			t.Errorf("#%d: bad paths: want %v, got %v", i, test.out.paths, paths)
+		}
+		for path, mode := range test.out.modes {
+			info, err := os.Lstat(filepath.Join(root, path))
+			if err != nil {
+				t.Errorf("#%d: %v", i, err)
+			} else if info.Mode() != mode {
+				t.Errorf("#%d: bad mode of %q: want %v, got %v", i, path, mode, info.Mode())
+			}
+		}
+		for path, uid := range test.out.uids {
+			info, err := os.Lstat(filepath.Join(root, path))
+			if err != nil {
+				t.Errorf("#%d: %v", i, err)
+			} else if got := int(info.Sys().(*syscall.Stat_t).Uid); got != uid {
+				t.Errorf("#%d: bad owner of %q: want %d, got %d", i, path, uid, got)
+			}
+		}
+		for path := range test.in.existing {
+			_, err := os.Lstat(filepath.Join(root, path))
+			overwritten := test.in.archive.Overwrite != nil && *test.in.archive.Overwrite
+			if overwritten != os.IsNotExist(err) {
+				t.Errorf("#%d: bad state of existing %q: %v", i, path, err)
+			}
+		}
+	}
+}
@@ -31,6 +31,7 @@ import (
 	"strings"
 	"unsafe"
 
+	"github.com/flatcar/ignition/config/shared/partitions"
 	"github.com/flatcar/ignition/config/util"
 	"github.com/flatcar/ignition/internal/config/types"
 )
@@ -131,6 +132,9 @@ func DumpPartitionTable(device string) ([]types.Partition, error) {
 			Start:    util.IntToPtr(int(cInfo.start)),
 			Size:     util.IntToPtr(int(cInfo.size)),
 		}
+		for _, attr := range partitions.FormatAttributes(uint64(cInfo.attributes)) {
+			current.Attributes = append(current.Attributes, types.PartitionAttribute(attr))
+		}
 
 		output = append(output, current)
 	}
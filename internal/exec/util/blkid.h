@@ -45,6 +45,7 @@ struct partition_info {
 	char type_guid[PART_INFO_BUF_SIZE];
 	long long start; // needs to be 64 bit
 	long long size;  // to handle large partitions
+	unsigned long long attributes;
 	int number;
 };
 
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -34,6 +35,15 @@ func DeviceAlias(path string) string {
 	return filepath.Join(deviceAliasDir, filepath.Clean(path))
 }
 
+// RaidDevice returns the device node of the RAID array with the supplied
+// name, which is either a device path or a name in /dev/md.
+func RaidDevice(name string) string {
+	if strings.HasPrefix(name, "/dev") {
+		return name
+	}
+	return "/dev/md/" + name
+}
+
 // evalSymlinks wraps filepath.EvalSymlinks, retrying if it fails
 func evalSymlinks(path string) (res string, err error) {
 	for i := 0; i < retrySymlinkCount; i++ {
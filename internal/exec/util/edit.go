@@ -0,0 +1,274 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+
+	"github.com/flatcar/ignition/internal/config/types"
+)
+
+// EditFile applies the edits of f to the existing file at its path. The
+// edited contents are written to a temporary file which then replaces the
+// file, so a failed edit leaves the file as it was. The file keeps its owner,
+// mode and extended attributes unless f sets them. Since it is replaced,
+// other hard links to the file keep the old contents.
+func (u Util) EditFile(f types.File) error {
+	path, err := u.JoinPath(f.Path)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("cannot edit %q: the file does not exist", f.Path)
+	} else if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("cannot edit %q: it is a symbolic link, edit its target instead", f.Path)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("can only edit files: %q", f.Path)
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok && st.Nlink > 1 {
+		u.Warning("%q has %d hard links, the others keep the unedited contents", f.Path, st.Nlink-1)
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	edited, err := applyEdits(contents, f.Edits)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "tmp")
+	if err != nil {
+		return err
+	}
+	defer tmp.Close()
+	// removing the temporary file fails once it has been renamed, which is
+	// fine
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(edited); err != nil {
+		return err
+	}
+
+	defaultUid, defaultGid, mode := getFileOwnerAndMode(path)
+	uid, gid, err := u.ResolveNodeUidAndGid(f.Node, defaultUid, defaultGid)
+	if err != nil {
+		return err
+	}
+	if f.Mode != nil {
+		mode = os.FileMode(*f.Mode)
+	}
+	if err := os.Chown(tmp.Name(), uid, gid); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	if err := copyXattrs(path, tmp.Name()); err != nil {
+		return fmt.Errorf("cannot edit %q: %v", f.Path, err)
+	}
+	if err := u.SetXattrs(f.Node, tmp.Name()); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// applyEdits applies edits to contents in order. Replacements work on the
+// contents as a whole, with ^ and $ matching at the start and end of lines.
+// All other edits work on lines, and end the contents with a newline.
+func applyEdits(contents []byte, edits []types.FileEdit) ([]byte, error) {
+	text := string(contents)
+	for _, e := range edits {
+		var re *regexp.Regexp
+		if e.Regex != "" {
+			var err error
+			if re, err = regexp.Compile("(?m)" + e.Regex); err != nil {
+				return nil, fmt.Errorf("invalid regex %q: %v", e.Regex, err)
+			}
+		}
+
+		if e.Action == "replace" {
+			text = re.ReplaceAllString(text, e.Replacement)
+			continue
+		}
+
+		lines := splitLines(text)
+		switch e.Action {
+		case "ensureLine":
+			lines = ensureLine(lines, e.Line, re)
+		case "deleteLines":
+			lines = deleteLines(lines, re)
+		case "setKey":
+			lines = setKey(lines, e.Section, e.Key, e.Value)
+		case "unsetKey":
+			lines = unsetKey(lines, e.Section, e.Key)
+		default:
+			return nil, fmt.Errorf("unsupported edit action %q", e.Action)
+		}
+		text = joinLines(lines)
+	}
+	return []byte(text), nil
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+func joinLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// ensureLine adds line unless it is already present. If re is set, the first
+// line it matches is replaced instead of adding line at the end.
+func ensureLine(lines []string, line string, re *regexp.Regexp) []string {
+	for _, l := range lines {
+		if l == line {
+			return lines
+		}
+	}
+	if re != nil {
+		for i, l := range lines {
+			if re.MatchString(l) {
+				lines[i] = line
+				return lines
+			}
+		}
+	}
+	return append(lines, line)
+}
+
+func deleteLines(lines []string, re *regexp.Regexp) []string {
+	var res []string
+	for _, l := range lines {
+		if !re.MatchString(l) {
+			res = append(res, l)
+		}
+	}
+	return res
+}
+
+// iniSection returns the name of the section which l starts, if it is a
+// section header.
+func iniSection(l string) (string, bool) {
+	t := strings.TrimSpace(l)
+	if strings.HasPrefix(t, "[") && strings.HasSuffix(t, "]") {
+		return strings.TrimSpace(t[1 : len(t)-1]), true
+	}
+	return "", false
+}
+
+// iniKey returns the key which l assigns, or "" if l is a comment or no
+// assignment.
+func iniKey(l string) string {
+	t := strings.TrimSpace(l)
+	if t == "" || t[0] == '#' || t[0] == ';' {
+		return ""
+	}
+	i := strings.Index(t, "=")
+	if i < 0 {
+		return ""
+	}
+	return strings.TrimSpace(t[:i])
+}
+
+// setKey sets key to value in section. The first assignment of the key in the
+// section is replaced and any later ones are removed. Otherwise the key is
+// added after the last line of the section, which is added at the end if it
+// doesn't exist. The empty section holds the keys before the first header.
+func setKey(lines []string, section, key, value string) []string {
+	assignment := key + "=" + value
+	current := ""
+	seen := section == ""
+	// insert is where the key goes if it isn't assigned yet
+	insert := 0
+	found := false
+	var res []string
+	for _, l := range lines {
+		if name, ok := iniSection(l); ok {
+			current = name
+			if current == section {
+				seen = true
+				insert = len(res) + 1
+			}
+			res = append(res, l)
+			continue
+		}
+		if current != section {
+			res = append(res, l)
+			continue
+		}
+		if iniKey(l) == key {
+			if !found {
+				res = append(res, assignment)
+				found = true
+			}
+			continue
+		}
+		res = append(res, l)
+		if strings.TrimSpace(l) != "" {
+			insert = len(res)
+		}
+	}
+
+	switch {
+	case found:
+		return res
+	case seen:
+		res = append(res, "")
+		copy(res[insert+1:], res[insert:])
+		res[insert] = assignment
+		return res
+	default:
+		if len(res) > 0 && strings.TrimSpace(res[len(res)-1]) != "" {
+			res = append(res, "")
+		}
+		return append(res, "["+section+"]", assignment)
+	}
+}
+
+// unsetKey removes all assignments of key in section.
+func unsetKey(lines []string, section, key string) []string {
+	current := ""
+	var res []string
+	for _, l := range lines {
+		if name, ok := iniSection(l); ok {
+			current = name
+		} else if current == section && iniKey(l) == key {
+			continue
+		}
+		res = append(res, l)
+	}
+	return res
+}
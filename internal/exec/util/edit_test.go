@@ -0,0 +1,212 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/flatcar/ignition/internal/config/types"
+	"github.com/flatcar/ignition/internal/log"
+)
+
+const journaldConf = `#  This file is part of systemd.
+
+[Journal]
+#Storage=auto
+#Compress=yes
+SystemMaxUse=1G
+
+[Other]
+Storage=volatile
+`
+
+func TestApplyEdits(t *testing.T) {
+	type in struct {
+		contents string
+		edits    []types.FileEdit
+	}
+	type out struct {
+		contents string
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		// a key is added after the last line of its section
+		{
+			in: in{journaldConf, []types.FileEdit{
+				{Action: "setKey", Section: "Journal", Key: "Storage", Value: "persistent"},
+			}},
+			out: out{"#  This file is part of systemd.\n\n[Journal]\n#Storage=auto\n#Compress=yes\nSystemMaxUse=1G\nStorage=persistent\n\n[Other]\nStorage=volatile\n"},
+		},
+		// an existing key is replaced, later assignments are removed
+		{
+			in: in{"[Journal]\nSystemMaxUse = 1G\nStorage=auto\nSystemMaxUse=2G\n", []types.FileEdit{
+				{Action: "setKey", Section: "Journal", Key: "SystemMaxUse", Value: "4G"},
+			}},
+			out: out{"[Journal]\nSystemMaxUse=4G\nStorage=auto\n"},
+		},
+		// a missing section is added at the end
+		{
+			in: in{journaldConf, []types.FileEdit{
+				{Action: "setKey", Section: "Upload", Key: "URL", Value: "https://logs.example.com"},
+			}},
+			out: out{journaldConf + "\n[Upload]\nURL=https://logs.example.com\n"},
+		},
+		// the empty section holds the keys before the first header
+		{
+			in: in{"# comment\nA=1\n\n[Section]\nB=2\n", []types.FileEdit{
+				{Action: "setKey", Key: "C", Value: "3"},
+				{Action: "setKey", Key: "B", Value: "4"},
+			}},
+			out: out{"# comment\nA=1\nC=3\nB=4\n\n[Section]\nB=2\n"},
+		},
+		{
+			in: in{"", []types.FileEdit{
+				{Action: "setKey", Key: "A", Value: "1"},
+				{Action: "setKey", Section: "Section", Key: "B", Value: ""},
+			}},
+			out: out{"A=1\n\n[Section]\nB=\n"},
+		},
+		{
+			in: in{journaldConf, []types.FileEdit{
+				{Action: "unsetKey", Section: "Journal", Key: "SystemMaxUse"},
+				{Action: "unsetKey", Section: "Journal", Key: "Storage"},
+			}},
+			out: out{"#  This file is part of systemd.\n\n[Journal]\n#Storage=auto\n#Compress=yes\n\n[Other]\nStorage=volatile\n"},
+		},
+		// replacements can use submatches and see the whole contents
+		{
+			in: in{journaldConf, []types.FileEdit{
+				{Action: "replace", Regex: `^#(Compress)=.*$`, Replacement: "${1}=no"},
+				{Action: "replace", Regex: `\n\n\[Other\](\n.*)*`},
+			}},
+			out: out{"#  This file is part of systemd.\n\n[Journal]\n#Storage=auto\nCompress=no\nSystemMaxUse=1G"},
+		},
+		{
+			in: in{journaldConf, []types.FileEdit{
+				{Action: "deleteLines", Regex: `^#`},
+				{Action: "deleteLines", Regex: `^$`},
+			}},
+			out: out{"[Journal]\nSystemMaxUse=1G\n[Other]\nStorage=volatile\n"},
+		},
+		{
+			in: in{"nameserver 10.0.0.1", []types.FileEdit{
+				{Action: "ensureLine", Line: "nameserver 10.0.0.2"},
+				{Action: "ensureLine", Line: "nameserver 10.0.0.1"},
+			}},
+			out: out{"nameserver 10.0.0.1\nnameserver 10.0.0.2\n"},
+		},
+		// ensureLine replaces the first line the regex matches
+		{
+			in: in{"#PermitRootLogin yes\nPermitRootLogin prohibit-password\n", []types.FileEdit{
+				{Action: "ensureLine", Line: "PermitRootLogin no", Regex: `^#?PermitRootLogin\b`},
+			}},
+			out: out{"PermitRootLogin no\nPermitRootLogin prohibit-password\n"},
+		},
+		{
+			in: in{"PermitRootLogin no\n", []types.FileEdit{
+				{Action: "ensureLine", Line: "PermitRootLogin no", Regex: `^#?PermitRootLogin\b`},
+			}},
+			out: out{"PermitRootLogin no\n"},
+		},
+	}
+
+	for i, test := range tests {
+		contents, err := applyEdits([]byte(test.in.contents), test.in.edits)
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if string(contents) != test.out.contents {
+			t.Errorf("#%d: wanted %q, got %q", i, test.out.contents, contents)
+		}
+	}
+}
+
+func TestEditFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ignition-edit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.MkdirAll(filepath.Join(dir, "etc/systemd"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "etc/systemd/journald.conf")
+	if err := ioutil.WriteFile(path, []byte(journaldConf), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chown(path, 0, 4); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("journald.conf", filepath.Join(dir, "etc/systemd/link.conf")); err != nil {
+		t.Fatal(err)
+	}
+	xattrsSupported := syscall.Setxattr(path, "user.agent.origin", []byte("image"), 0) != syscall.ENOTSUP
+
+	logger := log.New(false)
+	u := Util{DestDir: dir, Logger: &logger}
+	edits := []types.FileEdit{{Action: "unsetKey", Section: "Other", Key: "Storage"}}
+
+	if err := u.EditFile(types.File{
+		Node:          types.Node{Path: "/etc/systemd/journald.conf"},
+		FileEmbedded1: types.FileEmbedded1{Edits: edits},
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "#  This file is part of systemd.\n\n[Journal]\n#Storage=auto\n#Compress=yes\nSystemMaxUse=1G\n\n[Other]\n"; string(contents) != want {
+		t.Errorf("wanted %q, got %q", want, contents)
+	}
+	uid, gid, mode := getFileOwnerAndMode(path)
+	if uid != 0 || gid != 4 || mode != 0640 {
+		t.Errorf("wanted owner 0:4 and mode 0640, got %d:%d and %o", uid, gid, mode)
+	}
+	if xattrsSupported {
+		if value := getxattr(t, path, "user.agent.origin"); string(value) != "image" {
+			t.Errorf("wanted xattr %q to be kept, got %q", "image", value)
+		}
+		if err := u.EditFile(types.File{
+			Node: types.Node{
+				Path:   "/etc/systemd/journald.conf",
+				Xattrs: []types.NodeXattr{{Name: "user.agent.origin", Value: "ignition"}},
+			},
+			FileEmbedded1: types.FileEmbedded1{Edits: edits},
+		}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if value := getxattr(t, path, "user.agent.origin"); string(value) != "ignition" {
+			t.Errorf("wanted xattr %q to be replaced, got %q", "ignition", value)
+		}
+	}
+
+	for _, p := range []string{"/etc/systemd/missing.conf", "/etc/systemd/link.conf", "/etc/systemd"} {
+		if err := u.EditFile(types.File{
+			Node:          types.Node{Path: p},
+			FileEmbedded1: types.FileEmbedded1{Edits: edits},
+		}); err == nil {
+			t.Errorf("%s: expected an error", p)
+		}
+	}
+}
@@ -46,6 +46,7 @@ type FetchOp struct {
 	FetchOptions resource.FetchOptions
 	Overwrite    *bool
 	Append       bool
+	Template     bool
 	Node         types.Node
 }
 
@@ -106,6 +107,7 @@ func (u Util) PrepareFetch(l *log.Logger, f types.File) *FetchOp {
 		Mode:      f.Mode,
 		Overwrite: f.Overwrite,
 		Append:    f.Append,
+		Template:  f.Contents.Template,
 		FetchOptions: resource.FetchOptions{
 			Hash:        hasher,
 			Compression: f.Contents.Compression,
@@ -146,7 +148,7 @@ func (u Util) WriteLink(s types.Link) error {
 		return err
 	}
 
-	return nil
+	return u.SetXattrs(s.Node, path)
 }
 
 // PerformFetch performs a fetch operation generated by PrepareFetch, retrieving
@@ -204,6 +206,15 @@ func (u Util) PerformFetch(f *FetchOp) error {
 		return err
 	}
 
+	// The hash covers the template, so the file is rendered after it
+	// has been verified.
+	if f.Template {
+		if err := u.renderFile(f.Path, tmp); err != nil {
+			u.Crit("Error rendering file %q: %v", f.Path, err)
+			return err
+		}
+	}
+
 	if f.Append {
 		// Make sure that we're appending to a file
 		finfo, err := os.Lstat(path)
@@ -248,6 +259,9 @@ func (u Util) PerformFetch(f *FetchOp) error {
 		if err = os.Chmod(targetFile.Name(), mode); err != nil {
 			return err
 		}
+		if err = u.SetXattrs(f.Node, targetFile.Name()); err != nil {
+			return err
+		}
 	} else {
 		// XXX(vc): Note that we assume to be operating on the file we just wrote, this is only guaranteed
 		// by using syscall.Fchown() and syscall.Fchmod()
@@ -272,6 +286,11 @@ func (u Util) PerformFetch(f *FetchOp) error {
 			return err
 		}
 
+		// Set extended attributes after chown, which clears file capabilities.
+		if err = u.SetXattrs(f.Node, tmp.Name()); err != nil {
+			return err
+		}
+
 		if err = os.Rename(tmp.Name(), path); err != nil {
 			return err
 		}
@@ -0,0 +1,138 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/flatcar/ignition/internal/distro"
+	"github.com/flatcar/ignition/internal/resource"
+)
+
+var (
+	// cmdlinePath and dmiDir are variables so the tests can point them
+	// elsewhere.
+	cmdlinePath = distro.KernelCmdlinePath()
+	dmiDir      = "/sys/class/dmi/id"
+
+	// cmdlineKeyReplacer turns kernel parameters into valid template keys.
+	cmdlineKeyReplacer = strings.NewReplacer(".", "_", "-", "_")
+)
+
+// templateData is the data templates are rendered against. Each source is
+// only read when a template references it, so templates which don't use the
+// provider metadata don't need the metadata service.
+type templateData struct {
+	fetcher resource.Fetcher
+}
+
+// Metadata returns the instance metadata reported by the provider. It is
+// empty on providers which don't report any.
+func (d templateData) Metadata() (map[string]string, error) {
+	if d.fetcher.Metadata == nil {
+		return map[string]string{}, nil
+	}
+	metadata, err := d.fetcher.Metadata()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the instance metadata: %v", err)
+	}
+	return metadata, nil
+}
+
+// Cmdline returns the kernel command line parameters, with dots and dashes in
+// their names replaced by underscores. Parameters without a value map to the
+// empty string.
+func (d templateData) Cmdline() (map[string]string, error) {
+	data, err := ioutil.ReadFile(cmdlinePath)
+	if err != nil {
+		return nil, err
+	}
+	params := map[string]string{}
+	for _, arg := range strings.Fields(string(data)) {
+		parts := strings.SplitN(arg, "=", 2)
+		value := ""
+		if len(parts) == 2 {
+			value = strings.Trim(parts[1], `"`)
+		}
+		params[cmdlineKeyReplacer.Replace(parts[0])] = value
+	}
+	return params, nil
+}
+
+// SMBIOS returns the fields the kernel exports from the SMBIOS tables, such as
+// sys_vendor or product_serial. Fields which can't be read are left out.
+func (d templateData) SMBIOS() (map[string]string, error) {
+	entries, err := ioutil.ReadDir(dmiDir)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]string{}
+	for _, e := range entries {
+		if !e.Mode().IsRegular() {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dmiDir, e.Name()))
+		if err != nil {
+			continue
+		}
+		fields[e.Name()] = strings.TrimSpace(string(data))
+	}
+	return fields, nil
+}
+
+// RenderTemplate renders contents as a Go text/template against the provider
+// metadata (.Metadata), the kernel command line (.Cmdline) and the SMBIOS
+// fields (.SMBIOS). Referencing a key which doesn't exist is an error rather
+// than rendering an empty string.
+func (u Util) RenderTemplate(name string, contents []byte) ([]byte, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(string(contents))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, templateData{fetcher: u.Fetcher}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// renderFile replaces the contents of f with its rendered contents.
+func (u Util) renderFile(name string, f *os.File) error {
+	if _, err := f.Seek(0, os.SEEK_SET); err != nil {
+		return err
+	}
+	contents, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	rendered, err := u.RenderTemplate(name, contents)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, os.SEEK_SET); err != nil {
+		return err
+	}
+	_, err = f.Write(rendered)
+	return err
+}
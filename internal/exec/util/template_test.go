@@ -0,0 +1,174 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/flatcar/ignition/internal/log"
+	"github.com/flatcar/ignition/internal/resource"
+
+	"github.com/vincent-petithory/dataurl"
+)
+
+func setupTemplateSources(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "ignition-template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldCmdlinePath, oldDMIDir := cmdlinePath, dmiDir
+	cmdlinePath = filepath.Join(dir, "cmdline")
+	dmiDir = filepath.Join(dir, "dmi")
+	if err := ioutil.WriteFile(cmdlinePath, []byte("BOOT_IMAGE=/vmlinuz quiet flatcar.oem.id=qemu console=\"ttyS0,115200n8\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(dmiDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dmiDir, "product_serial"), []byte("ABC123\n"), 0400); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dmiDir, "power"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() {
+		cmdlinePath, dmiDir = oldCmdlinePath, oldDMIDir
+		os.RemoveAll(dir)
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	_, cleanup := setupTemplateSources(t)
+	defer cleanup()
+
+	metadata := func() (map[string]string, error) {
+		return map[string]string{"hostname": "node1", "region": "eu-central-1"}, nil
+	}
+	failing := func() (map[string]string, error) {
+		return nil, errors.New("metadata service unreachable")
+	}
+
+	tests := []struct {
+		metadata func() (map[string]string, error)
+		in       string
+		out      string
+		err      bool
+	}{
+		{
+			metadata: metadata,
+			in:       "HOSTNAME={{ .Metadata.hostname }}\nREGION={{ .Metadata.region }}\n",
+			out:      "HOSTNAME=node1\nREGION=eu-central-1\n",
+		},
+		{
+			in:  "{{ .Cmdline.flatcar_oem_id }} {{ .Cmdline.console }} [{{ .Cmdline.quiet }}]",
+			out: "qemu ttyS0,115200n8 []",
+		},
+		{
+			in:  "serial: {{ .SMBIOS.product_serial }}",
+			out: "serial: ABC123",
+		},
+		{
+			// the metadata is only fetched if it is referenced
+			metadata: failing,
+			in:       "{{ .Cmdline.BOOT_IMAGE }}",
+			out:      "/vmlinuz",
+		},
+		{
+			metadata: metadata,
+			in:       "{{ .Metadata.zone }}",
+			err:      true,
+		},
+		{
+			in:  "{{ .Metadata.hostname }}",
+			err: true,
+		},
+		{
+			metadata: failing,
+			in:       "{{ .Metadata.hostname }}",
+			err:      true,
+		},
+		{
+			in:  "{{ .Cmdline.ignition_config_url }}",
+			err: true,
+		},
+		{
+			in:  "{{ .SMBIOS.power }}",
+			err: true,
+		},
+		{
+			in:  "{{ .Environment.HOME }}",
+			err: true,
+		},
+	}
+
+	logger := log.New(false)
+	for i, test := range tests {
+		u := Util{
+			Logger:  &logger,
+			Fetcher: resource.Fetcher{Logger: &logger, Metadata: test.metadata},
+		}
+		out, err := u.RenderTemplate("test", []byte(test.in))
+		if test.err {
+			if err == nil {
+				t.Errorf("#%d: expected an error, got %q", i, out)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if string(out) != test.out {
+			t.Errorf("#%d: wanted %q, got %q", i, test.out, out)
+		}
+	}
+}
+
+func TestPerformFetchTemplate(t *testing.T) {
+	dir, cleanup := setupTemplateSources(t)
+	defer cleanup()
+
+	logger := log.New(false)
+	u := Util{
+		DestDir: dir,
+		Logger:  &logger,
+		Fetcher: resource.Fetcher{Logger: &logger},
+	}
+	source, err := url.Parse(dataurl.EncodeBytes([]byte("{{ .SMBIOS.product_serial }}\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mode := 0644
+	if err := u.PerformFetch(&FetchOp{
+		Path:     "/etc/serial",
+		Url:      *source,
+		Mode:     &mode,
+		Template: true,
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "etc/serial"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != "ABC123\n" {
+		t.Errorf("wanted %q, got %q", "ABC123\n", contents)
+	}
+}
@@ -46,9 +46,10 @@ func FileFromSystemdUnit(unit types.Unit, runtime bool) (*FetchOp, error) {
 	}
 
 	return &FetchOp{
-		Path: filepath.Join(path, string(unit.Name)),
-		Url:  *u,
-		Mode: configUtil.IntToPtr(int(DefaultFilePermissions)),
+		Path:     filepath.Join(path, string(unit.Name)),
+		Url:      *u,
+		Mode:     configUtil.IntToPtr(int(DefaultFilePermissions)),
+		Template: unit.Template,
 	}, nil
 }
 
@@ -78,9 +79,10 @@ func FileFromSystemdUnitDropin(unit types.Unit, dropin types.SystemdDropin, runt
 	}
 
 	return &FetchOp{
-		Path: filepath.Join(path, string(dropin.Name)),
-		Url:  *u,
-		Mode: configUtil.IntToPtr(int(DefaultFilePermissions)),
+		Path:     filepath.Join(path, string(dropin.Name)),
+		Url:      *u,
+		Mode:     configUtil.IntToPtr(int(DefaultFilePermissions)),
+		Template: dropin.Template,
 	}, nil
 }
 
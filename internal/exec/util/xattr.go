@@ -0,0 +1,116 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/flatcar/ignition/config/shared/xattrs"
+	"github.com/flatcar/ignition/internal/config/types"
+)
+
+// SetXattrs sets the extended attributes and the SELinux context of n on path,
+// without following path if it is a symlink. Every attribute is logged as its
+// own operation, so failures are reported for the node they belong to.
+func (u Util) SetXattrs(n types.Node, path string) error {
+	for _, x := range n.Xattrs {
+		value, err := xattrs.DecodeValue(x.Value)
+		if err != nil {
+			return err
+		}
+		if err := u.LogOp(
+			func() error { return lsetxattr(path, x.Name, value) },
+			"setting xattr %q on %q", x.Name, n.Path,
+		); err != nil {
+			return fmt.Errorf("failed to set xattr %q on %q: %v", x.Name, n.Path, err)
+		}
+	}
+
+	if n.SELinuxContext != "" {
+		// like lsetfilecon(3), include the terminating NUL
+		value := append([]byte(n.SELinuxContext), 0)
+		if err := u.LogOp(
+			func() error { return lsetxattr(path, xattrs.SELinux, value) },
+			"setting SELinux context %q on %q", n.SELinuxContext, n.Path,
+		); err != nil {
+			return fmt.Errorf("failed to set SELinux context %q on %q: %v", n.SELinuxContext, n.Path, err)
+		}
+	}
+	return nil
+}
+
+// copyXattrs copies all extended attributes of the regular file src to dst,
+// including its SELinux label, capabilities and ACLs. Changing the owner of a
+// file clears its capabilities, so dst must be chowned before.
+func copyXattrs(src, dst string) error {
+	size, err := syscall.Listxattr(src, nil)
+	if err == syscall.ENOTSUP || (err == nil && size == 0) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	names := make([]byte, size)
+	if size, err = syscall.Listxattr(src, names); err != nil {
+		return err
+	}
+	for _, name := range strings.Split(strings.TrimSuffix(string(names[:size]), "\x00"), "\x00") {
+		value, err := readXattr(src, name)
+		if err != nil {
+			return fmt.Errorf("failed to read xattr %q: %v", name, err)
+		}
+		if err := syscall.Setxattr(dst, name, value, 0); err != nil {
+			return fmt.Errorf("failed to copy xattr %q: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func readXattr(path, name string) ([]byte, error) {
+	size, err := syscall.Getxattr(path, name, nil)
+	if err != nil {
+		return nil, err
+	}
+	value := make([]byte, size)
+	if size, err = syscall.Getxattr(path, name, value); err != nil {
+		return nil, err
+	}
+	return value[:size], nil
+}
+
+// lsetxattr wraps lsetxattr(2), which the syscall package doesn't provide.
+func lsetxattr(path, name string, value []byte) error {
+	pathPtr, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	namePtr, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return err
+	}
+	var valuePtr unsafe.Pointer
+	if len(value) > 0 {
+		valuePtr = unsafe.Pointer(&value[0])
+	}
+	_, _, errno := syscall.Syscall6(syscall.SYS_LSETXATTR,
+		uintptr(unsafe.Pointer(pathPtr)), uintptr(unsafe.Pointer(namePtr)),
+		uintptr(valuePtr), uintptr(len(value)), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
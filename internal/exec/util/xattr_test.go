@@ -0,0 +1,93 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/flatcar/ignition/internal/config/types"
+	"github.com/flatcar/ignition/internal/log"
+)
+
+func getxattr(t *testing.T, path, name string) []byte {
+	size, err := syscall.Getxattr(path, name, nil)
+	if err != nil {
+		t.Fatalf("getting xattr %q: %v", name, err)
+	}
+	value := make([]byte, size)
+	if _, err := syscall.Getxattr(path, name, value); err != nil {
+		t.Fatalf("getting xattr %q: %v", name, err)
+	}
+	return value
+}
+
+func TestSetXattrs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ignition-xattr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "helper")
+	if err := ioutil.WriteFile(path, nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Setxattr(path, "user.probe", nil, 0); err == syscall.ENOTSUP {
+		t.Skip("filesystem doesn't support user xattrs")
+	}
+
+	logger := log.New(false)
+	u := Util{Logger: &logger}
+	node := types.Node{
+		Path: "/usr/bin/helper",
+		Xattrs: []types.NodeXattr{
+			{Name: "user.agent.role", Value: "worker"},
+			{Name: "user.agent.key", Value: "0xdeadbeef"},
+			{Name: "user.agent.token", Value: "0sdG9rZW4="},
+		},
+	}
+	if err := u.SetXattrs(node, path); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][]byte{
+		"user.agent.role":  []byte("worker"),
+		"user.agent.key":   {0xde, 0xad, 0xbe, 0xef},
+		"user.agent.token": []byte("token"),
+	}
+	for name, value := range expected {
+		if got := getxattr(t, path, name); !reflect.DeepEqual(value, got) {
+			t.Errorf("bad value of %q: want %v, got %v", name, value, got)
+		}
+	}
+
+	// user xattrs are not permitted on symlinks, so this must fail instead
+	// of setting the xattr on the target
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink("helper", link); err != nil {
+		t.Fatal(err)
+	}
+	node = types.Node{Path: "/usr/bin/link", Xattrs: []types.NodeXattr{{Name: "user.agent.link", Value: "yes"}}}
+	if err := u.SetXattrs(node, link); err == nil {
+		t.Errorf("setting a user xattr on a symlink succeeded")
+	}
+	if _, err := syscall.Getxattr(path, "user.agent.link", nil); err == nil {
+		t.Errorf("xattr of symlink was set on its target")
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gpt
+
+import (
+	"io"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// ioctl requests from linux/fs.h
+const (
+	blkrrpart = 0x125f
+	blksszget = 0x1268
+)
+
+// DeviceGeometry returns the size of the device f in bytes and its logical
+// sector size.
+func DeviceGeometry(f *os.File) (int64, int, error) {
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, 0, err
+	}
+	sectorSize, err := logicalSectorSize(f)
+	if err != nil {
+		return 0, 0, err
+	}
+	return size, sectorSize, nil
+}
+
+// logicalSectorSize returns the logical sector size of the block device f.
+// Regular files, e.g. disk images, have 512 byte sectors.
+func logicalSectorSize(f *os.File) (int, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if fi.Mode().IsRegular() {
+		return 512, nil
+	}
+	var size int32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), blksszget, uintptr(unsafe.Pointer(&size))); errno != 0 {
+		return 0, errno
+	}
+	return int(size), nil
+}
+
+// RereadPartitionTable makes the kernel reread the partition table of the
+// block device f. It fails if a partition of f is in use.
+func RereadPartitionTable(f *os.File) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Mode().IsRegular() {
+		return nil
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), blkrrpart, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,464 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gpt reads and writes GUID partition tables, as described in
+// chapter 5 of the UEFI specification, without calling out to external
+// tools.
+package gpt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	headerSignature = "EFI PART"
+	headerRevision  = 0x00010000
+	headerSize      = 92
+
+	defaultEntryCount = 128
+	defaultEntrySize  = 128
+	maxEntryArraySize = 1024 * 1024
+	nameLength        = 36
+
+	mbrSignature          = 0xaa55
+	mbrPartitionOffset    = 446
+	mbrBootCodeLength     = 440
+	protectiveMBRType     = 0xee
+	protectiveMBRMaxCount = 0xffffffff
+)
+
+var (
+	ErrNoTable          = errors.New("no GUID partition table found")
+	ErrMBRPartitions    = errors.New("device has an MBR partition table")
+	ErrInvalidGUID      = errors.New("invalid GUID")
+	ErrDeviceTooSmall   = errors.New("device is too small for a GUID partition table")
+	ErrTooManyEntries   = errors.New("partition entry array doesn't fit into its sectors")
+	ErrBadEntrySize     = errors.New("unsupported partition entry size")
+	ErrBadHeaderSize    = errors.New("unsupported header size")
+	ErrBadHeaderCRC     = errors.New("header checksum mismatch")
+	ErrBadEntriesCRC    = errors.New("partition entry array checksum mismatch")
+	ErrBadHeaderAddress = errors.New("header is not at the expected sector")
+)
+
+// LinuxFilesystemType is the partition type GUID sgdisk uses when no type is
+// given.
+var LinuxFilesystemType = MustParseGUID("0FC63DAF-8483-4772-8E79-3D69D8477DE4")
+
+// GUID is a GUID in the byte order of its string form.
+type GUID [16]byte
+
+// ParseGUID parses the string form of a GUID, e.g.
+// 0FC63DAF-8483-4772-8E79-3D69D8477DE4.
+func ParseGUID(s string) (GUID, error) {
+	var g GUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return g, ErrInvalidGUID
+	}
+	b, err := hex.DecodeString(strings.Replace(s, "-", "", -1))
+	if err != nil {
+		return g, ErrInvalidGUID
+	}
+	copy(g[:], b)
+	return g, nil
+}
+
+// MustParseGUID is like ParseGUID but panics if s is invalid.
+func MustParseGUID(s string) GUID {
+	g, err := ParseGUID(s)
+	if err != nil {
+		panic(fmt.Sprintf("%q: %v", s, err))
+	}
+	return g
+}
+
+// NewGUID returns a random (version 4) GUID.
+func NewGUID() (GUID, error) {
+	var g GUID
+	if _, err := io.ReadFull(rand.Reader, g[:]); err != nil {
+		return g, err
+	}
+	g[6] = g[6]&0x0f | 0x40
+	g[8] = g[8]&0x3f | 0x80
+	return g, nil
+}
+
+// String returns the upper case string form of g, the way sgdisk and blkid
+// print GUIDs.
+func (g GUID) String() string {
+	s := strings.ToUpper(hex.EncodeToString(g[:]))
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:32]
+}
+
+// IsZero returns whether g is the all-zero GUID, which marks unused entries.
+func (g GUID) IsZero() bool {
+	return g == GUID{}
+}
+
+// encode returns g in the on-disk byte order, where the first three fields
+// are little endian.
+func (g GUID) encode() [16]byte {
+	var b [16]byte
+	b[0], b[1], b[2], b[3] = g[3], g[2], g[1], g[0]
+	b[4], b[5] = g[5], g[4]
+	b[6], b[7] = g[7], g[6]
+	copy(b[8:], g[8:])
+	return b
+}
+
+// decodeGUID is the inverse of GUID.encode.
+func decodeGUID(b []byte) GUID {
+	var g GUID
+	g[0], g[1], g[2], g[3] = b[3], b[2], b[1], b[0]
+	g[4], g[5] = b[5], b[4]
+	g[6], g[7] = b[7], b[6]
+	copy(g[8:], b[8:16])
+	return g
+}
+
+// Entry is a partition entry. Entries with a zero Type are unused.
+type Entry struct {
+	Type       GUID
+	GUID       GUID
+	FirstLBA   uint64
+	LastLBA    uint64
+	Attributes uint64
+	Name       string
+}
+
+// IsUsed returns whether e describes a partition.
+func (e Entry) IsUsed() bool {
+	return !e.Type.IsZero()
+}
+
+// Sectors returns the number of sectors of the partition e.
+func (e Entry) Sectors() uint64 {
+	return e.LastLBA - e.FirstLBA + 1
+}
+
+// Table is a GUID partition table. Entries are indexed by partition number
+// minus one.
+type Table struct {
+	SectorSize     int
+	LastLBA        uint64 // the last sector of the device
+	DiskGUID       GUID
+	FirstUsableLBA uint64
+	LastUsableLBA  uint64
+	BackupLBA      uint64 // the sector of the backup header
+	EntrySize      uint32
+	Entries        []Entry
+}
+
+// New returns an empty table for a device of size bytes, with the backup
+// header in the last sector.
+func New(size int64, sectorSize int) (*Table, error) {
+	guid, err := NewGUID()
+	if err != nil {
+		return nil, err
+	}
+	t := &Table{
+		SectorSize: sectorSize,
+		LastLBA:    uint64(size/int64(sectorSize)) - 1,
+		DiskGUID:   guid,
+		EntrySize:  defaultEntrySize,
+		Entries:    make([]Entry, defaultEntryCount),
+	}
+	entrySectors := t.entrySectors()
+	if t.LastLBA < 2*entrySectors+3 {
+		return nil, ErrDeviceTooSmall
+	}
+	t.FirstUsableLBA = 2 + entrySectors
+	t.MoveBackupHeader()
+	return t, nil
+}
+
+// MoveBackupHeader moves the backup header and its partition entries to the
+// end of the device, making the space between them and the old location
+// usable.
+func (t *Table) MoveBackupHeader() {
+	t.BackupLBA = t.LastLBA
+	t.LastUsableLBA = t.LastLBA - t.entrySectors() - 1
+}
+
+// entrySectors returns the number of sectors taken by one copy of the
+// partition entry array.
+func (t *Table) entrySectors() uint64 {
+	size := uint64(len(t.Entries)) * uint64(t.EntrySize)
+	return (size + uint64(t.SectorSize) - 1) / uint64(t.SectorSize)
+}
+
+// Read reads the table of the device r of size bytes. If the primary header
+// or its entries are corrupt, the backup header is used. ErrNoTable is
+// returned if neither header is valid. If the device has no GPT but an MBR
+// partition table, ErrMBRPartitions is returned instead.
+func Read(r io.ReaderAt, size int64, sectorSize int) (*Table, error) {
+	lastLBA := uint64(size/int64(sectorSize)) - 1
+	t, err := readHeader(r, 1, lastLBA, sectorSize)
+	if err == nil {
+		return t, nil
+	}
+	if backup, err := readHeader(r, lastLBA, lastLBA, sectorSize); err == nil {
+		return backup, nil
+	}
+
+	mbr := make([]byte, 512)
+	if _, err := r.ReadAt(mbr, 0); err != nil {
+		return nil, err
+	}
+	if hasMBRPartitions(mbr) {
+		return nil, ErrMBRPartitions
+	}
+	return nil, ErrNoTable
+}
+
+// readHeader reads and verifies the header in sector lba and its entries.
+func readHeader(r io.ReaderAt, lba, lastLBA uint64, sectorSize int) (*Table, error) {
+	buf := make([]byte, sectorSize)
+	if _, err := r.ReadAt(buf, int64(lba)*int64(sectorSize)); err != nil {
+		return nil, err
+	}
+	if string(buf[0:8]) != headerSignature {
+		return nil, ErrNoTable
+	}
+	size := binary.LittleEndian.Uint32(buf[12:16])
+	if size < headerSize || int(size) > sectorSize {
+		return nil, ErrBadHeaderSize
+	}
+	hdr := append([]byte{}, buf[:size]...)
+	sum := binary.LittleEndian.Uint32(hdr[16:20])
+	binary.LittleEndian.PutUint32(hdr[16:20], 0)
+	if crc32.ChecksumIEEE(hdr) != sum {
+		return nil, ErrBadHeaderCRC
+	}
+	if binary.LittleEndian.Uint64(hdr[24:32]) != lba {
+		return nil, ErrBadHeaderAddress
+	}
+
+	t := &Table{
+		SectorSize:     sectorSize,
+		LastLBA:        lastLBA,
+		FirstUsableLBA: binary.LittleEndian.Uint64(hdr[40:48]),
+		LastUsableLBA:  binary.LittleEndian.Uint64(hdr[48:56]),
+		DiskGUID:       decodeGUID(hdr[56:72]),
+		EntrySize:      binary.LittleEndian.Uint32(hdr[84:88]),
+	}
+	if lba == 1 {
+		t.BackupLBA = binary.LittleEndian.Uint64(hdr[32:40])
+	} else {
+		t.BackupLBA = lba
+	}
+	if t.EntrySize < 128 || t.EntrySize%8 != 0 {
+		return nil, ErrBadEntrySize
+	}
+
+	count := binary.LittleEndian.Uint32(hdr[80:84])
+	if uint64(count)*uint64(t.EntrySize) > maxEntryArraySize {
+		return nil, ErrTooManyEntries
+	}
+	entries := make([]byte, uint64(count)*uint64(t.EntrySize))
+	if _, err := r.ReadAt(entries, int64(binary.LittleEndian.Uint64(hdr[72:80]))*int64(sectorSize)); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(entries) != binary.LittleEndian.Uint32(hdr[88:92]) {
+		return nil, ErrBadEntriesCRC
+	}
+	t.Entries = make([]Entry, count)
+	for i := range t.Entries {
+		t.Entries[i] = decodeEntry(entries[i*int(t.EntrySize):])
+	}
+	return t, nil
+}
+
+func decodeEntry(b []byte) Entry {
+	name := make([]uint16, nameLength)
+	for i := range name {
+		name[i] = binary.LittleEndian.Uint16(b[56+2*i:])
+	}
+	for len(name) > 0 && name[len(name)-1] == 0 {
+		name = name[:len(name)-1]
+	}
+	return Entry{
+		Type:       decodeGUID(b[0:16]),
+		GUID:       decodeGUID(b[16:32]),
+		FirstLBA:   binary.LittleEndian.Uint64(b[32:40]),
+		LastLBA:    binary.LittleEndian.Uint64(b[40:48]),
+		Attributes: binary.LittleEndian.Uint64(b[48:56]),
+		Name:       string(utf16.Decode(name)),
+	}
+}
+
+func (e Entry) encode(b []byte) {
+	typ := e.Type.encode()
+	guid := e.GUID.encode()
+	copy(b[0:16], typ[:])
+	copy(b[16:32], guid[:])
+	binary.LittleEndian.PutUint64(b[32:40], e.FirstLBA)
+	binary.LittleEndian.PutUint64(b[40:48], e.LastLBA)
+	binary.LittleEndian.PutUint64(b[48:56], e.Attributes)
+	for i, c := range utf16.Encode([]rune(e.Name)) {
+		if i == nameLength {
+			break
+		}
+		binary.LittleEndian.PutUint16(b[56+2*i:], c)
+	}
+}
+
+// encodeEntries returns the partition entry array of t.
+func (t *Table) encodeEntries() []byte {
+	buf := make([]byte, t.entrySectors()*uint64(t.SectorSize))
+	for i, e := range t.Entries {
+		if e.IsUsed() {
+			e.encode(buf[i*int(t.EntrySize):])
+		}
+	}
+	return buf
+}
+
+// encodeHeader returns the header sector located at lba, with its entries at
+// entryLBA and the other header at alternateLBA.
+func (t *Table) encodeHeader(lba, alternateLBA, entryLBA uint64, entriesCRC uint32) []byte {
+	buf := make([]byte, t.SectorSize)
+	copy(buf[0:8], headerSignature)
+	binary.LittleEndian.PutUint32(buf[8:12], headerRevision)
+	binary.LittleEndian.PutUint32(buf[12:16], headerSize)
+	binary.LittleEndian.PutUint64(buf[24:32], lba)
+	binary.LittleEndian.PutUint64(buf[32:40], alternateLBA)
+	binary.LittleEndian.PutUint64(buf[40:48], t.FirstUsableLBA)
+	binary.LittleEndian.PutUint64(buf[48:56], t.LastUsableLBA)
+	guid := t.DiskGUID.encode()
+	copy(buf[56:72], guid[:])
+	binary.LittleEndian.PutUint64(buf[72:80], entryLBA)
+	binary.LittleEndian.PutUint32(buf[80:84], uint32(len(t.Entries)))
+	binary.LittleEndian.PutUint32(buf[84:88], t.EntrySize)
+	binary.LittleEndian.PutUint32(buf[88:92], entriesCRC)
+	binary.LittleEndian.PutUint32(buf[16:20], crc32.ChecksumIEEE(buf[:headerSize]))
+	return buf
+}
+
+// Write writes t to the device rw: the protective MBR, the primary header
+// and entries at the start of the device, and the backup entries and header
+// ending at t.BackupLBA. The boot code of an existing MBR is kept.
+func (t *Table) Write(rw ReadWriterAt) error {
+	entrySectors := t.entrySectors()
+	if t.FirstUsableLBA < 2+entrySectors || t.LastUsableLBA+entrySectors >= t.BackupLBA {
+		return ErrTooManyEntries
+	}
+
+	mbr := make([]byte, t.SectorSize)
+	if _, err := rw.ReadAt(mbr[:512], 0); err != nil {
+		return err
+	}
+	if err := t.writeSector(rw, 0, t.protectiveMBR(mbr[:mbrBootCodeLength])); err != nil {
+		return err
+	}
+
+	entries := t.encodeEntries()
+	entriesCRC := crc32.ChecksumIEEE(entries[:len(t.Entries)*int(t.EntrySize)])
+	backupEntryLBA := t.BackupLBA - entrySectors
+
+	if err := t.writeSector(rw, 1, t.encodeHeader(1, t.BackupLBA, 2, entriesCRC)); err != nil {
+		return err
+	}
+	if err := t.writeSector(rw, 2, entries); err != nil {
+		return err
+	}
+	if err := t.writeSector(rw, backupEntryLBA, entries); err != nil {
+		return err
+	}
+	return t.writeSector(rw, t.BackupLBA, t.encodeHeader(t.BackupLBA, 1, backupEntryLBA, entriesCRC))
+}
+
+// protectiveMBR returns an MBR with bootCode and a single partition of type
+// 0xee covering the device.
+func (t *Table) protectiveMBR(bootCode []byte) []byte {
+	mbr := make([]byte, t.SectorSize)
+	copy(mbr, bootCode)
+	p := mbr[mbrPartitionOffset:]
+	// CHS addresses of the start (sector 2) and of the end (out of range)
+	copy(p[1:4], []byte{0x00, 0x02, 0x00})
+	p[4] = protectiveMBRType
+	copy(p[5:8], []byte{0xff, 0xff, 0xff})
+	binary.LittleEndian.PutUint32(p[8:12], 1)
+	count := t.LastLBA
+	if count > protectiveMBRMaxCount {
+		count = protectiveMBRMaxCount
+	}
+	binary.LittleEndian.PutUint32(p[12:16], uint32(count))
+	binary.LittleEndian.PutUint16(mbr[510:512], mbrSignature)
+	return mbr
+}
+
+// hasMBRPartitions returns whether mbr is a valid MBR with partitions other
+// than a protective one.
+func hasMBRPartitions(mbr []byte) bool {
+	if binary.LittleEndian.Uint16(mbr[510:512]) != mbrSignature {
+		return false
+	}
+	for i := 0; i < 4; i++ {
+		typ := mbr[mbrPartitionOffset+16*i+4]
+		if typ != 0 && typ != protectiveMBRType {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Table) writeSector(w io.WriterAt, lba uint64, data []byte) error {
+	_, err := w.WriteAt(data, int64(lba)*int64(t.SectorSize))
+	return err
+}
+
+// ReadWriterAt is a device tables are read from and written to.
+type ReadWriterAt interface {
+	io.ReaderAt
+	io.WriterAt
+}
+
+// Zap destroys any GPT and MBR on the device rw of size bytes by zeroing the
+// MBR, the primary header and entries, and the backup header and entries,
+// both at the end of the device and wherever the primary header says the
+// backup is.
+func Zap(rw ReadWriterAt, size int64, sectorSize int) error {
+	lastLBA := uint64(size/int64(sectorSize)) - 1
+	// room for the default number of entries in any sector size
+	zapSectors := uint64(2 + defaultEntryCount*defaultEntrySize/sectorSize)
+	if lastLBA < 2*zapSectors {
+		return ErrDeviceTooSmall
+	}
+	ranges := [][2]uint64{
+		{0, zapSectors},
+		{lastLBA - zapSectors + 1, zapSectors},
+	}
+	if t, err := readHeader(rw, 1, lastLBA, sectorSize); err == nil && t.BackupLBA < lastLBA {
+		entrySectors := t.entrySectors()
+		if t.BackupLBA > entrySectors {
+			ranges = append(ranges, [2]uint64{t.BackupLBA - entrySectors, entrySectors + 1})
+		}
+	}
+	for _, r := range ranges {
+		zero := bytes.Repeat([]byte{0}, int(r[1])*sectorSize)
+		if _, err := rw.WriteAt(zero, int64(r[0])*int64(sectorSize)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gpt
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// newImage returns an empty image file of size bytes, which is removed when
+// the test ends.
+func newImage(t *testing.T, size int64) *os.File {
+	f, err := ioutil.TempFile("", "ignition-gpt-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		f.Close()
+		os.Remove(f.Name())
+	})
+	if err := f.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestGUID(t *testing.T) {
+	type in struct {
+		guid string
+	}
+	type out struct {
+		guid    string
+		encoded []byte
+		err     error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in: in{"0FC63DAF-8483-4772-8E79-3D69D8477DE4"},
+			out: out{
+				guid:    "0FC63DAF-8483-4772-8E79-3D69D8477DE4",
+				encoded: []byte{0xaf, 0x3d, 0xc6, 0x0f, 0x83, 0x84, 0x72, 0x47, 0x8e, 0x79, 0x3d, 0x69, 0xd8, 0x47, 0x7d, 0xe4},
+			},
+		},
+		{
+			in: in{"c12a7328-f81f-11d2-ba4b-00a0c93ec93b"},
+			out: out{
+				guid:    "C12A7328-F81F-11D2-BA4B-00A0C93EC93B",
+				encoded: []byte{0x28, 0x73, 0x2a, 0xc1, 0x1f, 0xf8, 0xd2, 0x11, 0xba, 0x4b, 0x00, 0xa0, 0xc9, 0x3e, 0xc9, 0x3b},
+			},
+		},
+		{
+			in:  in{"0FC63DAF84834772-8E79-3D69D8477DE4"},
+			out: out{err: ErrInvalidGUID},
+		},
+		{
+			in:  in{"0FC63DAF-8483-4772-8E79-3D69D8477DEX"},
+			out: out{err: ErrInvalidGUID},
+		},
+	}
+
+	for i, test := range tests {
+		g, err := ParseGUID(test.in.guid)
+		if err != test.out.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+		if err != nil {
+			continue
+		}
+		if g.String() != test.out.guid {
+			t.Errorf("#%d: bad string: want %s, got %s", i, test.out.guid, g)
+		}
+		encoded := g.encode()
+		if !reflect.DeepEqual(encoded[:], test.out.encoded) {
+			t.Errorf("#%d: bad encoding: want %x, got %x", i, test.out.encoded, encoded)
+		}
+		if decodeGUID(encoded[:]) != g {
+			t.Errorf("#%d: decoding didn't round trip", i)
+		}
+	}
+}
+
+func TestNewGUID(t *testing.T) {
+	g, err := NewGUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.IsZero() || g[6]>>4 != 4 || g[8]>>6 != 2 {
+		t.Errorf("bad random GUID %s", g)
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	const size = 64 * 1024 * 1024
+	f := newImage(t, size)
+
+	table, err := New(size, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.FirstUsableLBA != 34 || table.LastUsableLBA != 131038 || table.BackupLBA != 131071 {
+		t.Fatalf("bad layout: usable %d-%d, backup at %d", table.FirstUsableLBA, table.LastUsableLBA, table.BackupLBA)
+	}
+	table.Entries[0] = Entry{
+		Type:     LinuxFilesystemType,
+		GUID:     MustParseGUID("5AD6E5A8-7F51-4B6F-9F2E-C1B6AAB9AB5C"),
+		FirstLBA: 2048,
+		LastLBA:  4095,
+		Name:     "ROOT",
+	}
+	table.Entries[3] = Entry{
+		Type:       MustParseGUID("C12A7328-F81F-11D2-BA4B-00A0C93EC93B"),
+		GUID:       MustParseGUID("9C43D3B6-4EAA-4DEC-9DB5-B0D1FA8DE6B9"),
+		FirstLBA:   4096,
+		LastLBA:    8191,
+		Attributes: 1 << 60,
+		Name:       "EFI-SYSTEM äöü",
+	}
+	if err := table.Write(f); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := Read(f, size, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(table, read) {
+		t.Errorf("bad table: want %+v, got %+v", table, read)
+	}
+
+	// protective MBR
+	mbr := make([]byte, 512)
+	if _, err := f.ReadAt(mbr, 0); err != nil {
+		t.Fatal(err)
+	}
+	if mbr[510] != 0x55 || mbr[511] != 0xaa {
+		t.Errorf("bad MBR signature %x", mbr[510:])
+	}
+	p := mbr[446:462]
+	if p[4] != 0xee || binary.LittleEndian.Uint32(p[8:12]) != 1 || binary.LittleEndian.Uint32(p[12:16]) != 131071 {
+		t.Errorf("bad protective partition %x", p)
+	}
+	if hasMBRPartitions(mbr) {
+		t.Errorf("protective MBR has partitions")
+	}
+
+	// the backup header is used if the primary one is corrupt
+	if _, err := f.WriteAt([]byte("garbage"), 512+60); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readHeader(f, 1, 131071, 512); err != ErrBadHeaderCRC {
+		t.Errorf("corrupt primary header wasn't detected: %v", err)
+	}
+	backup, err := Read(f, size, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(table.Entries, backup.Entries) || backup.BackupLBA != 131071 {
+		t.Errorf("bad backup table: want %+v, got %+v", table, backup)
+	}
+}
+
+func TestWriteKeepsBootCode(t *testing.T) {
+	const size = 8 * 1024 * 1024
+	f := newImage(t, size)
+	bootCode := []byte{0xeb, 0x63, 0x90}
+	if _, err := f.WriteAt(bootCode, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	table, err := New(size, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Write(f); err != nil {
+		t.Fatal(err)
+	}
+
+	mbr := make([]byte, 3)
+	if _, err := f.ReadAt(mbr, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(mbr, bootCode) {
+		t.Errorf("bad boot code: want %x, got %x", bootCode, mbr)
+	}
+}
+
+func TestLargeSectors(t *testing.T) {
+	const size = 64 * 1024 * 1024
+	f := newImage(t, size)
+
+	table, err := New(size, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 128 entries of 128 bytes take 4 sectors of 4096 bytes
+	if table.FirstUsableLBA != 6 || table.LastUsableLBA != 16378 || table.BackupLBA != 16383 {
+		t.Fatalf("bad layout: usable %d-%d, backup at %d", table.FirstUsableLBA, table.LastUsableLBA, table.BackupLBA)
+	}
+	if table.Alignment() != 256 {
+		t.Errorf("bad alignment %d", table.Alignment())
+	}
+	if err := table.Write(f); err != nil {
+		t.Fatal(err)
+	}
+	read, err := Read(f, size, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(table, read) {
+		t.Errorf("bad table: want %+v, got %+v", table, read)
+	}
+}
+
+func TestReadWithoutTable(t *testing.T) {
+	const size = 8 * 1024 * 1024
+	f := newImage(t, size)
+	if _, err := Read(f, size, 512); err != ErrNoTable {
+		t.Errorf("bad error for empty device: want %v, got %v", ErrNoTable, err)
+	}
+
+	// an MBR with a Linux partition
+	mbr := make([]byte, 512)
+	mbr[446+4] = 0x83
+	mbr[510], mbr[511] = 0x55, 0xaa
+	if _, err := f.WriteAt(mbr, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Read(f, size, 512); err != ErrMBRPartitions {
+		t.Errorf("bad error for MBR: want %v, got %v", ErrMBRPartitions, err)
+	}
+}
+
+func TestZap(t *testing.T) {
+	const size = 64 * 1024 * 1024
+	f := newImage(t, size)
+
+	// a table written for a smaller disk has its backup in the middle
+	table, err := New(size/2, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table.LastLBA = size/512 - 1
+	if err := table.Write(f); err != nil {
+		t.Fatal(err)
+	}
+	if err := Zap(f, size, 512); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Read(f, size, 512); err != ErrNoTable {
+		t.Errorf("bad error after zapping: want %v, got %v", ErrNoTable, err)
+	}
+	if _, err := readHeader(f, table.BackupLBA, table.LastLBA, 512); err != ErrNoTable {
+		t.Errorf("backup header survived zapping: %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gpt
+
+import (
+	"fmt"
+	"sort"
+)
+
+// segment is a range of free sectors, both ends inclusive.
+type segment struct {
+	first, last uint64
+}
+
+func (s segment) sectors() uint64 {
+	return s.last - s.first + 1
+}
+
+// freeSegments returns the ranges of usable sectors not taken by any
+// partition, in ascending order.
+func (t *Table) freeSegments() []segment {
+	used := []Entry{}
+	for _, e := range t.Entries {
+		if e.IsUsed() {
+			used = append(used, e)
+		}
+	}
+	sort.Slice(used, func(i, j int) bool { return used[i].FirstLBA < used[j].FirstLBA })
+
+	var free []segment
+	next := t.FirstUsableLBA
+	for _, e := range used {
+		if e.FirstLBA > next {
+			free = append(free, segment{next, e.FirstLBA - 1})
+		}
+		if e.LastLBA+1 > next {
+			next = e.LastLBA + 1
+		}
+	}
+	if next <= t.LastUsableLBA {
+		free = append(free, segment{next, t.LastUsableLBA})
+	}
+	return free
+}
+
+// freeSegmentAt returns the free segment containing lba.
+func (t *Table) freeSegmentAt(lba uint64) (segment, bool) {
+	for _, s := range t.freeSegments() {
+		if lba >= s.first && lba <= s.last {
+			return s, true
+		}
+	}
+	return segment{}, false
+}
+
+// Alignment returns the number of sectors partition starts are aligned to
+// by default. Like sgdisk, this is 1 MiB, reduced to the largest power of
+// two all existing partitions are aligned to.
+func (t *Table) Alignment() uint64 {
+	align := uint64(1024 * 1024 / t.SectorSize)
+	if align == 0 {
+		align = 1
+	}
+	for _, e := range t.Entries {
+		if !e.IsUsed() {
+			continue
+		}
+		for align > 1 && e.FirstLBA%align != 0 {
+			align /= 2
+		}
+	}
+	return align
+}
+
+// DefaultStart returns the sector a partition starts at if no start is
+// given: the first aligned sector of the largest free segment. If several
+// segments are the largest, the first one is used.
+func (t *Table) DefaultStart() (uint64, error) {
+	var largest segment
+	found := false
+	for _, s := range t.freeSegments() {
+		if !found || s.sectors() > largest.sectors() {
+			largest = s
+			found = true
+		}
+	}
+	if !found {
+		return 0, fmt.Errorf("no free space left")
+	}
+	align := t.Alignment()
+	if start := (largest.first + align - 1) / align * align; start <= largest.last {
+		return start, nil
+	}
+	return largest.first, nil
+}
+
+// FirstFreeNumber returns the lowest partition number not in use.
+func (t *Table) FirstFreeNumber() (int, error) {
+	for i, e := range t.Entries {
+		if !e.IsUsed() {
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("all %d partition entries are in use", len(t.Entries))
+}
+
+// AddPartition adds e as partition number num, spanning sectors sectors. If
+// e.FirstLBA is 0, the partition starts at DefaultStart. If sectors is 0,
+// the partition fills the free segment it starts in. The partition has to
+// fit into a single free segment. The added entry is returned.
+func (t *Table) AddPartition(num int, e Entry, sectors uint64) (Entry, error) {
+	if num < 1 || num > len(t.Entries) {
+		return e, fmt.Errorf("partition number %d is out of range 1-%d", num, len(t.Entries))
+	}
+	if t.Entries[num-1].IsUsed() {
+		return e, fmt.Errorf("partition %d already exists", num)
+	}
+	if e.FirstLBA == 0 {
+		start, err := t.DefaultStart()
+		if err != nil {
+			return e, fmt.Errorf("partition %d: %v", num, err)
+		}
+		e.FirstLBA = start
+	}
+	free, ok := t.freeSegmentAt(e.FirstLBA)
+	if !ok {
+		return e, fmt.Errorf("partition %d: start sector %d is not free", num, e.FirstLBA)
+	}
+	if sectors == 0 {
+		e.LastLBA = free.last
+	} else {
+		e.LastLBA = e.FirstLBA + sectors - 1
+	}
+	if e.LastLBA > free.last {
+		return e, fmt.Errorf("partition %d: sectors %d-%d don't fit into free sectors %d-%d", num, e.FirstLBA, e.LastLBA, free.first, free.last)
+	}
+	t.Entries[num-1] = e
+	return e, nil
+}
+
+// DeletePartition removes partition number num.
+func (t *Table) DeletePartition(num int) error {
+	if num < 1 || num > len(t.Entries) || !t.Entries[num-1].IsUsed() {
+		return fmt.Errorf("partition %d doesn't exist", num)
+	}
+	t.Entries[num-1] = Entry{}
+	return nil
+}
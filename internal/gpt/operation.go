@@ -0,0 +1,260 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gpt
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/flatcar/ignition/internal/config/types"
+	"github.com/flatcar/ignition/internal/log"
+	"github.com/flatcar/ignition/internal/partitioners"
+)
+
+// Operation is a partitioners.Operation which reads and writes the table
+// itself. It follows the semantics of the sgdisk command line Ignition used
+// before, e.g. default starts are aligned the same way.
+type Operation struct {
+	logger    *log.Logger
+	dev       string
+	wipe      bool
+	moveHdr   bool
+	parts     []types.Partition
+	deletions []int
+	infos     []int
+}
+
+// Begin begins an operation on the device dev.
+func Begin(logger *log.Logger, dev string) *Operation {
+	return &Operation{logger: logger, dev: dev}
+}
+
+// CreatePartition adds the supplied partition to the list of partitions to be created as part of an operation.
+func (op *Operation) CreatePartition(p types.Partition) {
+	op.parts = append(op.parts, p)
+}
+
+func (op *Operation) DeletePartition(num int) {
+	op.deletions = append(op.deletions, num)
+}
+
+func (op *Operation) Info(num int) {
+	op.infos = append(op.infos, num)
+}
+
+// WipeTable toggles if the table is to be wiped first when commiting this operation.
+func (op *Operation) WipeTable(wipe bool) {
+	op.wipe = wipe
+}
+
+// MoveSecondHeader toggles if the backup GPT header is moved to the end of
+// the disk before any partitions are changed.
+func (op *Operation) MoveSecondHeader(move bool) {
+	op.moveHdr = move
+}
+
+// Creations returns the partitions to be created as part of an operation.
+func (op *Operation) Creations() []types.Partition {
+	return op.parts
+}
+
+// Deletions returns the numbers of the partitions to be deleted as part of an
+// operation.
+func (op *Operation) Deletions() []int {
+	return op.deletions
+}
+
+// Pretend reads the table of the device and applies the operation to it in
+// memory. A table which is wiped is pretended to be empty.
+func (op *Operation) Pretend() (map[int]partitioners.Dimensions, error) {
+	f, err := os.Open(op.dev)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	t, err := op.readTable(f, op.wipe)
+	if err != nil {
+		return nil, err
+	}
+	if err := op.apply(t); err != nil {
+		return nil, err
+	}
+
+	dims := map[int]partitioners.Dimensions{}
+	for _, num := range op.infos {
+		if num < 1 || num > len(t.Entries) || !t.Entries[num-1].IsUsed() {
+			return nil, fmt.Errorf("partition %d doesn't exist", num)
+		}
+		e := t.Entries[num-1]
+		dims[num] = partitioners.Dimensions{
+			Start: int(e.FirstLBA),
+			Size:  int(e.Sectors()),
+		}
+	}
+	return dims, nil
+}
+
+// Commit commits an partitioning operation.
+func (op *Operation) Commit() error {
+	if !op.wipe && !op.moveHdr && len(op.deletions) == 0 && len(op.parts) == 0 {
+		return nil
+	}
+	if err := op.logger.LogOp(
+		op.commit,
+		"deleting %d partitions and creating %d partitions on %q", len(op.deletions), len(op.parts), op.dev,
+	); err != nil {
+		return fmt.Errorf("create partitions failed: %v", err)
+	}
+	return nil
+}
+
+func (op *Operation) commit() error {
+	f, err := os.OpenFile(op.dev, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if op.wipe {
+		size, sectorSize, err := DeviceGeometry(f)
+		if err != nil {
+			return err
+		}
+		if err := Zap(f, size, sectorSize); err != nil {
+			return fmt.Errorf("failed to wipe partition table: %v", err)
+		}
+	}
+
+	if op.moveHdr || len(op.deletions) > 0 || len(op.parts) > 0 {
+		t, err := op.readTable(f, op.wipe)
+		if err != nil {
+			return err
+		}
+		oldBackupLBA := t.BackupLBA
+		if err := op.apply(t); err != nil {
+			return err
+		}
+		if err := t.Write(f); err != nil {
+			return fmt.Errorf("failed to write partition table: %v", err)
+		}
+		if t.BackupLBA != oldBackupLBA {
+			// don't leave a stale backup header behind
+			if err := t.writeSector(f, oldBackupLBA, make([]byte, t.SectorSize)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := RereadPartitionTable(f); err != nil {
+		// like sgdisk, leave it to the caller to wait for the kernel
+		op.logger.Warning("failed to make the kernel reread the partition table of %q: %v", op.dev, err)
+	}
+	return nil
+}
+
+// readTable reads the table of the device f, or returns a new table if the
+// device has none or fresh is set.
+func (op *Operation) readTable(f *os.File, fresh bool) (*Table, error) {
+	size, sectorSize, err := DeviceGeometry(f)
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		t, err := Read(f, size, sectorSize)
+		if err == nil {
+			return t, nil
+		} else if err != ErrNoTable {
+			return nil, fmt.Errorf("failed to read partition table of %q: %v", op.dev, err)
+		}
+	}
+	return New(size, sectorSize)
+}
+
+// apply makes the changes of the operation to t.
+func (op *Operation) apply(t *Table) error {
+	if op.moveHdr {
+		t.MoveBackupHeader()
+	}
+
+	for _, num := range op.deletions {
+		if err := t.DeletePartition(num); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range op.parts {
+		num := p.Number
+		if num == 0 {
+			n, err := t.FirstFreeNumber()
+			if err != nil {
+				return err
+			}
+			num = n
+		}
+		e, size, err := entryFromPartition(p, t.SectorSize)
+		if err != nil {
+			return fmt.Errorf("partition %d: %v", num, err)
+		}
+		if _, err := t.AddPartition(num, e, size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// entryFromPartition returns the entry for p and its size in sectors. An
+// unspecified start and size are left 0, unspecified GUIDs are generated.
+func entryFromPartition(p types.Partition, sectorSize int) (Entry, uint64, error) {
+	e := Entry{Type: LinuxFilesystemType}
+	var err error
+	if p.TypeGUID != "" {
+		if e.Type, err = ParseGUID(p.TypeGUID); err != nil {
+			return e, 0, fmt.Errorf("type %q: %v", p.TypeGUID, err)
+		}
+	}
+	if p.GUID != "" {
+		if e.GUID, err = ParseGUID(p.GUID); err != nil {
+			return e, 0, fmt.Errorf("GUID %q: %v", p.GUID, err)
+		}
+	} else if e.GUID, err = NewGUID(); err != nil {
+		return e, 0, err
+	}
+	if p.Label != nil {
+		e.Name = *p.Label
+	}
+	if e.Attributes, err = p.ParseAttributes(); err != nil {
+		return e, 0, err
+	}
+
+	mib := uint64(1024 * 1024 / sectorSize)
+	switch {
+	case p.Start != nil:
+		e.FirstLBA = uint64(*p.Start)
+	case p.StartMiB != nil:
+		e.FirstLBA = uint64(*p.StartMiB) * mib
+	}
+	var size uint64
+	switch {
+	case p.Size != nil:
+		size = uint64(*p.Size)
+	case p.SizeMiB != nil:
+		size = uint64(*p.SizeMiB) * mib
+	}
+	return e, size, nil
+}
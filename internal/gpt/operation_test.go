@@ -0,0 +1,312 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gpt
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flatcar/ignition/internal/config/types"
+	"github.com/flatcar/ignition/internal/log"
+	"github.com/flatcar/ignition/internal/partitioners"
+)
+
+func intToPtr(i int) *int {
+	return &i
+}
+
+func TestAlignment(t *testing.T) {
+	type in struct {
+		starts []uint64
+	}
+	type out struct {
+		align uint64
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{},
+			out: out{2048},
+		},
+		{
+			in:  in{[]uint64{2048, 4096, 1050624}},
+			out: out{2048},
+		},
+		// partitions created by old tools
+		{
+			in:  in{[]uint64{63}},
+			out: out{1},
+		},
+		{
+			in:  in{[]uint64{2048, 4096 + 256}},
+			out: out{256},
+		},
+	}
+
+	for i, test := range tests {
+		table, err := New(1024*1024*1024, 512)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j, start := range test.in.starts {
+			table.Entries[j] = Entry{Type: LinuxFilesystemType, FirstLBA: start, LastLBA: start}
+		}
+		if align := table.Alignment(); align != test.out.align {
+			t.Errorf("#%d: bad alignment: want %d, got %d", i, test.out.align, align)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	type in struct {
+		existing []Entry
+		parts    []types.Partition
+		deletes  []int
+		infos    []int
+	}
+	type out struct {
+		dims map[int]partitioners.Dimensions
+		err  bool
+	}
+
+	existing := func(first, last uint64) Entry {
+		return Entry{Type: LinuxFilesystemType, FirstLBA: first, LastLBA: last}
+	}
+
+	// 64 MiB: usable sectors 34-131038
+	tests := []struct {
+		in  in
+		out out
+	}{
+		// start and size 0 fill the largest free segment from its first
+		// aligned sector
+		{
+			in: in{
+				parts: []types.Partition{{Number: 1, Start: intToPtr(0), Size: intToPtr(0)}},
+				infos: []int{1},
+			},
+			out: out{dims: map[int]partitioners.Dimensions{1: {Start: 2048, Size: 128991}}},
+		},
+		{
+			in: in{
+				parts: []types.Partition{
+					{Number: 1, SizeMiB: intToPtr(8)},
+					{Number: 2, StartMiB: intToPtr(32), SizeMiB: intToPtr(4)},
+					{Number: 3},
+				},
+				infos: []int{1, 2, 3},
+			},
+			out: out{dims: map[int]partitioners.Dimensions{
+				1: {Start: 2048, Size: 16384},
+				2: {Start: 65536, Size: 8192},
+				3: {Start: 73728, Size: 57311},
+			}},
+		},
+		// explicit starts are not aligned
+		{
+			in: in{
+				parts: []types.Partition{{Number: 1, Start: intToPtr(34), Size: intToPtr(100)}},
+				infos: []int{1},
+			},
+			out: out{dims: map[int]partitioners.Dimensions{1: {Start: 34, Size: 100}}},
+		},
+		// number 0 takes the first free number, the gap before partition
+		// 2 is smaller than the space after it
+		{
+			in: in{
+				existing: []Entry{{}, existing(16384, 32767)},
+				parts:    []types.Partition{{Number: 0}},
+				infos:    []int{1},
+			},
+			out: out{dims: map[int]partitioners.Dimensions{1: {Start: 32768, Size: 98271}}},
+		},
+		// a deleted partition is recreated larger at the same start
+		{
+			in: in{
+				existing: []Entry{existing(2048, 4095), existing(8192, 16383)},
+				deletes:  []int{1},
+				parts:    []types.Partition{{Number: 1, Start: intToPtr(2048), Size: intToPtr(0)}},
+				infos:    []int{1},
+			},
+			out: out{dims: map[int]partitioners.Dimensions{1: {Start: 2048, Size: 6144}}},
+		},
+		{
+			in: in{
+				existing: []Entry{existing(2048, 4095)},
+				parts:    []types.Partition{{Number: 1}},
+			},
+			out: out{err: true},
+		},
+		{
+			in: in{
+				existing: []Entry{existing(2048, 4095)},
+				parts:    []types.Partition{{Number: 2, Start: intToPtr(4000)}},
+			},
+			out: out{err: true},
+		},
+		{
+			in: in{
+				existing: []Entry{existing(8192, 16383)},
+				parts:    []types.Partition{{Number: 2, Start: intToPtr(2048), SizeMiB: intToPtr(4)}},
+			},
+			out: out{err: true},
+		},
+		{
+			in:  in{deletes: []int{1}},
+			out: out{err: true},
+		},
+		{
+			in:  in{infos: []int{1}},
+			out: out{err: true},
+		},
+	}
+
+	logger := log.New(false)
+	for i, test := range tests {
+		const size = 64 * 1024 * 1024
+		f := newImage(t, size)
+		table, err := New(size, 512)
+		if err != nil {
+			t.Fatal(err)
+		}
+		copy(table.Entries, test.in.existing)
+		if err := table.Write(f); err != nil {
+			t.Fatal(err)
+		}
+
+		op := Begin(&logger, f.Name())
+		for _, num := range test.in.deletes {
+			op.DeletePartition(num)
+		}
+		for _, p := range test.in.parts {
+			op.CreatePartition(p)
+		}
+		for _, num := range test.in.infos {
+			op.Info(num)
+		}
+		dims, err := op.Pretend()
+		if test.out.err != (err != nil) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+		if err == nil && !reflect.DeepEqual(test.out.dims, dims) {
+			t.Errorf("#%d: bad dimensions: want %v, got %v", i, test.out.dims, dims)
+		}
+	}
+}
+
+func TestCommit(t *testing.T) {
+	const size = 64 * 1024 * 1024
+	f := newImage(t, size)
+	logger := log.New(false)
+	label := "DATA"
+
+	op := Begin(&logger, f.Name())
+	op.CreatePartition(types.Partition{
+		Number:     1,
+		SizeMiB:    intToPtr(16),
+		Label:      &label,
+		TypeGUID:   "c12a7328-f81f-11d2-ba4b-00a0c93ec93b",
+		GUID:       "5ad6e5a8-7f51-4b6f-9f2e-c1b6aab9ab5c",
+		Attributes: []types.PartitionAttribute{"required-partition", "priority=2"},
+	})
+	op.CreatePartition(types.Partition{Number: 0})
+	if err := op.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	table, err := Read(f, size, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Entry{
+		Type:       MustParseGUID("C12A7328-F81F-11D2-BA4B-00A0C93EC93B"),
+		GUID:       MustParseGUID("5AD6E5A8-7F51-4B6F-9F2E-C1B6AAB9AB5C"),
+		FirstLBA:   2048,
+		LastLBA:    34815,
+		Attributes: 0x0002000000000001,
+		Name:       "DATA",
+	}
+	if !reflect.DeepEqual(table.Entries[0], want) {
+		t.Errorf("bad partition 1: want %+v, got %+v", want, table.Entries[0])
+	}
+	second := table.Entries[1]
+	if second.Type != LinuxFilesystemType || second.GUID.IsZero() || second.FirstLBA != 34816 || second.LastLBA != 131038 {
+		t.Errorf("bad partition 2: %+v", second)
+	}
+
+	// wiping the table leaves no table behind
+	op = Begin(&logger, f.Name())
+	op.WipeTable(true)
+	if err := op.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Read(f, size, 512); err != ErrNoTable {
+		t.Errorf("bad error after wiping: want %v, got %v", ErrNoTable, err)
+	}
+}
+
+func TestCommitMovesBackupHeader(t *testing.T) {
+	// an image written to a larger disk
+	const imageSize = 32 * 1024 * 1024
+	const size = 2 * imageSize
+	f := newImage(t, size)
+	table, err := New(imageSize, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table.LastLBA = size/512 - 1
+	table.Entries[0] = Entry{Type: LinuxFilesystemType, GUID: MustParseGUID("5AD6E5A8-7F51-4B6F-9F2E-C1B6AAB9AB5C"), FirstLBA: 2048, LastLBA: 65502}
+	if err := table.Write(f); err != nil {
+		t.Fatal(err)
+	}
+	oldBackupLBA := table.BackupLBA
+	logger := log.New(false)
+
+	// without moving the header, the space after it is unusable
+	op := Begin(&logger, f.Name())
+	op.CreatePartition(types.Partition{Number: 2, StartMiB: intToPtr(40)})
+	if _, err := op.Pretend(); err == nil {
+		t.Errorf("partition was created behind the backup header")
+	}
+
+	// grow partition 1 into the new space
+	op = Begin(&logger, f.Name())
+	op.MoveSecondHeader(true)
+	op.DeletePartition(1)
+	op.CreatePartition(types.Partition{Number: 1, Start: intToPtr(2048), Size: intToPtr(0), GUID: "5AD6E5A8-7F51-4B6F-9F2E-C1B6AAB9AB5C"})
+	if err := op.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	table, err = Read(f, size, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.BackupLBA != size/512-1 || table.LastUsableLBA != size/512-34 {
+		t.Errorf("backup header wasn't moved: at %d, usable until %d", table.BackupLBA, table.LastUsableLBA)
+	}
+	if table.Entries[0].LastLBA != table.LastUsableLBA {
+		t.Errorf("partition didn't grow: %+v", table.Entries[0])
+	}
+	if _, err := readHeader(f, oldBackupLBA, table.LastLBA, 512); err != ErrNoTable {
+		t.Errorf("old backup header wasn't removed: %v", err)
+	}
+	if _, err := readHeader(f, table.BackupLBA, table.LastLBA, 512); err != nil {
+		t.Errorf("bad backup header: %v", err)
+	}
+}
@@ -17,10 +17,13 @@ package log
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"log/syslog"
 	"os/exec"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 )
 
 type LoggerOps interface {
@@ -40,13 +43,29 @@ type Logger struct {
 	ops           LoggerOps
 	prefixStack   []string
 	opSequenceNum int
+	record        *operationRecord
+}
+
+// Operation is the record of an operation run through LogOp.
+type Operation struct {
+	Description string    `json:"description"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// operationRecord collects the operations of a logger and its copies.
+// Operations may be run concurrently, so access is serialized.
+type operationRecord struct {
+	sync.Mutex
+	operations []Operation
 }
 
 // New creates a new logger.
 // If logToStdout is true, syslog is tried first. If syslog fails or logToStdout
 // is false Stdout is used.
 func New(logToStdout bool) Logger {
-	logger := Logger{}
+	logger := Logger{record: &operationRecord{}}
 	if !logToStdout {
 		var err error
 		logger.ops, err = syslog.New(syslog.LOG_DEBUG, "ignition")
@@ -137,6 +156,7 @@ func QuotedCmd(cmd *exec.Cmd) string {
 
 // LogCmd runs and logs the supplied cmd as an operation with distinct start/finish/fail log messages uniformly combined with the supplied format string.
 // The exact command path and arguments being executed are also logged for debugging assistance.
+// If cmd.Stdout is set, the output of the command is also written to it.
 func (l *Logger) LogCmd(cmd *exec.Cmd, format string, a ...interface{}) (int, error) {
 	code := -1
 	f := func() error {
@@ -145,7 +165,11 @@ func (l *Logger) LogCmd(cmd *exec.Cmd, format string, a ...interface{}) (int, er
 
 		stdout := &bytes.Buffer{}
 		stderr := &bytes.Buffer{}
-		cmd.Stdout = stdout
+		if cmd.Stdout != nil {
+			cmd.Stdout = io.MultiWriter(cmd.Stdout, stdout)
+		} else {
+			cmd.Stdout = stdout
+		}
 		cmd.Stderr = stderr
 		if err := cmd.Run(); err != nil {
 			if exitErr, ok := err.(*exec.ExitError); ok {
@@ -160,13 +184,24 @@ func (l *Logger) LogCmd(cmd *exec.Cmd, format string, a ...interface{}) (int, er
 }
 
 // LogOp calls and logs the supplied function as an operation with distinct start/finish/fail log messages uniformly combined with the supplied format string.
+// The operation is recorded and can be retrieved with Operations.
 func (l *Logger) LogOp(op func() error, format string, a ...interface{}) error {
 	l.opSequenceNum++
 	l.PushPrefix("op(%x)", l.opSequenceNum)
 	defer l.PopPrefix()
 
 	l.logStart(format, a...)
-	if err := op(); err != nil {
+	rec := Operation{
+		Description: fmt.Sprintf(format, a...),
+		Start:       time.Now(),
+	}
+	err := op()
+	rec.End = time.Now()
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	l.addOperation(rec)
+	if err != nil {
 		l.logFail("%s: %v", fmt.Sprintf(format, a...), err)
 		return err
 	}
@@ -174,6 +209,26 @@ func (l *Logger) LogOp(op func() error, format string, a ...interface{}) error {
 	return nil
 }
 
+// Operations returns the operations run through LogOp so far, in the order
+// they finished.
+func (l Logger) Operations() []Operation {
+	if l.record == nil {
+		return nil
+	}
+	l.record.Lock()
+	defer l.record.Unlock()
+	return append([]Operation(nil), l.record.operations...)
+}
+
+func (l Logger) addOperation(op Operation) {
+	if l.record == nil {
+		return
+	}
+	l.record.Lock()
+	defer l.record.Unlock()
+	l.record.operations = append(l.record.operations, op)
+}
+
 // logStart logs the start of a multi-step/substantial/time-consuming operation.
 func (l Logger) logStart(format string, a ...interface{}) {
 	l.Info(fmt.Sprintf("[started]  %s", format), a...)
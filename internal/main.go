@@ -34,6 +34,8 @@ func main() {
 	flags := struct {
 		clearCache   bool
 		configCache  string
+		dryRun       bool
+		resultCache  string
 		fetchTimeout time.Duration
 		oem          oem.Name
 		root         string
@@ -44,8 +46,10 @@ func main() {
 
 	flag.BoolVar(&flags.clearCache, "clear-cache", false, "clear any cached config")
 	flag.StringVar(&flags.configCache, "config-cache", "/run/ignition.json", "where to cache the config")
+	flag.StringVar(&flags.resultCache, "result-cache", "/run/ignition-result.json", "where to collect the result of all stages before it is written to the target root")
+	flag.BoolVar(&flags.dryRun, "dry-run", false, "print what the disks and files stages (or the given stage) would do instead of running them")
 	flag.DurationVar(&flags.fetchTimeout, "fetch-timeout", exec.DefaultFetchTimeout, "initial duration for which to wait for config")
-	flag.Var(&flags.oem, "oem", fmt.Sprintf("current oem. %v", oem.Names()))
+	flag.Var(&flags.oem, "oem", fmt.Sprintf("current oem, or %q to detect it. %v", oem.Auto, oem.Names()))
 	flag.StringVar(&flags.root, "root", "/", "root of the filesystem")
 	flag.Var(&flags.stage, "stage", fmt.Sprintf("execution stage. %v", stages.Names()))
 	flag.BoolVar(&flags.version, "version", false, "print the version and exit")
@@ -63,7 +67,7 @@ func main() {
 		os.Exit(2)
 	}
 
-	if flags.stage == "" {
+	if flags.stage == "" && !flags.dryRun {
 		fmt.Fprint(os.Stderr, "'--stage' must be provided\n")
 		os.Exit(2)
 	}
@@ -80,21 +84,44 @@ func main() {
 		}
 	}
 
-	oemConfig := oem.MustGet(flags.oem.String())
+	var oemConfig oem.Config
+	if flags.oem == oem.Auto {
+		oemConfig = oem.Detect(&logger)
+	} else {
+		oemConfig = oem.MustGet(flags.oem.String())
+	}
 	fetcher, err := oemConfig.NewFetcherFunc()(&logger)
 	if err != nil {
 		logger.Crit("failed to generate fetcher: %s", err)
 		os.Exit(3)
 	}
+	fetcher.Metadata = oemConfig.MetadataFunc(&fetcher)
 	engine := exec.Engine{
 		Root:         flags.root,
 		FetchTimeout: flags.fetchTimeout,
 		Logger:       &logger,
 		ConfigCache:  flags.configCache,
+		ResultCache:  flags.resultCache,
 		OEMConfig:    oemConfig,
 		Fetcher:      &fetcher,
 	}
 
+	if flags.dryRun {
+		stageNames := []string{"disks", "files"}
+		if flags.stage != "" {
+			stageNames = []string{flags.stage.String()}
+		}
+		steps, err := engine.Plan(stageNames...)
+		if err != nil {
+			logger.Crit("Ignition plan failed: %v", err.Error())
+			os.Exit(1)
+		}
+		for _, step := range steps {
+			fmt.Println(step)
+		}
+		return
+	}
+
 	err = engine.Run(flags.stage.String())
 	if statusErr := engine.OEMConfig.Status(flags.stage.String(), *engine.Fetcher, err); statusErr != nil {
 		logger.Err("POST Status error: %v", statusErr.Error())
@@ -0,0 +1,225 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mbr
+
+import (
+	"fmt"
+	"sort"
+)
+
+// segment is a range of free sectors, both ends inclusive.
+type segment struct {
+	first, last uint64
+}
+
+func (s segment) sectors() uint64 {
+	return s.last - s.first + 1
+}
+
+// freeSegments returns the ranges of sectors between first and last not
+// taken by used, in ascending order.
+func freeSegments(used []segment, first, last uint64) []segment {
+	sort.Slice(used, func(i, j int) bool { return used[i].first < used[j].first })
+
+	var free []segment
+	next := first
+	for _, u := range used {
+		if u.first > next {
+			free = append(free, segment{next, u.first - 1})
+		}
+		if u.last+1 > next {
+			next = u.last + 1
+		}
+	}
+	if next <= last {
+		free = append(free, segment{next, last})
+	}
+	return free
+}
+
+// lastUsableLBA returns the last sector partitions can use. MBR partition
+// entries address sectors with 32 bits.
+func (t *Table) lastUsableLBA() uint64 {
+	if t.LastLBA > maxSectors-1 {
+		return maxSectors - 1
+	}
+	return t.LastLBA
+}
+
+// freePrimarySegments returns the free sectors outside of primary and
+// extended partitions. The first sector holds the MBR.
+func (t *Table) freePrimarySegments() []segment {
+	var used []segment
+	for _, p := range t.Primary {
+		if p.IsUsed() {
+			used = append(used, segment{p.FirstLBA, p.LastLBA})
+		}
+	}
+	return freeSegments(used, 1, t.lastUsableLBA())
+}
+
+// freeLogicalSegments returns the free sectors of the extended partition
+// ext. Each logical partition takes the sectors from its EBR to its end.
+func (t *Table) freeLogicalSegments(ext Partition) []segment {
+	var used []segment
+	for _, p := range t.Logical {
+		used = append(used, segment{p.ebrLBA, p.LastLBA})
+	}
+	return freeSegments(used, ext.FirstLBA, ext.LastLBA)
+}
+
+// Alignment returns the number of sectors partition starts are aligned to
+// by default: 1 MiB, reduced to the largest power of two all existing
+// partitions are aligned to.
+func (t *Table) Alignment() uint64 {
+	align := uint64(1024 * 1024 / t.SectorSize)
+	if align == 0 {
+		align = 1
+	}
+	for _, p := range append(t.Primary[:], t.Logical...) {
+		if !p.IsUsed() {
+			continue
+		}
+		for align > 1 && p.FirstLBA%align != 0 {
+			align /= 2
+		}
+	}
+	return align
+}
+
+// place sets the start and end of p in the free segments free, keeping
+// reserved sectors in front of it free. If p.FirstLBA is 0, p starts at the
+// first aligned sector of the largest free segment. If sectors is 0, p fills
+// the free segment it starts in. The segment p is placed in is returned.
+func (t *Table) place(p *Partition, free []segment, reserved, sectors uint64) (segment, error) {
+	if p.FirstLBA == 0 {
+		var largest segment
+		found := false
+		for _, s := range free {
+			if s.sectors() > reserved && (!found || s.sectors() > largest.sectors()) {
+				largest = s
+				found = true
+			}
+		}
+		if !found {
+			return segment{}, fmt.Errorf("no free space left")
+		}
+		align := t.Alignment()
+		p.FirstLBA = (largest.first + reserved + align - 1) / align * align
+		if p.FirstLBA > largest.last {
+			p.FirstLBA = largest.first + reserved
+		}
+	}
+	var seg segment
+	found := false
+	for _, s := range free {
+		if p.FirstLBA >= s.first+reserved && p.FirstLBA <= s.last {
+			seg = s
+			found = true
+		}
+	}
+	if !found {
+		return seg, fmt.Errorf("start sector %d is not free", p.FirstLBA)
+	}
+	if sectors == 0 {
+		p.LastLBA = seg.last
+	} else {
+		p.LastLBA = p.FirstLBA + sectors - 1
+	}
+	if p.LastLBA > seg.last {
+		return seg, fmt.Errorf("sectors %d-%d don't fit into free sectors %d-%d", p.FirstLBA, p.LastLBA, seg.first+reserved, seg.last)
+	}
+	return seg, nil
+}
+
+// AddPartition adds p as partition number num, spanning sectors sectors.
+// Numbers 1-4 are primary partitions, one of which can be extended.
+// Logical partitions are numbered consecutively from 5 and are placed in
+// the extended partition, behind an EBR. Start and size 0 are handled like
+// in place. The added partition is returned.
+func (t *Table) AddPartition(num int, p Partition, sectors uint64) (Partition, error) {
+	if num < 1 {
+		return p, fmt.Errorf("partition number %d is out of range", num)
+	}
+	if num <= len(t.Primary) {
+		if t.Primary[num-1].IsUsed() {
+			return p, fmt.Errorf("partition %d already exists", num)
+		}
+		if p.IsExtended() && t.Extended() != nil {
+			return p, fmt.Errorf("partition %d: there already is an extended partition", num)
+		}
+		if _, err := t.place(&p, t.freePrimarySegments(), 0, sectors); err != nil {
+			return p, fmt.Errorf("partition %d: %v", num, err)
+		}
+		t.Primary[num-1] = p
+		return p, nil
+	}
+
+	ext := t.Extended()
+	if ext == nil {
+		return p, fmt.Errorf("logical partition %d requires an extended partition", num)
+	}
+	if next := len(t.Primary) + 1 + len(t.Logical); num != next {
+		if num < next {
+			return p, fmt.Errorf("partition %d already exists", num)
+		}
+		return p, fmt.Errorf("logical partitions are numbered consecutively, the next one is %d", next)
+	}
+	if p.IsExtended() {
+		return p, fmt.Errorf("partition %d: logical partitions can't be extended partitions", num)
+	}
+	// the EBR goes into the first sector of the free segment
+	seg, err := t.place(&p, t.freeLogicalSegments(*ext), 1, sectors)
+	if err != nil {
+		return p, fmt.Errorf("partition %d: %v", num, err)
+	}
+	p.ebrLBA = seg.first
+	t.Logical = append(t.Logical, p)
+	return p, nil
+}
+
+// DeletePartition removes partition number num. Deleting the extended
+// partition removes all logical partitions, and only the last logical
+// partition can be deleted.
+func (t *Table) DeletePartition(num int) error {
+	if num >= 1 && num <= len(t.Primary) && t.Primary[num-1].IsUsed() {
+		if t.Primary[num-1].IsExtended() {
+			t.Logical = nil
+		}
+		t.Primary[num-1] = Partition{}
+		return nil
+	}
+	last := len(t.Primary) + len(t.Logical)
+	if num <= len(t.Primary) || num > last {
+		return fmt.Errorf("partition %d doesn't exist", num)
+	}
+	if num != last {
+		return fmt.Errorf("partition %d can't be deleted, only the last logical partition (%d) can", num, last)
+	}
+	t.Logical = t.Logical[:len(t.Logical)-1]
+	return nil
+}
+
+// Partition returns partition number num.
+func (t *Table) Partition(num int) (Partition, bool) {
+	switch {
+	case num >= 1 && num <= len(t.Primary):
+		p := t.Primary[num-1]
+		return p, p.IsUsed()
+	case num > len(t.Primary) && num <= len(t.Primary)+len(t.Logical):
+		return t.Logical[num-len(t.Primary)-1], true
+	}
+	return Partition{}, false
+}
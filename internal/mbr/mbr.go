@@ -0,0 +1,305 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mbr reads and writes MBR partition tables, also known as DOS
+// partition tables, including the chain of extended boot records describing
+// logical partitions, without calling out to external tools.
+package mbr
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	sectorLength     = 512
+	bootCodeLength   = 440
+	signatureOffset  = 440
+	partitionOffset  = 446
+	partitionLength  = 16
+	bootSignature    = 0xaa55
+	bootableFlag     = 0x80
+	gptProtectedType = 0xee
+	maxSectors       = 0xffffffff
+	maxLogical       = 256
+
+	// geometry used for the legacy CHS addresses of partitions
+	heads           = 255
+	sectorsPerTrack = 63
+)
+
+// LinuxType is the type of partitions which don't specify one.
+const LinuxType = 0x83
+
+var (
+	ErrNoTable        = errors.New("no MBR partition table found")
+	ErrGPT            = errors.New("device has a GUID partition table")
+	ErrBadEBR         = errors.New("invalid extended boot record")
+	ErrDeviceTooSmall = errors.New("device is too small for a partition table")
+	ErrInvalidType    = errors.New("invalid MBR partition type")
+)
+
+// ParseType parses a partition type code in hex, like "83" or "0x0c".
+func ParseType(s string) (byte, error) {
+	code, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(s), "0x"), 16, 8)
+	if err != nil || code == 0 {
+		return 0, ErrInvalidType
+	}
+	return byte(code), nil
+}
+
+// Partition is a primary, extended or logical partition.
+type Partition struct {
+	Type     byte
+	Bootable bool
+	FirstLBA uint64
+	LastLBA  uint64
+
+	ebrLBA uint64 // the sector of the EBR of a logical partition
+}
+
+// IsUsed returns whether p describes a partition.
+func (p Partition) IsUsed() bool {
+	return p.Type != 0
+}
+
+// IsExtended returns whether p is an extended partition, i.e. a container
+// for logical partitions.
+func (p Partition) IsExtended() bool {
+	return p.Type == 0x05 || p.Type == 0x0f || p.Type == 0x85
+}
+
+// Sectors returns the number of sectors of the partition p.
+func (p Partition) Sectors() uint64 {
+	return p.LastLBA - p.FirstLBA + 1
+}
+
+// Table is an MBR partition table. Primary holds partitions 1-4, Logical
+// partitions 5 and up, in the order of the EBR chain.
+type Table struct {
+	SectorSize    int
+	LastLBA       uint64 // the last sector of the device
+	DiskSignature uint32
+	Primary       [4]Partition
+	Logical       []Partition
+}
+
+// New returns an empty table with a random disk signature for a device of
+// size bytes.
+func New(size int64, sectorSize int) (*Table, error) {
+	if size/int64(sectorSize) < 2 {
+		return nil, ErrDeviceTooSmall
+	}
+	t := &Table{
+		SectorSize: sectorSize,
+		LastLBA:    uint64(size/int64(sectorSize)) - 1,
+	}
+	sig := make([]byte, 4)
+	if _, err := rand.Read(sig); err != nil {
+		return nil, err
+	}
+	t.DiskSignature = binary.LittleEndian.Uint32(sig)
+	return t, nil
+}
+
+// Extended returns the extended partition, or nil if there is none.
+func (t *Table) Extended() *Partition {
+	for i := range t.Primary {
+		if t.Primary[i].IsExtended() {
+			return &t.Primary[i]
+		}
+	}
+	return nil
+}
+
+// Read reads the table of the device r of size bytes. ErrNoTable is
+// returned if the device has no MBR, ErrGPT if the MBR only protects a GUID
+// partition table.
+func Read(r io.ReaderAt, size int64, sectorSize int) (*Table, error) {
+	mbr := make([]byte, sectorLength)
+	if _, err := r.ReadAt(mbr, 0); err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint16(mbr[510:]) != bootSignature {
+		return nil, ErrNoTable
+	}
+	t := &Table{
+		SectorSize:    sectorSize,
+		LastLBA:       uint64(size/int64(sectorSize)) - 1,
+		DiskSignature: binary.LittleEndian.Uint32(mbr[signatureOffset:]),
+	}
+	for i := range t.Primary {
+		b := mbr[partitionOffset+partitionLength*i:]
+		// filesystems like FAT carry the boot signature too, but not
+		// valid boot indicators
+		if b[0] != 0 && b[0] != bootableFlag {
+			return nil, ErrNoTable
+		}
+		if b[4] == gptProtectedType {
+			return nil, ErrGPT
+		}
+		t.Primary[i] = decodePartition(b, 0)
+	}
+
+	if ext := t.Extended(); ext != nil {
+		logical, err := readLogical(r, *ext, sectorSize)
+		if err != nil {
+			return nil, err
+		}
+		t.Logical = logical
+	}
+	return t, nil
+}
+
+// readLogical follows the EBR chain of the extended partition ext.
+func readLogical(r io.ReaderAt, ext Partition, sectorSize int) ([]Partition, error) {
+	var logical []Partition
+	ebr := make([]byte, sectorLength)
+	lba := ext.FirstLBA
+	for {
+		if len(logical) == maxLogical {
+			return nil, ErrBadEBR
+		}
+		if _, err := r.ReadAt(ebr, int64(lba)*int64(sectorSize)); err != nil {
+			return nil, err
+		}
+		if binary.LittleEndian.Uint16(ebr[510:]) != bootSignature {
+			if lba == ext.FirstLBA {
+				// an extended partition without logical partitions
+				return nil, nil
+			}
+			return nil, ErrBadEBR
+		}
+		p := decodePartition(ebr[partitionOffset:], lba)
+		if !p.IsUsed() {
+			return logical, nil
+		}
+		if p.FirstLBA <= lba || p.LastLBA > ext.LastLBA {
+			return nil, ErrBadEBR
+		}
+		p.ebrLBA = lba
+		logical = append(logical, p)
+
+		next := decodePartition(ebr[partitionOffset+partitionLength:], ext.FirstLBA)
+		if !next.IsExtended() {
+			return logical, nil
+		}
+		if next.FirstLBA <= ext.FirstLBA || next.FirstLBA > ext.LastLBA {
+			return nil, ErrBadEBR
+		}
+		lba = next.FirstLBA
+	}
+}
+
+// decodePartition decodes the partition entry b, whose start is relative
+// to the sector base.
+func decodePartition(b []byte, base uint64) Partition {
+	count := binary.LittleEndian.Uint32(b[12:])
+	if b[4] == 0 || count == 0 {
+		return Partition{}
+	}
+	first := base + uint64(binary.LittleEndian.Uint32(b[8:]))
+	return Partition{
+		Type:     b[4],
+		Bootable: b[0] == bootableFlag,
+		FirstLBA: first,
+		LastLBA:  first + uint64(count) - 1,
+	}
+}
+
+// encodePartition encodes p into the partition entry b, with its start
+// relative to the sector base.
+func encodePartition(b []byte, p Partition, base uint64) {
+	if !p.IsUsed() {
+		copy(b[:partitionLength], make([]byte, partitionLength))
+		return
+	}
+	b[0] = 0
+	if p.Bootable {
+		b[0] = bootableFlag
+	}
+	copy(b[1:4], chs(p.FirstLBA))
+	b[4] = p.Type
+	copy(b[5:8], chs(p.LastLBA))
+	binary.LittleEndian.PutUint32(b[8:], uint32(p.FirstLBA-base))
+	binary.LittleEndian.PutUint32(b[12:], uint32(p.Sectors()))
+}
+
+// chs returns the legacy cylinder/head/sector address of lba. Addresses
+// beyond 1024 cylinders are clamped, like other partitioning tools do.
+func chs(lba uint64) []byte {
+	if lba >= 1024*heads*sectorsPerTrack {
+		return []byte{0xfe, 0xff, 0xff}
+	}
+	c := lba / (heads * sectorsPerTrack)
+	h := lba / sectorsPerTrack % heads
+	s := lba%sectorsPerTrack + 1
+	return []byte{byte(h), byte(s) | byte(c>>2&0xc0), byte(c)}
+}
+
+// Write writes t to the device rw: the MBR in the first sector and one EBR
+// per logical partition. The boot code of an existing MBR is kept.
+func (t *Table) Write(rw ReadWriterAt) error {
+	mbr := make([]byte, sectorLength)
+	if _, err := rw.ReadAt(mbr, 0); err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint32(mbr[signatureOffset:], t.DiskSignature)
+	mbr[444], mbr[445] = 0, 0
+	for i, p := range t.Primary {
+		encodePartition(mbr[partitionOffset+partitionLength*i:], p, 0)
+	}
+	binary.LittleEndian.PutUint16(mbr[510:], bootSignature)
+	if _, err := rw.WriteAt(mbr, 0); err != nil {
+		return err
+	}
+
+	ext := t.Extended()
+	if ext == nil {
+		return nil
+	}
+	if len(t.Logical) == 0 {
+		// make sure no stale EBR chain is picked up
+		return t.writeEBR(rw, ext.FirstLBA, make([]byte, sectorLength))
+	}
+	for i, p := range t.Logical {
+		ebr := make([]byte, sectorLength)
+		encodePartition(ebr[partitionOffset:], p, p.ebrLBA)
+		if i+1 < len(t.Logical) {
+			next := t.Logical[i+1]
+			link := Partition{Type: 0x05, FirstLBA: next.ebrLBA, LastLBA: next.LastLBA}
+			encodePartition(ebr[partitionOffset+partitionLength:], link, ext.FirstLBA)
+		}
+		binary.LittleEndian.PutUint16(ebr[510:], bootSignature)
+		if err := t.writeEBR(rw, p.ebrLBA, ebr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *Table) writeEBR(w io.WriterAt, lba uint64, ebr []byte) error {
+	_, err := w.WriteAt(ebr, int64(lba)*int64(t.SectorSize))
+	return err
+}
+
+// ReadWriterAt is a device tables are read from and written to.
+type ReadWriterAt interface {
+	io.ReaderAt
+	io.WriterAt
+}
@@ -0,0 +1,181 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mbr
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/flatcar/ignition/internal/gpt"
+)
+
+func newImage(t *testing.T, size int64) *os.File {
+	f, err := ioutil.TempFile("", "ignition-mbr-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		f.Close()
+		os.Remove(f.Name())
+	})
+	if err := f.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestParseType(t *testing.T) {
+	type in struct {
+		typ string
+	}
+	type out struct {
+		typ byte
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{"83"},
+			out: out{typ: 0x83},
+		},
+		{
+			in:  in{"0x0C"},
+			out: out{typ: 0x0c},
+		},
+		{
+			in:  in{"f"},
+			out: out{typ: 0x0f},
+		},
+		{
+			in:  in{"00"},
+			out: out{err: ErrInvalidType},
+		},
+		{
+			in:  in{"100"},
+			out: out{err: ErrInvalidType},
+		},
+	}
+
+	for i, test := range tests {
+		typ, err := ParseType(test.in.typ)
+		if err != test.out.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+		if typ != test.out.typ {
+			t.Errorf("#%d: bad type: want %#x, got %#x", i, test.out.typ, typ)
+		}
+	}
+}
+
+func TestCHS(t *testing.T) {
+	tests := []struct {
+		lba uint64
+		chs []byte
+	}{
+		{2048, []byte{0x20, 0x21, 0x00}},
+		{16065, []byte{0x00, 0x01, 0x01}},
+		{1023 * 16065, []byte{0x00, 0xc1, 0xff}},
+		{1024 * 16065, []byte{0xfe, 0xff, 0xff}},
+	}
+	for i, test := range tests {
+		if c := chs(test.lba); !bytes.Equal(c, test.chs) {
+			t.Errorf("#%d: bad CHS address: want %x, got %x", i, test.chs, c)
+		}
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	const size = 64 * 1024 * 1024
+	f := newImage(t, size)
+	bootCode := bytes.Repeat([]byte{0xeb}, bootCodeLength)
+	if _, err := f.WriteAt(bootCode, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	table, err := New(size, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := table.AddPartition(1, Partition{Type: 0x0c, Bootable: true}, 16384); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := table.AddPartition(2, Partition{Type: 0x05}, 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, num := range []int{5, 6, 7} {
+		if _, err := table.AddPartition(num, Partition{Type: LinuxType}, 8192); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := table.Write(f); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := Read(f, size, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(table, read) {
+		t.Errorf("bad table: want %+v, got %+v", table, read)
+	}
+	mbr := make([]byte, bootCodeLength)
+	if _, err := f.ReadAt(mbr, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(mbr, bootCode) {
+		t.Errorf("boot code wasn't kept")
+	}
+
+	// removing the logical partitions leaves an empty EBR chain
+	for _, num := range []int{7, 6, 5} {
+		if err := table.DeletePartition(num); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := table.Write(f); err != nil {
+		t.Fatal(err)
+	}
+	if read, err = Read(f, size, 512); err != nil {
+		t.Fatal(err)
+	}
+	if len(read.Logical) != 0 {
+		t.Errorf("logical partitions weren't removed: %+v", read.Logical)
+	}
+}
+
+func TestReadOtherTables(t *testing.T) {
+	const size = 64 * 1024 * 1024
+	f := newImage(t, size)
+	if _, err := Read(f, size, 512); err != ErrNoTable {
+		t.Errorf("bad error for empty device: want %v, got %v", ErrNoTable, err)
+	}
+
+	table, err := gpt.New(size, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Write(f); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Read(f, size, 512); err != ErrGPT {
+		t.Errorf("bad error for GPT: want %v, got %v", ErrGPT, err)
+	}
+}
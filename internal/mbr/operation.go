@@ -0,0 +1,273 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mbr
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/flatcar/ignition/config/util"
+	"github.com/flatcar/ignition/internal/config/types"
+	"github.com/flatcar/ignition/internal/gpt"
+	"github.com/flatcar/ignition/internal/log"
+	"github.com/flatcar/ignition/internal/partitioners"
+)
+
+// Operation is a partitioners.Operation for MBR partition tables.
+type Operation struct {
+	logger    *log.Logger
+	dev       string
+	wipe      bool
+	parts     []types.Partition
+	deletions []int
+	infos     []int
+}
+
+// Begin begins an operation on the device dev.
+func Begin(logger *log.Logger, dev string) *Operation {
+	return &Operation{logger: logger, dev: dev}
+}
+
+// CreatePartition adds the supplied partition to the list of partitions to be created as part of an operation.
+func (op *Operation) CreatePartition(p types.Partition) {
+	op.parts = append(op.parts, p)
+}
+
+func (op *Operation) DeletePartition(num int) {
+	op.deletions = append(op.deletions, num)
+}
+
+func (op *Operation) Info(num int) {
+	op.infos = append(op.infos, num)
+}
+
+// WipeTable toggles if the table is to be wiped first when commiting this
+// operation. Any GUID partition table is wiped as well.
+func (op *Operation) WipeTable(wipe bool) {
+	op.wipe = wipe
+}
+
+// MoveSecondHeader does nothing, MBR partition tables have no backup.
+func (op *Operation) MoveSecondHeader(move bool) {}
+
+// Creations returns the partitions to be created as part of an operation.
+func (op *Operation) Creations() []types.Partition {
+	return op.parts
+}
+
+// Deletions returns the numbers of the partitions to be deleted as part of an
+// operation.
+func (op *Operation) Deletions() []int {
+	return op.deletions
+}
+
+// Pretend reads the table of the device and applies the operation to it in
+// memory. A table which is wiped is pretended to be empty.
+func (op *Operation) Pretend() (map[int]partitioners.Dimensions, error) {
+	f, err := os.Open(op.dev)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	t, err := op.readTable(f, op.wipe)
+	if err != nil {
+		return nil, err
+	}
+	if err := op.apply(t); err != nil {
+		return nil, err
+	}
+
+	dims := map[int]partitioners.Dimensions{}
+	for _, num := range op.infos {
+		p, ok := t.Partition(num)
+		if !ok {
+			return nil, fmt.Errorf("partition %d doesn't exist", num)
+		}
+		dims[num] = partitioners.Dimensions{
+			Start: int(p.FirstLBA),
+			Size:  int(p.Sectors()),
+		}
+	}
+	return dims, nil
+}
+
+// Commit commits an partitioning operation.
+func (op *Operation) Commit() error {
+	if !op.wipe && len(op.deletions) == 0 && len(op.parts) == 0 {
+		return nil
+	}
+	if err := op.logger.LogOp(
+		op.commit,
+		"deleting %d partitions and creating %d partitions on %q", len(op.deletions), len(op.parts), op.dev,
+	); err != nil {
+		return fmt.Errorf("create partitions failed: %v", err)
+	}
+	return nil
+}
+
+func (op *Operation) commit() error {
+	f, err := os.OpenFile(op.dev, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if op.wipe {
+		size, sectorSize, err := gpt.DeviceGeometry(f)
+		if err != nil {
+			return err
+		}
+		// also removes the old MBR and its boot code
+		if err := gpt.Zap(f, size, sectorSize); err != nil {
+			return fmt.Errorf("failed to wipe partition table: %v", err)
+		}
+	}
+
+	t, err := op.readTable(f, op.wipe)
+	if err != nil {
+		return err
+	}
+	if err := op.apply(t); err != nil {
+		return err
+	}
+	if err := t.Write(f); err != nil {
+		return fmt.Errorf("failed to write partition table: %v", err)
+	}
+
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := gpt.RereadPartitionTable(f); err != nil {
+		op.logger.Warning("failed to make the kernel reread the partition table of %q: %v", op.dev, err)
+	}
+	return nil
+}
+
+// readTable reads the table of the device f, or returns a new table if the
+// device has none or fresh is set. A GUID partition table is only replaced
+// if fresh is set.
+func (op *Operation) readTable(f *os.File, fresh bool) (*Table, error) {
+	size, sectorSize, err := gpt.DeviceGeometry(f)
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		t, err := Read(f, size, sectorSize)
+		if err == nil {
+			return t, nil
+		} else if err == ErrGPT {
+			return nil, fmt.Errorf("%q has a GUID partition table, wipeTable is required to replace it", op.dev)
+		} else if err != ErrNoTable {
+			return nil, fmt.Errorf("failed to read partition table of %q: %v", op.dev, err)
+		}
+	}
+	return New(size, sectorSize)
+}
+
+// apply makes the changes of the operation to t. Logical partitions are
+// deleted from the last one and created from the first one, and the
+// extended partition is created before them.
+func (op *Operation) apply(t *Table) error {
+	deletions := append([]int{}, op.deletions...)
+	sort.Sort(sort.Reverse(sort.IntSlice(deletions)))
+	for _, num := range deletions {
+		if err := t.DeletePartition(num); err != nil {
+			return err
+		}
+	}
+
+	parts := append([]types.Partition{}, op.parts...)
+	sort.SliceStable(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+	for _, p := range parts {
+		if p.Number == 0 {
+			return fmt.Errorf("partitions on MBR partition tables require a number")
+		}
+		mp, size, err := partitionFromConfig(p, t.SectorSize)
+		if err != nil {
+			return fmt.Errorf("partition %d: %v", p.Number, err)
+		}
+		if _, err := t.AddPartition(p.Number, mp, size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// partitionFromConfig returns the partition for p and its size in sectors.
+// An unspecified start and size are left 0.
+func partitionFromConfig(p types.Partition, sectorSize int) (Partition, uint64, error) {
+	mp := Partition{Type: LinuxType, Bootable: p.Bootable}
+	if p.MbrType != "" {
+		typ, err := ParseType(p.MbrType)
+		if err != nil {
+			return mp, 0, fmt.Errorf("type %q: %v", p.MbrType, err)
+		}
+		mp.Type = typ
+	}
+
+	mib := uint64(1024 * 1024 / sectorSize)
+	switch {
+	case p.Start != nil:
+		mp.FirstLBA = uint64(*p.Start)
+	case p.StartMiB != nil:
+		mp.FirstLBA = uint64(*p.StartMiB) * mib
+	}
+	var size uint64
+	switch {
+	case p.Size != nil:
+		size = uint64(*p.Size)
+	case p.SizeMiB != nil:
+		size = uint64(*p.SizeMiB) * mib
+	}
+	return mp, size, nil
+}
+
+// DumpPartitionTable returns the partitions of the MBR partition table of
+// device. A device without a partition table has no partitions.
+func DumpPartitionTable(device string) ([]types.Partition, error) {
+	f, err := os.Open(device)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	size, sectorSize, err := gpt.DeviceGeometry(f)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := Read(f, size, sectorSize)
+	if err == ErrNoTable {
+		return []types.Partition{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("%q: %v", device, err)
+	}
+	parts := []types.Partition{}
+	for num := 1; num <= len(t.Primary)+len(t.Logical); num++ {
+		p, ok := t.Partition(num)
+		if !ok {
+			continue
+		}
+		parts = append(parts, types.Partition{
+			Number:   num,
+			Start:    util.IntToPtr(int(p.FirstLBA)),
+			Size:     util.IntToPtr(int(p.Sectors())),
+			MbrType:  fmt.Sprintf("%02x", p.Type),
+			Bootable: p.Bootable,
+		})
+	}
+	return parts, nil
+}
@@ -0,0 +1,212 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mbr
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flatcar/ignition/internal/config/types"
+	"github.com/flatcar/ignition/internal/gpt"
+	"github.com/flatcar/ignition/internal/log"
+	"github.com/flatcar/ignition/internal/partitioners"
+)
+
+func intToPtr(i int) *int {
+	return &i
+}
+
+func TestApply(t *testing.T) {
+	type in struct {
+		existing []types.Partition
+		parts    []types.Partition
+		deletes  []int
+		infos    []int
+	}
+	type out struct {
+		dims map[int]partitioners.Dimensions
+		err  bool
+	}
+
+	layout := []types.Partition{
+		{Number: 1, SizeMiB: intToPtr(8)},
+		{Number: 2, MbrType: "05"},
+		{Number: 5, SizeMiB: intToPtr(4)},
+		{Number: 6},
+	}
+
+	// 64 MiB: usable sectors 1-131071
+	tests := []struct {
+		in  in
+		out out
+	}{
+		// logical partitions start 1 MiB behind their EBR
+		{
+			in: in{
+				parts: layout,
+				infos: []int{1, 2, 5, 6},
+			},
+			out: out{dims: map[int]partitioners.Dimensions{
+				1: {Start: 2048, Size: 16384},
+				2: {Start: 18432, Size: 112640},
+				5: {Start: 20480, Size: 8192},
+				6: {Start: 30720, Size: 100352},
+			}},
+		},
+		// the extended partition is created first
+		{
+			in: in{
+				parts: []types.Partition{layout[3], layout[2], layout[1], layout[0]},
+				infos: []int{6},
+			},
+			out: out{dims: map[int]partitioners.Dimensions{6: {Start: 30720, Size: 100352}}},
+		},
+		// the last logical partitions are recreated larger
+		{
+			in: in{
+				existing: layout,
+				deletes:  []int{5, 6},
+				parts: []types.Partition{
+					{Number: 5, Start: intToPtr(20480), SizeMiB: intToPtr(8)},
+					{Number: 6},
+				},
+				infos: []int{5, 6},
+			},
+			out: out{dims: map[int]partitioners.Dimensions{
+				5: {Start: 20480, Size: 16384},
+				6: {Start: 38912, Size: 92160},
+			}},
+		},
+		// deleting the extended partition deletes its logical partitions
+		{
+			in: in{
+				existing: layout,
+				deletes:  []int{2},
+				infos:    []int{5},
+			},
+			out: out{err: true},
+		},
+		{
+			in: in{
+				existing: layout,
+				deletes:  []int{5},
+			},
+			out: out{err: true},
+		},
+		{
+			in: in{
+				parts: []types.Partition{{Number: 5}},
+			},
+			out: out{err: true},
+		},
+		{
+			in: in{
+				parts: []types.Partition{{Number: 1, MbrType: "05", SizeMiB: intToPtr(8)}, {Number: 6}},
+			},
+			out: out{err: true},
+		},
+		{
+			in: in{
+				parts: []types.Partition{{Number: 1, MbrType: "05", SizeMiB: intToPtr(8)}, {Number: 2, MbrType: "0f"}},
+			},
+			out: out{err: true},
+		},
+		{
+			in: in{
+				parts: []types.Partition{{Number: 0}},
+			},
+			out: out{err: true},
+		},
+	}
+
+	logger := log.New(false)
+	for i, test := range tests {
+		const size = 64 * 1024 * 1024
+		f := newImage(t, size)
+		if len(test.in.existing) > 0 {
+			op := Begin(&logger, f.Name())
+			for _, p := range test.in.existing {
+				op.CreatePartition(p)
+			}
+			if err := op.Commit(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		op := Begin(&logger, f.Name())
+		for _, num := range test.in.deletes {
+			op.DeletePartition(num)
+		}
+		for _, p := range test.in.parts {
+			op.CreatePartition(p)
+		}
+		for _, num := range test.in.infos {
+			op.Info(num)
+		}
+		dims, err := op.Pretend()
+		if test.out.err != (err != nil) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+		if err == nil && !reflect.DeepEqual(test.out.dims, dims) {
+			t.Errorf("#%d: bad dimensions: want %v, got %v", i, test.out.dims, dims)
+		}
+	}
+}
+
+func TestCommit(t *testing.T) {
+	const size = 64 * 1024 * 1024
+	f := newImage(t, size)
+	logger := log.New(false)
+
+	// a GUID partition table is only replaced when wiping
+	gptTable, err := gpt.New(size, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gptTable.Write(f); err != nil {
+		t.Fatal(err)
+	}
+	op := Begin(&logger, f.Name())
+	op.CreatePartition(types.Partition{Number: 1})
+	if err := op.Commit(); err == nil {
+		t.Fatalf("GUID partition table was replaced without wiping")
+	}
+
+	op = Begin(&logger, f.Name())
+	op.WipeTable(true)
+	op.CreatePartition(types.Partition{Number: 1, SizeMiB: intToPtr(16), MbrType: "ef", Bootable: true})
+	op.CreatePartition(types.Partition{Number: 2})
+	if err := op.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	table, err := Read(f, size, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [4]Partition{
+		{Type: 0xef, Bootable: true, FirstLBA: 2048, LastLBA: 34815},
+		{Type: LinuxType, FirstLBA: 34816, LastLBA: 131071},
+	}
+	if table.Primary != want {
+		t.Errorf("bad partitions: want %+v, got %+v", want, table.Primary)
+	}
+	if table.DiskSignature == 0 {
+		t.Errorf("no disk signature was set")
+	}
+	if _, err := gpt.Read(f, size, 512); err != gpt.ErrMBRPartitions {
+		t.Errorf("GUID partition table wasn't wiped: %v", err)
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oem
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/flatcar/ignition/internal/distro"
+	"github.com/flatcar/ignition/internal/log"
+)
+
+const (
+	// Auto is the OEM name which selects the OEM by probing the machine.
+	Auto = "auto"
+
+	// fallbackName is used if no rule matches. Its provider reads nothing,
+	// which leaves the config on the kernel command line.
+	fallbackName = "metal"
+
+	dmiDir   = "/sys/class/dmi/id"
+	fwCfgDir = "/sys/firmware/qemu_fw_cfg/by_name"
+
+	azureAssetTag = "7783-7084-3265-9085-8269-3286-77"
+)
+
+// machine provides the data the detection rules look at.
+type machine interface {
+	// dmi returns the DMI/SMBIOS field, or "" if it can't be read.
+	dmi(field string) string
+	// hypervisor returns the vendor signature of the hypervisor from CPUID
+	// leaf 0x40000000, or "" if there is none.
+	hypervisor() string
+	// labelExists reports whether a filesystem with the label exists.
+	labelExists(label string) bool
+	// fwCfgExists reports whether the QEMU firmware config entry exists.
+	fwCfgExists(name string) bool
+}
+
+// probe returns whether the machine matches and a description of why.
+type probe func(m machine) (bool, string)
+
+type rule struct {
+	oem    string
+	probes []probe
+}
+
+// rules are tried in order and the OEM of the first rule with a matching
+// probe is used. Rules based on DMI come first since config drives and
+// firmware config entries are used by several platforms.
+var rules = []rule{
+	{"azure", []probe{dmiEquals("chassis_asset_tag", azureAssetTag)}},
+	{"hyperv", []probe{allOf(dmiEquals("sys_vendor", "Microsoft Corporation"), dmiEquals("product_name", "Virtual Machine"))}},
+	{"ec2", []probe{dmiEquals("sys_vendor", "Amazon EC2"), dmiContains("bios_version", "amazon")}},
+	{"gce", []probe{dmiEquals("product_name", "Google Compute Engine")}},
+	{"aliyun", []probe{dmiEquals("sys_vendor", "Alibaba Cloud")}},
+	{"digitalocean", []probe{dmiEquals("sys_vendor", "DigitalOcean")}},
+	{"vultr", []probe{dmiEquals("sys_vendor", "Vultr")}},
+	{"exoscale", []probe{dmiHasPrefix("product_name", "Exoscale")}},
+	{"cloudsigma", []probe{dmiEquals("product_name", "CloudSigma")}},
+	{"cloudstack", []probe{dmiHasPrefix("product_name", "CloudStack")}},
+	{"openstack", []probe{dmiEquals("sys_vendor", "OpenStack Foundation"), dmiHasPrefix("product_name", "OpenStack")}},
+	{"vmware", []probe{dmiEquals("sys_vendor", "VMware, Inc."), dmiHasPrefix("product_name", "VMware"), hypervisorEquals("VMwareVMware")}},
+	{"virtualbox", []probe{dmiEquals("product_name", "VirtualBox")}},
+	{"qemu", []probe{fwCfgExists("opt/org.flatcar-linux/config"), fwCfgExists("opt/com.coreos/config")}},
+	{"openstack", []probe{labelExists("config-2"), labelExists("CONFIG-2")}},
+	{"nocloud", []probe{labelExists("cidata"), labelExists("CIDATA")}},
+}
+
+func dmiEquals(field, value string) probe {
+	return func(m machine) (bool, string) {
+		return m.dmi(field) == value, fmt.Sprintf("DMI %s is %q", field, value)
+	}
+}
+
+func dmiHasPrefix(field, prefix string) probe {
+	return func(m machine) (bool, string) {
+		return strings.HasPrefix(m.dmi(field), prefix), fmt.Sprintf("DMI %s starts with %q", field, prefix)
+	}
+}
+
+func dmiContains(field, substr string) probe {
+	return func(m machine) (bool, string) {
+		return strings.Contains(strings.ToLower(m.dmi(field)), substr), fmt.Sprintf("DMI %s contains %q", field, substr)
+	}
+}
+
+func hypervisorEquals(vendor string) probe {
+	return func(m machine) (bool, string) {
+		return m.hypervisor() == vendor, fmt.Sprintf("the CPUID hypervisor vendor is %q", vendor)
+	}
+}
+
+func labelExists(label string) probe {
+	return func(m machine) (bool, string) {
+		return m.labelExists(label), fmt.Sprintf("a config drive labeled %q exists", label)
+	}
+}
+
+func fwCfgExists(name string) probe {
+	return func(m machine) (bool, string) {
+		return m.fwCfgExists(name), fmt.Sprintf("the firmware config entry %q exists", name)
+	}
+}
+
+func allOf(probes ...probe) probe {
+	return func(m machine) (bool, string) {
+		reasons := []string{}
+		for _, p := range probes {
+			ok, reason := p(m)
+			if !ok {
+				return false, ""
+			}
+			reasons = append(reasons, reason)
+		}
+		return true, strings.Join(reasons, " and ")
+	}
+}
+
+// Detect probes the machine and returns the Config of the platform it runs
+// on, falling back to metal if no platform is recognized.
+func Detect(logger *log.Logger) Config {
+	return detect(logger, &system{logger: logger})
+}
+
+func detect(logger *log.Logger, m machine) Config {
+	for _, r := range rules {
+		for _, p := range r.probes {
+			if ok, reason := p(m); ok {
+				logger.Info("detected oem %q: %s", r.oem, reason)
+				return MustGet(r.oem)
+			}
+		}
+	}
+	logger.Info("no oem detected, using %q", fallbackName)
+	return MustGet(fallbackName)
+}
+
+// system is the machine Ignition runs on.
+type system struct {
+	logger      *log.Logger
+	fwCfgLoaded bool
+	udevSettled bool
+}
+
+func (s *system) dmi(field string) string {
+	data, err := ioutil.ReadFile(filepath.Join(dmiDir, field))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func (s *system) hypervisor() string {
+	return hypervisorVendor()
+}
+
+func (s *system) labelExists(label string) bool {
+	if !s.udevSettled {
+		s.udevSettled = true
+		// detection runs early, so udev may not have created the
+		// by-label links of config drives yet
+		if _, err := s.logger.LogCmd(exec.Command(distro.UdevadmCmd(), "settle"), "waiting for udev to settle"); err != nil {
+			s.logger.Debug("could not wait for udev to settle: %v", err)
+		}
+	}
+	_, err := os.Stat(filepath.Join(distro.DiskByLabelDir(), label))
+	return err == nil
+}
+
+func (s *system) fwCfgExists(name string) bool {
+	if !s.fwCfgLoaded {
+		s.fwCfgLoaded = true
+		// the module is missing outside of QEMU, so failures are expected
+		if _, err := s.logger.LogCmd(exec.Command("modprobe", "qemu_fw_cfg"), "loading QEMU firmware config module"); err != nil {
+			s.logger.Debug("could not load QEMU firmware config module: %v", err)
+		}
+	}
+	_, err := os.Stat(filepath.Join(fwCfgDir, name))
+	return err == nil
+}
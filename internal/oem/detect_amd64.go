@@ -0,0 +1,40 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oem
+
+import (
+	"encoding/binary"
+	"strings"
+)
+
+// cpuid executes the CPUID instruction for the leaf, implemented in
+// cpuid_amd64.s.
+func cpuid(leaf uint32) (eax, ebx, ecx, edx uint32)
+
+// hypervisorVendor reads the vendor signature from the hypervisor leaf.
+// Unlike probing the VMware backdoor port, executing CPUID is harmless on
+// bare metal and other hypervisors.
+func hypervisorVendor() string {
+	// the hypervisor leaves only exist if the hypervisor bit is set
+	if _, _, ecx, _ := cpuid(1); ecx&(1<<31) == 0 {
+		return ""
+	}
+	_, ebx, ecx, edx := cpuid(0x40000000)
+	sig := make([]byte, 12)
+	binary.LittleEndian.PutUint32(sig[0:], ebx)
+	binary.LittleEndian.PutUint32(sig[4:], ecx)
+	binary.LittleEndian.PutUint32(sig[8:], edx)
+	return strings.TrimRight(string(sig), "\x00")
+}
@@ -0,0 +1,148 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oem
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flatcar/ignition/internal/log"
+)
+
+type fakeMachine struct {
+	dmiFields map[string]string
+	cpuVendor string
+	labels    []string
+	fwCfg     []string
+}
+
+func (m fakeMachine) dmi(field string) string {
+	return m.dmiFields[field]
+}
+
+func (m fakeMachine) hypervisor() string {
+	return m.cpuVendor
+}
+
+func (m fakeMachine) labelExists(label string) bool {
+	for _, l := range m.labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+func (m fakeMachine) fwCfgExists(name string) bool {
+	for _, n := range m.fwCfg {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDetect(t *testing.T) {
+	type in struct {
+		machine fakeMachine
+	}
+	type out struct {
+		oem string
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{machine: fakeMachine{}},
+			out: out{oem: "metal"},
+		},
+		{
+			in: in{machine: fakeMachine{dmiFields: map[string]string{
+				"sys_vendor":        "Microsoft Corporation",
+				"product_name":      "Virtual Machine",
+				"chassis_asset_tag": "7783-7084-3265-9085-8269-3286-77",
+			}}},
+			out: out{oem: "azure"},
+		},
+		{
+			in: in{machine: fakeMachine{dmiFields: map[string]string{
+				"sys_vendor":   "Microsoft Corporation",
+				"product_name": "Virtual Machine",
+			}}},
+			out: out{oem: "hyperv"},
+		},
+		{
+			in:  in{machine: fakeMachine{dmiFields: map[string]string{"sys_vendor": "Amazon EC2"}}},
+			out: out{oem: "ec2"},
+		},
+		{
+			in:  in{machine: fakeMachine{dmiFields: map[string]string{"sys_vendor": "Xen", "bios_version": "4.2.amazon"}}},
+			out: out{oem: "ec2"},
+		},
+		{
+			in:  in{machine: fakeMachine{dmiFields: map[string]string{"product_name": "Google Compute Engine"}}},
+			out: out{oem: "gce"},
+		},
+		{
+			in:  in{machine: fakeMachine{dmiFields: map[string]string{"product_name": "CloudStack KVM Hypervisor"}, labels: []string{"config-2"}}},
+			out: out{oem: "cloudstack"},
+		},
+		{
+			in:  in{machine: fakeMachine{dmiFields: map[string]string{"sys_vendor": "QEMU"}, labels: []string{"config-2"}}},
+			out: out{oem: "openstack"},
+		},
+		{
+			in:  in{machine: fakeMachine{dmiFields: map[string]string{"sys_vendor": "QEMU"}, labels: []string{"CIDATA"}}},
+			out: out{oem: "nocloud"},
+		},
+		{
+			in:  in{machine: fakeMachine{dmiFields: map[string]string{"sys_vendor": "VMware, Inc."}}},
+			out: out{oem: "vmware"},
+		},
+		{
+			in:  in{machine: fakeMachine{dmiFields: map[string]string{"product_name": "VMware7,1"}}},
+			out: out{oem: "vmware"},
+		},
+		{
+			in:  in{machine: fakeMachine{cpuVendor: "VMwareVMware"}},
+			out: out{oem: "vmware"},
+		},
+		{
+			in:  in{machine: fakeMachine{dmiFields: map[string]string{"sys_vendor": "QEMU"}, cpuVendor: "KVMKVMKVM"}},
+			out: out{oem: "metal"},
+		},
+		{
+			in:  in{machine: fakeMachine{dmiFields: map[string]string{"sys_vendor": "QEMU"}, fwCfg: []string{"opt/org.flatcar-linux/config"}}},
+			out: out{oem: "qemu"},
+		},
+	}
+
+	logger := log.New(true)
+	for i, test := range tests {
+		config := detect(&logger, test.in.machine)
+		assert.Equal(t, test.out.oem, config.Name(), "#%d", i)
+	}
+}
+
+func TestDetectRulesRegistered(t *testing.T) {
+	for _, r := range rules {
+		_, ok := Get(r.oem)
+		assert.True(t, ok, "oem %q is not registered", r.oem)
+		assert.NotEmpty(t, r.probes, "oem %q has no probes", r.oem)
+	}
+}
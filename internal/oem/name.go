@@ -18,7 +18,8 @@ import (
 	"fmt"
 )
 
-// Name is used to identify an OEM. It must be in the set of registered OEMs.
+// Name is used to identify an OEM. It must be in the set of registered OEMs
+// or Auto.
 type Name string
 
 func (s Name) String() string {
@@ -26,7 +27,7 @@ func (s Name) String() string {
 }
 
 func (s *Name) Set(val string) error {
-	if _, ok := Get(val); !ok {
+	if _, ok := Get(val); !ok && val != Auto {
 		return fmt.Errorf("%s is not a valid oem", val)
 	}
 
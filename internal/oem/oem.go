@@ -16,20 +16,25 @@ package oem
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/flatcar/ignition/internal/log"
 	"github.com/flatcar/ignition/internal/providers"
 	"github.com/flatcar/ignition/internal/providers/aliyun"
 	"github.com/flatcar/ignition/internal/providers/azure"
+	"github.com/flatcar/ignition/internal/providers/cloudsigma"
 	"github.com/flatcar/ignition/internal/providers/cloudstack"
 	"github.com/flatcar/ignition/internal/providers/digitalocean"
 	"github.com/flatcar/ignition/internal/providers/ec2"
+	"github.com/flatcar/ignition/internal/providers/exoscale"
 	"github.com/flatcar/ignition/internal/providers/file"
 	"github.com/flatcar/ignition/internal/providers/gce"
+	"github.com/flatcar/ignition/internal/providers/nocloud"
 	"github.com/flatcar/ignition/internal/providers/noop"
 	"github.com/flatcar/ignition/internal/providers/openstack"
 	"github.com/flatcar/ignition/internal/providers/packet"
 	"github.com/flatcar/ignition/internal/providers/qemu"
+	"github.com/flatcar/ignition/internal/providers/rackspace"
 	"github.com/flatcar/ignition/internal/providers/virtualbox"
 	"github.com/flatcar/ignition/internal/providers/vmware"
 	"github.com/flatcar/ignition/internal/providers/vultr"
@@ -43,6 +48,7 @@ type Config struct {
 	fetch      providers.FuncFetchConfig
 	newFetcher providers.FuncNewFetcher
 	status     providers.FuncPostStatus
+	metadata   providers.FuncFetchMetadata
 }
 
 func (c Config) Name() string {
@@ -64,6 +70,26 @@ func (c Config) NewFetcherFunc() providers.FuncNewFetcher {
 	}
 }
 
+// MetadataFunc returns a function fetching the metadata of the instance with
+// the given Fetcher. The metadata is only fetched once, on the first call. It
+// returns nil if the platform doesn't provide any metadata.
+func (c Config) MetadataFunc(f *resource.Fetcher) func() (map[string]string, error) {
+	if c.metadata == nil {
+		return nil
+	}
+	var (
+		once     sync.Once
+		metadata map[string]string
+		err      error
+	)
+	return func() (map[string]string, error) {
+		once.Do(func() {
+			metadata, err = c.metadata(f)
+		})
+		return metadata, err
+	}
+}
+
 // Status takes a Fetcher and the error from Run (from engine)
 func (c Config) Status(stageName string, f resource.Fetcher, statusErr error) error {
 	if c.status != nil {
@@ -80,12 +106,13 @@ func init() {
 		fetch: aliyun.FetchConfig,
 	})
 	configs.Register(Config{
-		name:  "azure",
-		fetch: azure.FetchConfig,
+		name:   "azure",
+		fetch:  azure.FetchConfig,
+		status: azure.PostStatus,
 	})
 	configs.Register(Config{
 		name:  "cloudsigma",
-		fetch: noop.FetchConfig,
+		fetch: cloudsigma.FetchConfig,
 	})
 	configs.Register(Config{
 		name:  "cloudstack",
@@ -96,25 +123,33 @@ func init() {
 		fetch: digitalocean.FetchConfig,
 	})
 	configs.Register(Config{
-		name:  "brightbox",
-		fetch: openstack.FetchConfig,
+		name:     "brightbox",
+		fetch:    openstack.FetchConfig,
+		metadata: openstack.FetchMetadata,
+	})
+	configs.Register(Config{
+		name:  "nocloud",
+		fetch: nocloud.FetchConfig,
 	})
 	configs.Register(Config{
-		name:  "openstack",
-		fetch: openstack.FetchConfig,
+		name:     "openstack",
+		fetch:    openstack.FetchConfig,
+		metadata: openstack.FetchMetadata,
 	})
 	configs.Register(Config{
 		name:       "ec2",
 		fetch:      ec2.FetchConfig,
 		newFetcher: ec2.NewFetcher,
+		metadata:   ec2.FetchMetadata,
 	})
 	configs.Register(Config{
 		name:  "exoscale",
-		fetch: noop.FetchConfig,
+		fetch: exoscale.FetchConfig,
 	})
 	configs.Register(Config{
-		name:  "gce",
-		fetch: gce.FetchConfig,
+		name:     "gce",
+		fetch:    gce.FetchConfig,
+		metadata: gce.FetchMetadata,
 	})
 	configs.Register(Config{
 		name:  "hyperv",
@@ -129,17 +164,21 @@ func init() {
 		fetch:  packet.FetchConfig,
 		status: packet.PostStatus,
 	})
+	configs.Register(Config{
+		name:  "proxmox",
+		fetch: nocloud.FetchConfig,
+	})
 	configs.Register(Config{
 		name:  "pxe",
 		fetch: noop.FetchConfig,
 	})
 	configs.Register(Config{
 		name:  "rackspace",
-		fetch: noop.FetchConfig,
+		fetch: rackspace.FetchConfig,
 	})
 	configs.Register(Config{
 		name:  "rackspace-onmetal",
-		fetch: noop.FetchConfig,
+		fetch: rackspace.FetchConfig,
 	})
 	configs.Register(Config{
 		name:  "vagrant",
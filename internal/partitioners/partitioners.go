@@ -0,0 +1,55 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package partitioners defines the interface of the backends the disks stage
+// changes partition tables with.
+package partitioners
+
+import (
+	"github.com/flatcar/ignition/internal/config/types"
+)
+
+// Dimensions is the location of a partition, in sectors.
+type Dimensions struct {
+	Start int
+	Size  int
+}
+
+// Operation is a set of changes to the partition table of a device. Nothing
+// is written before Commit is called. Deletions are done before creations.
+type Operation interface {
+	// CreatePartition adds p to the partitions to be created. Start and
+	// size 0 are replaced by the defaults of the backend.
+	CreatePartition(p types.Partition)
+	// DeletePartition adds partition number num to the partitions to be
+	// deleted.
+	DeletePartition(num int)
+	// Info adds partition number num to the partitions Pretend reports.
+	Info(num int)
+	// WipeTable toggles if the table is to be wiped first.
+	WipeTable(wipe bool)
+	// MoveSecondHeader toggles if the backup GPT header is moved to the
+	// end of the disk before any partitions are changed.
+	MoveSecondHeader(move bool)
+	// Creations returns the partitions to be created.
+	Creations() []types.Partition
+	// Deletions returns the numbers of the partitions to be deleted.
+	Deletions() []int
+	// Pretend computes the partition table the operation would result
+	// in, without writing it, and returns the dimensions of the
+	// partitions passed to Info.
+	Pretend() (map[int]Dimensions, error)
+	// Commit writes the changes to the device.
+	Commit() error
+}
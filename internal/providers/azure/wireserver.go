@@ -0,0 +1,158 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azure
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/flatcar/ignition/internal/resource"
+)
+
+const (
+	wireserverVersion = "2012-11-30"
+	agentName         = "Ignition"
+
+	// lastStage is the stage after which the VM is provisioned.
+	lastStage = "files"
+
+	healthReportTimeout = 10 * time.Second
+)
+
+var (
+	goalStateUrl = url.URL{
+		Scheme:   "http",
+		Host:     "168.63.129.16",
+		Path:     "machine/",
+		RawQuery: "comp=goalstate",
+	}
+	healthUrl = url.URL{
+		Scheme:   "http",
+		Host:     "168.63.129.16",
+		Path:     "machine/",
+		RawQuery: "comp=health",
+	}
+
+	wireserverHeaders = http.Header{
+		"x-ms-agent-name": []string{agentName},
+		"x-ms-version":    []string{wireserverVersion},
+	}
+
+	// healthClient doesn't let an unresponsive wireserver block the stage
+	// forever.
+	healthClient = &http.Client{Timeout: healthReportTimeout}
+)
+
+type goalState struct {
+	Incarnation string `xml:"Incarnation"`
+	Container   struct {
+		ContainerId   string `xml:"ContainerId"`
+		RoleInstances []struct {
+			InstanceId string `xml:"InstanceId"`
+		} `xml:"RoleInstanceList>RoleInstance"`
+	} `xml:"Container"`
+}
+
+type healthReport struct {
+	XMLName              xml.Name `xml:"Health"`
+	GoalStateIncarnation string   `xml:"GoalStateIncarnation"`
+	ContainerId          string   `xml:"Container>ContainerId"`
+	Roles                []role   `xml:"Container>RoleInstanceList>Role"`
+}
+
+type role struct {
+	InstanceId string  `xml:"InstanceId"`
+	State      string  `xml:"Health>State"`
+	Details    *detail `xml:"Health>Details,omitempty"`
+}
+
+type detail struct {
+	SubStatus   string `xml:"SubStatus"`
+	Description string `xml:"Description"`
+}
+
+// PostStatus reports the health of the VM to the Azure wireserver. The VM is
+// reported Ready once the last stage succeeded, and NotReady with the error
+// as soon as any stage fails. Without the report Azure considers the VM to
+// be provisioning until it times out.
+func PostStatus(stageName string, f resource.Fetcher, statusErr error) error {
+	if statusErr == nil && stageName != lastStage {
+		return nil
+	}
+
+	f.Logger.Info("POST health report to the Azure wireserver")
+	data, err := f.FetchToBuffer(goalStateUrl, resource.FetchOptions{
+		Headers: wireserverHeaders,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to fetch goal state: %v", err)
+	}
+	var state goalState
+	if err := xml.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to parse goal state: %v", err)
+	}
+	if len(state.Container.RoleInstances) == 0 {
+		return fmt.Errorf("goal state contains no role instance")
+	}
+
+	report := healthReport{
+		GoalStateIncarnation: state.Incarnation,
+		ContainerId:          state.Container.ContainerId,
+		Roles: []role{{
+			InstanceId: state.Container.RoleInstances[0].InstanceId,
+			State:      "Ready",
+		}},
+	}
+	if statusErr != nil {
+		report.Roles[0].State = "NotReady"
+		report.Roles[0].Details = &detail{
+			SubStatus:   "ProvisioningFailed",
+			Description: fmt.Sprintf("[%s] Ignition error: %v", stageName, statusErr),
+		}
+	}
+
+	return postHealthReport(report)
+}
+
+// postHealthReport posts the report to the wireserver.
+func postHealthReport(report healthReport) error {
+	body, err := xml.Marshal(report)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", healthUrl.String(), bytes.NewReader(append([]byte(xml.Header), body...)))
+	if err != nil {
+		return err
+	}
+	for key, values := range wireserverHeaders {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	req.Header.Set("Content-Type", "text/xml; charset=utf-8")
+	resp, err := healthClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("wireserver rejected health report: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azure
+
+import (
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flatcar/ignition/internal/log"
+	"github.com/flatcar/ignition/internal/resource"
+)
+
+const testGoalState = `<?xml version="1.0" encoding="utf-8"?>
+<GoalState xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xmlns:xsd="http://www.w3.org/2001/XMLSchema">
+  <Version>2012-11-30</Version>
+  <Incarnation>3</Incarnation>
+  <Machine>
+    <ExpectedState>Started</ExpectedState>
+  </Machine>
+  <Container>
+    <ContainerId>c6d5526c-5ac2-4200-b6e2-56f2b70c5ab2</ContainerId>
+    <RoleInstanceList>
+      <RoleInstance>
+        <InstanceId>d0f1b7d4-3a9d-4a4a-8f0c-2f2d5b8d1b8e._vm</InstanceId>
+      </RoleInstance>
+    </RoleInstanceList>
+  </Container>
+</GoalState>`
+
+// stubWireserver serves the goal state and records the health reports.
+func stubWireserver(t *testing.T, reports *[]healthReport) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-ms-version") != wireserverVersion {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Query().Get("comp") {
+		case "goalstate":
+			w.Write([]byte(testGoalState))
+		case "health":
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var report healthReport
+			if err := xml.Unmarshal(body, &report); err != nil {
+				t.Errorf("bad health report %q: %v", body, err)
+			}
+			*reports = append(*reports, report)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestPostStatus(t *testing.T) {
+	var reports []healthReport
+	server := stubWireserver(t, &reports)
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	origGoalState, origHealth := goalStateUrl, healthUrl
+	defer func() { goalStateUrl, healthUrl = origGoalState, origHealth }()
+	goalStateUrl.Scheme, goalStateUrl.Host = u.Scheme, u.Host
+	healthUrl.Scheme, healthUrl.Host = u.Scheme, u.Host
+
+	logger := log.New(true)
+	f := resource.Fetcher{Logger: &logger}
+	ready := role{
+		InstanceId: "d0f1b7d4-3a9d-4a4a-8f0c-2f2d5b8d1b8e._vm",
+		State:      "Ready",
+	}
+	failed := role{
+		InstanceId: "d0f1b7d4-3a9d-4a4a-8f0c-2f2d5b8d1b8e._vm",
+		State:      "NotReady",
+		Details: &detail{
+			SubStatus:   "ProvisioningFailed",
+			Description: "[disks] Ignition error: broken",
+		},
+	}
+
+	type in struct {
+		stage string
+		err   error
+	}
+	type out struct {
+		roles []role
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{stage: "disks"},
+			out: out{},
+		},
+		{
+			in:  in{stage: "files"},
+			out: out{roles: []role{ready}},
+		},
+		{
+			in:  in{stage: "disks", err: errors.New("broken")},
+			out: out{roles: []role{failed}},
+		},
+	}
+
+	for i, test := range tests {
+		reports = nil
+		err := PostStatus(test.in.stage, f, test.in.err)
+		if !assert.NoError(t, err, "#%d", i) {
+			continue
+		}
+		if test.out.roles == nil {
+			assert.Empty(t, reports, "#%d", i)
+			continue
+		}
+		if assert.Len(t, reports, 1, "#%d", i) {
+			assert.Equal(t, "3", reports[0].GoalStateIncarnation, "#%d", i)
+			assert.Equal(t, "c6d5526c-5ac2-4200-b6e2-56f2b70c5ab2", reports[0].ContainerId, "#%d", i)
+			assert.Equal(t, test.out.roles, reports[0].Roles, "#%d", i)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The cloudsigma provider fetches a configuration from the server context,
+// which CloudSigma serves on the second serial port.
+
+package cloudsigma
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/flatcar/ignition/config/validate/report"
+	"github.com/flatcar/ignition/internal/config/types"
+	"github.com/flatcar/ignition/internal/providers/util"
+	"github.com/flatcar/ignition/internal/resource"
+)
+
+const (
+	// contextRequest asks for the whole server context.
+	contextRequest = "<\n\n>"
+	// contextEnd terminates the response.
+	contextEnd = '\x04'
+
+	userdataKey     = "cloudinit-user-data"
+	base64FieldsKey = "base64_fields"
+
+	readTimeout = 60 * time.Second
+)
+
+var (
+	serialPort = "/dev/ttyS1"
+)
+
+func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
+	f.Logger.Debug("opening %q", serialPort)
+	port, err := os.OpenFile(serialPort, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return types.Config{}, report.Report{}, fmt.Errorf("failed to open %q: %v", serialPort, err)
+	}
+	defer port.Close()
+
+	if err := makeRaw(port); err != nil {
+		return types.Config{}, report.Report{}, fmt.Errorf("failed to configure %q: %v", serialPort, err)
+	}
+	if err := port.SetReadDeadline(time.Now().Add(readTimeout)); err != nil {
+		f.Logger.Debug("serial port does not support timeouts: %v", err)
+	}
+
+	serverContext, err := fetchServerContext(port)
+	if err != nil {
+		return types.Config{}, report.Report{}, fmt.Errorf("failed to read the server context: %v", err)
+	}
+	data, err := userdataFromContext(serverContext)
+	if err != nil {
+		return types.Config{}, report.Report{}, err
+	}
+
+	return util.ParseConfig(f.Logger, data)
+}
+
+// makeRaw disables the line discipline of the serial port, so the request
+// and the response are passed unmodified.
+func makeRaw(port *os.File) error {
+	var termios syscall.Termios
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, port.Fd(), syscall.TCGETS, uintptr(unsafe.Pointer(&termios))); errno != 0 {
+		return errno
+	}
+	termios.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	termios.Oflag &^= syscall.OPOST
+	termios.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	termios.Cflag &^= syscall.CSIZE | syscall.PARENB
+	termios.Cflag |= syscall.CS8
+	termios.Cc[syscall.VMIN] = 1
+	termios.Cc[syscall.VTIME] = 0
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, port.Fd(), syscall.TCSETS, uintptr(unsafe.Pointer(&termios))); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// fetchServerContext requests the server context and reads the response,
+// which is a single line of JSON terminated by contextEnd.
+func fetchServerContext(port io.ReadWriter) ([]byte, error) {
+	if _, err := io.WriteString(port, contextRequest); err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(port)
+	var response []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b == contextEnd || b == '\n' {
+			return response, nil
+		}
+		response = append(response, b)
+	}
+}
+
+// userdataFromContext extracts the userdata from the meta fields of the
+// server context, decoding it if it is listed in the base64 fields.
+func userdataFromContext(serverContext []byte) ([]byte, error) {
+	var context struct {
+		Meta map[string]string `json:"meta"`
+	}
+	if err := json.Unmarshal(serverContext, &context); err != nil {
+		return nil, fmt.Errorf("failed to parse the server context: %v", err)
+	}
+
+	userdata, ok := context.Meta[userdataKey]
+	if !ok {
+		return nil, nil
+	}
+	for _, field := range strings.Split(context.Meta[base64FieldsKey], ",") {
+		if strings.TrimSpace(field) == userdataKey {
+			data, err := base64.StdEncoding.DecodeString(userdata)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode %s: %v", userdataKey, err)
+			}
+			return data, nil
+		}
+	}
+	return []byte(userdata), nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudsigma
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePort records the request and answers with the response.
+type fakePort struct {
+	io.Reader
+	request bytes.Buffer
+}
+
+func (p *fakePort) Write(b []byte) (int, error) {
+	return p.request.Write(b)
+}
+
+func TestFetchServerContext(t *testing.T) {
+	type in struct {
+		response string
+	}
+	type out struct {
+		context string
+		err     bool
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{response: `{"meta": {}}` + "\x04\n"},
+			out: out{context: `{"meta": {}}`},
+		},
+		{
+			in:  in{response: `{"meta": {}}` + "\n"},
+			out: out{context: `{"meta": {}}`},
+		},
+		{
+			in:  in{response: `{"meta": {}}`},
+			out: out{err: true},
+		},
+	}
+
+	for i, test := range tests {
+		port := &fakePort{Reader: bytes.NewBufferString(test.in.response)}
+		context, err := fetchServerContext(port)
+		assert.Equal(t, contextRequest, port.request.String(), "#%d: bad request", i)
+		if test.out.err {
+			assert.Error(t, err, "#%d", i)
+			continue
+		}
+		assert.NoError(t, err, "#%d", i)
+		assert.Equal(t, test.out.context, string(context), "#%d: bad context", i)
+	}
+}
+
+func TestUserdataFromContext(t *testing.T) {
+	type in struct {
+		context string
+	}
+	type out struct {
+		userdata []byte
+		err      bool
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{context: `{"meta": {"cloudinit-user-data": "{\"ignition\": {}}"}}`},
+			out: out{userdata: []byte(`{"ignition": {}}`)},
+		},
+		{
+			in:  in{context: `{"meta": {"cloudinit-user-data": "eyJpZ25pdGlvbiI6IHt9fQ==", "base64_fields": "ssh_key, cloudinit-user-data"}}`},
+			out: out{userdata: []byte(`{"ignition": {}}`)},
+		},
+		{
+			in:  in{context: `{"meta": {"cloudinit-user-data": "not base64", "base64_fields": "cloudinit-user-data"}}`},
+			out: out{err: true},
+		},
+		{
+			in:  in{context: `{"meta": {"ssh_public_key": "ssh-rsa AAAA"}}`},
+			out: out{},
+		},
+		{
+			in:  in{context: `not json`},
+			out: out{err: true},
+		},
+	}
+
+	for i, test := range tests {
+		userdata, err := userdataFromContext([]byte(test.in.context))
+		if test.out.err {
+			assert.Error(t, err, "#%d", i)
+			continue
+		}
+		assert.NoError(t, err, "#%d", i)
+		assert.Equal(t, test.out.userdata, userdata, "#%d: bad userdata", i)
+	}
+}
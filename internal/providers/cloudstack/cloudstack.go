@@ -22,21 +22,16 @@ import (
 	"bufio"
 	"context"
 	"fmt"
-	"io/ioutil"
 	"net"
 	"net/url"
 	"os"
-	"os/exec"
-	"path/filepath"
 	"strings"
-	"syscall"
 	"time"
 
 	"github.com/flatcar/ignition/config/validate/report"
 	"github.com/flatcar/ignition/internal/config"
 	"github.com/flatcar/ignition/internal/config/types"
-	"github.com/flatcar/ignition/internal/distro"
-	"github.com/flatcar/ignition/internal/log"
+	"github.com/flatcar/ignition/internal/providers/util"
 	"github.com/flatcar/ignition/internal/resource"
 )
 
@@ -45,6 +40,10 @@ const (
 	LeaseRetryInterval      = 500 * time.Millisecond
 )
 
+var (
+	configDriveLabels = []string{"config-2", "CONFIG-2"}
+)
+
 func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
 	var data []byte
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -66,12 +65,8 @@ func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
 		cancel()
 	}
 
-	go dispatch("config drive (config)", func() ([]byte, error) {
-		return fetchConfigFromDevice(f.Logger, ctx, "config-2")
-	})
-
-	go dispatch("config drive (CONFIG)", func() ([]byte, error) {
-		return fetchConfigFromDevice(f.Logger, ctx, "CONFIG-2")
+	go dispatch("config drive", func() ([]byte, error) {
+		return util.FetchFromConfigDrive(f.Logger, ctx, configDriveLabels, configDriveUserdataPath)
 	})
 
 	go dispatch("metadata service", func() ([]byte, error) {
@@ -86,26 +81,6 @@ func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
 	return config.Parse(data)
 }
 
-func fileExists(path string) bool {
-	_, err := os.Stat(path)
-	return (err == nil)
-}
-
-func labelExists(label string) bool {
-	_, err := getPath(label)
-	return (err == nil)
-}
-
-func getPath(label string) (string, error) {
-	path := filepath.Join(distro.DiskByLabelDir(), label)
-
-	if fileExists(path) {
-		return path, nil
-	}
-
-	return "", fmt.Errorf("label not found: %s", label)
-}
-
 func findLease() (*os.File, error) {
 	ifaces, err := net.Interfaces()
 	if err != nil {
@@ -153,44 +128,6 @@ func getDHCPServerAddress() (string, error) {
 	return address, nil
 }
 
-func fetchConfigFromDevice(logger *log.Logger, ctx context.Context, label string) ([]byte, error) {
-	for !labelExists(label) {
-		logger.Debug("config drive (%q) not found. Waiting...", label)
-		select {
-		case <-time.After(time.Second):
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		}
-	}
-
-	path, err := getPath(label)
-	if err != nil {
-		return nil, err
-	}
-
-	logger.Debug("creating temporary mount point")
-	mnt, err := ioutil.TempDir("", "ignition-configdrive")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create temp directory: %v", err)
-	}
-	defer os.Remove(mnt)
-
-	cmd := exec.Command(distro.MountCmd(), "-o", "ro", "-t", "auto", path, mnt)
-	if _, err := logger.LogCmd(cmd, "mounting config drive"); err != nil {
-		return nil, err
-	}
-	defer logger.LogOp(
-		func() error { return syscall.Unmount(mnt, 0) },
-		"unmounting %q at %q", path, mnt,
-	)
-
-	if !fileExists(filepath.Join(mnt, configDriveUserdataPath)) {
-		return nil, nil
-	}
-
-	return ioutil.ReadFile(filepath.Join(mnt, configDriveUserdataPath))
-}
-
 func fetchConfigFromMetadataService(f *resource.Fetcher) ([]byte, error) {
 	addr, err := getDHCPServerAddress()
 	if err != nil {
@@ -0,0 +1,105 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/flatcar/ignition/internal/resource"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+const (
+	credentialsPath = "latest/meta-data/iam/security-credentials/"
+
+	// expiryWindow makes the credentials expire early so that requests
+	// are not signed with credentials which run out in flight.
+	expiryWindow = 5 * time.Minute
+)
+
+// roleProvider retrieves the credentials of the IAM role attached to the
+// instance from the metadata service. Unlike the ec2rolecreds provider of
+// the AWS SDK, it sends the same IMDSv2 session token as the rest of the
+// provider, so S3 URLs also work on instances which require IMDSv2.
+type roleProvider struct {
+	credentials.Expiry
+
+	fetcher *resource.Fetcher
+}
+
+func (p *roleProvider) Retrieve() (credentials.Value, error) {
+	creds, err := p.retrieve()
+	if err != nil {
+		// the S3 fetcher retries anonymously on this code, like it does
+		// for the SDK's own provider
+		return credentials.Value{}, awserr.New("EC2RoleRequestError", "failed to retrieve the IAM role credentials", err)
+	}
+	return creds, nil
+}
+
+func (p *roleProvider) retrieve() (credentials.Value, error) {
+	m, err := connect(p.fetcher)
+	if err != nil {
+		return credentials.Value{}, err
+	}
+
+	data, err := p.fetcher.FetchToBuffer(m.url(credentialsPath), resource.FetchOptions{
+		Headers: m.headers(nil),
+	})
+	if err != nil {
+		return credentials.Value{}, err
+	}
+	// the listing holds one role per line; instances have at most one
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	if !scanner.Scan() || scanner.Text() == "" {
+		return credentials.Value{}, fmt.Errorf("no IAM role attached to the instance")
+	}
+	role := scanner.Text()
+
+	data, err = p.fetcher.FetchToBuffer(m.url(credentialsPath+role), resource.FetchOptions{
+		Headers: m.headers(nil),
+	})
+	if err != nil {
+		return credentials.Value{}, err
+	}
+	var resp struct {
+		Code            string
+		Message         string
+		AccessKeyID     string `json:"AccessKeyId"`
+		SecretAccessKey string
+		Token           string
+		Expiration      time.Time
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return credentials.Value{}, err
+	}
+	if resp.Code != "Success" {
+		return credentials.Value{}, fmt.Errorf("credentials of role %q unavailable: %s: %s", role, resp.Code, resp.Message)
+	}
+	p.SetExpiration(resp.Expiration, expiryWindow)
+
+	return credentials.Value{
+		AccessKeyID:     resp.AccessKeyID,
+		SecretAccessKey: resp.SecretAccessKey,
+		SessionToken:    resp.Token,
+		ProviderName:    "EC2RoleProvider",
+	}, nil
+}
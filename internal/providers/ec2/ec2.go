@@ -18,39 +18,96 @@
 package ec2
 
 import (
+	"encoding/json"
+	"errors"
+	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/flatcar/ignition/config/validate/report"
 	"github.com/flatcar/ignition/internal/config/types"
+	"github.com/flatcar/ignition/internal/distro"
 	"github.com/flatcar/ignition/internal/log"
 	"github.com/flatcar/ignition/internal/providers/util"
 	"github.com/flatcar/ignition/internal/resource"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials/ec2rolecreds"
-	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 )
 
+const (
+	userdataPath = "2009-04-04/user-data"
+	identityPath = "latest/dynamic/instance-identity/document"
+	hostnamePath = "latest/meta-data/local-hostname"
+	publicIPPath = "latest/meta-data/public-ipv4"
+	tokenPath    = "latest/api/token"
+
+	tokenHeader    = "X-aws-ec2-metadata-token"
+	tokenTTLHeader = "X-aws-ec2-metadata-token-ttl-seconds"
+	tokenTTL       = "21600"
+)
+
 var (
-	userdataUrl = url.URL{
+	// metadataHosts are the endpoints of the instance metadata service. The
+	// IPv6 endpoint is the only one reachable from IPv6-only subnets.
+	metadataHosts = []string{"169.254.169.254", "[fd00:ec2::254]"}
+
+	// probeTimeout is how long an endpoint is tried before moving on to
+	// the next one.
+	probeTimeout = 10 * time.Second
+
+	// allowIMDSv1 permits endpoints which reject the token request to be
+	// used without a session token. Distributions which only run on
+	// IMDSv2-capable services can disable it at build time.
+	allowIMDSv1 = distro.EC2IMDSv1Fallback()
+)
+
+// metadataService is an endpoint of the instance metadata service and the
+// IMDSv2 session token obtained from it. The token is empty if the endpoint
+// only supports IMDSv1.
+type metadataService struct {
+	host  string
+	token string
+}
+
+func (m metadataService) url(path string) url.URL {
+	return url.URL{
 		Scheme: "http",
-		Host:   "169.254.169.254",
-		Path:   "2009-04-04/user-data",
+		Host:   m.host,
+		Path:   path,
 	}
-)
+}
+
+// headers returns a copy of base with the session token added.
+func (m metadataService) headers(base http.Header) http.Header {
+	h := http.Header{}
+	for key, values := range base {
+		h[key] = values
+	}
+	if m.token != "" {
+		h.Set(tokenHeader, m.token)
+	}
+	return h
+}
 
 func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
-	data, err := f.FetchToBuffer(userdataUrl, resource.FetchOptions{
-		Headers: resource.ConfigHeaders,
+	m, err := connect(f)
+	if err != nil {
+		return types.Config{}, report.Report{}, err
+	}
+
+	data, err := f.FetchToBuffer(m.url(userdataPath), resource.FetchOptions{
+		Headers: m.headers(resource.ConfigHeaders),
 	})
 	if err != nil && err != resource.ErrNotFound {
 		return types.Config{}, report.Report{}, err
 	}
 
 	// Determine the partition and region this instance is in
-	regionHint, err := ec2metadata.New(f.AWSSession).Region()
+	regionHint, err := fetchRegion(f, m)
 	if err != nil {
+		f.Logger.Warning("failed to determine the region, assuming us-east-1: %v", err)
 		regionHint = "us-east-1"
 	}
 	f.S3RegionHint = regionHint
@@ -58,12 +115,119 @@ func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
 	return util.ParseConfig(f.Logger, data)
 }
 
+// connect finds a reachable metadata endpoint and obtains a session token
+// from it. The endpoints are tried in turn until one of them responds, since
+// the network might not be up yet. IMDSv1 is only used if the endpoint
+// rejects the token request and allowIMDSv1 is set; instances which require
+// IMDSv2 always hand out tokens, so this only affects metadata services
+// which predate IMDSv2.
+func connect(f *resource.Fetcher) (metadataService, error) {
+	for {
+		for _, host := range metadataHosts {
+			m := metadataService{host: host}
+			token, err := f.FetchToBuffer(m.url(tokenPath), resource.FetchOptions{
+				HTTPVerb: "PUT",
+				Headers:  http.Header{tokenTTLHeader: []string{tokenTTL}},
+				Timeout:  probeTimeout,
+			})
+			switch err {
+			case nil:
+				m.token = string(token)
+				return m, nil
+			case resource.ErrForbidden, resource.ErrNotFound, resource.ErrMethodNotAllowed:
+				if !allowIMDSv1 {
+					f.Logger.Err("metadata service at %s does not hand out session tokens and IMDSv1 is disabled", host)
+					return metadataService{}, err
+				}
+				f.Logger.Warning("metadata service at %s does not hand out session tokens, falling back to IMDSv1: %v", host, err)
+				return m, nil
+			case resource.ErrTimeout:
+				continue
+			default:
+				return metadataService{}, err
+			}
+		}
+	}
+}
+
+// fetchRegion reads the region from the instance identity document.
+func fetchRegion(f *resource.Fetcher, m metadataService) (string, error) {
+	data, err := f.FetchToBuffer(m.url(identityPath), resource.FetchOptions{
+		Headers: m.headers(nil),
+	})
+	if err != nil {
+		return "", err
+	}
+	var doc struct {
+		Region string `json:"region"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return "", err
+	}
+	if doc.Region == "" {
+		return "", errors.New("identity document contains no region")
+	}
+	return doc.Region, nil
+}
+
+// FetchMetadata reads the instance identity document, the hostname and the
+// public address of the instance from the metadata service.
+func FetchMetadata(f *resource.Fetcher) (map[string]string, error) {
+	m, err := connect(f)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := f.FetchToBuffer(m.url(identityPath), resource.FetchOptions{
+		Headers: m.headers(nil),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		AvailabilityZone string `json:"availabilityZone"`
+		InstanceID       string `json:"instanceId"`
+		InstanceType     string `json:"instanceType"`
+		PrivateIP        string `json:"privateIp"`
+		Region           string `json:"region"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	metadata := util.Metadata{}
+	metadata.Add("instance_id", doc.InstanceID)
+	metadata.Add("instance_type", doc.InstanceType)
+	metadata.Add("private_ipv4", doc.PrivateIP)
+	metadata.Add("region", doc.Region)
+	metadata.Add("zone", doc.AvailabilityZone)
+
+	// the public address is missing for instances without one
+	for key, path := range map[string]string{
+		"hostname":    hostnamePath,
+		"public_ipv4": publicIPPath,
+	} {
+		value, err := f.FetchToBuffer(m.url(path), resource.FetchOptions{
+			Headers: m.headers(nil),
+		})
+		switch err {
+		case nil:
+			metadata.Add(key, string(value))
+		case resource.ErrNotFound:
+		default:
+			return nil, err
+		}
+	}
+	return metadata, nil
+}
+
 func NewFetcher(l *log.Logger) (resource.Fetcher, error) {
 	sess, err := session.NewSession(&aws.Config{})
 	if err != nil {
 		return resource.Fetcher{}, err
 	}
-	sess.Config.Credentials = ec2rolecreds.NewCredentials(sess)
+	sess.Config.Credentials = credentials.NewCredentials(&roleProvider{
+		fetcher: &resource.Fetcher{Logger: l},
+	})
 
 	return resource.Fetcher{
 		Logger:     l,
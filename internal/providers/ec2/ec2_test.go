@@ -0,0 +1,270 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flatcar/ignition/internal/log"
+	"github.com/flatcar/ignition/internal/resource"
+)
+
+const (
+	testToken    = "AQAEAFTNrA4eEGx0AQgJ1arIq_Cc-t4tWt3fB0Hd8p3Ge4iuQ2Q0Qw=="
+	testIdentity = `{"availabilityZone": "eu-central-1a", "instanceId": "i-0123456789abcdef0", "instanceType": "t3.micro", "privateIp": "10.0.0.5", "region": "eu-central-1"}`
+	testUserdata = `{"ignition": {"version": "2.2.0"}, "passwd": {"users": [{"name": "core"}]}}`
+	testRole     = `{"Code": "Success", "AccessKeyId": "ASIAEXAMPLE", "SecretAccessKey": "secret", "Token": "session", "Expiration": "2030-01-01T00:00:00Z"}`
+)
+
+// stubMetadataService serves the metadata service and records the requests.
+// If tokenStatus is not zero, token requests fail with it. Other requests
+// must carry the session token if one was handed out and none otherwise.
+func stubMetadataService(tokenStatus int, requests *[]string) *httptest.Server {
+	return httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r.Method+" "+r.URL.Path)
+		if r.URL.Path == "/"+tokenPath {
+			switch {
+			case tokenStatus != 0:
+				w.WriteHeader(tokenStatus)
+			case r.Method != "PUT" || r.Header.Get(tokenTTLHeader) == "":
+				w.WriteHeader(http.StatusBadRequest)
+			default:
+				w.Write([]byte(testToken))
+			}
+			return
+		}
+		token := r.Header.Get(tokenHeader)
+		if (tokenStatus == 0 && token != testToken) || (tokenStatus != 0 && token != "") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/" + userdataPath:
+			w.Write([]byte(testUserdata))
+		case "/" + identityPath:
+			w.Write([]byte(testIdentity))
+		case "/" + hostnamePath:
+			w.Write([]byte("ip-10-0-0-5.eu-central-1.compute.internal"))
+		case "/" + credentialsPath:
+			w.Write([]byte("ignition-role\n"))
+		case "/" + credentialsPath + "ignition-role":
+			w.Write([]byte(testRole))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+// useStub points the provider at the given hosts and returns a function
+// restoring the defaults.
+func useStub(hosts ...string) func() {
+	origHosts, origTimeout, origAllow := metadataHosts, probeTimeout, allowIMDSv1
+	metadataHosts = hosts
+	probeTimeout = time.Second
+	return func() { metadataHosts, probeTimeout, allowIMDSv1 = origHosts, origTimeout, origAllow }
+}
+
+func serverHost(t *testing.T, server *httptest.Server) string {
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Host
+}
+
+func TestConnect(t *testing.T) {
+	type in struct {
+		tokenStatus int
+		allowIMDSv1 bool
+	}
+	type out struct {
+		token string
+		err   error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{allowIMDSv1: true},
+			out: out{token: testToken},
+		},
+		{
+			in:  in{allowIMDSv1: false},
+			out: out{token: testToken},
+		},
+		{
+			in:  in{tokenStatus: http.StatusForbidden, allowIMDSv1: true},
+			out: out{},
+		},
+		{
+			in:  in{tokenStatus: http.StatusNotFound, allowIMDSv1: true},
+			out: out{},
+		},
+		{
+			in:  in{tokenStatus: http.StatusMethodNotAllowed, allowIMDSv1: true},
+			out: out{},
+		},
+		{
+			in:  in{tokenStatus: http.StatusForbidden, allowIMDSv1: false},
+			out: out{err: resource.ErrForbidden},
+		},
+		{
+			in:  in{tokenStatus: http.StatusMethodNotAllowed, allowIMDSv1: false},
+			out: out{err: resource.ErrMethodNotAllowed},
+		},
+	}
+
+	for i, test := range tests {
+		var requests []string
+		server := stubMetadataService(test.in.tokenStatus, &requests)
+		server.Start()
+		restore := useStub(serverHost(t, server))
+		allowIMDSv1 = test.in.allowIMDSv1
+
+		logger := log.New(true)
+		m, err := connect(&resource.Fetcher{Logger: &logger})
+		restore()
+		server.Close()
+
+		assert.Equal(t, test.out.err, err, "#%d: bad error", i)
+		assert.Equal(t, test.out.token, m.token, "#%d: bad token", i)
+		assert.Equal(t, []string{"PUT /" + tokenPath}, requests, "#%d: bad requests", i)
+	}
+}
+
+func TestFetchConfig(t *testing.T) {
+	tests := []struct {
+		tokenStatus int
+	}{
+		{},
+		{tokenStatus: http.StatusForbidden},
+		{tokenStatus: http.StatusMethodNotAllowed},
+	}
+
+	for i, test := range tests {
+		var requests []string
+		server := stubMetadataService(test.tokenStatus, &requests)
+		server.Start()
+		restore := useStub(serverHost(t, server))
+		allowIMDSv1 = true
+
+		logger := log.New(true)
+		f := resource.Fetcher{Logger: &logger}
+		config, _, err := FetchConfig(&f)
+		restore()
+		server.Close()
+
+		// the stub rejects requests with a missing or unexpected token
+		if !assert.NoError(t, err, "#%d", i) {
+			continue
+		}
+		if assert.Len(t, config.Passwd.Users, 1, "#%d: bad users", i) {
+			assert.Equal(t, "core", config.Passwd.Users[0].Name, "#%d: bad user", i)
+		}
+		assert.Equal(t, "eu-central-1", f.S3RegionHint, "#%d: bad region", i)
+		assert.Equal(t, []string{
+			"PUT /" + tokenPath,
+			"GET /" + userdataPath,
+			"GET /" + identityPath,
+		}, requests, "#%d: bad requests", i)
+	}
+}
+
+func TestFetchMetadata(t *testing.T) {
+	var requests []string
+	server := stubMetadataService(0, &requests)
+	server.Start()
+	defer server.Close()
+	defer useStub(serverHost(t, server))()
+
+	logger := log.New(true)
+	metadata, err := FetchMetadata(&resource.Fetcher{Logger: &logger})
+	if !assert.NoError(t, err) {
+		return
+	}
+	// the stub has no public address
+	assert.Equal(t, map[string]string{
+		"hostname":      "ip-10-0-0-5.eu-central-1.compute.internal",
+		"instance_id":   "i-0123456789abcdef0",
+		"instance_type": "t3.micro",
+		"private_ipv4":  "10.0.0.5",
+		"region":        "eu-central-1",
+		"zone":          "eu-central-1a",
+	}, metadata)
+}
+
+func TestRoleProvider(t *testing.T) {
+	var requests []string
+	server := stubMetadataService(0, &requests)
+	server.Start()
+	defer server.Close()
+	defer useStub(serverHost(t, server))()
+
+	logger := log.New(true)
+	p := roleProvider{fetcher: &resource.Fetcher{Logger: &logger}}
+	creds, err := p.Retrieve()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "ASIAEXAMPLE", creds.AccessKeyID)
+	assert.Equal(t, "secret", creds.SecretAccessKey)
+	assert.Equal(t, "session", creds.SessionToken)
+	assert.False(t, p.IsExpired())
+	assert.Equal(t, []string{
+		"PUT /" + tokenPath,
+		"GET /" + credentialsPath,
+		"GET /" + credentialsPath + "ignition-role",
+	}, requests)
+}
+
+func TestIPv6Endpoint(t *testing.T) {
+	listener, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("no IPv6 loopback: %v", err)
+	}
+	var requests []string
+	server := stubMetadataService(0, &requests)
+	server.Listener.Close()
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	// the IPv4 endpoint is unreachable, like in IPv6-only subnets
+	closed, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable := closed.Addr().String()
+	closed.Close()
+	defer useStub(unreachable, serverHost(t, server))()
+
+	logger := log.New(true)
+	f := resource.Fetcher{Logger: &logger}
+	config, _, err := FetchConfig(&f)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, config.Passwd.Users, 1)
+	assert.Equal(t, "eu-central-1", f.S3RegionHint)
+}
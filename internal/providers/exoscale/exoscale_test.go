@@ -0,0 +1,89 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exoscale
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flatcar/ignition/config/shared/errors"
+	"github.com/flatcar/ignition/internal/log"
+	"github.com/flatcar/ignition/internal/resource"
+)
+
+func TestFetchConfig(t *testing.T) {
+	type in struct {
+		status   int
+		userdata string
+	}
+	type out struct {
+		user string
+		err  error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{status: http.StatusOK, userdata: `{"ignition": {"version": "2.2.0"}, "passwd": {"users": [{"name": "core"}]}}`},
+			out: out{user: "core"},
+		},
+		{
+			in:  in{status: http.StatusNotFound},
+			out: out{err: errors.ErrEmpty},
+		},
+		{
+			in:  in{status: http.StatusOK, userdata: "#cloud-config"},
+			out: out{err: errors.ErrCloudConfig},
+		},
+		{
+			in:  in{status: http.StatusForbidden},
+			out: out{err: resource.ErrForbidden},
+		},
+	}
+
+	origUrl := userdataUrl
+	defer func() { userdataUrl = origUrl }()
+
+	for i, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/"+origUrl.Path {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(test.in.status)
+			w.Write([]byte(test.in.userdata))
+		}))
+		u, err := url.Parse(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		userdataUrl.Host = u.Host
+
+		logger := log.New(true)
+		config, _, err := FetchConfig(&resource.Fetcher{Logger: &logger})
+		server.Close()
+
+		assert.Equal(t, test.out.err, err, "#%d: bad error", i)
+		if test.out.err == nil && assert.Len(t, config.Passwd.Users, 1, "#%d: bad users", i) {
+			assert.Equal(t, test.out.user, config.Passwd.Users[0].Name, "#%d: bad user", i)
+		}
+	}
+}
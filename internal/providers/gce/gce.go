@@ -18,7 +18,10 @@
 package gce
 
 import (
+	"net/http"
 	"net/url"
+	"path"
+	"strings"
 
 	"github.com/flatcar/ignition/config/validate/report"
 	"github.com/flatcar/ignition/internal/config/types"
@@ -34,6 +37,17 @@ var (
 	}
 	metadataHeaderKey = "Metadata-Flavor"
 	metadataHeaderVal = "Google"
+
+	// metadataPaths maps the keys of the instance metadata to the paths
+	// they are read from.
+	metadataPaths = map[string]string{
+		"hostname":      "computeMetadata/v1/instance/hostname",
+		"instance_id":   "computeMetadata/v1/instance/id",
+		"instance_type": "computeMetadata/v1/instance/machine-type",
+		"private_ipv4":  "computeMetadata/v1/instance/network-interfaces/0/ip",
+		"public_ipv4":   "computeMetadata/v1/instance/network-interfaces/0/access-configs/0/external-ip",
+		"zone":          "computeMetadata/v1/instance/zone",
+	}
 )
 
 func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
@@ -48,3 +62,44 @@ func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
 
 	return util.ParseConfig(f.Logger, data)
 }
+
+// FetchMetadata reads the hostname, addresses and placement of the instance
+// from the metadata server. The machine type and zone are reported as
+// resource paths, of which only the last element is kept.
+func FetchMetadata(f *resource.Fetcher) (map[string]string, error) {
+	headers := http.Header{}
+	headers.Set(metadataHeaderKey, metadataHeaderVal)
+
+	metadata := util.Metadata{}
+	for key, p := range metadataPaths {
+		value, err := f.FetchToBuffer(url.URL{
+			Scheme: "http",
+			Host:   "metadata.google.internal",
+			Path:   p,
+		}, resource.FetchOptions{
+			Headers: headers,
+		})
+		switch err {
+		case nil:
+		case resource.ErrNotFound:
+			// instances without an external address have no access config
+			continue
+		default:
+			return nil, err
+		}
+		switch key {
+		case "instance_type", "zone":
+			if len(value) > 0 {
+				metadata.Add(key, path.Base(string(value)))
+			}
+		default:
+			metadata.Add(key, string(value))
+		}
+	}
+	if zone, ok := metadata["zone"]; ok {
+		if i := strings.LastIndex(zone, "-"); i > 0 {
+			metadata["region"] = zone[:i]
+		}
+	}
+	return metadata, nil
+}
@@ -21,33 +21,51 @@ package openstack
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net/url"
-	"os"
-	"os/exec"
-	"path/filepath"
-	"syscall"
 	"time"
 
 	"github.com/flatcar/ignition/config/validate/report"
 	"github.com/flatcar/ignition/internal/config"
 	"github.com/flatcar/ignition/internal/config/types"
-	"github.com/flatcar/ignition/internal/distro"
-	"github.com/flatcar/ignition/internal/log"
+	"github.com/flatcar/ignition/internal/providers/util"
 	"github.com/flatcar/ignition/internal/resource"
 )
 
 const (
-	configDriveUserdataPath = "/openstack/latest/user_data"
+	configDriveUserdataPath    = "/openstack/latest/user_data"
+	configDriveMetadataPath    = "/openstack/latest/meta_data.json"
+	configDriveEC2MetadataPath = "/ec2/latest/meta-data.json"
 )
 
 var (
+	configDriveLabels = []string{"config-2", "CONFIG-2"}
+
 	metadataServiceUrl = url.URL{
 		Scheme: "http",
 		Host:   "169.254.169.254",
 		Path:   "openstack/latest/user_data",
 	}
+
+	metadataJSONUrl = url.URL{
+		Scheme: "http",
+		Host:   "169.254.169.254",
+		Path:   "openstack/latest/meta_data.json",
+	}
+
+	// ec2MetadataPaths are the paths of the EC2-compatible metadata service
+	// carrying the addresses of the instance, which meta_data.json lacks.
+	ec2MetadataPaths = map[string]string{
+		"private_ipv4": "latest/meta-data/local-ipv4",
+		"public_ipv4":  "latest/meta-data/public-ipv4",
+	}
+
+	// metadataTimeout bounds how long the config drive is waited for and
+	// each request to the metadata service is retried while fetching the
+	// metadata. The config drive, if any, was already found while fetching
+	// the config, so it doesn't need to be waited for long.
+	metadataTimeout = 10 * time.Second
 )
 
 func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
@@ -71,12 +89,8 @@ func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
 		cancel()
 	}
 
-	go dispatch("config drive (config-2)", func() ([]byte, error) {
-		return fetchConfigFromDevice(f.Logger, ctx, filepath.Join(distro.DiskByLabelDir(), "config-2"))
-	})
-
-	go dispatch("config drive (CONFIG-2)", func() ([]byte, error) {
-		return fetchConfigFromDevice(f.Logger, ctx, filepath.Join(distro.DiskByLabelDir(), "CONFIG-2"))
+	go dispatch("config drive", func() ([]byte, error) {
+		return util.FetchFromConfigDrive(f.Logger, ctx, configDriveLabels, configDriveUserdataPath)
 	})
 
 	go dispatch("metadata service", func() ([]byte, error) {
@@ -91,47 +105,111 @@ func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
 	return config.Parse(data)
 }
 
-func fileExists(path string) bool {
-	_, err := os.Stat(path)
-	return (err == nil)
+func fetchConfigFromMetadataService(f *resource.Fetcher) ([]byte, error) {
+	res, err := f.FetchToBuffer(metadataServiceUrl, resource.FetchOptions{
+		Headers: resource.ConfigHeaders,
+	})
+	return res, err
 }
 
-func fetchConfigFromDevice(logger *log.Logger, ctx context.Context, path string) ([]byte, error) {
-	for !fileExists(path) {
-		logger.Debug("config drive (%q) not found. Waiting...", path)
-		select {
-		case <-time.After(time.Second):
-		case <-ctx.Done():
-			return nil, ctx.Err()
+// FetchMetadata reads the hostname, identity and zone of the instance from
+// the config drive, or from the metadata service if there is no config drive.
+// The addresses are read from the EC2-compatible metadata of either, if it is
+// available. OpenStack doesn't report the region to the instance.
+func FetchMetadata(f *resource.Fetcher) (map[string]string, error) {
+	metadata, err := fetchMetadataFromConfigDrive(f)
+	if err != nil {
+		switch err {
+		case context.DeadlineExceeded:
+			f.Logger.Info("config drive not found, fetching metadata from the metadata service")
+		default:
+			f.Logger.Err("failed to fetch metadata from config drive: %v", err)
 		}
+		return fetchMetadataFromMetadataService(f)
 	}
+	return metadata, nil
+}
+
+// metadataJSON is the subset of meta_data.json used as metadata.
+type metadataJSON struct {
+	AvailabilityZone string `json:"availability_zone"`
+	Hostname         string `json:"hostname"`
+	UUID             string `json:"uuid"`
+}
+
+func (doc metadataJSON) metadata() util.Metadata {
+	metadata := util.Metadata{}
+	metadata.Add("hostname", doc.Hostname)
+	metadata.Add("instance_id", doc.UUID)
+	metadata.Add("zone", doc.AvailabilityZone)
+	return metadata
+}
+
+func fetchMetadataFromConfigDrive(f *resource.Fetcher) (util.Metadata, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), metadataTimeout)
+	defer cancel()
 
-	logger.Debug("creating temporary mount point")
-	mnt, err := ioutil.TempDir("", "ignition-configdrive")
+	data, err := util.FetchFromConfigDrive(f.Logger, ctx, configDriveLabels, configDriveMetadataPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create temp directory: %v", err)
+		return nil, err
 	}
-	defer os.Remove(mnt)
-
-	cmd := exec.Command(distro.MountCmd(), "-o", "ro", "-t", "auto", path, mnt)
-	if _, err := logger.LogCmd(cmd, "mounting config drive"); err != nil {
+	if data == nil {
+		return nil, fmt.Errorf("config drive has no %s", configDriveMetadataPath)
+	}
+	var doc metadataJSON
+	if err := json.Unmarshal(data, &doc); err != nil {
 		return nil, err
 	}
-	defer logger.LogOp(
-		func() error { return syscall.Unmount(mnt, 0) },
-		"unmounting %q at %q", path, mnt,
-	)
+	metadata := doc.metadata()
 
-	if !fileExists(filepath.Join(mnt, configDriveUserdataPath)) {
-		return nil, nil
+	// the EC2-compatible metadata is missing if the cloud disabled it
+	data, err = util.FetchFromConfigDrive(f.Logger, ctx, configDriveLabels, configDriveEC2MetadataPath)
+	if err != nil {
+		return nil, err
 	}
-
-	return ioutil.ReadFile(filepath.Join(mnt, configDriveUserdataPath))
+	if data == nil {
+		return metadata, nil
+	}
+	var ec2Doc struct {
+		LocalIPv4  string `json:"local-ipv4"`
+		PublicIPv4 string `json:"public-ipv4"`
+	}
+	if err := json.Unmarshal(data, &ec2Doc); err != nil {
+		return nil, err
+	}
+	metadata.Add("private_ipv4", ec2Doc.LocalIPv4)
+	metadata.Add("public_ipv4", ec2Doc.PublicIPv4)
+	return metadata, nil
 }
 
-func fetchConfigFromMetadataService(f *resource.Fetcher) ([]byte, error) {
-	res, err := f.FetchToBuffer(metadataServiceUrl, resource.FetchOptions{
-		Headers: resource.ConfigHeaders,
+func fetchMetadataFromMetadataService(f *resource.Fetcher) (util.Metadata, error) {
+	data, err := f.FetchToBuffer(metadataJSONUrl, resource.FetchOptions{
+		Timeout: metadataTimeout,
 	})
-	return res, err
+	if err != nil {
+		return nil, err
+	}
+	var doc metadataJSON
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	metadata := doc.metadata()
+
+	for key, path := range ec2MetadataPaths {
+		value, err := f.FetchToBuffer(url.URL{
+			Scheme: "http",
+			Host:   metadataJSONUrl.Host,
+			Path:   path,
+		}, resource.FetchOptions{
+			Timeout: metadataTimeout,
+		})
+		switch err {
+		case nil:
+			metadata.Add(key, string(value))
+		case resource.ErrNotFound:
+		default:
+			return nil, err
+		}
+	}
+	return metadata, nil
 }
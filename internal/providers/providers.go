@@ -30,3 +30,4 @@ var (
 type FuncFetchConfig func(f *resource.Fetcher) (types.Config, report.Report, error)
 type FuncNewFetcher func(logger *log.Logger) (resource.Fetcher, error)
 type FuncPostStatus func(stageName string, f resource.Fetcher, e error) error
+type FuncFetchMetadata func(f *resource.Fetcher) (map[string]string, error)
@@ -0,0 +1,55 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The rackspace provider fetches a configuration from the userdata on the
+// config drive, which Rackspace attaches to both virtual and OnMetal servers.
+
+package rackspace
+
+import (
+	"context"
+	"time"
+
+	"github.com/flatcar/ignition/config/validate/report"
+	"github.com/flatcar/ignition/internal/config/types"
+	"github.com/flatcar/ignition/internal/providers/util"
+	"github.com/flatcar/ignition/internal/resource"
+)
+
+const (
+	configDriveUserdataPath = "/openstack/latest/user_data"
+	configDriveTimeout      = 30 * time.Second
+)
+
+var (
+	configDriveLabels = []string{"config-2", "CONFIG-2"}
+
+	// fetchFromConfigDrive is replaced in tests, which can't mount a
+	// config drive.
+	fetchFromConfigDrive = util.FetchFromConfigDrive
+)
+
+func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), configDriveTimeout)
+	defer cancel()
+
+	data, err := fetchFromConfigDrive(f.Logger, ctx, configDriveLabels, configDriveUserdataPath)
+	if err == context.DeadlineExceeded {
+		f.Logger.Info("config drive was not available in time. Continuing without a config...")
+	} else if err != nil {
+		return types.Config{}, report.Report{}, err
+	}
+
+	return util.ParseConfig(f.Logger, data)
+}
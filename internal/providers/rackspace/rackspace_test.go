@@ -0,0 +1,82 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rackspace
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flatcar/ignition/config/shared/errors"
+	"github.com/flatcar/ignition/internal/log"
+	"github.com/flatcar/ignition/internal/resource"
+)
+
+func TestFetchConfig(t *testing.T) {
+	type in struct {
+		userdata []byte
+		err      error
+	}
+	type out struct {
+		user string
+		err  error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{userdata: []byte(`{"ignition": {"version": "2.2.0"}, "passwd": {"users": [{"name": "core"}]}}`)},
+			out: out{user: "core"},
+		},
+		// no userdata on the config drive
+		{
+			in:  in{},
+			out: out{err: errors.ErrEmpty},
+		},
+		// no config drive
+		{
+			in:  in{err: context.DeadlineExceeded},
+			out: out{err: errors.ErrEmpty},
+		},
+		{
+			in:  in{userdata: []byte("#cloud-config")},
+			out: out{err: errors.ErrCloudConfig},
+		},
+	}
+
+	origFetch := fetchFromConfigDrive
+	defer func() { fetchFromConfigDrive = origFetch }()
+
+	for i, test := range tests {
+		var path string
+		fetchFromConfigDrive = func(_ *log.Logger, _ context.Context, labels []string, p string) ([]byte, error) {
+			assert.Equal(t, configDriveLabels, labels, "#%d: bad labels", i)
+			path = p
+			return test.in.userdata, test.in.err
+		}
+
+		logger := log.New(true)
+		config, _, err := FetchConfig(&resource.Fetcher{Logger: &logger})
+
+		assert.Equal(t, configDriveUserdataPath, path, "#%d: bad path", i)
+		assert.Equal(t, test.out.err, err, "#%d: bad error", i)
+		if test.out.err == nil && assert.Len(t, config.Passwd.Users, 1, "#%d: bad users", i) {
+			assert.Equal(t, test.out.user, config.Passwd.Users[0].Name, "#%d: bad user", i)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/flatcar/ignition/internal/distro"
+	"github.com/flatcar/ignition/internal/log"
+)
+
+// FetchFromConfigDrive waits for a device with one of the given labels to
+// appear, mounts it read-only and returns the contents of the file at path
+// on it. A config drive without the file yields no data. It gives up with
+// the error of ctx once ctx is done.
+func FetchFromConfigDrive(logger *log.Logger, ctx context.Context, labels []string, path string) ([]byte, error) {
+	device, err := waitForConfigDrive(logger, ctx, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debug("creating temporary mount point")
+	mnt, err := ioutil.TempDir("", "ignition-configdrive")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %v", err)
+	}
+	defer os.Remove(mnt)
+
+	cmd := exec.Command(distro.MountCmd(), "-o", "ro", "-t", "auto", device, mnt)
+	if _, err := logger.LogCmd(cmd, "mounting config drive"); err != nil {
+		return nil, err
+	}
+	defer logger.LogOp(
+		func() error { return syscall.Unmount(mnt, 0) },
+		"unmounting %q at %q", device, mnt,
+	)
+
+	return readConfigDriveFile(mnt, path)
+}
+
+// waitForConfigDrive returns the path of the first device with one of the
+// labels.
+func waitForConfigDrive(logger *log.Logger, ctx context.Context, labels []string) (string, error) {
+	for {
+		for _, label := range labels {
+			device := filepath.Join(distro.DiskByLabelDir(), label)
+			if _, err := os.Stat(device); err == nil {
+				return device, nil
+			}
+		}
+		logger.Debug("config drive (%v) not found. Waiting...", labels)
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// readConfigDriveFile reads the file at path on the config drive mounted at
+// mnt.
+func readConfigDriveFile(mnt, path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(filepath.Join(mnt, path))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
@@ -0,0 +1,72 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadConfigDriveFile(t *testing.T) {
+	type in struct {
+		files map[string]string
+	}
+	type out struct {
+		userdata []byte
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{files: map[string]string{"openstack/latest/user_data": `{"ignition": {}}`}},
+			out: out{userdata: []byte(`{"ignition": {}}`)},
+		},
+		{
+			in:  in{files: map[string]string{"openstack/latest/meta_data.json": `{}`}},
+			out: out{},
+		},
+		{
+			in:  in{},
+			out: out{},
+		},
+	}
+
+	for i, test := range tests {
+		mnt, err := ioutil.TempDir("", "ignition-configdrive")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(mnt)
+		for path, contents := range test.in.files {
+			path = filepath.Join(mnt, path)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		userdata, err := readConfigDriveFile(mnt, "/openstack/latest/user_data")
+		assert.NoError(t, err, "#%d", i)
+		assert.Equal(t, test.out.userdata, userdata, "#%d: bad userdata", i)
+	}
+}
@@ -240,12 +240,17 @@ func (f *Fetcher) newHttpClient() error {
 	return nil
 }
 
-// getReaderWithHeader performs an HTTP GET on the provided URL with the
-// provided request header and returns the response body Reader, HTTP status
-// code, a cancel function for the result's context, and error (if any). By
-// default, User-Agent is added to the header but this can be overridden.
-func (c HttpClient) getReaderWithHeader(url string, header http.Header) (io.ReadCloser, int, context.CancelFunc, error) {
-	req, err := http.NewRequest("GET", url, nil)
+// getReaderWithHeader performs an HTTP request with the provided verb (GET if
+// empty) on the provided URL with the provided request header and returns the
+// response body Reader, HTTP status code, a cancel function for the result's
+// context, and error (if any). By default, User-Agent is added to the header
+// but this can be overridden. If timeout is non-zero it replaces the total
+// timeout of the client.
+func (c HttpClient) getReaderWithHeader(verb, url string, header http.Header, timeout time.Duration) (io.ReadCloser, int, context.CancelFunc, error) {
+	if verb == "" {
+		verb = "GET"
+	}
+	req, err := http.NewRequest(verb, url, nil)
 	if err != nil {
 		return nil, 0, nil, err
 	}
@@ -259,25 +264,28 @@ func (c HttpClient) getReaderWithHeader(url string, header http.Header) (io.Read
 		}
 	}
 
+	if timeout == 0 {
+		timeout = c.timeout
+	}
 	ctx, cancelFn := context.WithCancel(context.Background())
-	if c.timeout != 0 {
+	if timeout != 0 {
 		cancelFn()
-		ctx, cancelFn = context.WithTimeout(context.Background(), c.timeout)
+		ctx, cancelFn = context.WithTimeout(context.Background(), timeout)
 	}
 
 	duration := initialBackoff
 	for attempt := 1; ; attempt++ {
-		c.logger.Info("GET %s: attempt #%d", url, attempt)
+		c.logger.Info("%s %s: attempt #%d", verb, url, attempt)
 		resp, err := c.client.Do(req.WithContext(ctx))
 
 		if err == nil {
-			c.logger.Info("GET result: %s", http.StatusText(resp.StatusCode))
+			c.logger.Info("%s result: %s", verb, http.StatusText(resp.StatusCode))
 			if resp.StatusCode < 500 {
 				return resp.Body, resp.StatusCode, cancelFn, nil
 			}
 			resp.Body.Close()
 		} else {
-			c.logger.Info("GET error: %v", err)
+			c.logger.Info("%s error: %v", verb, err)
 		}
 
 		duration = duration * 2
@@ -16,6 +16,7 @@ package resource
 
 import (
 	"bytes"
+	"compress/bzip2"
 	"compress/gzip"
 	"context"
 	"encoding/hex"
@@ -30,6 +31,7 @@ import (
 	"path/filepath"
 	"strings"
 	"syscall"
+	"time"
 
 	configErrors "github.com/flatcar/ignition/config/shared/errors"
 	"github.com/flatcar/ignition/internal/distro"
@@ -52,6 +54,8 @@ var (
 	ErrPathNotAbsolute        = errors.New("path is not absolute")
 	ErrNotFound               = errors.New("resource not found")
 	ErrFailed                 = errors.New("failed to fetch resource")
+	ErrForbidden              = errors.New("access to resource is forbidden")
+	ErrMethodNotAllowed       = errors.New("method not allowed for resource")
 	ErrCompressionUnsupported = errors.New("compression is not supported with that scheme")
 
 	// ConfigHeaders are the HTTP headers that should be used when the Ignition
@@ -80,6 +84,11 @@ type Fetcher struct {
 	// The region where the EC2 machine trying to fetch is.
 	// This is used as a hint to fetch the S3 bucket from the right partition and region.
 	S3RegionHint string
+
+	// Metadata returns the metadata of the instance as reported by the
+	// platform, for rendering templates. If left nil, no metadata is
+	// available.
+	Metadata func() (map[string]string, error)
 }
 
 type FetchOptions struct {
@@ -93,6 +102,14 @@ type FetchOptions struct {
 	// schemes.
 	HeadersRedirect http.Header
 
+	// HTTPVerb is the HTTP method used when fetching http(s) resources. If
+	// left empty, GET will be used.
+	HTTPVerb string
+
+	// Timeout limits how long a http(s) resource is retried. If left as zero,
+	// the total timeout of the HTTP client is used.
+	Timeout time.Duration
+
 	// Hash is the hash to use when calculating a fetched resource's hash. If
 	// left as nil, no hash will be calculated.
 	Hash hash.Hash
@@ -241,7 +258,7 @@ func (f *Fetcher) FetchFromHTTP(u url.URL, dest *os.File, opts FetchOptions) err
 		return nil
 	}
 
-	dataReader, status, ctxCancel, err := f.client.getReaderWithHeader(u.String(), opts.Headers)
+	dataReader, status, ctxCancel, err := f.client.getReaderWithHeader(opts.HTTPVerb, u.String(), opts.Headers, opts.Timeout)
 	if ctxCancel != nil {
 		// whatever context getReaderWithHeader created for the request should
 		// be cancelled once we're done reading the response
@@ -257,6 +274,10 @@ func (f *Fetcher) FetchFromHTTP(u url.URL, dest *os.File, opts FetchOptions) err
 		break
 	case http.StatusNotFound:
 		return ErrNotFound
+	case http.StatusForbidden:
+		return ErrForbidden
+	case http.StatusMethodNotAllowed:
+		return ErrMethodNotAllowed
 	default:
 		return ErrFailed
 	}
@@ -376,7 +397,7 @@ func (f *Fetcher) FetchFromS3(u url.URL, dest *os.File, opts FetchOptions) error
 		Key:       &u.Path,
 		VersionId: versionId,
 	}
-	err = f.fetchFromS3WithCreds(ctx, dest, input, sess)
+	size, err := f.fetchFromS3WithCreds(ctx, dest, input, sess)
 	if err != nil {
 		return err
 	}
@@ -386,7 +407,8 @@ func (f *Fetcher) FetchFromS3(u url.URL, dest *os.File, opts FetchOptions) error
 		if err != nil {
 			return err
 		}
-		_, err = io.Copy(opts.Hash, dest)
+		// dest may be a block device which is larger than the object
+		_, err = io.Copy(opts.Hash, io.LimitReader(dest, size))
 		if err != nil {
 			return err
 		}
@@ -402,25 +424,26 @@ func (f *Fetcher) FetchFromS3(u url.URL, dest *os.File, opts FetchOptions) error
 	return nil
 }
 
-func (f *Fetcher) fetchFromS3WithCreds(ctx context.Context, dest *os.File, input *s3.GetObjectInput, sess *session.Session) error {
+func (f *Fetcher) fetchFromS3WithCreds(ctx context.Context, dest *os.File, input *s3.GetObjectInput, sess *session.Session) (int64, error) {
 	httpClient, err := defaultHTTPClient()
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	awsConfig := aws.NewConfig().WithHTTPClient(httpClient)
 	s3Client := s3.New(sess, awsConfig)
 	downloader := s3manager.NewDownloaderWithClient(s3Client)
-	if _, err := downloader.DownloadWithContext(ctx, dest, input); err != nil {
+	size, err := downloader.DownloadWithContext(ctx, dest, input)
+	if err != nil {
 		if awserrval, ok := err.(awserr.Error); ok && awserrval.Code() == "EC2RoleRequestError" {
 			// If this error was due to an EC2 role request error, try again
 			// with the anonymous credentials.
 			sess.Config.Credentials = credentials.AnonymousCredentials
 			return f.fetchFromS3WithCreds(ctx, dest, input, sess)
 		}
-		return err
+		return 0, err
 	}
-	return nil
+	return size, nil
 }
 
 // uncompress will wrap the given io.Reader in a decompresser specified in the
@@ -431,6 +454,8 @@ func (f *Fetcher) uncompress(r io.Reader, opts FetchOptions) (io.ReadCloser, err
 		return ioutil.NopCloser(r), nil
 	case "gzip":
 		return gzip.NewReader(r)
+	case "bzip2":
+		return ioutil.NopCloser(bzip2.NewReader(r)), nil
 	default:
 		return nil, configErrors.ErrCompressionInvalid
 	}
@@ -15,19 +15,29 @@
 package sgdisk
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/flatcar/ignition/internal/config/types"
 	"github.com/flatcar/ignition/internal/distro"
 	"github.com/flatcar/ignition/internal/log"
+	"github.com/flatcar/ignition/internal/partitioners"
+)
+
+var (
+	ErrBadSgdiskOutput = errors.New("sgdisk had unexpected output")
 )
 
 type Operation struct {
 	logger    *log.Logger
 	dev       string
 	wipe      bool
+	moveHdr   bool
 	parts     []types.Partition
 	deletions []int
 	infos     []int
@@ -56,50 +66,84 @@ func (op *Operation) WipeTable(wipe bool) {
 	op.wipe = wipe
 }
 
-// Pretend is like Commit() but uses the --pretend flag and returns the output
-// on stdout for parsing.
+// MoveSecondHeader toggles if the backup GPT header is moved to the end of
+// the disk before any partitions are changed. This is needed to make the
+// space of a disk that grew since it was partitioned usable.
+func (op *Operation) MoveSecondHeader(move bool) {
+	op.moveHdr = move
+}
+
+// Creations returns the partitions to be created as part of an operation.
+func (op *Operation) Creations() []types.Partition {
+	return op.parts
+}
+
+// Deletions returns the numbers of the partitions to be deleted as part of an
+// operation.
+func (op *Operation) Deletions() []int {
+	return op.deletions
+}
+
+// Pretend is like Commit() but uses the --pretend flag and returns the
+// dimensions of the partitions passed to Info, parsed from the output.
+// Wiping the table is pretended by clearing it, since --zap-all doesn't
+// honor --pretend.
 //
 // Note: because sgdisk does not do any escaping on its output, callers should ensure
 //       the partitions' labels do not have any nasty characters that will interfere
 //       with parsing (e.g. \n)
-func (op *Operation) Pretend() (string, error) {
-	opts := []string{"--pretend"}
-	opts = append(opts, op.buildOptions()...)
+func (op *Operation) Pretend() (map[int]partitioners.Dimensions, error) {
+	pretendOp := *op
+	pretendOp.wipe = false
+	opts, err := pretendOp.buildOptions()
+	if err != nil {
+		return nil, err
+	}
+	if op.wipe {
+		if len(opts) == 0 {
+			opts = []string{op.dev}
+		}
+		opts = append([]string{"--clear"}, opts...)
+	}
+	opts = append([]string{"--pretend"}, opts...)
 	op.logger.Info("running sgdisk with options: %v", opts)
 
 	cmd := exec.Command(distro.SgdiskCmd(), opts...)
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if err := cmd.Start(); err != nil {
-		return "", err
+		return nil, err
 	}
 	output, err := ioutil.ReadAll(stdout)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	errors, err := ioutil.ReadAll(stderr)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if err := cmd.Wait(); err != nil {
-		return "", fmt.Errorf("Failed to pretend to create partitions. Err: %v. Stderr: %v", err, string(errors))
+		return nil, fmt.Errorf("Failed to pretend to create partitions. Err: %v. Stderr: %v", err, string(errors))
 	}
 
-	return string(output), nil
+	return parseSgdiskPretend(string(output), op.infos)
 }
 
 // Commit commits an partitioning operation.
 func (op *Operation) Commit() error {
-	opts := op.buildOptions()
+	opts, err := op.buildOptions()
+	if err != nil {
+		return err
+	}
 	if len(opts) == 0 {
 		return nil
 	}
@@ -112,13 +156,17 @@ func (op *Operation) Commit() error {
 	return nil
 }
 
-func (op Operation) buildOptions() []string {
+func (op Operation) buildOptions() ([]string, error) {
 	opts := []string{}
 
 	if op.wipe {
 		opts = append(opts, "--zap-all")
 	}
 
+	if op.moveHdr {
+		opts = append(opts, "--move-second-header")
+	}
+
 	// Do all deletions before creations
 	for _, partition := range op.deletions {
 		opts = append(opts, fmt.Sprintf("--delete=%d", partition))
@@ -135,6 +183,13 @@ func (op Operation) buildOptions() []string {
 		if p.GUID != "" {
 			opts = append(opts, fmt.Sprintf("--partition-guid=%d:%s", p.Number, p.GUID))
 		}
+		if len(p.Attributes) > 0 {
+			bits, err := p.ParseAttributes()
+			if err != nil {
+				return nil, fmt.Errorf("partition %d: %v", p.Number, err)
+			}
+			opts = append(opts, fmt.Sprintf("--attributes=%d:=:%016x", p.Number, bits))
+		}
 	}
 
 	for _, partition := range op.infos {
@@ -142,11 +197,11 @@ func (op Operation) buildOptions() []string {
 	}
 
 	if len(opts) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	opts = append(opts, op.dev)
-	return opts
+	return opts, nil
 }
 
 func partitionGetStart(p types.Partition) string {
@@ -168,3 +223,85 @@ func partitionGetSize(p types.Partition) string {
 	}
 	return "0"
 }
+
+// parseLine takes a regexp that captures an int and a string to match on. On success it returns
+// the captured int and nil. If the regexp does not match it returns -1 and nil. If it encountered
+// an error it returns 0 and the error.
+func parseLine(r *regexp.Regexp, line string) (int, error) {
+	matches := r.FindStringSubmatch(line)
+	switch len(matches) {
+	case 0:
+		return -1, nil
+	case 2:
+		return strconv.Atoi(matches[1])
+	default:
+		return 0, ErrBadSgdiskOutput
+	}
+}
+
+// parseSgdiskPretend parses the output of running sgdisk pretend with --info specified for each partition
+// number specified in partitionNumbers. E.g. if paritionNumbers is [1,4,5], it is expected that the sgdisk
+// output was from running `sgdisk --pretend <commands> --info=1 --info=4 --info=5`. It assumes the the
+// partition labels are well behaved (i.e. contain no control characters). It returns a list of partitions
+// matching the partition numbers specified, but with the start and size information as determined by sgdisk.
+// The partition numbers need to passed in because sgdisk includes them in its output.
+func parseSgdiskPretend(sgdiskOut string, partitionNumbers []int) (map[int]partitioners.Dimensions, error) {
+	if len(partitionNumbers) == 0 {
+		return nil, nil
+	}
+	startRegex := regexp.MustCompile("^First sector: (\\d*) \\(.*\\)$")
+	endRegex := regexp.MustCompile("^Last sector: (\\d*) \\(.*\\)$")
+	const (
+		START             = iota
+		END               = iota
+		FAIL_ON_START_END = iota
+	)
+
+	output := map[int]partitioners.Dimensions{}
+	state := START
+	current := partitioners.Dimensions{}
+	i := 0
+
+	lines := strings.Split(sgdiskOut, "\n")
+	for _, line := range lines {
+		switch state {
+		case START:
+			start, err := parseLine(startRegex, line)
+			if err != nil {
+				return nil, err
+			}
+			if start != -1 {
+				current.Start = start
+				state = END
+			}
+		case END:
+			end, err := parseLine(endRegex, line)
+			if err != nil {
+				return nil, err
+			}
+			if end != -1 {
+				current.Size = 1 + end - current.Start
+				output[partitionNumbers[i]] = current
+				i++
+				if i == len(partitionNumbers) {
+					state = FAIL_ON_START_END
+				} else {
+					current = partitioners.Dimensions{}
+					state = START
+				}
+			}
+		case FAIL_ON_START_END:
+			if len(startRegex.FindStringSubmatch(line)) != 0 ||
+				len(endRegex.FindStringSubmatch(line)) != 0 {
+				return nil, ErrBadSgdiskOutput
+			}
+		}
+	}
+
+	if state != FAIL_ON_START_END {
+		// We stopped parsing in the middle of a info block. Something is wrong
+		return nil, ErrBadSgdiskOutput
+	}
+
+	return output, nil
+}
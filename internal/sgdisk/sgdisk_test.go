@@ -0,0 +1,80 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sgdisk
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flatcar/ignition/internal/partitioners"
+)
+
+func TestParseSgdiskPretend(t *testing.T) {
+	type in struct {
+		output  string
+		numbers []int
+	}
+	type out struct {
+		dims map[int]partitioners.Dimensions
+		err  error
+	}
+
+	info := func(first, last string) string {
+		return "Partition GUID code: 0FC63DAF-8483-4772-8E79-3D69D8477DE4 (Linux filesystem)\n" +
+			"Partition unique GUID: 5AD6E5A8-7F51-4B6F-9F2E-C1B6AAB9AB5C\n" +
+			"First sector: " + first + " (at 1024.0 KiB)\n" +
+			"Last sector: " + last + " (at 8.0 MiB)\n" +
+			"Partition size: 16384 sectors (8.0 MiB)\n" +
+			"Attribute flags: 0000000000000000\n" +
+			"Partition name: ''\n"
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{"", nil},
+			out: out{},
+		},
+		{
+			in: in{"Setting name!\npartNum is 0\n" + info("2048", "18431") + info("18432", "131038"), []int{1, 3}},
+			out: out{dims: map[int]partitioners.Dimensions{
+				1: {Start: 2048, Size: 16384},
+				3: {Start: 18432, Size: 112607},
+			}},
+		},
+		// more partitions than requested
+		{
+			in:  in{info("2048", "18431") + info("18432", "131038"), []int{1}},
+			out: out{err: ErrBadSgdiskOutput},
+		},
+		// fewer partitions than requested
+		{
+			in:  in{info("2048", "18431"), []int{1, 3}},
+			out: out{err: ErrBadSgdiskOutput},
+		},
+	}
+
+	for i, test := range tests {
+		dims, err := parseSgdiskPretend(test.in.output, test.in.numbers)
+		if err != test.out.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+		if !reflect.DeepEqual(test.out.dims, dims) {
+			t.Errorf("#%d: bad dimensions: want %v, got %v", i, test.out.dims, dims)
+		}
+	}
+}
@@ -49,6 +49,15 @@ func TestAssertValid(t *testing.T) {
 			},
 			out: out{},
 		},
+		{
+			in: in{
+				verification: types.Verification{
+					Hash: stringDeref("sha256-2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"),
+				},
+				data: []byte("hello"),
+			},
+			out: out{},
+		},
 		{
 			in: in{
 				verification: types.Verification{
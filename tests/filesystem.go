@@ -18,6 +18,7 @@ import (
 	"bufio"
 	"context"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -161,7 +162,8 @@ func umountPartition(p *types.Partition) error {
 // returns true if no error, false if error
 func runIgnition(t *testing.T, ctx context.Context, stage, root, cwd string, appendEnv []string) error {
 	args := []string{"-clear-cache", "-oem", "file", "-stage", stage,
-		"-root", root, "-log-to-stdout", "--config-cache", filepath.Join(cwd, "ignition.json")}
+		"-root", root, "-log-to-stdout", "--config-cache", filepath.Join(cwd, "ignition.json"),
+		"--result-cache", filepath.Join(cwd, "ignition-result.json")}
 	cmd := exec.CommandContext(ctx, "ignition", args...)
 	t.Log("ignition", args)
 	cmd.Dir = cwd
@@ -262,10 +264,33 @@ func setupDisk(ctx context.Context, disk *types.Disk, diskIndex int, imageSize i
 }
 
 func destroyDevice(loopDevice string) error {
+	if err := removeMappedDevices(loopDevice); err != nil {
+		return err
+	}
 	_, err := runWithoutContext("losetup", "-d", loopDevice)
 	return err
 }
 
+// removeMappedDevices removes the device-mapper devices stacked on the
+// partitions of loopDevice, such as opened LUKS volumes, which would keep
+// it busy otherwise.
+func removeMappedDevices(loopDevice string) error {
+	holders, err := filepath.Glob(filepath.Join("/sys/block", filepath.Base(loopDevice), "*", "holders", "dm-*"))
+	if err != nil {
+		return err
+	}
+	for _, holder := range holders {
+		name, err := ioutil.ReadFile(filepath.Join("/sys/block", filepath.Base(holder), "dm/name"))
+		if err != nil {
+			return err
+		}
+		if _, err := runWithoutContext("dmsetup", "remove", strings.TrimSpace(string(name))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func formatPartition(ctx context.Context, partition *types.Partition) error {
 	var mkfs string
 	var opts, label, uuid []string
@@ -450,6 +475,18 @@ func createFilesFromSlice(basedir string, files []types.File) error {
 			}
 			writer.Flush()
 		}
+		if err := setXattrs(filepath.Join(basedir, file.Directory, file.Name), file.Node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setXattrs(path string, node types.Node) error {
+	for _, x := range node.Xattrs {
+		if err := syscall.Setxattr(path, x.Name, []byte(x.Value), 0); err != nil {
+			return fmt.Errorf("setting xattr %q on %q: %v", x.Name, path, err)
+		}
 	}
 	return nil
 }
@@ -0,0 +1,49 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"github.com/flatcar/ignition/tests/register"
+	"github.com/flatcar/ignition/tests/types"
+)
+
+func init() {
+	register.Register(register.NegativeTest, RemoveMissingNodeWhichMustExist())
+}
+
+func RemoveMissingNodeWhichMustExist() types.Test {
+	name := "Remove a Missing File which Must Exist"
+	in := types.GetBaseDisk()
+	out := in
+	config := `{
+	  "ignition": { "version": "$version" },
+	  "storage": {
+	    "remove": [{
+	      "filesystem": "root",
+	      "path": "/etc/missing.conf",
+	      "mustExist": true
+	    }]
+	  }
+	}`
+	configMinVersion := "2.4.0"
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
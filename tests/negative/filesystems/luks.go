@@ -0,0 +1,70 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesystems
+
+import (
+	"github.com/flatcar/ignition/tests/register"
+	"github.com/flatcar/ignition/tests/types"
+)
+
+func init() {
+	register.Register(register.NegativeTest, CreateLuksVolumeOverFilesystem())
+}
+
+func CreateLuksVolumeOverFilesystem() types.Test {
+	name := "Create a LUKS volume over an existing filesystem without wipeVolume"
+	in := types.GetBaseDisk()
+	out := in
+	mntDevices := []types.MntDevice{
+		{
+			Label:        "encrypted",
+			Substitution: "$DEVICE",
+		},
+	}
+	config := `{
+		"ignition": {"version": "$version"},
+		"storage": {
+			"luks": [{
+				"name": "data",
+				"device": "$DEVICE",
+				"keyFile": {
+					"source": "data:,secret"
+				},
+				"wipeVolume": false
+			}]
+		}
+	}`
+	configMinVersion := "2.4.0"
+	in = append(in, types.Disk{
+		Alignment: types.IgnitionAlignment,
+		Partitions: types.Partitions{
+			{
+				Label:          "encrypted",
+				Number:         1,
+				Length:         65536,
+				FilesystemType: "ext4",
+			},
+		},
+	})
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		MntDevices:       mntDevices,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
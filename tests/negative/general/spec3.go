@@ -0,0 +1,66 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package general
+
+import (
+	"github.com/flatcar/ignition/tests/register"
+	"github.com/flatcar/ignition/tests/types"
+)
+
+func init() {
+	register.Register(register.NegativeTest, UnsupportedFeatureSpec3())
+	register.Register(register.NegativeTest, VersionOnlyConfig35())
+}
+
+func UnsupportedFeatureSpec3() types.Test {
+	name := "Kernel Arguments in a Spec 3 Config"
+	in := types.GetBaseDisk()
+	out := in
+	config := `{
+	  "ignition": { "version": "$version" },
+	  "kernelArguments": {
+	    "shouldExist": ["quiet"]
+	  }
+	}`
+	configMinVersion := "3.3.0"
+
+	return types.Test{
+		Name:              name,
+		In:                in,
+		Out:               out,
+		Config:            config,
+		ConfigMinVersion:  configMinVersion,
+		ConfigShouldBeBad: true,
+	}
+}
+
+func VersionOnlyConfig35() types.Test {
+	name := "Version Only Config 3.5.0"
+	in := types.GetBaseDisk()
+	out := in
+	config := `{
+	  "ignition": {
+	    "version": "3.5.0"
+	  }
+	}`
+
+	return types.Test{
+		Name:              name,
+		In:                in,
+		Out:               out,
+		Config:            config,
+		ConfigShouldBeBad: true,
+	}
+}
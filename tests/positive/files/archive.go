@@ -0,0 +1,81 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"github.com/flatcar/ignition/tests/register"
+	"github.com/flatcar/ignition/tests/types"
+)
+
+func init() {
+	register.Register(register.PositiveTest, ExtractArchive())
+}
+
+func ExtractArchive() types.Test {
+	name := "Extract an Archive on the Root Filesystem"
+	in := types.GetBaseDisk()
+	out := types.GetBaseDisk()
+	config := `{
+	  "ignition": { "version": "$version" },
+	  "storage": {
+	    "archives": [{
+	      "filesystem": "root",
+	      "path": "/opt/bundle",
+	      "format": "tar",
+	      "contents": {
+	        "source": "http://127.0.0.1:8080/archive.tar.gz",
+	        "compression": "gzip"
+	      },
+	      "fileMode": 384
+	    }]
+	  }
+	}`
+	out[0].Partitions.AddDirectories("ROOT", []types.Directory{
+		{
+			Node: types.Node{
+				Directory: "opt/bundle",
+				Name:      "etc",
+			},
+		},
+	})
+	out[0].Partitions.AddFiles("ROOT", []types.File{
+		{
+			Node: types.Node{
+				Directory: "opt/bundle/etc",
+				Name:      "motd",
+			},
+			Contents: "etc/motd\n",
+			Mode:     0600,
+		},
+	})
+	out[0].Partitions.AddLinks("ROOT", []types.Link{
+		{
+			Node: types.Node{
+				Directory: "opt/bundle/etc",
+				Name:      "issue",
+			},
+			Target: "motd",
+		},
+	})
+	configMinVersion := "2.4.0"
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"github.com/flatcar/ignition/tests/register"
+	"github.com/flatcar/ignition/tests/types"
+)
+
+func init() {
+	register.Register(register.PositiveTest, EditExistingFile())
+}
+
+func EditExistingFile() types.Test {
+	name := "Edit an Existing File on the Root Filesystem"
+	in := types.GetBaseDisk()
+	out := types.GetBaseDisk()
+	config := `{
+	  "ignition": { "version": "$version" },
+	  "storage": {
+	    "files": [{
+	      "filesystem": "root",
+	      "path": "/etc/systemd/journald.conf",
+	      "edits": [
+	        { "action": "setKey", "section": "Journal", "key": "Storage", "value": "persistent" },
+	        { "action": "deleteLines", "regex": "^#Compress=" },
+	        { "action": "replace", "regex": "^SystemMaxUse=.*$", "replacement": "SystemMaxUse=500M" }
+	      ]
+	    }]
+	  }
+	}`
+	in[0].Partitions.AddFiles("ROOT", []types.File{
+		{
+			Node: types.Node{
+				Directory: "etc/systemd",
+				Name:      "journald.conf",
+				Xattrs: []types.Xattr{
+					{Name: "user.agent.origin", Value: "image"},
+				},
+			},
+			Contents: "[Journal]\n#Storage=auto\n#Compress=yes\nSystemMaxUse=1G\n",
+		},
+	})
+	// the edited file keeps the attributes of the original
+	out[0].Partitions.AddFiles("ROOT", []types.File{
+		{
+			Node: types.Node{
+				Directory: "etc/systemd",
+				Name:      "journald.conf",
+				Xattrs: []types.Xattr{
+					{Name: "user.agent.origin", Value: "image"},
+				},
+			},
+			Contents: "[Journal]\n#Storage=auto\nSystemMaxUse=500M\nStorage=persistent\n",
+		},
+	})
+	configMinVersion := "2.4.0"
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"github.com/flatcar/ignition/tests/register"
+	"github.com/flatcar/ignition/tests/types"
+)
+
+func init() {
+	register.Register(register.PositiveTest, RemoveNodes())
+}
+
+func RemoveNodes() types.Test {
+	name := "Remove Files, Directories and Links from the Root Filesystem"
+	in := types.GetBaseDisk()
+	out := types.GetBaseDisk()
+	config := `{
+	  "ignition": { "version": "$version" },
+	  "storage": {
+	    "remove": [{
+	      "filesystem": "root",
+	      "path": "/etc/obsolete.conf"
+	    }, {
+	      "filesystem": "root",
+	      "path": "/var/cache/old",
+	      "recursive": true
+	    }, {
+	      "filesystem": "root",
+	      "path": "/etc/editor"
+	    }, {
+	      "filesystem": "root",
+	      "path": "/etc/missing.conf"
+	    }]
+	  }
+	}`
+	in[0].Partitions.AddFiles("ROOT", []types.File{
+		{
+			Node: types.Node{
+				Directory: "etc",
+				Name:      "obsolete.conf",
+			},
+			Contents: "obsolete",
+		},
+		{
+			Node: types.Node{
+				Directory: "var/cache/old/data",
+				Name:      "entry",
+			},
+			Contents: "cached",
+		},
+		{
+			Node: types.Node{
+				Directory: "etc",
+				Name:      "editor.conf",
+			},
+			Contents: "vim",
+		},
+	})
+	in[0].Partitions.AddLinks("ROOT", []types.Link{
+		{
+			Node: types.Node{
+				Directory: "etc",
+				Name:      "editor",
+			},
+			Target: "editor.conf",
+		},
+	})
+	out[0].Partitions.AddRemovedNodes("ROOT", []types.Node{
+		{
+			Directory: "etc",
+			Name:      "obsolete.conf",
+		},
+		{
+			Directory: "var/cache",
+			Name:      "old",
+		},
+		{
+			Directory: "etc",
+			Name:      "editor",
+		},
+	})
+	// removing the link leaves its target alone
+	out[0].Partitions.AddFiles("ROOT", []types.File{
+		{
+			Node: types.Node{
+				Directory: "etc",
+				Name:      "editor.conf",
+			},
+			Contents: "vim",
+		},
+	})
+	configMinVersion := "2.4.0"
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
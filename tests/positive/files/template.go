@@ -0,0 +1,66 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"github.com/flatcar/ignition/tests/register"
+	"github.com/flatcar/ignition/tests/types"
+)
+
+func init() {
+	register.Register(register.PositiveTest, RenderTemplate())
+}
+
+func RenderTemplate() types.Test {
+	name := "Render a File Template on the Root Filesystem"
+	in := types.GetBaseDisk()
+	out := types.GetBaseDisk()
+	// The template is
+	//   hostname={{ with index .Metadata "hostname" }}{{ . }}{{ else }}localhost{{ end }}
+	// and the file provider reports no metadata.
+	config := `{
+	  "ignition": { "version": "$version" },
+	  "storage": {
+	    "files": [{
+	      "filesystem": "root",
+	      "path": "/etc/agent.conf",
+	      "contents": {
+	        "source": "data:;base64,aG9zdG5hbWU9e3sgd2l0aCBpbmRleCAuTWV0YWRhdGEgImhvc3RuYW1lIiB9fXt7IC4gfX17eyBlbHNlIH19bG9jYWxob3N0e3sgZW5kIH19Cg==",
+	        "template": true
+	      },
+	      "mode": 420
+	    }]
+	  }
+	}`
+	out[0].Partitions.AddFiles("ROOT", []types.File{
+		{
+			Node: types.Node{
+				Directory: "etc",
+				Name:      "agent.conf",
+			},
+			Contents: "hostname=localhost\n",
+			Mode:     0644,
+		},
+	})
+	configMinVersion := "2.4.0"
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
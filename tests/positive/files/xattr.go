@@ -0,0 +1,89 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"github.com/flatcar/ignition/tests/register"
+	"github.com/flatcar/ignition/tests/types"
+)
+
+func init() {
+	register.Register(register.PositiveTest, SetXattrsAndSELinuxContext())
+}
+
+func SetXattrsAndSELinuxContext() types.Test {
+	name := "Set Extended Attributes and SELinux Contexts on the Root Filesystem"
+	in := types.GetBaseDisk()
+	out := types.GetBaseDisk()
+	config := `{
+	  "ignition": { "version": "$version" },
+	  "storage": {
+	    "files": [{
+	      "filesystem": "root",
+	      "path": "/etc/agent.conf",
+	      "contents": { "source": "data:,role%3Dworker%0A" },
+	      "mode": 420,
+	      "xattrs": [
+	        { "name": "user.agent.role", "value": "worker" },
+	        { "name": "trusted.agent.origin", "value": "0x69676e6974696f6e" }
+	      ],
+	      "seLinuxContext": "system_u:object_r:etc_t:s0"
+	    }],
+	    "directories": [{
+	      "filesystem": "root",
+	      "path": "/var/lib/agent",
+	      "mode": 493,
+	      "xattrs": [
+	        { "name": "user.agent.owner", "value": "0sYWdlbnQ=" }
+	      ]
+	    }]
+	  }
+	}`
+	out[0].Partitions.AddFiles("ROOT", []types.File{
+		{
+			Node: types.Node{
+				Directory: "etc",
+				Name:      "agent.conf",
+				Xattrs: []types.Xattr{
+					{Name: "user.agent.role", Value: "worker"},
+					{Name: "trusted.agent.origin", Value: "ignition"},
+					{Name: "security.selinux", Value: "system_u:object_r:etc_t:s0\x00"},
+				},
+			},
+			Contents: "role=worker\n",
+			Mode:     0644,
+		},
+	})
+	out[0].Partitions.AddDirectories("ROOT", []types.Directory{
+		{
+			Node: types.Node{
+				Directory: "var/lib",
+				Name:      "agent",
+				Xattrs: []types.Xattr{
+					{Name: "user.agent.owner", Value: "agent"},
+				},
+			},
+		},
+	})
+	configMinVersion := "2.4.0"
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
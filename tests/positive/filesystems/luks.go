@@ -0,0 +1,106 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesystems
+
+import (
+	"github.com/flatcar/ignition/tests/register"
+	"github.com/flatcar/ignition/tests/types"
+)
+
+func init() {
+	register.Register(register.PositiveTest, CreateLuksVolume())
+}
+
+func CreateLuksVolume() types.Test {
+	name := "Create, open and format a LUKS volume"
+	in := types.GetBaseDisk()
+	out := types.GetBaseDisk()
+	mntDevices := []types.MntDevice{
+		{
+			Label:        "encrypted",
+			Substitution: "$DEVICE",
+		},
+	}
+	// the UUID is fixed so the crypttab entry is known in advance
+	config := `{
+		"ignition": {"version": "$version"},
+		"storage": {
+			"luks": [{
+				"name": "data",
+				"device": "$DEVICE",
+				"keyFile": {
+					"source": "data:,secret"
+				},
+				"label": "data",
+				"uuid": "5ad6e5a8-7f51-4b6f-9f2e-c1b6aab9ab5c"
+			}],
+			"filesystems": [{
+				"mount": {
+					"device": "/dev/disk/by-id/dm-name-data",
+					"format": "ext4",
+					"label": "DATA"
+				}
+			}]
+		}
+	}`
+	configMinVersion := "2.4.0"
+	in = append(in, types.Disk{
+		Alignment: types.IgnitionAlignment,
+		Partitions: types.Partitions{
+			{
+				Label:  "encrypted",
+				Number: 1,
+				Length: 65536,
+			},
+		},
+	})
+	out = append(out, types.Disk{
+		Alignment: types.IgnitionAlignment,
+		Partitions: types.Partitions{
+			{
+				Label:  "encrypted",
+				Number: 1,
+				Length: 65536,
+			},
+		},
+	})
+	out[0].Partitions.AddFiles("ROOT", []types.File{
+		{
+			Node: types.Node{
+				Name:      "crypttab",
+				Directory: "etc",
+			},
+			Contents: "data UUID=5ad6e5a8-7f51-4b6f-9f2e-c1b6aab9ab5c /etc/luks/data luks\n",
+			Mode:     0600,
+		},
+		{
+			Node: types.Node{
+				Name:      "data",
+				Directory: "etc/luks",
+			},
+			Contents: "secret",
+			Mode:     0400,
+		},
+	})
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		MntDevices:       mntDevices,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
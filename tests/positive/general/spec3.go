@@ -0,0 +1,212 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package general
+
+import (
+	"github.com/flatcar/ignition/tests/register"
+	"github.com/flatcar/ignition/tests/types"
+)
+
+func init() {
+	register.Register(register.PositiveTest, WriteFileSpec3())
+	register.Register(register.PositiveTest, WriteFileToMountedFilesystemSpec3())
+	register.Register(register.PositiveTest, MergeFileSpec3())
+	register.Register(register.PositiveTest, ChangeModeOfExistingFileSpec3())
+}
+
+func WriteFileSpec3() types.Test {
+	name := "Write a File and Enable a Unit with a Spec 3 Config"
+	in := types.GetBaseDisk()
+	out := types.GetBaseDisk()
+	config := `{
+	  "ignition": { "version": "$version" },
+	  "storage": {
+	    "files": [{
+	      "path": "/foo/bar",
+	      "contents": { "source": "data:,example%20file%0A" },
+	      "append": [{ "source": "data:,appended%0A" }]
+	    }]
+	  },
+	  "systemd": {
+	    "units": [{
+	      "name": "example.service",
+	      "contents": "[Service]\nType=oneshot\nExecStart=/usr/bin/true\n\n[Install]\nWantedBy=multi-user.target"
+	    }]
+	  }
+	}`
+	configMinVersion := "3.0.0"
+	out[0].Partitions.AddFiles("ROOT", []types.File{
+		{
+			Node: types.Node{
+				Name:      "bar",
+				Directory: "foo",
+			},
+			Contents: "example file\nappended\n",
+		},
+		{
+			Node: types.Node{
+				Name:      "example.service",
+				Directory: "etc/systemd/system",
+			},
+			Contents: "[Service]\nType=oneshot\nExecStart=/usr/bin/true\n\n[Install]\nWantedBy=multi-user.target",
+		},
+	})
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
+
+func WriteFileToMountedFilesystemSpec3() types.Test {
+	name := "Write a File below a Filesystem Path with a Spec 3 Config"
+	in := types.GetBaseDisk()
+	out := types.GetBaseDisk()
+	mntDevices := []types.MntDevice{
+		{
+			Label:        "EFI-SYSTEM",
+			Substitution: "$DEVICE",
+		},
+	}
+	config := `{
+	  "ignition": { "version": "$version" },
+	  "storage": {
+	    "filesystems": [{
+	      "device": "$DEVICE",
+	      "format": "ext4",
+	      "wipeFilesystem": true,
+	      "path": "/boot/efi"
+	    }],
+	    "files": [{
+	      "path": "/boot/efi/ignition/test",
+	      "contents": { "source": "data:,asdf" }
+	    }]
+	  }
+	}`
+	configMinVersion := "3.0.0"
+
+	out[0].Partitions.GetPartition("EFI-SYSTEM").FilesystemType = "ext4"
+	out[0].Partitions.GetPartition("EFI-SYSTEM").Files = []types.File{
+		{
+			Node: types.Node{
+				Name:      "test",
+				Directory: "ignition",
+			},
+			Contents: "asdf",
+		},
+	}
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		MntDevices:       mntDevices,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
+
+func MergeFileSpec3() types.Test {
+	name := "Merge a File from a Referenced Spec 3 Config"
+	in := types.GetBaseDisk()
+	out := types.GetBaseDisk()
+	// The referenced config sets new contents for /foo/bar. Appending it
+	// would write the file twice and fail because overwrite is false.
+	config := `{
+	  "ignition": {
+	    "version": "$version",
+	    "config": {
+	      "merge": [{
+	        "source": "data:,%7B%22ignition%22%3A%7B%22version%22%3A%223.0.0%22%7D%2C%22storage%22%3A%7B%22files%22%3A%5B%7B%22path%22%3A%22%2Ffoo%2Fbar%22%2C%22contents%22%3A%7B%22source%22%3A%22data%3A%2Cmerged%250A%22%7D%7D%5D%7D%7D"
+	      }]
+	    }
+	  },
+	  "storage": {
+	    "files": [{
+	      "path": "/foo/bar",
+	      "mode": 384,
+	      "contents": { "source": "data:,original%0A" }
+	    }]
+	  }
+	}`
+	configMinVersion := "3.0.0"
+	out[0].Partitions.AddFiles("ROOT", []types.File{
+		{
+			Node: types.Node{
+				Name:      "bar",
+				Directory: "foo",
+			},
+			Contents: "merged\n",
+			Mode:     0600,
+		},
+	})
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
+
+func ChangeModeOfExistingFileSpec3() types.Test {
+	name := "Change the Mode and Group of an Existing File with a Spec 3 Config"
+	in := types.GetBaseDisk()
+	out := types.GetBaseDisk()
+	// Without contents, the existing file is kept rather than replaced.
+	config := `{
+	  "ignition": { "version": "$version" },
+	  "storage": {
+	    "files": [{
+	      "path": "/etc/motd",
+	      "mode": 384,
+	      "group": { "id": 4 }
+	    }]
+	  }
+	}`
+	configMinVersion := "3.0.0"
+	in[0].Partitions.AddFiles("ROOT", []types.File{
+		{
+			Node: types.Node{
+				Name:      "motd",
+				Directory: "etc",
+			},
+			Contents: "hello\n",
+		},
+	})
+	out[0].Partitions.AddFiles("ROOT", []types.File{
+		{
+			Node: types.Node{
+				Name:      "motd",
+				Directory: "etc",
+				Group:     4,
+			},
+			Contents: "hello\n",
+			Mode:     0600,
+		},
+	})
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
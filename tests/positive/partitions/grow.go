@@ -0,0 +1,193 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package partitions
+
+import (
+	"github.com/flatcar/ignition/tests/register"
+	"github.com/flatcar/ignition/tests/types"
+)
+
+func init() {
+	register.Register(register.PositiveTest, GrowRootPartition())
+	register.Register(register.PositiveTest, GrowRootPartitionAndFilesystem())
+	register.Register(register.PositiveTest, GrowPartitionAndXfsFilesystem())
+}
+
+func GrowRootPartition() types.Test {
+	name := "Grow the ROOT partition to fill the disk keeping its GUID"
+	in := types.GetBaseDisk()
+	in[0].Partitions[9-2-1].GUID = "$uuid0"
+	out := types.GetBaseDisk()
+	out[0].Partitions[9-2-1].GUID = "$uuid0"
+	out[0].Partitions[9-2-1].Length = 12943360 + 65536
+	config := `{
+		"ignition": {
+			"version": "$version"
+		},
+		"storage": {
+			"disks": [{
+				"device": "$disk0",
+				"partitions": [{
+					"number": 9,
+					"resize": true
+				}]
+			}]
+		}
+	}`
+	configMinVersion := "2.4.0"
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
+
+func GrowRootPartitionAndFilesystem() types.Test {
+	name := "Grow the ROOT partition and its filesystem to fill the disk"
+	in := types.GetBaseDisk()
+	in[0].Partitions[9-2-1].Files = []types.File{
+		{
+			Node: types.Node{
+				Name:      "bar",
+				Directory: "foo",
+			},
+			Contents: "example file\n",
+		},
+	}
+	out := types.GetBaseDisk()
+	out[0].Partitions[9-2-1].Length = 12943360 + 65536
+	out[0].Partitions[9-2-1].FilesystemSize = 12943360 + 65536
+	out[0].Partitions[9-2-1].Files = in[0].Partitions[9-2-1].Files
+	mntDevices := []types.MntDevice{
+		{
+			Label:        "ROOT",
+			Substitution: "$DEVICE",
+		},
+	}
+	config := `{
+		"ignition": {
+			"version": "$version"
+		},
+		"storage": {
+			"disks": [{
+				"device": "$disk0",
+				"partitions": [{
+					"label": "ROOT",
+					"number": 9,
+					"resize": true
+				}]
+			}],
+			"filesystems": [{
+				"mount": {
+					"device": "$DEVICE",
+					"format": "ext4",
+					"growFilesystem": true
+				}
+			}]
+		}
+	}`
+	configMinVersion := "2.4.0"
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		MntDevices:       mntDevices,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
+
+func GrowPartitionAndXfsFilesystem() types.Test {
+	name := "Grow a partition and its xfs filesystem to fill the disk"
+	in := types.GetBaseDisk()
+	out := types.GetBaseDisk()
+	files := []types.File{
+		{
+			Node: types.Node{
+				Name:      "bar",
+				Directory: "foo",
+			},
+			Contents: "example file\n",
+		},
+	}
+	// xfs needs at least 300 MiB
+	in = append(in, types.Disk{
+		Alignment: types.IgnitionAlignment,
+		Partitions: types.Partitions{
+			{
+				Label:          "data",
+				Number:         1,
+				Length:         614400,
+				FilesystemType: "xfs",
+				Files:          files,
+			},
+		},
+	})
+	out = append(out, types.Disk{
+		Alignment: types.IgnitionAlignment,
+		Partitions: types.Partitions{
+			{
+				Label:          "data",
+				Number:         1,
+				Length:         614400 + 204800,
+				FilesystemType: "xfs",
+				FilesystemSize: 614400 + 204800,
+				Files:          files,
+			},
+		},
+	})
+	mntDevices := []types.MntDevice{
+		{
+			Label:        "data",
+			Substitution: "$DEVICE",
+		},
+	}
+	config := `{
+		"ignition": {
+			"version": "$version"
+		},
+		"storage": {
+			"disks": [{
+				"device": "$disk1",
+				"partitions": [{
+					"label": "data",
+					"number": 1,
+					"resize": true
+				}]
+			}],
+			"filesystems": [{
+				"mount": {
+					"device": "$DEVICE",
+					"format": "xfs",
+					"growFilesystem": true
+				}
+			}]
+		}
+	}`
+	configMinVersion := "2.4.0"
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		MntDevices:       mntDevices,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
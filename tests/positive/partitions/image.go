@@ -0,0 +1,60 @@
+// Copyright 2020 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package partitions
+
+import (
+	"github.com/flatcar/ignition/tests/register"
+	"github.com/flatcar/ignition/tests/types"
+)
+
+func init() {
+	register.Register(register.PositiveTest, WritePartitionImage())
+}
+
+func WritePartitionImage() types.Test {
+	name := "Write a compressed image onto an existing partition"
+	in := types.GetBaseDisk()
+	out := types.GetBaseDisk()
+	// the served image is a swap area, which replaces the ext2 filesystem
+	out[0].Partitions.GetPartition("USR-A").FilesystemType = "swap"
+	out[0].Partitions.GetPartition("USR-A").FilesystemLabel = "imaged"
+	out[0].Partitions.GetPartition("USR-A").FilesystemUUID = "5f1a2b3c-4d5e-4f60-8172-839405a6b7c8"
+	config := `{
+		"ignition": {
+			"version": "$version"
+		},
+		"storage": {
+			"disks": [{
+				"device": "$disk0",
+				"partitions": [{
+					"number": 3,
+					"image": {
+						"source": "http://127.0.0.1:8080/swap.img.gz",
+						"compression": "gzip"
+					}
+				}]
+			}]
+		}
+	}`
+	configMinVersion := "2.4.0"
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
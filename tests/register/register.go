@@ -50,6 +50,11 @@ func Register(tType TestType, t types.Test) {
 		{semver.Version{}}, // place holder
 		{types1.MaxVersion},
 		{types20.MaxVersion, types21.MaxVersion, types22.MaxVersion, types23.MaxVersion, types24.MaxVersion},
+		{
+			semver.Version{Major: 3, Minor: 0}, semver.Version{Major: 3, Minor: 1},
+			semver.Version{Major: 3, Minor: 2}, semver.Version{Major: 3, Minor: 3},
+			semver.Version{Major: 3, Minor: 4},
+		},
 	}
 
 	test := types.DeepCopy(t)
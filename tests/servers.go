@@ -15,7 +15,11 @@
 package blackbox
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net/http"
@@ -59,8 +63,66 @@ AKbyaAqbChEy9CvDgyv6qxTYU+eeBImLKS3PH2uW5etc/69V/sDojqpH3hEffsOt
 
 	servedContents = []byte(`asdf
 fdsa`)
+
+	// servedSwapImage is a 1 MiB swap area labeled "imaged", which blkid
+	// recognizes once it is written onto a partition.
+	servedSwapImage = swapImage("imaged", "5f1a2b3c-4d5e-4f60-8172-839405a6b7c8")
+
+	// servedArchive is a tar archive with a directory holding a file and a
+	// symlink to it.
+	servedArchive = tarArchive([]tar.Header{
+		{Name: "etc/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "etc/motd", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "etc/issue", Typeflag: tar.TypeSymlink, Linkname: "motd"},
+	})
+
+	// servedEscapingArchive is a tar archive with a member outside of the
+	// directory it is extracted into.
+	servedEscapingArchive = tarArchive([]tar.Header{
+		{Name: "../escaped", Typeflag: tar.TypeReg, Mode: 0644},
+	})
 )
 
+// tarArchive returns a tar archive with the given members. Regular files
+// contain their name and a newline.
+func tarArchive(members []tar.Header) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, m := range members {
+		var contents []byte
+		if m.Typeflag == tar.TypeReg {
+			contents = []byte(m.Name + "\n")
+			m.Size = int64(len(contents))
+		}
+		if err := tw.WriteHeader(&m); err != nil {
+			panic(err)
+		}
+		if _, err := tw.Write(contents); err != nil {
+			panic(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+// swapImage returns a swap area with 4 KiB pages and the given label and
+// UUID, laid out like mkswap does.
+func swapImage(label, uuid string) []byte {
+	image := make([]byte, 1<<20)
+	binary.LittleEndian.PutUint32(image[1024:], 1)                         // version
+	binary.LittleEndian.PutUint32(image[1028:], uint32(len(image)/4096-1)) // last page
+	id, err := hex.DecodeString(strings.Replace(uuid, "-", "", -1))
+	if err != nil {
+		panic(err)
+	}
+	copy(image[1036:1052], id)
+	copy(image[1052:1068], label)
+	copy(image[4096-10:], "SWAPSPACE2")
+	return image
+}
+
 // HTTP Server
 func (server *HTTPServer) Config(w http.ResponseWriter, r *http.Request) {
 	w.Write(servedConfig)
@@ -70,6 +132,24 @@ func (server *HTTPServer) Contents(w http.ResponseWriter, r *http.Request) {
 	w.Write(servedContents)
 }
 
+// SwapImage serves servedSwapImage compressed with gzip.
+func (server *HTTPServer) SwapImage(w http.ResponseWriter, r *http.Request) {
+	gw := gzip.NewWriter(w)
+	gw.Write(servedSwapImage)
+	gw.Close()
+}
+
+// Archive serves servedArchive compressed with gzip.
+func (server *HTTPServer) Archive(w http.ResponseWriter, r *http.Request) {
+	gw := gzip.NewWriter(w)
+	gw.Write(servedArchive)
+	gw.Close()
+}
+
+func (server *HTTPServer) EscapingArchive(w http.ResponseWriter, r *http.Request) {
+	w.Write(servedEscapingArchive)
+}
+
 func (server *HTTPServer) Certificates(w http.ResponseWriter, r *http.Request) {
 	w.Write(servedPublicKey)
 }
@@ -233,6 +313,9 @@ func (server *HTTPServer) Start() {
 	http.HandleFunc("/config_headers_replace", server.ConfigReplaceOriginalHeaders)
 	http.HandleFunc("/config_headers_redirect", server.ConfigRedirect)
 	http.HandleFunc("/config_headers_redirected", server.ConfigRedirected)
+	http.HandleFunc("/swap.img.gz", server.SwapImage)
+	http.HandleFunc("/archive.tar.gz", server.Archive)
+	http.HandleFunc("/escape.tar", server.EscapingArchive)
 
 	s := &http.Server{Addr: ":8080"}
 	go s.ListenAndServe()
@@ -54,6 +54,14 @@ type Node struct {
 	Directory string
 	User      int
 	Group     int
+	Xattrs    []Xattr
+}
+
+// Xattr is an extended attribute of a node, with its raw value. SELinux
+// contexts are stored with a terminating NUL.
+type Xattr struct {
+	Name  string
+	Value string
 }
 
 type Disk struct {
@@ -77,6 +85,7 @@ type Partition struct {
 	FilesystemType  string
 	FilesystemLabel string
 	FilesystemUUID  string
+	FilesystemSize  int
 	MountPath       string
 	Hybrid          bool
 	Files           []File
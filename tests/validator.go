@@ -161,6 +161,18 @@ func validateFilesystems(t *testing.T, expected []*types.Partition) error {
 					e.FilesystemUUID, filesystemUUID)
 			}
 		}
+		if e.FilesystemSize != 0 {
+			filesystemSize, err := getFilesystemSize(e.Device, e.FilesystemType)
+			if err != nil {
+				return fmt.Errorf("couldn't determine filesystem size: %v", err)
+			}
+			// growing rounds down to whole blocks, and xfs_growfs leaves
+			// out a trailing allocation group which would be too small
+			if filesystemSize > e.FilesystemSize || e.FilesystemSize-filesystemSize > 2048 {
+				t.Errorf("FilesystemSize does not match, expected:%d actual:%d",
+					e.FilesystemSize, filesystemSize)
+			}
+		}
 		if e.FilesystemLabel != "" {
 			filesystemLabel, err := util.FilesystemLabel(e.Device)
 			if err != nil {
@@ -175,6 +187,44 @@ func validateFilesystems(t *testing.T, expected []*types.Partition) error {
 	return nil
 }
 
+// getFilesystemSize returns the size in sectors of the filesystem on device
+// as recorded in its superblock.
+func getFilesystemSize(device, format string) (int, error) {
+	var out []byte
+	var blocksPattern, blockSizePattern string
+	var err error
+	switch format {
+	case "ext2", "ext4":
+		out, err = exec.Command("dumpe2fs", "-h", device).CombinedOutput()
+		blocksPattern, blockSizePattern = `Block count:\s+(\d+)`, `Block size:\s+(\d+)`
+	case "xfs":
+		out, err = exec.Command("xfs_db", "-r", "-c", "sb 0", "-c", "print dblocks blocksize", device).CombinedOutput()
+		blocksPattern, blockSizePattern = `dblocks = (\d+)`, `blocksize = (\d+)`
+	default:
+		return 0, fmt.Errorf("unsupported filesystem %q", format)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%v: %s", err, out)
+	}
+	blocks, err := regexpSearch("block count", blocksPattern, out)
+	if err != nil {
+		return 0, err
+	}
+	blockSize, err := regexpSearch("block size", blockSizePattern, out)
+	if err != nil {
+		return 0, err
+	}
+	b, err := strconv.Atoi(blocks)
+	if err != nil {
+		return 0, err
+	}
+	bs, err := strconv.Atoi(blockSize)
+	if err != nil {
+		return 0, err
+	}
+	return b * bs / 512, nil
+}
+
 func validatePartitionNodes(t *testing.T, ctx context.Context, partition *types.Partition) {
 	if err := mountPartition(ctx, partition); err != nil {
 		t.Errorf("failed to mount %s: %v", partition.Device, err)
@@ -234,6 +284,7 @@ func validateFile(t *testing.T, partition *types.Partition, file types.File) {
 
 	validateMode(t, path, file.Mode)
 	validateNode(t, fileInfo, file.Node)
+	validateXattrs(t, path, file.Node)
 }
 
 func validateDirectory(t *testing.T, partition *types.Partition, dir types.Directory) {
@@ -248,6 +299,7 @@ func validateDirectory(t *testing.T, partition *types.Partition, dir types.Direc
 	}
 	validateMode(t, path, dir.Mode)
 	validateNode(t, dirInfo, dir.Node)
+	validateXattrs(t, path, dir.Node)
 }
 
 func validateLink(t *testing.T, partition *types.Partition, link types.Link) {
@@ -324,3 +376,21 @@ func validateNode(t *testing.T, nodeInfo os.FileInfo, node types.Node) {
 		return
 	}
 }
+
+func validateXattrs(t *testing.T, path string, node types.Node) {
+	for _, x := range node.Xattrs {
+		size, err := syscall.Getxattr(path, x.Name, nil)
+		if err != nil {
+			t.Errorf("Error getting xattr %q of %s: %v", x.Name, path, err)
+			continue
+		}
+		value := make([]byte, size)
+		if size, err = syscall.Getxattr(path, x.Name, value); err != nil {
+			t.Errorf("Error getting xattr %q of %s: %v", x.Name, path, err)
+			continue
+		}
+		if string(value[:size]) != x.Value {
+			t.Errorf("Xattr %q of %s does not match, expected:%q actual:%q", x.Name, path, x.Value, value[:size])
+		}
+	}
+}
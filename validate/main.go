@@ -21,16 +21,19 @@ import (
 	"os"
 	"strings"
 
-	config "github.com/flatcar/ignition/config/v2_4"
+	"github.com/flatcar/ignition/config/canonical"
+	"github.com/flatcar/ignition/internal/config"
 	"github.com/flatcar/ignition/internal/version"
 )
 
 var (
 	flagVersion bool
+	flagEmitV24 bool
 )
 
 func init() {
 	flag.BoolVar(&flagVersion, "version", false, "print the version of ignition-validate")
+	flag.BoolVar(&flagEmitV24, "emit-v2_4", false, "print the config translated to spec version 2.4.0")
 	flag.Usage = func() {
 		fmt.Fprintf(flag.CommandLine.Output(), "Usage:\n  %s config.ign [flags]\n\n", os.Args[0])
 		flag.PrintDefaults()
@@ -40,7 +43,7 @@ func init() {
 func main() {
 	flag.Parse()
 
-	runIgnValidate(os.Args[1:])
+	runIgnValidate(flag.Args())
 }
 
 func stdout(format string, a ...interface{}) {
@@ -76,6 +79,10 @@ func runIgnValidate(args []string) {
 	if err != nil {
 		die("couldn't read config: %v", err)
 	}
+	if flagEmitV24 {
+		emitV2_4(blob)
+		return
+	}
 	_, rpt, err := config.Parse(blob)
 	if len(rpt.Entries) > 0 {
 		stdout(rpt.String())
@@ -87,3 +94,19 @@ func runIgnValidate(args []string) {
 		die("couldn't parse config: %v", err)
 	}
 }
+
+// emitV2_4 writes the translated config to stdout. The report goes to stderr
+// so that stdout can be consumed as JSON.
+func emitV2_4(blob []byte) {
+	out, rpt, err := canonical.Emit(blob)
+	if len(rpt.Entries) > 0 {
+		stderr(rpt.String())
+	}
+	if rpt.IsFatal() {
+		os.Exit(1)
+	}
+	if err != nil {
+		die("couldn't translate config: %v", err)
+	}
+	os.Stdout.Write(out)
+}